@@ -23,7 +23,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/openyurtio/node-resource-manager/pkg/health"
 	"github.com/openyurtio/node-resource-manager/pkg/manager"
+	"github.com/openyurtio/node-resource-manager/pkg/metrics"
 	"github.com/openyurtio/node-resource-manager/pkg/signals"
 	klog "k8s.io/klog/v2"
 )
@@ -48,13 +50,31 @@ var (
 	nodeID         = flag.String("nodeid", "", "node id")
 	cmName         = flag.String("cm-name", "node-resource-manager", "used configmap name")
 	cmNameSpace    = flag.String("cm-namespace", "kube-system", "used configmap namespace")
+	configSource   = flag.String("config-source", "configmap", "where to read resource topology: configmap (mounted node-resource-topo ConfigMap) or crd (NodeResourceTopology objects)")
 	updateInterval = flag.Int("update-interval", 30, "Node Storage update internal time(s)")
+	dryRun         = flag.Bool("dry-run", false, "log the actions would be taken on node in every reconcile, without executing them")
+	audit          = flag.Bool("audit", false, "report resources on node absent from resource topology or differ from spec as node events and NodeLocalResource status, without changing them")
+	healthAddress  = flag.String("health-address", ":10291", "The address to serve /healthz and /readyz probes on, empty to disable")
+	healthTimeout  = flag.Int("health-timeout", 600, "/healthz fails if no reconcile finished in this time(s) and no command is running within its own timeout")
+	commandTimeout = flag.Int("command-timeout", 120, "Timeout of one external command(s), mkfs and fsck are allowed 30 minutes")
+	metricsAddress = flag.String("metrics-address", ":10290", "The address to expose prometheus metrics on, empty to disable")
+	output         = flag.String("output", manager.PlanOutputText, "output format of plan subcommand: text or json")
 	masterURL      = flag.String("master", "", "The address of the Kubernetes API server (https://hostname:port, overrides any value in kubeconfig)")
 	kubeconfig     = flag.String("kubeconfig", "", "Path to kubeconfig file with authorization and master location information")
 )
 
 // main
+// Usage:
+//
+//	node-resource-manager [flags]       maintain local resources of node
+//	node-resource-manager plan [flags]  print the actions would be taken on node once, and exit
 func main() {
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "plan" {
+		flag.CommandLine.Parse(args[1:])
+		runPlan()
+		return
+	}
 	flag.Parse()
 
 	// set log config
@@ -64,13 +84,31 @@ func main() {
 	// new signal handler
 	stopCh := signals.SetupSignalHandler()
 
+	// Expose prometheus metrics
+	metrics.Serve(*metricsAddress)
+
 	// New Controller Manager
-	manager := manager.NewManager(*nodeID, *cmName, *cmNameSpace, *updateInterval, *masterURL, *kubeconfig)
+	manager := manager.NewManager(*nodeID, *cmName, *cmNameSpace, *configSource, *updateInterval, *healthTimeout, *commandTimeout, *dryRun, *audit, *masterURL, *kubeconfig)
+	health.Serve(*healthAddress, manager.Health)
 	manager.Run(stopCh)
 
 	os.Exit(0)
 }
 
+// runPlan prints plan to stdout, logs go to stderr only
+func runPlan() {
+	urm := manager.NewManager(*nodeID, *cmName, *cmNameSpace, *configSource, *updateInterval, *healthTimeout, *commandTimeout, true, false, *masterURL, *kubeconfig)
+	plan, err := urm.Plan()
+	if err != nil {
+		klog.Errorf("plan error: %v", err)
+		os.Exit(1)
+	}
+	if err := manager.PrintPlan(os.Stdout, plan, *output); err != nil {
+		klog.Errorf("print plan error: %v", err)
+		os.Exit(1)
+	}
+}
+
 func init() {
 	flag.Set("logtostderr", "true")
 }
@@ -0,0 +1,318 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// NodeLocalResourceKind is the kind of NodeLocalResource
+	NodeLocalResourceKind = "NodeLocalResource"
+	// NodeLocalResourcePlural is the plural resource name of NodeLocalResource
+	NodeLocalResourcePlural = "nodelocalresources"
+	// NodeResourceTopologyKind is the kind of NodeResourceTopology
+	NodeResourceTopologyKind = "NodeResourceTopology"
+	// NodeResourceTopologyPlural is the plural resource name of NodeResourceTopology
+	NodeResourceTopologyPlural = "noderesourcetopologies"
+)
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// NodeLocalResource reports the local resources of one node, it is named after the node.
+type NodeLocalResource struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NodeLocalResourceSpec   `json:"spec,omitempty"`
+	Status NodeLocalResourceStatus `json:"status,omitempty"`
+}
+
+// NodeLocalResourceSpec ...
+type NodeLocalResourceSpec struct {
+	// NodeName is the node which the resources belong to
+	NodeName string `json:"nodeName,omitempty"`
+}
+
+// NodeLocalResourceStatus is the actual local resources on node
+type NodeLocalResourceStatus struct {
+	VolumeGroups   []VolumeGroupStatus   `json:"volumeGroups,omitempty"`
+	LogicalVolumes []LogicalVolumeStatus `json:"logicalVolumes,omitempty"`
+	QuotaPaths     []QuotaPathStatus     `json:"quotaPaths,omitempty"`
+	Memories       []MemoryStatus        `json:"memories,omitempty"`
+	PmemRegions    []PmemRegionStatus    `json:"pmemRegions,omitempty"`
+	// Drifts is the difference between node and resource topology found in audit mode
+	Drifts []DriftStatus `json:"drifts,omitempty"`
+
+	// LastUpdateTime is the last time the status is refreshed
+	LastUpdateTime metav1.Time `json:"lastUpdateTime,omitempty"`
+}
+
+// VolumeGroupStatus is one lvm volume group on node, sizes are in bytes
+type VolumeGroupStatus struct {
+	Name            string                 `json:"name"`
+	Size            int64                  `json:"size"`
+	FreeSize        int64                  `json:"freeSize"`
+	PhysicalVolumes []PhysicalVolumeStatus `json:"physicalVolumes,omitempty"`
+}
+
+// PhysicalVolumeStatus is one lvm physical volume of volume group
+type PhysicalVolumeStatus struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// LogicalVolumeStatus is one lvm logical volume on node, size is in bytes
+type LogicalVolumeStatus struct {
+	Name        string   `json:"name"`
+	VolumeGroup string   `json:"volumeGroup"`
+	Size        int64    `json:"size"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+// QuotaPathStatus is one mounted quota path on node, sizes are in bytes
+type QuotaPathStatus struct {
+	MountPath string `json:"mountPath"`
+	Device    string `json:"device"`
+	Fstype    string `json:"fstype,omitempty"`
+	Options   string `json:"options,omitempty"`
+	Capacity  int64  `json:"capacity"`
+	Used      int64  `json:"used"`
+	Available int64  `json:"available"`
+	// Directories are the projects with directory in the quota path
+	Directories []QuotaDirectoryStatus `json:"directories,omitempty"`
+}
+
+// QuotaDirectoryStatus is the project quota usage and limits of one directory, block sizes are in bytes, 0 limit is unlimited
+type QuotaDirectoryStatus struct {
+	Path           string `json:"path"`
+	ProjectID      int64  `json:"projectId"`
+	BlockUsed      int64  `json:"blockUsed"`
+	BlockSoftLimit int64  `json:"blockSoftLimit,omitempty"`
+	BlockHardLimit int64  `json:"blockHardLimit,omitempty"`
+	InodeUsed      int64  `json:"inodeUsed"`
+	InodeSoftLimit int64  `json:"inodeSoftLimit,omitempty"`
+	InodeHardLimit int64  `json:"inodeHardLimit,omitempty"`
+}
+
+// PmemRegionStatus is one pmem region on node, sizes are in bytes
+type PmemRegionStatus struct {
+	Name          string `json:"name"`
+	Size          int64  `json:"size"`
+	AvailableSize int64  `json:"availableSize"`
+	// Namespaces is the names of namespaces created in region
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+// MemoryStatus is one pmem dax device onlined as system memory
+type MemoryStatus struct {
+	Region     string `json:"region"`
+	Chardev    string `json:"chardev"`
+	Size       int64  `json:"size"`
+	TargetNode int    `json:"targetNode"`
+	Movable    bool   `json:"movable,omitempty"`
+}
+
+// DriftStatus is one resource on node absent from resource topology, or differs from its spec
+type DriftStatus struct {
+	// Kind is volumegroup, physicalvolume or quotapath
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// Reason is the kind of drift, e.g. NotInTopology, ExtraPhysicalVolumes, FstypeMismatch, MountOptionsMismatch
+	Reason  string `json:"reason"`
+	Message string `json:"message,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// NodeLocalResourceList is a list of NodeLocalResource
+type NodeLocalResourceList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []NodeLocalResource `json:"items"`
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// NodeResourceTopology defines the desired local resources of nodes in cluster,
+// it replaces the node-resource-topo ConfigMap.
+type NodeResourceTopology struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec NodeResourceTopologySpec `json:"spec,omitempty"`
+}
+
+// NodeResourceTopologySpec ...
+type NodeResourceTopologySpec struct {
+	VolumeGroups   []VolumeGroupSpec   `json:"volumeGroups,omitempty"`
+	LogicalVolumes []LogicalVolumeSpec `json:"logicalVolumes,omitempty"`
+	QuotaPaths     []QuotaPathSpec     `json:"quotaPaths,omitempty"`
+	Memories       []MemorySpec        `json:"memories,omitempty"`
+}
+
+// NodeLabelMatcher chooses target nodes by one node label, works as key/operator/value in ConfigMap
+type NodeLabelMatcher struct {
+	Key      string                       `json:"key"`
+	Operator metav1.LabelSelectorOperator `json:"operator"`
+	Value    string                       `json:"value,omitempty"`
+}
+
+// VolumeGroupSpec defines one lvm volume group, Name is the volume group name
+type VolumeGroupSpec struct {
+	Name             string `json:"name"`
+	NodeLabelMatcher `json:",inline"`
+	// State is present or absent, the volume group is removed from matched nodes if absent
+	State    string              `json:"state,omitempty"`
+	Topology VolumeGroupTopology `json:"topology"`
+}
+
+// VolumeGroupTopology ...
+type VolumeGroupTopology struct {
+	// Type is one of device, localdisk, alibabacloud-local-disk, pmem
+	Type    string   `json:"type"`
+	Devices []string `json:"devices,omitempty"`
+	// DeviceSelector selects disks by attributes in addition to devices
+	DeviceSelector *DeviceSelectorSpec `json:"deviceSelector,omitempty"`
+	Regions        []string            `json:"regions,omitempty"`
+	// Include and Exclude filter the disks of localdisk type by glob of name, path or /dev/disk link
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+	// ThinPool is created in the volume group if set
+	ThinPool *ThinPoolSpec `json:"thinPool,omitempty"`
+	// AllowShrink allows moving data off the physical volumes removed from devices, and removing them from the volume group
+	AllowShrink bool `json:"allowShrink,omitempty"`
+	// Adopt allows changing an existing volume group without the managed tag, and tags it
+	Adopt bool `json:"adopt,omitempty"`
+	// Wipe allows wiping the signatures found on devices by preflight check, devices in use are never wiped
+	Wipe bool `json:"wipe,omitempty"`
+}
+
+// ThinPoolSpec ...
+type ThinPoolSpec struct {
+	// Name is thinpool by default
+	Name string `json:"name,omitempty"`
+	// Size is a quantity, e.g. 10Gi, or a percent of volume group, e.g. 80%
+	Size string `json:"size"`
+	// ChunkSize and MetadataSize are chosen by lvm if empty
+	ChunkSize    string `json:"chunkSize,omitempty"`
+	MetadataSize string `json:"metadataSize,omitempty"`
+}
+
+// LogicalVolumeSpec defines one lvm logical volume, Name is the logical volume name
+type LogicalVolumeSpec struct {
+	Name             string `json:"name"`
+	NodeLabelMatcher `json:",inline"`
+	Topology         LogicalVolumeTopology `json:"topology"`
+}
+
+// LogicalVolumeTopology ...
+type LogicalVolumeTopology struct {
+	// VolumeGroup is the volume group which the logical volume is created in
+	VolumeGroup string `json:"volumeGroup"`
+	// Size is a quantity, e.g. 10Gi
+	Size    string   `json:"size"`
+	Mirrors uint32   `json:"mirrors,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+	// Pool is the thin pool which the logical volume is created in, Size is the virtual size of thin logical volume
+	Pool string `json:"pool,omitempty"`
+}
+
+// QuotaPathSpec defines one quota path, Name is the mount path
+type QuotaPathSpec struct {
+	Name             string `json:"name"`
+	NodeLabelMatcher `json:",inline"`
+	Topology         QuotaPathTopology `json:"topology"`
+}
+
+// QuotaPathTopology ...
+type QuotaPathTopology struct {
+	// Type is one of device, pmem, lvm
+	Type    string   `json:"type"`
+	Options string   `json:"options,omitempty"`
+	Fstype  string   `json:"fstype,omitempty"`
+	Devices []string `json:"devices,omitempty"`
+	// Layout is one of single, raid0, raid1, single mounts the first existing device,
+	// raid0 and raid1 build a md array on all devices
+	Layout string `json:"layout,omitempty"`
+	// Persistence is fstab or systemd, the mount is kept in host fstab or systemd mount unit to mount it on boot
+	Persistence string `json:"persistence,omitempty"`
+	// DeviceSelector selects disks by attributes in addition to devices
+	DeviceSelector *DeviceSelectorSpec `json:"deviceSelector,omitempty"`
+	Regions        []string            `json:"regions,omitempty"`
+	// VolumeGroup and Size define the logical volume of lvm quota path, size is a quantity, e.g. 100Gi
+	VolumeGroup string `json:"volumeGroup,omitempty"`
+	Size        string `json:"size,omitempty"`
+	// Directories are the directories in quota path limited by project quota
+	Directories []QuotaDirectorySpec `json:"directories,omitempty"`
+}
+
+// QuotaDirectorySpec is a directory in quota path with its own project quota, a limit not set or 0 is unlimited
+type QuotaDirectorySpec struct {
+	// Name is the path relative to quota path
+	Name string `json:"name"`
+	// BlockSoftLimit and BlockHardLimit are quantities, e.g. 10Gi
+	BlockSoftLimit string `json:"blockSoftLimit,omitempty"`
+	BlockHardLimit string `json:"blockHardLimit,omitempty"`
+	InodeSoftLimit int64  `json:"inodeSoftLimit,omitempty"`
+	InodeHardLimit int64  `json:"inodeHardLimit,omitempty"`
+}
+
+// DeviceSelectorSpec matches whole disks on node by attributes, a disk is selected if it matches every field set
+type DeviceSelectorSpec struct {
+	// ByID and ByPath are names of links in /dev/disk/by-id and /dev/disk/by-path
+	ByID   []string `json:"byId,omitempty"`
+	ByPath []string `json:"byPath,omitempty"`
+	Serial []string `json:"serial,omitempty"`
+	WWN    []string `json:"wwn,omitempty"`
+	Model  []string `json:"model,omitempty"`
+	// MinSize and MaxSize are quantities, e.g. 100Gi
+	MinSize    string `json:"minSize,omitempty"`
+	MaxSize    string `json:"maxSize,omitempty"`
+	Rotational *bool  `json:"rotational,omitempty"`
+	// Transport is one of nvme, sata, scsi, virtio, usb
+	Transport []string `json:"transport,omitempty"`
+}
+
+// MemorySpec defines pmem used as system memory, Name is only a symbol
+type MemorySpec struct {
+	Name             string `json:"name"`
+	NodeLabelMatcher `json:",inline"`
+	Topology         MemoryTopology `json:"topology"`
+}
+
+// MemoryTopology ...
+type MemoryTopology struct {
+	// Type only supports pmem
+	Type    string   `json:"type"`
+	Regions []string `json:"regions"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// NodeResourceTopologyList is a list of NodeResourceTopology
+type NodeResourceTopologyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []NodeResourceTopology `json:"items"`
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"github.com/openyurtio/node-resource-manager/pkg/apis/nrm/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/client-go/rest"
+)
+
+var (
+	// Scheme contains the types of nrm.openyurt.io api group
+	Scheme = runtime.NewScheme()
+	// Codecs provides access to encoding and decoding for Scheme
+	Codecs = serializer.NewCodecFactory(Scheme)
+	// ParameterCodec handles versioning of objects that are converted to query parameters
+	ParameterCodec = runtime.NewParameterCodec(Scheme)
+)
+
+func init() {
+	metav1.AddToGroupVersion(Scheme, schema.GroupVersion{Version: "v1"})
+	if err := v1alpha1.AddToScheme(Scheme); err != nil {
+		panic(err)
+	}
+}
+
+// Interface is the client of nrm.openyurt.io api group
+type Interface interface {
+	NodeLocalResources() NodeLocalResourceInterface
+	NodeResourceTopologies() NodeResourceTopologyInterface
+}
+
+// Clientset implements Interface with a rest client
+type Clientset struct {
+	restClient rest.Interface
+}
+
+// NewForConfig create a Clientset for the given config
+func NewForConfig(c *rest.Config) (*Clientset, error) {
+	config := *c
+	config.GroupVersion = &v1alpha1.SchemeGroupVersion
+	config.APIPath = "/apis"
+	config.NegotiatedSerializer = Codecs.WithoutConversion()
+	if config.UserAgent == "" {
+		config.UserAgent = rest.DefaultKubernetesUserAgent()
+	}
+	restClient, err := rest.RESTClientFor(&config)
+	if err != nil {
+		return nil, err
+	}
+	return &Clientset{restClient: restClient}, nil
+}
+
+// NodeLocalResources ...
+func (c *Clientset) NodeLocalResources() NodeLocalResourceInterface {
+	return &nodeLocalResources{client: c.restClient}
+}
+
+// NodeResourceTopologies ...
+func (c *Clientset) NodeResourceTopologies() NodeResourceTopologyInterface {
+	return &nodeResourceTopologies{client: c.restClient}
+}
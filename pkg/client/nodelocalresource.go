@@ -0,0 +1,74 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+
+	"github.com/openyurtio/node-resource-manager/pkg/apis/nrm/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+// NodeLocalResourceInterface has methods to work with NodeLocalResource resources
+type NodeLocalResourceInterface interface {
+	Get(ctx context.Context, name string, opts metav1.GetOptions) (*v1alpha1.NodeLocalResource, error)
+	Create(ctx context.Context, nlr *v1alpha1.NodeLocalResource, opts metav1.CreateOptions) (*v1alpha1.NodeLocalResource, error)
+	UpdateStatus(ctx context.Context, nlr *v1alpha1.NodeLocalResource, opts metav1.UpdateOptions) (*v1alpha1.NodeLocalResource, error)
+}
+
+type nodeLocalResources struct {
+	client rest.Interface
+}
+
+// Get takes name of the NodeLocalResource, and returns the corresponding object
+func (c *nodeLocalResources) Get(ctx context.Context, name string, opts metav1.GetOptions) (*v1alpha1.NodeLocalResource, error) {
+	result := &v1alpha1.NodeLocalResource{}
+	err := c.client.Get().
+		Resource(v1alpha1.NodeLocalResourcePlural).
+		Name(name).
+		VersionedParams(&opts, ParameterCodec).
+		Do(ctx).
+		Into(result)
+	return result, err
+}
+
+// Create takes the representation of a NodeLocalResource and creates it
+func (c *nodeLocalResources) Create(ctx context.Context, nlr *v1alpha1.NodeLocalResource, opts metav1.CreateOptions) (*v1alpha1.NodeLocalResource, error) {
+	result := &v1alpha1.NodeLocalResource{}
+	err := c.client.Post().
+		Resource(v1alpha1.NodeLocalResourcePlural).
+		VersionedParams(&opts, ParameterCodec).
+		Body(nlr).
+		Do(ctx).
+		Into(result)
+	return result, err
+}
+
+// UpdateStatus updates the status subresource of a NodeLocalResource
+func (c *nodeLocalResources) UpdateStatus(ctx context.Context, nlr *v1alpha1.NodeLocalResource, opts metav1.UpdateOptions) (*v1alpha1.NodeLocalResource, error) {
+	result := &v1alpha1.NodeLocalResource{}
+	err := c.client.Put().
+		Resource(v1alpha1.NodeLocalResourcePlural).
+		Name(nlr.Name).
+		SubResource("status").
+		VersionedParams(&opts, ParameterCodec).
+		Body(nlr).
+		Do(ctx).
+		Into(result)
+	return result, err
+}
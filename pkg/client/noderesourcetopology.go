@@ -0,0 +1,80 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/openyurtio/node-resource-manager/pkg/apis/nrm/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+)
+
+// NodeResourceTopologyInterface has methods to work with NodeResourceTopology resources
+type NodeResourceTopologyInterface interface {
+	Get(ctx context.Context, name string, opts metav1.GetOptions) (*v1alpha1.NodeResourceTopology, error)
+	List(ctx context.Context, opts metav1.ListOptions) (*v1alpha1.NodeResourceTopologyList, error)
+	Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+}
+
+type nodeResourceTopologies struct {
+	client rest.Interface
+}
+
+// Get takes name of the NodeResourceTopology, and returns the corresponding object
+func (c *nodeResourceTopologies) Get(ctx context.Context, name string, opts metav1.GetOptions) (*v1alpha1.NodeResourceTopology, error) {
+	result := &v1alpha1.NodeResourceTopology{}
+	err := c.client.Get().
+		Resource(v1alpha1.NodeResourceTopologyPlural).
+		Name(name).
+		VersionedParams(&opts, ParameterCodec).
+		Do(ctx).
+		Into(result)
+	return result, err
+}
+
+// List takes label and field selectors, and returns the list of NodeResourceTopology that match those selectors
+func (c *nodeResourceTopologies) List(ctx context.Context, opts metav1.ListOptions) (*v1alpha1.NodeResourceTopologyList, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result := &v1alpha1.NodeResourceTopologyList{}
+	err := c.client.Get().
+		Resource(v1alpha1.NodeResourceTopologyPlural).
+		VersionedParams(&opts, ParameterCodec).
+		Timeout(timeout).
+		Do(ctx).
+		Into(result)
+	return result, err
+}
+
+// Watch returns a watch.Interface that watches the requested NodeResourceTopology
+func (c *nodeResourceTopologies) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Resource(v1alpha1.NodeResourceTopologyPlural).
+		VersionedParams(&opts, ParameterCodec).
+		Timeout(timeout).
+		Watch(ctx)
+}
@@ -19,7 +19,9 @@ package config
 import (
 	"context"
 
+	"github.com/openyurtio/node-resource-manager/pkg/client"
 	v1 "k8s.io/api/core/v1"
+	apiextclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
@@ -30,8 +32,10 @@ import (
 type GlobalConfig struct {
 	//EcsClient  *ecs.Client
 	//Region     string
-	NodeInfo   *v1.Node
-	KubeClient *kubernetes.Clientset
+	NodeInfo     *v1.Node
+	KubeClient   *kubernetes.Clientset
+	NrmClient    client.Interface
+	APIExtClient apiextclient.Interface
 }
 
 var (
@@ -57,6 +61,14 @@ func GlobalConfigSet(nodeID, masterURL, kubeconfig string) {
 	if err != nil {
 		klog.Fatalf("Error building kubernetes clientset: %s", err.Error())
 	}
+	nrmClient, err := client.NewForConfig(cfg)
+	if err != nil {
+		klog.Fatalf("Error building nrm clientset: %s", err.Error())
+	}
+	apiExtClient, err := apiextclient.NewForConfig(cfg)
+	if err != nil {
+		klog.Fatalf("Error building apiextensions clientset: %s", err.Error())
+	}
 
 	node, err := kubeClient.CoreV1().Nodes().Get(context.Background(), nodeID, metav1.GetOptions{})
 	if err != nil {
@@ -65,7 +77,9 @@ func GlobalConfigSet(nodeID, masterURL, kubeconfig string) {
 
 	// Global Config Set
 	GlobalConfigVar = GlobalConfig{
-		KubeClient: kubeClient,
-		NodeInfo:   node,
+		KubeClient:   kubeClient,
+		NrmClient:    nrmClient,
+		APIExtClient: apiExtClient,
+		NodeInfo:     node,
 	}
 }
@@ -0,0 +1,336 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/openyurtio/node-resource-manager/pkg/apis/nrm/v1alpha1"
+	"github.com/openyurtio/node-resource-manager/pkg/client"
+	"github.com/openyurtio/node-resource-manager/pkg/model"
+	"gopkg.in/yaml.v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	klog "k8s.io/klog/v2"
+)
+
+const (
+	// ConfigSourceCRD reads resource topology from NodeResourceTopology objects
+	ConfigSourceCRD = "crd"
+	// ConfigSourceConfigMap reads resource topology from the mounted node-resource-topo ConfigMap
+	ConfigSourceConfigMap = "configmap"
+
+	// DefaultConfigDir is the mount path of node-resource-topo ConfigMap
+	DefaultConfigDir = "/etc/unified-config"
+
+	// ResourceKindVolumeGroup is the volumegroup resource, also the ConfigMap key
+	ResourceKindVolumeGroup = "volumegroup"
+	// ResourceKindLogicalVolume is the logicalvolume resource, also the ConfigMap key
+	ResourceKindLogicalVolume = "logicalvolume"
+	// ResourceKindQuotaPath is the quotapath resource, also the ConfigMap key
+	ResourceKindQuotaPath = "quotapath"
+	// ResourceKindMemory is the memory resource, also the ConfigMap key
+	ResourceKindMemory = "memory"
+)
+
+// TopologySource provides the desired resources defined for the cluster
+type TopologySource interface {
+	// Start starts the source and waits until it is ready
+	Start(stopCh <-chan struct{}) error
+	// GetResources returns the resources of one kind, nil if the kind is not defined
+	GetResources(kind string) ([]model.ResourceYaml, error)
+	// AddChangeHandler registers handler called when the resources may be changed, must be called before Start
+	AddChangeHandler(handler func())
+}
+
+// NewTopologySource create the TopologySource of configSource
+func NewTopologySource(configSource string, nrmClient client.Interface) (TopologySource, error) {
+	switch configSource {
+	case ConfigSourceCRD:
+		return NewCRDSource(nrmClient), nil
+	case ConfigSourceConfigMap:
+		return &FileSource{ConfigDir: DefaultConfigDir}, nil
+	default:
+		return nil, fmt.Errorf("unsupported config source: %s", configSource)
+	}
+}
+
+// FileSource reads the mounted ConfigMap, every kind is saved in the file named after the kind
+type FileSource struct {
+	ConfigDir string
+	handlers  []func()
+}
+
+// AddChangeHandler ...
+func (fs *FileSource) AddChangeHandler(handler func()) {
+	fs.handlers = append(fs.handlers, handler)
+}
+
+// Start watches ConfigDir, kubelet updates the ConfigMap by swapping the ..data symlink,
+// so any event in the dir is treated as a change.
+func (fs *FileSource) Start(stopCh <-chan struct{}) error {
+	if len(fs.handlers) == 0 {
+		return nil
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("create config watcher error: %v", err)
+	}
+	if err := watcher.Add(fs.ConfigDir); err != nil {
+		watcher.Close()
+		return fmt.Errorf("watch config dir %s error: %v", fs.ConfigDir, err)
+	}
+
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				klog.V(4).Infof("FileSource:: config dir event: %s", event.String())
+				for _, handler := range fs.handlers {
+					handler()
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				klog.Errorf("FileSource:: watch config dir %s error: %v", fs.ConfigDir, err)
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// GetResources ...
+func (fs *FileSource) GetResources(kind string) ([]model.ResourceYaml, error) {
+	configPath := filepath.Join(fs.ConfigDir, kind)
+	yamlFile, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			klog.Errorf("%s config file %s not exist", kind, configPath)
+			return nil, nil
+		}
+		klog.Errorf("GetResources:: read %s config file error: %v", kind, err)
+		return nil, err
+	}
+	resourceLists := map[string][]model.ResourceYaml{}
+	err = yaml.Unmarshal(yamlFile, &resourceLists)
+	if err != nil {
+		klog.Errorf("GetResources:: parse yaml file %s error: %v", configPath, err)
+		return nil, err
+	}
+	return resourceLists[kind], nil
+}
+
+// CRDSource reads all NodeResourceTopology objects from informer cache
+type CRDSource struct {
+	informer cache.SharedIndexInformer
+}
+
+// NewCRDSource ...
+func NewCRDSource(nrmClient client.Interface) *CRDSource {
+	listWatch := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return nrmClient.NodeResourceTopologies().List(context.Background(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return nrmClient.NodeResourceTopologies().Watch(context.Background(), options)
+		},
+	}
+	return &CRDSource{
+		informer: cache.NewSharedIndexInformer(listWatch, &v1alpha1.NodeResourceTopology{}, 0, cache.Indexers{}),
+	}
+}
+
+// AddChangeHandler ...
+func (cs *CRDSource) AddChangeHandler(handler func()) {
+	cs.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { handler() },
+		UpdateFunc: func(oldObj, newObj interface{}) { handler() },
+		DeleteFunc: func(obj interface{}) { handler() },
+	})
+}
+
+// Start ...
+func (cs *CRDSource) Start(stopCh <-chan struct{}) error {
+	go cs.informer.Run(stopCh)
+	if !cache.WaitForCacheSync(stopCh, cs.informer.HasSynced) {
+		return fmt.Errorf("wait for NodeResourceTopology cache sync failed")
+	}
+	return nil
+}
+
+// GetResources merge resources of one kind from all NodeResourceTopology objects, ordered by object name
+func (cs *CRDSource) GetResources(kind string) ([]model.ResourceYaml, error) {
+	topologies := []*v1alpha1.NodeResourceTopology{}
+	for _, obj := range cs.informer.GetStore().List() {
+		if topology, ok := obj.(*v1alpha1.NodeResourceTopology); ok {
+			topologies = append(topologies, topology)
+		}
+	}
+	sort.Slice(topologies, func(i, j int) bool { return topologies[i].Name < topologies[j].Name })
+
+	resources := []model.ResourceYaml{}
+	for _, topology := range topologies {
+		resources = append(resources, ConvertTopologySpec(kind, &topology.Spec)...)
+	}
+	return resources, nil
+}
+
+// ConvertTopologySpec converts resources of one kind in NodeResourceTopologySpec to ResourceYaml
+func ConvertTopologySpec(kind string, spec *v1alpha1.NodeResourceTopologySpec) []model.ResourceYaml {
+	resources := []model.ResourceYaml{}
+	switch kind {
+	case ResourceKindVolumeGroup:
+		for _, vg := range spec.VolumeGroups {
+			resource := model.ResourceYaml{
+				Name:     vg.Name,
+				Key:      vg.Key,
+				Operator: vg.Operator,
+				Value:    vg.Value,
+				State:    vg.State,
+				Topology: model.Topology{
+					Type:           vg.Topology.Type,
+					Devices:        vg.Topology.Devices,
+					DeviceSelector: ConvertDeviceSelector(vg.Topology.DeviceSelector),
+					Regions:        vg.Topology.Regions,
+					Include:        vg.Topology.Include,
+					Exclude:        vg.Topology.Exclude,
+					AllowShrink:    vg.Topology.AllowShrink,
+					Adopt:          vg.Topology.Adopt,
+					Wipe:           vg.Topology.Wipe,
+				},
+			}
+			if tp := vg.Topology.ThinPool; tp != nil {
+				resource.Topology.ThinPool = &model.ThinPool{
+					Name:         tp.Name,
+					Size:         tp.Size,
+					ChunkSize:    tp.ChunkSize,
+					MetadataSize: tp.MetadataSize,
+				}
+			}
+			resources = append(resources, resource)
+		}
+	case ResourceKindLogicalVolume:
+		for _, lv := range spec.LogicalVolumes {
+			resources = append(resources, model.ResourceYaml{
+				Name:     lv.Name,
+				Key:      lv.Key,
+				Operator: lv.Operator,
+				Value:    lv.Value,
+				Topology: model.Topology{
+					VolumeGroup: lv.Topology.VolumeGroup,
+					Size:        lv.Topology.Size,
+					Mirrors:     lv.Topology.Mirrors,
+					Tags:        lv.Topology.Tags,
+					Pool:        lv.Topology.Pool,
+				},
+			})
+		}
+	case ResourceKindQuotaPath:
+		for _, qp := range spec.QuotaPaths {
+			resources = append(resources, model.ResourceYaml{
+				Name:     qp.Name,
+				Key:      qp.Key,
+				Operator: qp.Operator,
+				Value:    qp.Value,
+				Topology: model.Topology{
+					Type:           qp.Topology.Type,
+					Options:        qp.Topology.Options,
+					Fstype:         qp.Topology.Fstype,
+					Devices:        qp.Topology.Devices,
+					Layout:         qp.Topology.Layout,
+					Persistence:    qp.Topology.Persistence,
+					DeviceSelector: ConvertDeviceSelector(qp.Topology.DeviceSelector),
+					Regions:        qp.Topology.Regions,
+					VolumeGroup:    qp.Topology.VolumeGroup,
+					Size:           qp.Topology.Size,
+					Directories:    ConvertQuotaDirectories(qp.Topology.Directories),
+				},
+			})
+		}
+	case ResourceKindMemory:
+		for _, mem := range spec.Memories {
+			resources = append(resources, model.ResourceYaml{
+				Name:     mem.Name,
+				Key:      mem.Key,
+				Operator: mem.Operator,
+				Value:    mem.Value,
+				Topology: model.Topology{
+					Type:    mem.Topology.Type,
+					Regions: mem.Topology.Regions,
+				},
+			})
+		}
+	}
+	return resources
+}
+
+// ConvertDeviceSelector converts the device selector of NodeResourceTopology, nil if not set
+func ConvertDeviceSelector(selector *v1alpha1.DeviceSelectorSpec) *model.DeviceSelector {
+	if selector == nil {
+		return nil
+	}
+	return &model.DeviceSelector{
+		ByID:       selector.ByID,
+		ByPath:     selector.ByPath,
+		Serial:     selector.Serial,
+		WWN:        selector.WWN,
+		Model:      selector.Model,
+		MinSize:    selector.MinSize,
+		MaxSize:    selector.MaxSize,
+		Rotational: selector.Rotational,
+		Transport:  selector.Transport,
+	}
+}
+
+// ConvertQuotaDirectories converts the directories of quotapath spec to model, negative inode limits are unlimited
+func ConvertQuotaDirectories(directories []v1alpha1.QuotaDirectorySpec) []model.QuotaDirectory {
+	if directories == nil {
+		return nil
+	}
+	result := make([]model.QuotaDirectory, 0, len(directories))
+	for _, directory := range directories {
+		quotaDirectory := model.QuotaDirectory{
+			Name:           directory.Name,
+			BlockSoftLimit: directory.BlockSoftLimit,
+			BlockHardLimit: directory.BlockHardLimit,
+		}
+		if directory.InodeSoftLimit > 0 {
+			quotaDirectory.InodeSoftLimit = uint64(directory.InodeSoftLimit)
+		}
+		if directory.InodeHardLimit > 0 {
+			quotaDirectory.InodeHardLimit = uint64(directory.InodeHardLimit)
+		}
+		result = append(result, quotaDirectory)
+	}
+	return result
+}
@@ -0,0 +1,243 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/openyurtio/node-resource-manager/pkg/apis/nrm/v1alpha1"
+	"github.com/openyurtio/node-resource-manager/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func makeTopologySpec() *v1alpha1.NodeResourceTopologySpec {
+	rotational := false
+	matcher := v1alpha1.NodeLabelMatcher{Key: "kubernetes.io/hostname", Operator: "In", Value: "node1"}
+	return &v1alpha1.NodeResourceTopologySpec{
+		VolumeGroups: []v1alpha1.VolumeGroupSpec{{
+			Name:             "volumegroup1",
+			NodeLabelMatcher: matcher,
+			Topology: v1alpha1.VolumeGroupTopology{
+				Type:           "device",
+				Devices:        []string{"/dev/vdb"},
+				DeviceSelector: &v1alpha1.DeviceSelectorSpec{Model: []string{"QEMU"}, MinSize: "10Gi", Rotational: &rotational},
+				AllowShrink:    true,
+				ThinPool:       &v1alpha1.ThinPoolSpec{Name: "pool1", Size: "50%"},
+			},
+		}},
+		LogicalVolumes: []v1alpha1.LogicalVolumeSpec{{
+			Name:             "lv1",
+			NodeLabelMatcher: matcher,
+			Topology:         v1alpha1.LogicalVolumeTopology{VolumeGroup: "volumegroup1", Size: "10Gi", Tags: []string{"app=edge"}},
+		}},
+		QuotaPaths: []v1alpha1.QuotaPathSpec{{
+			Name:             "/mnt/path1",
+			NodeLabelMatcher: matcher,
+			Topology: v1alpha1.QuotaPathTopology{
+				Type:    "device",
+				Options: "prjquota",
+				Fstype:  "ext4",
+				Devices: []string{"/dev/vdc"},
+				Directories: []v1alpha1.QuotaDirectorySpec{
+					{Name: "dir1", BlockHardLimit: "1Gi", InodeHardLimit: 1000},
+					{Name: "dir2", InodeSoftLimit: -1},
+				},
+			},
+		}},
+		Memories: []v1alpha1.MemorySpec{{
+			Name:             "memory1",
+			NodeLabelMatcher: matcher,
+			Topology:         v1alpha1.MemoryTopology{Type: "pmem", Regions: []string{"region0"}},
+		}},
+	}
+}
+
+// topologyConfig is the ConfigMap layout of makeTopologySpec
+var topologyConfig = map[string]string{
+	ResourceKindVolumeGroup: `volumegroup:
+- name: volumegroup1
+  key: kubernetes.io/hostname
+  operator: In
+  value: node1
+  topology:
+    type: device
+    devices:
+    - /dev/vdb
+    deviceSelector:
+      model:
+      - QEMU
+      minSize: 10Gi
+      rotational: false
+    allowShrink: true
+    thinPool:
+      name: pool1
+      size: 50%
+`,
+	ResourceKindLogicalVolume: `logicalvolume:
+- name: lv1
+  key: kubernetes.io/hostname
+  operator: In
+  value: node1
+  topology:
+    volumeGroup: volumegroup1
+    size: 10Gi
+    tags:
+    - app=edge
+`,
+	ResourceKindQuotaPath: `quotapath:
+- name: /mnt/path1
+  key: kubernetes.io/hostname
+  operator: In
+  value: node1
+  topology:
+    type: device
+    options: prjquota
+    fstype: ext4
+    devices:
+    - /dev/vdc
+    directories:
+    - name: dir1
+      blockHardLimit: 1Gi
+      inodeHardLimit: 1000
+    - name: dir2
+`,
+	ResourceKindMemory: `memory:
+- name: memory1
+  key: kubernetes.io/hostname
+  operator: In
+  value: node1
+  topology:
+    type: pmem
+    regions:
+    - region0
+`,
+}
+
+func makeConfigDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func writeTopologyConfig(t *testing.T, dir string) {
+	for kind, content := range topologyConfig {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, kind), []byte(content), 0644))
+	}
+}
+
+func TestConvertTopologySpec(t *testing.T) {
+	spec := makeTopologySpec()
+
+	vgs := ConvertTopologySpec(ResourceKindVolumeGroup, spec)
+	assert.Equal(t, 1, len(vgs))
+	assert.Equal(t, "volumegroup1", vgs[0].Name)
+	assert.Equal(t, "kubernetes.io/hostname", vgs[0].Key)
+	assert.Equal(t, "node1", vgs[0].Value)
+	assert.Equal(t, []string{"/dev/vdb"}, vgs[0].Topology.Devices)
+	assert.True(t, vgs[0].Topology.AllowShrink)
+	assert.Equal(t, &model.ThinPool{Name: "pool1", Size: "50%"}, vgs[0].Topology.ThinPool)
+	assert.Equal(t, []string{"QEMU"}, vgs[0].Topology.DeviceSelector.Model)
+	assert.False(t, *vgs[0].Topology.DeviceSelector.Rotational)
+
+	lvs := ConvertTopologySpec(ResourceKindLogicalVolume, spec)
+	assert.Equal(t, 1, len(lvs))
+	assert.Equal(t, "volumegroup1", lvs[0].Topology.VolumeGroup)
+	assert.Equal(t, "10Gi", lvs[0].Topology.Size)
+	assert.Equal(t, []string{"app=edge"}, lvs[0].Topology.Tags)
+
+	// negative inode limits are unlimited
+	qps := ConvertTopologySpec(ResourceKindQuotaPath, spec)
+	assert.Equal(t, 1, len(qps))
+	assert.Nil(t, qps[0].Topology.DeviceSelector)
+	assert.Equal(t, []model.QuotaDirectory{
+		{Name: "dir1", BlockHardLimit: "1Gi", InodeHardLimit: 1000},
+		{Name: "dir2"},
+	}, qps[0].Topology.Directories)
+
+	mems := ConvertTopologySpec(ResourceKindMemory, spec)
+	assert.Equal(t, 1, len(mems))
+	assert.Equal(t, []string{"region0"}, mems[0].Topology.Regions)
+
+	assert.Empty(t, ConvertTopologySpec("unknown", spec))
+	assert.Empty(t, ConvertTopologySpec(ResourceKindVolumeGroup, &v1alpha1.NodeResourceTopologySpec{}))
+}
+
+func TestFileSourceGetResources(t *testing.T) {
+	dir := makeConfigDir(t)
+	defer os.RemoveAll(dir)
+	writeTopologyConfig(t, dir)
+	fs := &FileSource{ConfigDir: dir}
+
+	// the ConfigMap and NodeResourceTopology of same spec are converted to same resources
+	spec := makeTopologySpec()
+	for kind := range topologyConfig {
+		resources, err := fs.GetResources(kind)
+		assert.Nil(t, err)
+		assert.Equal(t, ConvertTopologySpec(kind, spec), resources, kind)
+	}
+}
+
+func TestFileSourceGetResourcesError(t *testing.T) {
+	dir := makeConfigDir(t)
+	defer os.RemoveAll(dir)
+	fs := &FileSource{ConfigDir: dir}
+
+	// a kind not defined in ConfigMap has no resources
+	resources, err := fs.GetResources(ResourceKindMemory)
+	assert.Nil(t, err)
+	assert.Nil(t, resources)
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, ResourceKindMemory), []byte("memory: [\n"), 0644))
+	_, err = fs.GetResources(ResourceKindMemory)
+	assert.NotNil(t, err)
+}
+
+func TestFileSourceStart(t *testing.T) {
+	dir := makeConfigDir(t)
+	defer os.RemoveAll(dir)
+	fs := &FileSource{ConfigDir: dir}
+	changed := make(chan struct{}, 10)
+	fs.AddChangeHandler(func() { changed <- struct{}{} })
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	assert.Nil(t, fs.Start(stopCh))
+
+	writeTopologyConfig(t, dir)
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("change handler is not called on config change")
+	}
+
+	assert.NotNil(t, (&FileSource{ConfigDir: filepath.Join(dir, "absent"), handlers: fs.handlers}).Start(stopCh))
+}
+
+func TestNewTopologySource(t *testing.T) {
+	source, err := NewTopologySource(ConfigSourceConfigMap, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, &FileSource{ConfigDir: DefaultConfigDir}, source)
+
+	_, err = NewTopologySource("etcd", nil)
+	assert.NotNil(t, err)
+}
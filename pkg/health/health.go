@@ -0,0 +1,140 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package health
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	klog "k8s.io/klog/v2"
+)
+
+// CommandTracker reports the external commands run by reconcile
+type CommandTracker interface {
+	// RunningDeadline returns the latest deadline of running commands, false if no command is running
+	RunningDeadline() (time.Time, bool)
+}
+
+// Checker tracks the progress of reconcile loop.
+// It is healthy if a reconcile finished within timeout or a command is running within its own deadline,
+// e.g. mkfs of a large disk, so a loop blocked on a hung command is restarted,
+// and ready once every manager has reconciled successfully.
+type Checker struct {
+	lock         sync.Mutex
+	timeout      time.Duration
+	lastProgress time.Time
+	commands     CommandTracker
+	// synced is whether each manager has reconciled successfully once
+	synced map[string]bool
+}
+
+// NewChecker create a checker of managers, the start time counts as the first progress
+func NewChecker(managers []string, timeout time.Duration) *Checker {
+	synced := map[string]bool{}
+	for _, manager := range managers {
+		synced[manager] = false
+	}
+	return &Checker{
+		timeout:      timeout,
+		lastProgress: time.Now(),
+		synced:       synced,
+	}
+}
+
+// TrackCommands makes the checker healthy while a command of tracker runs within its deadline
+func (c *Checker) TrackCommands(tracker CommandTracker) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.commands = tracker
+}
+
+// Progress records a finished reconcile of manager, successful or not
+func (c *Checker) Progress(manager string, err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.lastProgress = time.Now()
+	if err == nil {
+		c.synced[manager] = true
+	}
+}
+
+// Healthy returns error if the reconcile loop made no progress within timeout, and no command is running within its deadline
+func (c *Checker) Healthy() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	since := time.Since(c.lastProgress)
+	if since <= c.timeout {
+		return nil
+	}
+	if c.commands != nil {
+		if deadline, running := c.commands.RunningDeadline(); running {
+			if time.Now().Before(deadline) {
+				return nil
+			}
+			return fmt.Errorf("no reconcile finished in %s, a command is running %s past its deadline",
+				since.Round(time.Second), time.Since(deadline).Round(time.Second))
+		}
+	}
+	return fmt.Errorf("no reconcile finished in %s, timeout %s", since.Round(time.Second), c.timeout)
+}
+
+// Ready returns error if some manager has not reconciled successfully yet
+func (c *Checker) Ready() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	notSynced := []string{}
+	for manager, synced := range c.synced {
+		if !synced {
+			notSynced = append(notSynced, manager)
+		}
+	}
+	if len(notSynced) != 0 {
+		sort.Strings(notSynced)
+		return fmt.Errorf("first reconcile of %s not succeeded", strings.Join(notSynced, ", "))
+	}
+	return nil
+}
+
+// Serve serves /healthz and /readyz on address until the process exits, address empty disables it
+func Serve(address string, checker *Checker) {
+	if address == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", handler(checker.Healthy))
+	mux.HandleFunc("/readyz", handler(checker.Ready))
+	go func() {
+		klog.Infof("Serve:: serving health probes on %s", address)
+		if err := http.ListenAndServe(address, mux); err != nil {
+			klog.Errorf("Serve:: serve health probes on %s error: %v", address, err)
+		}
+	}()
+}
+
+func handler(check func() error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := check(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package health
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeTracker reports a running command with deadline if running is true
+type fakeTracker struct {
+	deadline time.Time
+	running  bool
+}
+
+func (t *fakeTracker) RunningDeadline() (time.Time, bool) {
+	return t.deadline, t.running
+}
+
+func TestHealthy(t *testing.T) {
+	checker := NewChecker([]string{"volumegroup"}, time.Minute)
+	assert.Nil(t, checker.Healthy())
+
+	// no reconcile finished within timeout
+	checker.lastProgress = time.Now().Add(-2 * time.Minute)
+	err := checker.Healthy()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "timeout 1m0s")
+
+	// a finished reconcile counts as progress, even if failed
+	checker.Progress("volumegroup", fmt.Errorf("create volume group failed"))
+	assert.Nil(t, checker.Healthy())
+}
+
+func TestHealthyRunningCommand(t *testing.T) {
+	checker := NewChecker([]string{"quotapath"}, time.Minute)
+	tracker := &fakeTracker{}
+	checker.TrackCommands(tracker)
+	checker.lastProgress = time.Now().Add(-10 * time.Minute)
+
+	// no command is running
+	err := checker.Healthy()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "timeout 1m0s")
+
+	// a long mkfs within its deadline
+	tracker.running = true
+	tracker.deadline = time.Now().Add(20 * time.Minute)
+	assert.Nil(t, checker.Healthy())
+
+	// a hung command past its deadline
+	tracker.deadline = time.Now().Add(-3 * time.Minute)
+	err = checker.Healthy()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "a command is running 3m0s past its deadline")
+}
+
+func TestReady(t *testing.T) {
+	checker := NewChecker([]string{"volumegroup", "logicalvolume", "quotapath"}, time.Minute)
+	err := checker.Ready()
+	assert.NotNil(t, err)
+	assert.Equal(t, "first reconcile of logicalvolume, quotapath, volumegroup not succeeded", err.Error())
+
+	// a failed reconcile does not make manager ready
+	checker.Progress("volumegroup", nil)
+	checker.Progress("quotapath", fmt.Errorf("mount failed"))
+	err = checker.Ready()
+	assert.NotNil(t, err)
+	assert.Equal(t, "first reconcile of logicalvolume, quotapath not succeeded", err.Error())
+
+	checker.Progress("logicalvolume", nil)
+	checker.Progress("quotapath", nil)
+	assert.Nil(t, checker.Ready())
+
+	// a manager stays ready after a later failure
+	checker.Progress("quotapath", fmt.Errorf("mount failed"))
+	assert.Nil(t, checker.Ready())
+}
+
+func TestHandler(t *testing.T) {
+	checker := NewChecker([]string{"memory"}, time.Minute)
+	recorder := httptest.NewRecorder()
+	handler(checker.Ready)(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "first reconcile of memory not succeeded")
+
+	checker.Progress("memory", nil)
+	recorder = httptest.NewRecorder()
+	handler(checker.Ready)(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "ok", recorder.Body.String())
+}
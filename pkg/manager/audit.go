@@ -0,0 +1,109 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/openyurtio/node-resource-manager/pkg/apis/nrm/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	klog "k8s.io/klog/v2"
+)
+
+// Auditor is implemented by managers which can find the drift between node and resource topology
+type Auditor interface {
+	// Audit returns the resources on node absent from the topology analysed in last reconcile, and the ones differ from spec,
+	// nothing is changed on node
+	Audit() ([]v1alpha1.DriftStatus, error)
+}
+
+// driftStore keeps the drifts found in the last audit of every manager, written by reconcile and read by status update
+type driftStore struct {
+	lock   sync.Mutex
+	drifts map[string][]v1alpha1.DriftStatus
+}
+
+func newDriftStore() *driftStore {
+	return &driftStore{drifts: map[string][]v1alpha1.DriftStatus{}}
+}
+
+// Set replaces the drifts of manager, returns the drifts not found in last audit
+func (s *driftStore) Set(name string, drifts []v1alpha1.DriftStatus) []v1alpha1.DriftStatus {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	last := map[v1alpha1.DriftStatus]bool{}
+	for _, drift := range s.drifts[name] {
+		last[drift] = true
+	}
+	added := []v1alpha1.DriftStatus{}
+	for _, drift := range drifts {
+		if !last[drift] {
+			added = append(added, drift)
+		}
+	}
+	s.drifts[name] = drifts
+	return added
+}
+
+// List returns the drifts of all managers sorted by kind and name
+func (s *driftStore) List() []v1alpha1.DriftStatus {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	drifts := []v1alpha1.DriftStatus{}
+	for _, managerDrifts := range s.drifts {
+		drifts = append(drifts, managerDrifts...)
+	}
+	sort.Slice(drifts, func(i, j int) bool {
+		if drifts[i].Kind != drifts[j].Kind {
+			return drifts[i].Kind < drifts[j].Kind
+		}
+		if drifts[i].Name != drifts[j].Name {
+			return drifts[i].Name < drifts[j].Name
+		}
+		return drifts[i].Reason < drifts[j].Reason
+	})
+	return drifts
+}
+
+// audit records the drifts of manager if it is an auditor, and emits a node event for every new drift
+func (c *Controller) audit(name string, rm Manager) {
+	auditor, ok := rm.(Auditor)
+	if !ok {
+		return
+	}
+	drifts, err := auditor.Audit()
+	if err != nil {
+		klog.Errorf("audit:: audit %s error: %v", name, err)
+		return
+	}
+	ref := &v1.ObjectReference{
+		Kind: "Node",
+		Name: c.nodeID,
+		UID:  types.UID(c.nodeID),
+	}
+	for _, drift := range c.drifts.Set(name, drifts) {
+		message := fmt.Sprintf("%s %s drifted from topology", drift.Kind, drift.Name)
+		if drift.Message != "" {
+			message = fmt.Sprintf("%s: %s", message, drift.Message)
+		}
+		klog.Infof("audit:: %s", message)
+		c.recorder.Event(ref, v1.EventTypeWarning, drift.Reason, message)
+	}
+}
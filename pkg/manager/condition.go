@@ -0,0 +1,143 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/openyurtio/node-resource-manager/pkg/config"
+	"github.com/openyurtio/node-resource-manager/pkg/model"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	klog "k8s.io/klog/v2"
+)
+
+// NodeConditionTypes is the node condition reporting resources of each kind
+var NodeConditionTypes = map[string]v1.NodeConditionType{
+	config.ResourceKindVolumeGroup:   "LocalVolumeGroupReady",
+	config.ResourceKindLogicalVolume: "LocalLogicalVolumeReady",
+	config.ResourceKindQuotaPath:     "LocalQuotaPathReady",
+	config.ResourceKindMemory:        "LocalMemoryReady",
+}
+
+// reportResults surfaces the reconcile result of one kind as node condition,
+// and emits node events for resources whose condition or reason changed.
+func (c *Controller) reportResults(kind string, results []*model.ResourceResult, reconcileErr error) {
+	ref := &v1.ObjectReference{
+		Kind: "Node",
+		Name: c.nodeID,
+		// same as kubelet, so events are shown in node description
+		UID: types.UID(c.nodeID),
+	}
+	for _, result := range results {
+		key := result.Kind + "/" + result.Name
+		if last, ok := c.lastResults[key]; ok && last.Condition == result.Condition && last.Reason == result.Reason {
+			continue
+		}
+		c.lastResults[key] = *result
+		eventType := v1.EventTypeNormal
+		if result.Condition == model.ResourceFailed {
+			eventType = v1.EventTypeWarning
+		}
+		message := fmt.Sprintf("%s %s is %s", result.Kind, result.Name, result.Condition)
+		if result.Message != "" {
+			message = fmt.Sprintf("%s: %s", message, result.Message)
+		}
+		c.recorder.Event(ref, eventType, result.Reason, message)
+	}
+
+	if err := c.patchNodeCondition(newNodeCondition(kind, results, reconcileErr)); err != nil {
+		klog.Errorf("reportResults:: update %s condition of node %s error: %v", kind, c.nodeID, err)
+	}
+}
+
+// newNodeCondition is True only if all resources of kind are ready
+func newNodeCondition(kind string, results []*model.ResourceResult, reconcileErr error) v1.NodeCondition {
+	condition := v1.NodeCondition{
+		Type:    NodeConditionTypes[kind],
+		Status:  v1.ConditionTrue,
+		Reason:  "ResourcesReady",
+		Message: fmt.Sprintf("all %d %s resources are ready", len(results), kind),
+	}
+	if reconcileErr != nil {
+		condition.Status = v1.ConditionFalse
+		condition.Reason = "ReconcileFailed"
+		condition.Message = reconcileErr.Error()
+		return condition
+	}
+
+	notReady := []string{}
+	for _, result := range results {
+		switch result.Condition {
+		case model.ResourceFailed:
+			condition.Status = v1.ConditionFalse
+			condition.Reason = "ResourcesFailed"
+		case model.ResourceProgressing:
+			if condition.Status == v1.ConditionTrue {
+				condition.Status = v1.ConditionFalse
+				condition.Reason = "ResourcesProgressing"
+			}
+		default:
+			continue
+		}
+		notReady = append(notReady, fmt.Sprintf("%s %s: %s", result.Name, result.Condition, result.Message))
+	}
+	if len(notReady) != 0 {
+		sort.Strings(notReady)
+		condition.Message = strings.Join(notReady, "; ")
+	}
+	return condition
+}
+
+// patchNodeCondition patch condition into node status if changed
+func (c *Controller) patchNodeCondition(condition v1.NodeCondition) error {
+	node, err := c.nodeLister.Get(c.nodeID)
+	if err != nil {
+		return err
+	}
+	now := metav1.Now()
+	condition.LastHeartbeatTime = now
+	condition.LastTransitionTime = now
+	for _, cond := range node.Status.Conditions {
+		if cond.Type != condition.Type {
+			continue
+		}
+		if cond.Status == condition.Status && cond.Reason == condition.Reason && cond.Message == condition.Message {
+			return nil
+		}
+		if cond.Status == condition.Status {
+			condition.LastTransitionTime = cond.LastTransitionTime
+		}
+	}
+
+	// conditions are merged by type in strategic merge patch
+	patch, err := json.Marshal(map[string]interface{}{
+		"status": map[string]interface{}{
+			"conditions": []v1.NodeCondition{condition},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = c.kubeClient.CoreV1().Nodes().PatchStatus(context.Background(), c.nodeID, patch)
+	return err
+}
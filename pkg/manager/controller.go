@@ -0,0 +1,226 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/openyurtio/node-resource-manager/pkg/config"
+	"github.com/openyurtio/node-resource-manager/pkg/health"
+	"github.com/openyurtio/node-resource-manager/pkg/metrics"
+	"github.com/openyurtio/node-resource-manager/pkg/model"
+	"github.com/openyurtio/node-resource-manager/pkg/utils"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	klog "k8s.io/klog/v2"
+)
+
+const (
+	// baseRetryDelay is the first retry delay of a failed resource, doubled on every failure
+	baseRetryDelay = 5 * time.Second
+	// maxRetryDelay is the max retry delay of a failed resource
+	maxRetryDelay = 5 * time.Minute
+)
+
+// Controller reconciles local resources by manager, every manager is a key of the workqueue.
+// A reconcile is triggered by resource topology changes, current node label changes and periodic resync,
+// a failed manager is retried with exponential backoff.
+type Controller struct {
+	nodeID         string
+	rms            map[string]Manager
+	source         config.TopologySource
+	resyncInterval time.Duration
+	nodeInformer   cache.SharedIndexInformer
+	nodeLister     corelisters.NodeLister
+	queue          workqueue.RateLimitingInterface
+	kubeClient     kubernetes.Interface
+	recorder       record.EventRecorder
+	plan           *utils.Plan
+	drifts         *driftStore
+	health         *health.Checker
+	// lastResults is the last result of every resource, keyed by kind/name, used to emit events on change
+	lastResults map[string]model.ResourceResult
+}
+
+// NewController create the controller of managers
+func NewController(urm *UnifiedResourceManager) *Controller {
+	c := &Controller{
+		nodeID:         urm.NodeID,
+		rms:            urm.rms,
+		source:         urm.source,
+		resyncInterval: time.Duration(urm.UpdateInterval) * time.Second,
+		kubeClient:     urm.KubeClientSet,
+		plan:           urm.plan,
+		drifts:         urm.drifts,
+		health:         urm.Health,
+		lastResults:    map[string]model.ResourceResult{},
+		queue: workqueue.NewNamedRateLimitingQueue(
+			workqueue.NewItemExponentialFailureRateLimiter(baseRetryDelay, maxRetryDelay), "node-resource-manager"),
+	}
+
+	// only watch current node
+	factory := informers.NewSharedInformerFactoryWithOptions(urm.KubeClientSet, 0, informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+		options.FieldSelector = fields.OneTermEqualSelector("metadata.name", urm.NodeID).String()
+	}))
+	c.nodeInformer = factory.Core().V1().Nodes().Informer()
+	c.nodeLister = factory.Core().V1().Nodes().Lister()
+	c.nodeInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldNode, ok := oldObj.(*v1.Node)
+			if !ok {
+				return
+			}
+			newNode, ok := newObj.(*v1.Node)
+			if !ok {
+				return
+			}
+			if !reflect.DeepEqual(oldNode.Labels, newNode.Labels) {
+				klog.Infof("Controller:: labels of node %s changed", newNode.Name)
+				c.enqueueAll()
+			}
+		},
+	})
+
+	if c.plan == nil {
+		c.recorder = utils.NewEventRecorder()
+	}
+	c.source.AddChangeHandler(c.enqueueAll)
+	return c
+}
+
+// Run starts the sources and worker, blocks until stopCh is closed and the running reconcile is finished
+func (c *Controller) Run(stopCh <-chan struct{}) error {
+	defer utilruntime.HandleCrash()
+
+	go c.nodeInformer.Run(stopCh)
+	if !cache.WaitForCacheSync(stopCh, c.nodeInformer.HasSynced) {
+		return fmt.Errorf("wait for node %s cache sync failed", c.nodeID)
+	}
+	if err := c.source.Start(stopCh); err != nil {
+		return fmt.Errorf("start resource topology source error: %v", err)
+	}
+
+	// managers share node info and local tools, so reconcile them one by one in a single worker
+	workerDone := make(chan struct{})
+	go func() {
+		defer close(workerDone)
+		for c.processNextItem() {
+		}
+	}()
+
+	// resync also triggers the first reconcile
+	go wait.Until(c.enqueueAll, c.resyncInterval, stopCh)
+
+	klog.Infof("Controller:: started to maintain local resources on %s", c.nodeID)
+	<-stopCh
+	c.queue.ShutDown()
+	<-workerDone
+	klog.Infof("Controller:: stopped to maintain local resources on %s", c.nodeID)
+	return nil
+}
+
+// enqueueAll add all managers to queue in the order of ResourceKinds, so volume groups are reconciled
+// before the logical volumes and quota paths on them, a manager already in queue is not added twice
+func (c *Controller) enqueueAll() {
+	for _, name := range ResourceKinds {
+		if _, ok := c.rms[name]; ok {
+			c.queue.Add(name)
+		}
+	}
+}
+
+func (c *Controller) processNextItem() bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	name := key.(string)
+	start := time.Now()
+	requeue, err := c.syncManager(name)
+	metrics.RecordReconcile(name, start, err)
+	c.health.Progress(name, err)
+	if err != nil {
+		klog.Errorf("Controller:: reconcile %s error, retry after backoff: %v", name, err)
+		c.queue.AddRateLimited(key)
+		return true
+	}
+	if requeue {
+		klog.V(3).Infof("Controller:: %s is not ready yet, retry after backoff", name)
+		c.queue.AddRateLimited(key)
+		return true
+	}
+	c.queue.Forget(key)
+	return true
+}
+
+// syncManager refresh current node info and build resources of manager,
+// requeue is true if some resource is still progressing.
+func (c *Controller) syncManager(name string) (bool, error) {
+	rm, ok := c.rms[name]
+	if !ok {
+		return false, nil
+	}
+	node, err := c.nodeLister.Get(c.nodeID)
+	if err != nil {
+		return false, fmt.Errorf("get node %s error: %v", c.nodeID, err)
+	}
+	config.GlobalConfigVar.NodeInfo = node
+
+	results, err := BuildResource(rm)
+	if c.plan != nil {
+		// dry-run only logs the plan, node is not changed so nothing to report
+		for _, action := range c.plan.Take(name) {
+			klog.Infof("Controller:: dry-run %s planned action: %s", name, action.Command)
+		}
+	} else {
+		c.reportResults(name, results, err)
+		if err == nil && c.drifts != nil {
+			c.audit(name, rm)
+		}
+	}
+	if err != nil {
+		return false, err
+	}
+
+	requeue := false
+	failed := []string{}
+	for _, result := range results {
+		switch result.Condition {
+		case model.ResourceFailed:
+			failed = append(failed, result.Name)
+		case model.ResourceProgressing:
+			requeue = true
+		}
+	}
+	if len(failed) != 0 {
+		return false, fmt.Errorf("%s %v failed", name, failed)
+	}
+	return requeue, nil
+}
@@ -0,0 +1,196 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/openyurtio/node-resource-manager/pkg/apis/nrm/v1alpha1"
+	"github.com/openyurtio/node-resource-manager/pkg/config"
+	"github.com/openyurtio/node-resource-manager/pkg/model"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// fakeManager returns the results in order, one per reconcile
+type fakeManager struct {
+	results [][]*model.ResourceResult
+	err     error
+	// node is the node info seen by AnalyseConfigMap
+	node *v1.Node
+}
+
+func (m *fakeManager) AnalyseConfigMap() error {
+	m.node = config.GlobalConfigVar.NodeInfo
+	return m.err
+}
+
+func (m *fakeManager) ApplyResourceDiff() ([]*model.ResourceResult, error) {
+	results := m.results[0]
+	if len(m.results) > 1 {
+		m.results = m.results[1:]
+	}
+	return results, nil
+}
+
+func (m *fakeManager) RecordStatus(status *v1alpha1.NodeLocalResourceStatus) error {
+	return nil
+}
+
+func newTestController(t *testing.T, rm Manager) (*Controller, *fake.Clientset, *record.FakeRecorder) {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1", Labels: map[string]string{"disk": "ssd"}}}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	assert.Nil(t, indexer.Add(node))
+	kubeClient := fake.NewSimpleClientset(node)
+	recorder := record.NewFakeRecorder(10)
+	c := &Controller{
+		nodeID:      node.Name,
+		rms:         map[string]Manager{config.ResourceKindVolumeGroup: rm},
+		nodeLister:  corelisters.NewNodeLister(indexer),
+		kubeClient:  kubeClient,
+		recorder:    recorder,
+		lastResults: map[string]model.ResourceResult{},
+	}
+	return c, kubeClient, recorder
+}
+
+func getNodeCondition(t *testing.T, client *fake.Clientset, kind string) *v1.NodeCondition {
+	node, err := client.CoreV1().Nodes().Get(context.Background(), "node1", metav1.GetOptions{})
+	assert.Nil(t, err)
+	for i := range node.Status.Conditions {
+		if node.Status.Conditions[i].Type == NodeConditionTypes[kind] {
+			return &node.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+func drainEvents(recorder *record.FakeRecorder) []string {
+	events := []string{}
+	for {
+		select {
+		case event := <-recorder.Events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func TestEnqueueAllInResourceKindsOrder(t *testing.T) {
+	rms := map[string]Manager{}
+	for _, kind := range ResourceKinds {
+		rms[kind] = nil
+	}
+	c := &Controller{
+		rms:   rms,
+		queue: workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+	}
+	defer c.queue.ShutDown()
+
+	// map iteration order is random, the queue order must not be
+	for i := 0; i < 10; i++ {
+		c.enqueueAll()
+		keys := []string{}
+		for c.queue.Len() > 0 {
+			key, _ := c.queue.Get()
+			keys = append(keys, key.(string))
+			c.queue.Done(key)
+		}
+		assert.Equal(t, ResourceKinds, keys)
+	}
+}
+
+func TestSyncManager(t *testing.T) {
+	progressing := model.NewResourceResult(config.ResourceKindVolumeGroup, "vg1", model.ResourceProgressing, "PhysicalVolumeMoving", "moving extents")
+	ready := model.NewResourceResult(config.ResourceKindVolumeGroup, "vg1", model.ResourceReady, "VolumeGroupReady", "")
+	failed := model.NewResourceResult(config.ResourceKindVolumeGroup, "vg1", model.ResourceFailed, "VolumeGroupNotOwned", "not owned")
+	rm := &fakeManager{results: [][]*model.ResourceResult{{progressing}, {ready}, {ready}, {failed}}}
+	c, client, recorder := newTestController(t, rm)
+	defer func() { config.GlobalConfigVar.NodeInfo = nil }()
+
+	// a progressing resource is requeued
+	requeue, err := c.syncManager(config.ResourceKindVolumeGroup)
+	assert.Nil(t, err)
+	assert.True(t, requeue)
+	assert.Equal(t, "ssd", rm.node.Labels["disk"])
+	assert.Equal(t, []string{"Normal PhysicalVolumeMoving volumegroup vg1 is Progressing: moving extents"}, drainEvents(recorder))
+	cond := getNodeCondition(t, client, config.ResourceKindVolumeGroup)
+	assert.NotNil(t, cond)
+	assert.Equal(t, v1.ConditionFalse, cond.Status)
+	assert.Equal(t, "ResourcesProgressing", cond.Reason)
+
+	requeue, err = c.syncManager(config.ResourceKindVolumeGroup)
+	assert.Nil(t, err)
+	assert.False(t, requeue)
+	assert.Equal(t, []string{"Normal VolumeGroupReady volumegroup vg1 is Ready"}, drainEvents(recorder))
+	cond = getNodeCondition(t, client, config.ResourceKindVolumeGroup)
+	assert.Equal(t, v1.ConditionTrue, cond.Status)
+	assert.Equal(t, "ResourcesReady", cond.Reason)
+
+	// an unchanged result emits no event
+	_, err = c.syncManager(config.ResourceKindVolumeGroup)
+	assert.Nil(t, err)
+	assert.Empty(t, drainEvents(recorder))
+
+	// a failed resource fails the reconcile, so it is retried with backoff
+	_, err = c.syncManager(config.ResourceKindVolumeGroup)
+	assert.NotNil(t, err)
+	assert.Equal(t, []string{"Warning VolumeGroupNotOwned volumegroup vg1 is Failed: not owned"}, drainEvents(recorder))
+	cond = getNodeCondition(t, client, config.ResourceKindVolumeGroup)
+	assert.Equal(t, v1.ConditionFalse, cond.Status)
+	assert.Equal(t, "ResourcesFailed", cond.Reason)
+}
+
+func TestSyncManagerReconcileError(t *testing.T) {
+	rm := &fakeManager{err: fmt.Errorf("get topology error")}
+	c, client, _ := newTestController(t, rm)
+	defer func() { config.GlobalConfigVar.NodeInfo = nil }()
+
+	_, err := c.syncManager(config.ResourceKindVolumeGroup)
+	assert.NotNil(t, err)
+	cond := getNodeCondition(t, client, config.ResourceKindVolumeGroup)
+	assert.NotNil(t, cond)
+	assert.Equal(t, v1.ConditionFalse, cond.Status)
+	assert.Equal(t, "ReconcileFailed", cond.Reason)
+	assert.Equal(t, "get topology error", cond.Message)
+}
+
+func TestNewNodeCondition(t *testing.T) {
+	ready := model.NewResourceResult(config.ResourceKindQuotaPath, "/mnt/path1", model.ResourceReady, "QuotaPathReady", "")
+	progressing := model.NewResourceResult(config.ResourceKindQuotaPath, "/mnt/path2", model.ResourceProgressing, "QuotaPathFormatting", "formatting")
+	failed := model.NewResourceResult(config.ResourceKindQuotaPath, "/mnt/path3", model.ResourceFailed, "DeviceNotFound", "device not found")
+
+	cond := newNodeCondition(config.ResourceKindQuotaPath, []*model.ResourceResult{ready}, nil)
+	assert.Equal(t, v1.NodeConditionType("LocalQuotaPathReady"), cond.Type)
+	assert.Equal(t, v1.ConditionTrue, cond.Status)
+	assert.Equal(t, "all 1 quotapath resources are ready", cond.Message)
+
+	// failed takes precedence over progressing, whatever the order
+	cond = newNodeCondition(config.ResourceKindQuotaPath, []*model.ResourceResult{failed, ready, progressing}, nil)
+	assert.Equal(t, v1.ConditionFalse, cond.Status)
+	assert.Equal(t, "ResourcesFailed", cond.Reason)
+	assert.Equal(t, "/mnt/path2 Progressing: formatting; /mnt/path3 Failed: device not found", cond.Message)
+}
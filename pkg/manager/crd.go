@@ -0,0 +1,288 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"github.com/openyurtio/node-resource-manager/pkg/apis/nrm/v1alpha1"
+	"github.com/openyurtio/node-resource-manager/pkg/model"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// NodeLocalResourceCRDName is the name of NodeLocalResource CustomResourceDefinition
+	NodeLocalResourceCRDName = v1alpha1.NodeLocalResourcePlural + "." + v1alpha1.GroupName
+	// NodeResourceTopologyCRDName is the name of NodeResourceTopology CustomResourceDefinition
+	NodeResourceTopologyCRDName = v1alpha1.NodeResourceTopologyPlural + "." + v1alpha1.GroupName
+)
+
+func stringProp() apiextv1.JSONSchemaProps {
+	return apiextv1.JSONSchemaProps{Type: "string"}
+}
+
+func integerProp() apiextv1.JSONSchemaProps {
+	return apiextv1.JSONSchemaProps{Type: "integer", Format: "int64"}
+}
+
+func booleanProp() apiextv1.JSONSchemaProps {
+	return apiextv1.JSONSchemaProps{Type: "boolean"}
+}
+
+func arrayProp(items apiextv1.JSONSchemaProps) apiextv1.JSONSchemaProps {
+	return apiextv1.JSONSchemaProps{
+		Type:  "array",
+		Items: &apiextv1.JSONSchemaPropsOrArray{Schema: &items},
+	}
+}
+
+func objectProp(properties map[string]apiextv1.JSONSchemaProps, required ...string) apiextv1.JSONSchemaProps {
+	return apiextv1.JSONSchemaProps{Type: "object", Properties: properties, Required: required}
+}
+
+func enumProp(values ...string) apiextv1.JSONSchemaProps {
+	prop := stringProp()
+	for _, value := range values {
+		prop.Enum = append(prop.Enum, apiextv1.JSON{Raw: []byte(`"` + value + `"`)})
+	}
+	return prop
+}
+
+func patternProp(pattern string) apiextv1.JSONSchemaProps {
+	prop := stringProp()
+	prop.Pattern = pattern
+	return prop
+}
+
+// resourceSpecProp returns the schema of one resource in NodeResourceTopology, with node label matcher
+func resourceSpecProp(name, topology apiextv1.JSONSchemaProps) apiextv1.JSONSchemaProps {
+	return objectProp(map[string]apiextv1.JSONSchemaProps{
+		"name":     name,
+		"key":      stringProp(),
+		"operator": enumProp("In", "NotIn", "Exists", "DoesNotExist"),
+		"value":    stringProp(),
+		"topology": topology,
+	}, "name", "key", "operator", "topology")
+}
+
+// nodeLocalResourceCRD returns the CustomResourceDefinition of NodeLocalResource
+func nodeLocalResourceCRD() *apiextv1.CustomResourceDefinition {
+	statusSchema := objectProp(map[string]apiextv1.JSONSchemaProps{
+		"volumeGroups": arrayProp(objectProp(map[string]apiextv1.JSONSchemaProps{
+			"name":     stringProp(),
+			"size":     integerProp(),
+			"freeSize": integerProp(),
+			"physicalVolumes": arrayProp(objectProp(map[string]apiextv1.JSONSchemaProps{
+				"name": stringProp(),
+				"size": integerProp(),
+			})),
+		})),
+		"logicalVolumes": arrayProp(objectProp(map[string]apiextv1.JSONSchemaProps{
+			"name":        stringProp(),
+			"volumeGroup": stringProp(),
+			"size":        integerProp(),
+			"tags":        arrayProp(stringProp()),
+		})),
+		"quotaPaths": arrayProp(objectProp(map[string]apiextv1.JSONSchemaProps{
+			"mountPath": stringProp(),
+			"device":    stringProp(),
+			"fstype":    stringProp(),
+			"options":   stringProp(),
+			"capacity":  integerProp(),
+			"used":      integerProp(),
+			"available": integerProp(),
+			"directories": arrayProp(objectProp(map[string]apiextv1.JSONSchemaProps{
+				"path":           stringProp(),
+				"projectId":      integerProp(),
+				"blockUsed":      integerProp(),
+				"blockSoftLimit": integerProp(),
+				"blockHardLimit": integerProp(),
+				"inodeUsed":      integerProp(),
+				"inodeSoftLimit": integerProp(),
+				"inodeHardLimit": integerProp(),
+			})),
+		})),
+		"memories": arrayProp(objectProp(map[string]apiextv1.JSONSchemaProps{
+			"region":     stringProp(),
+			"chardev":    stringProp(),
+			"size":       integerProp(),
+			"targetNode": {Type: "integer"},
+			"movable":    {Type: "boolean"},
+		})),
+		"pmemRegions": arrayProp(objectProp(map[string]apiextv1.JSONSchemaProps{
+			"name":          stringProp(),
+			"size":          integerProp(),
+			"availableSize": integerProp(),
+			"namespaces":    arrayProp(stringProp()),
+		})),
+		"drifts": arrayProp(objectProp(map[string]apiextv1.JSONSchemaProps{
+			"kind":    stringProp(),
+			"name":    stringProp(),
+			"reason":  stringProp(),
+			"message": stringProp(),
+		})),
+		"lastUpdateTime": {Type: "string", Format: "date-time"},
+	})
+
+	return &apiextv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: NodeLocalResourceCRDName,
+		},
+		Spec: apiextv1.CustomResourceDefinitionSpec{
+			Group: v1alpha1.GroupName,
+			Names: apiextv1.CustomResourceDefinitionNames{
+				Plural:     v1alpha1.NodeLocalResourcePlural,
+				Singular:   "nodelocalresource",
+				Kind:       v1alpha1.NodeLocalResourceKind,
+				ListKind:   v1alpha1.NodeLocalResourceKind + "List",
+				ShortNames: []string{"nlr"},
+			},
+			Scope: apiextv1.ClusterScoped,
+			Versions: []apiextv1.CustomResourceDefinitionVersion{{
+				Name:    v1alpha1.Version,
+				Served:  true,
+				Storage: true,
+				Schema: &apiextv1.CustomResourceValidation{
+					OpenAPIV3Schema: &apiextv1.JSONSchemaProps{
+						Type: "object",
+						Properties: map[string]apiextv1.JSONSchemaProps{
+							"apiVersion": stringProp(),
+							"kind":       stringProp(),
+							"metadata":   {Type: "object"},
+							"spec": objectProp(map[string]apiextv1.JSONSchemaProps{
+								"nodeName": stringProp(),
+							}),
+							"status": statusSchema,
+						},
+					},
+				},
+				Subresources: &apiextv1.CustomResourceSubresources{
+					Status: &apiextv1.CustomResourceSubresourceStatus{},
+				},
+			}},
+		},
+	}
+}
+
+// nodeResourceTopologyCRD returns the CustomResourceDefinition of NodeResourceTopology
+func nodeResourceTopologyCRD() *apiextv1.CustomResourceDefinition {
+	minMirrors := float64(0)
+	sizePattern := "^[0-9]+(\\.[0-9]+)?([KMGTPE]i?)?$"
+	deviceSelector := objectProp(map[string]apiextv1.JSONSchemaProps{
+		"byId":       arrayProp(stringProp()),
+		"byPath":     arrayProp(stringProp()),
+		"serial":     arrayProp(stringProp()),
+		"wwn":        arrayProp(stringProp()),
+		"model":      arrayProp(stringProp()),
+		"minSize":    patternProp(sizePattern),
+		"maxSize":    patternProp(sizePattern),
+		"rotational": booleanProp(),
+		"transport":  arrayProp(enumProp("nvme", "sata", "scsi", "virtio", "usb")),
+	})
+	volumeGroupTopology := objectProp(map[string]apiextv1.JSONSchemaProps{
+		"type":           enumProp("device", "localdisk", "alibabacloud-local-disk", "pmem"),
+		"devices":        arrayProp(patternProp("^/dev/")),
+		"deviceSelector": deviceSelector,
+		"regions":        arrayProp(stringProp()),
+		"include":        arrayProp(stringProp()),
+		"exclude":        arrayProp(stringProp()),
+		"thinPool": objectProp(map[string]apiextv1.JSONSchemaProps{
+			"name":         patternProp("^[a-zA-Z0-9+_.][a-zA-Z0-9+_.-]*$"),
+			"size":         patternProp("^([0-9]+(\\.[0-9]+)?([KMGTPE]i?)?|([1-9][0-9]?|100)%)$"),
+			"chunkSize":    patternProp("^[0-9]+([KMG]i?)?$"),
+			"metadataSize": patternProp("^[0-9]+(\\.[0-9]+)?([KMGT]i?)?$"),
+		}, "size"),
+		"allowShrink": booleanProp(),
+		"adopt":       booleanProp(),
+		"wipe":        booleanProp(),
+	}, "type")
+	logicalVolumeTopology := objectProp(map[string]apiextv1.JSONSchemaProps{
+		"volumeGroup": patternProp("^[a-zA-Z0-9+_.][a-zA-Z0-9+_.-]*$"),
+		"size":        patternProp("^[0-9]+(\\.[0-9]+)?([KMGTPE]i?)?$"),
+		"mirrors":     {Type: "integer", Format: "int32", Minimum: &minMirrors},
+		"tags":        arrayProp(patternProp("^[a-zA-Z0-9+_./=:][a-zA-Z0-9+_./=:-]*$")),
+		"pool":        patternProp("^[a-zA-Z0-9+_.][a-zA-Z0-9+_.-]*$"),
+	}, "volumeGroup", "size")
+	quotaPathTopology := objectProp(map[string]apiextv1.JSONSchemaProps{
+		"type":           enumProp("device", "pmem", "lvm"),
+		"layout":         enumProp("single", "raid0", "raid1"),
+		"persistence":    enumProp("fstab", "systemd"),
+		"options":        stringProp(),
+		"fstype":         enumProp("ext4", "xfs"),
+		"devices":        arrayProp(patternProp("^/dev/")),
+		"deviceSelector": deviceSelector,
+		"regions":        arrayProp(stringProp()),
+		"volumeGroup":    patternProp("^[a-zA-Z0-9+_.][a-zA-Z0-9+_.-]*$"),
+		"size":           patternProp(sizePattern),
+		"directories": arrayProp(objectProp(map[string]apiextv1.JSONSchemaProps{
+			"name":           patternProp("^[a-zA-Z0-9+_.:@-][a-zA-Z0-9+_.:@/-]*$"),
+			"blockSoftLimit": stringProp(),
+			"blockHardLimit": stringProp(),
+			"inodeSoftLimit": integerProp(),
+			"inodeHardLimit": integerProp(),
+		}, "name")),
+	}, "type")
+	memoryRegions := arrayProp(stringProp())
+	minItems, maxItems := int64(1), int64(1)
+	memoryRegions.MinItems, memoryRegions.MaxItems = &minItems, &maxItems
+	memoryTopology := objectProp(map[string]apiextv1.JSONSchemaProps{
+		"type":    enumProp("pmem"),
+		"regions": memoryRegions,
+	}, "type", "regions")
+
+	volumeGroupSpec := resourceSpecProp(patternProp("^[a-zA-Z0-9+_.][a-zA-Z0-9+_.-]*$"), volumeGroupTopology)
+	volumeGroupSpec.Properties["state"] = enumProp(model.ResourceStatePresent, model.ResourceStateAbsent)
+
+	specSchema := objectProp(map[string]apiextv1.JSONSchemaProps{
+		"volumeGroups":   arrayProp(volumeGroupSpec),
+		"logicalVolumes": arrayProp(resourceSpecProp(patternProp("^[a-zA-Z0-9+_.][a-zA-Z0-9+_.-]*$"), logicalVolumeTopology)),
+		"quotaPaths":     arrayProp(resourceSpecProp(patternProp("^/"), quotaPathTopology)),
+		"memories":       arrayProp(resourceSpecProp(stringProp(), memoryTopology)),
+	})
+
+	return &apiextv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: NodeResourceTopologyCRDName,
+		},
+		Spec: apiextv1.CustomResourceDefinitionSpec{
+			Group: v1alpha1.GroupName,
+			Names: apiextv1.CustomResourceDefinitionNames{
+				Plural:     v1alpha1.NodeResourceTopologyPlural,
+				Singular:   "noderesourcetopology",
+				Kind:       v1alpha1.NodeResourceTopologyKind,
+				ListKind:   v1alpha1.NodeResourceTopologyKind + "List",
+				ShortNames: []string{"nrtopo"},
+			},
+			Scope: apiextv1.ClusterScoped,
+			Versions: []apiextv1.CustomResourceDefinitionVersion{{
+				Name:    v1alpha1.Version,
+				Served:  true,
+				Storage: true,
+				Schema: &apiextv1.CustomResourceValidation{
+					OpenAPIV3Schema: &apiextv1.JSONSchemaProps{
+						Type: "object",
+						Properties: map[string]apiextv1.JSONSchemaProps{
+							"apiVersion": stringProp(),
+							"kind":       stringProp(),
+							"metadata":   {Type: "object"},
+							"spec":       specSchema,
+						},
+					},
+				},
+			}},
+		},
+	}
+}
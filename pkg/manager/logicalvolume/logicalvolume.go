@@ -0,0 +1,370 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logicalvolume
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/openyurtio/node-resource-manager/pkg/apis/nrm/v1alpha1"
+	"github.com/openyurtio/node-resource-manager/pkg/config"
+	"github.com/openyurtio/node-resource-manager/pkg/model"
+	"github.com/openyurtio/node-resource-manager/pkg/utils"
+	"k8s.io/apimachinery/pkg/api/resource"
+	klog "k8s.io/klog/v2"
+)
+
+// ResourceManager ...
+type ResourceManager struct {
+	LogicalVolumes []*LvConfig
+	// invalid is the results of logical volumes with invalid spec
+	invalid []*model.ResourceResult
+	// growFSPending is the logical volumes extended whose filesystem is not grown yet, by vg/lv
+	growFSPending map[string]bool
+	lvmer         utils.LVM
+	mounter       utils.Mounter
+	source        config.TopologySource
+}
+
+// NewResourceManager create manager, commands are run by executor, changes are recorded into plan instead of executed if plan is not nil
+func NewResourceManager(source config.TopologySource, executor utils.CommandExecutor, plan *utils.Plan) *ResourceManager {
+	lrm := &ResourceManager{
+		LogicalVolumes: []*LvConfig{},
+		growFSPending:  map[string]bool{},
+		source:         source,
+	}
+	if plan != nil {
+		// dry-run changes nothing on node
+		lrm.lvmer = utils.NewDryRunLVM(utils.NewNodeLVM(executor), plan)
+		lrm.mounter = utils.NewDryRunMounter(utils.NewMounter(executor), executor, plan)
+	} else {
+		lrm.lvmer = utils.NewNodeLVM(executor)
+		lrm.mounter = utils.NewMounter(executor)
+	}
+	return lrm
+}
+
+// AnalyseConfigMap analyse logical volume resource config
+func (lrm *ResourceManager) AnalyseConfigMap() error {
+	lvConfigs := []*LvConfig{}
+	invalid := []*model.ResourceResult{}
+	logicalVolumes, err := lrm.source.GetResources(config.ResourceKindLogicalVolume)
+	if err != nil {
+		klog.Errorf("AnalyseConfigMap:: get logicalvolume config error: %v", err)
+		return err
+	}
+
+	nodeInfo := config.GlobalConfigVar.NodeInfo
+	for _, lvConfig := range logicalVolumes {
+		if !utils.NodeFilter(lvConfig.Operator, lvConfig.Key, lvConfig.Value, nodeInfo) {
+			continue
+		}
+		size, err := resource.ParseQuantity(lvConfig.Topology.Size)
+		if err != nil || size.Sign() <= 0 {
+			klog.Errorf("AnalyseConfigMap:: logical volume %s has invalid size %q", lvConfig.Name, lvConfig.Topology.Size)
+			invalid = append(invalid, model.NewResourceResult(config.ResourceKindLogicalVolume, lvConfig.Name, model.ResourceFailed,
+				"InvalidSize", fmt.Sprintf("invalid size %q", lvConfig.Topology.Size)))
+			continue
+		}
+		if lvConfig.Topology.Pool != "" && lvConfig.Topology.Mirrors > 0 {
+			klog.Errorf("AnalyseConfigMap:: thin logical volume %s can not be mirrored", lvConfig.Name)
+			invalid = append(invalid, model.NewResourceResult(config.ResourceKindLogicalVolume, lvConfig.Name, model.ResourceFailed,
+				"InvalidSpec", "thin logical volume can not be mirrored"))
+			continue
+		}
+		lvConfigs = append(lvConfigs, &LvConfig{
+			Name:        lvConfig.Name,
+			VolumeGroup: lvConfig.Topology.VolumeGroup,
+			Size:        uint64(size.Value()),
+			Mirrors:     lvConfig.Topology.Mirrors,
+			Tags:        lvConfig.Topology.Tags,
+			Pool:        lvConfig.Topology.Pool,
+		})
+	}
+	lrm.LogicalVolumes = lvConfigs
+	lrm.invalid = invalid
+	return nil
+}
+
+// ApplyResourceDiff creates missing logical volumes, and reports the ones drifted from spec
+func (lrm *ResourceManager) ApplyResourceDiff() ([]*model.ResourceResult, error) {
+	results := append([]*model.ResourceResult{}, lrm.invalid...)
+	if len(lrm.LogicalVolumes) == 0 {
+		return results, nil
+	}
+	vgs, err := lrm.lvmer.ListVG()
+	if err != nil {
+		klog.Errorf("ApplyResourceDiff:: list volume groups error: %v", err)
+		return nil, err
+	}
+	// free size of volume group is reduced by every logical volume grown in this loop
+	actualVgs := map[string]*model.VG{}
+	for _, vg := range vgs {
+		actualVgs[vg.Name] = vg
+	}
+
+	// logical volumes of every volume group, listed once
+	actualLvs := map[string]map[string]*model.LV{}
+	for _, lvConfig := range lrm.LogicalVolumes {
+		vg, ok := actualVgs[lvConfig.VolumeGroup]
+		if !ok {
+			results = append(results, model.NewResourceResult(config.ResourceKindLogicalVolume, lvConfig.Name, model.ResourceProgressing,
+				"VolumeGroupNotReady", fmt.Sprintf("volume group %s not exists", lvConfig.VolumeGroup)))
+			continue
+		}
+		// logical volumes are only created or grown in volume groups managed by node-resource-manager
+		if !hasVgTag(vg, utils.ManagedTagName) {
+			results = append(results, model.NewResourceResult(config.ResourceKindLogicalVolume, lvConfig.Name, model.ResourceFailed,
+				"VolumeGroupNotOwned", fmt.Sprintf("volume group %s is not managed by node-resource-manager", vg.Name)))
+			continue
+		}
+		lvs, ok := actualLvs[lvConfig.VolumeGroup]
+		if !ok {
+			lvs, err = lrm.listLV(lvConfig.VolumeGroup)
+			if err != nil {
+				klog.Errorf("ApplyResourceDiff:: list logical volumes of %s error: %v", lvConfig.VolumeGroup, err)
+				results = append(results, model.NewResourceResult(config.ResourceKindLogicalVolume, lvConfig.Name, model.ResourceFailed,
+					"ListLogicalVolumeFailed", err.Error()))
+				continue
+			}
+			actualLvs[lvConfig.VolumeGroup] = lvs
+		}
+		lv, ok := lvs[lvConfig.Name]
+		if !ok {
+			if pool, ok := lvs[lvConfig.Pool]; lvConfig.Pool != "" && (!ok || pool.Attributes.Type != model.VolumeTypeThinPool) {
+				results = append(results, model.NewResourceResult(config.ResourceKindLogicalVolume, lvConfig.Name, model.ResourceProgressing,
+					"ThinPoolNotReady", fmt.Sprintf("thin pool %s/%s not exists", lvConfig.VolumeGroup, lvConfig.Pool)))
+				continue
+			}
+			results = append(results, lrm.createLv(lvConfig))
+			continue
+		}
+		results = append(results, lrm.checkLv(lvConfig, lv, vg))
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	klog.Infof("ApplyResourceDiff:: Finish logicalvolume loop...")
+	return results, nil
+}
+
+// listLV returns the visible logical volumes of volume group by name
+func (lrm *ResourceManager) listLV(vgName string) (map[string]*model.LV, error) {
+	lvs, err := lrm.lvmer.ListLV(vgName)
+	if err != nil {
+		return nil, err
+	}
+	lvMap := map[string]*model.LV{}
+	for _, lv := range lvs {
+		// hidden sub volumes of mirror and raid are listed in brackets
+		if strings.HasPrefix(lv.Name, "[") {
+			continue
+		}
+		lvMap[lv.Name] = lv
+	}
+	return lvMap, nil
+}
+
+// createLv creates the missing logical volume, in thin pool if pool is set
+func (lrm *ResourceManager) createLv(lvConfig *LvConfig) *model.ResourceResult {
+	var out string
+	var err error
+	if lvConfig.Pool != "" {
+		out, err = lrm.lvmer.CreateThinLV(lvConfig.VolumeGroup, lvConfig.Pool, lvConfig.Name, lvConfig.Size, lvConfig.Tags)
+	} else {
+		out, err = lrm.lvmer.CreateLV(lvConfig.VolumeGroup, lvConfig.Name, lvConfig.Size, lvConfig.Mirrors, lvConfig.Tags)
+	}
+	if err != nil {
+		klog.Errorf("createLv:: create logical volume %s/%s error: %v", lvConfig.VolumeGroup, lvConfig.Name, err)
+		return model.NewResourceResult(config.ResourceKindLogicalVolume, lvConfig.Name, model.ResourceFailed,
+			"CreateLogicalVolumeFailed", err.Error())
+	}
+	klog.Infof("createLv:: Successful create logical volume %s/%s with out: %s", lvConfig.VolumeGroup, lvConfig.Name, out)
+	return model.NewResourceResult(config.ResourceKindLogicalVolume, lvConfig.Name, model.ResourceReady,
+		"LogicalVolumeCreated", fmt.Sprintf("created in %s with size %d", lvConfig.VolumeGroup, lvConfig.Size))
+}
+
+// checkLv adds the missing tags to existing logical volume, grows it if its size is increased in spec,
+// and reports the drift which can not be fixed. A protected logical volume is never changed.
+func (lrm *ResourceManager) checkLv(lvConfig *LvConfig, lv *model.LV, vg *model.VG) *model.ResourceResult {
+	protected := hasTag(lv, utils.ProtectedTagName)
+	drifts := []string{}
+	expectSize := utils.RoundUpToExtent(lvConfig.Size, vg)
+	if lv.Size != expectSize && protected {
+		drifts = append(drifts, fmt.Sprintf("protected, size is %d, expect %d", lv.Size, expectSize))
+	}
+	if mirrored := isMirrored(lv); mirrored != (lvConfig.Mirrors > 0) {
+		drifts = append(drifts, fmt.Sprintf("mirrored is %t, expect %d mirrors", mirrored, lvConfig.Mirrors))
+	}
+	if thin := lv.Attributes.Type == model.VolumeTypeThin; thin != (lvConfig.Pool != "") {
+		drifts = append(drifts, fmt.Sprintf("thin is %t, expect pool %q", thin, lvConfig.Pool))
+	}
+
+	missingTags := []string{}
+	for _, tag := range lvConfig.Tags {
+		if !hasTag(lv, tag) {
+			missingTags = append(missingTags, tag)
+		}
+	}
+	if len(missingTags) > 0 {
+		if protected {
+			drifts = append(drifts, fmt.Sprintf("protected, missing tags %v", missingTags))
+		} else if _, err := lrm.lvmer.AddTagLV(lvConfig.VolumeGroup, lvConfig.Name, missingTags); err != nil {
+			klog.Errorf("checkLv:: add tags %v to %s/%s error: %v", missingTags, lvConfig.VolumeGroup, lvConfig.Name, err)
+			return model.NewResourceResult(config.ResourceKindLogicalVolume, lvConfig.Name, model.ResourceFailed,
+				"AddTagFailed", err.Error())
+		}
+	}
+
+	if len(drifts) > 0 {
+		klog.Errorf("checkLv:: logical volume %s/%s drifted from spec: %s", lvConfig.VolumeGroup, lvConfig.Name, strings.Join(drifts, "; "))
+		return model.NewResourceResult(config.ResourceKindLogicalVolume, lvConfig.Name, model.ResourceFailed,
+			"LogicalVolumeDrifted", strings.Join(drifts, "; "))
+	}
+	if lv.Size > expectSize {
+		klog.Errorf("checkLv:: refuse to shrink logical volume %s/%s from %d to %d", lvConfig.VolumeGroup, lvConfig.Name, lv.Size, expectSize)
+		return model.NewResourceResult(config.ResourceKindLogicalVolume, lvConfig.Name, model.ResourceFailed,
+			"ShrinkNotSupported", fmt.Sprintf("size is %d, shrinking to %d is not supported", lv.Size, expectSize))
+	}
+	if lv.Size < expectSize {
+		return lrm.growLv(lvConfig, lv, vg, expectSize)
+	}
+	if lrm.growFSPending[lvConfig.VolumeGroup+"/"+lvConfig.Name] {
+		return lrm.growFS(lvConfig, fmt.Sprintf("size is %d", lv.Size))
+	}
+	if len(missingTags) > 0 {
+		return model.NewResourceResult(config.ResourceKindLogicalVolume, lvConfig.Name, model.ResourceReady,
+			"TagsAdded", fmt.Sprintf("tags %v added", missingTags))
+	}
+	return model.NewResourceResult(config.ResourceKindLogicalVolume, lvConfig.Name, model.ResourceReady,
+		"LogicalVolumeReady", "")
+}
+
+// growLv extends logical volume to expectSize if volume group has enough free space, then grows its mounted filesystem.
+// The virtual size of thin logical volume takes no space of volume group.
+func (lrm *ResourceManager) growLv(lvConfig *LvConfig, lv *model.LV, vg *model.VG, expectSize uint64) *model.ResourceResult {
+	var out string
+	var err error
+	if lvConfig.Pool != "" {
+		out, err = lrm.lvmer.ExtendThinLV(lvConfig.VolumeGroup, lvConfig.Name, expectSize)
+	} else {
+		// every mirror takes the same space
+		required := (expectSize - lv.Size) * uint64(lvConfig.Mirrors+1)
+		if required > vg.FreeSize {
+			klog.Errorf("growLv:: volume group %s has %d free, growing %s needs %d", vg.Name, vg.FreeSize, lvConfig.Name, required)
+			return model.NewResourceResult(config.ResourceKindLogicalVolume, lvConfig.Name, model.ResourceFailed,
+				"InsufficientFreeSpace", fmt.Sprintf("growing from %d to %d needs %d bytes, volume group %s has %d free",
+					lv.Size, expectSize, required, vg.Name, vg.FreeSize))
+		}
+		out, err = lrm.lvmer.ExtendLV(lvConfig.VolumeGroup, lvConfig.Name, expectSize)
+		if err == nil {
+			vg.FreeSize -= required
+		}
+	}
+	if err != nil {
+		klog.Errorf("growLv:: extend logical volume %s/%s to %d error: %v", lvConfig.VolumeGroup, lvConfig.Name, expectSize, err)
+		return model.NewResourceResult(config.ResourceKindLogicalVolume, lvConfig.Name, model.ResourceFailed,
+			"ExtendLogicalVolumeFailed", err.Error())
+	}
+	klog.Infof("growLv:: Successful extend logical volume %s/%s to %d with out: %s", lvConfig.VolumeGroup, lvConfig.Name, expectSize, out)
+	lrm.growFSPending[lvConfig.VolumeGroup+"/"+lvConfig.Name] = true
+	return lrm.growFS(lvConfig, fmt.Sprintf("extended from %d to %d", lv.Size, expectSize))
+}
+
+// growFS grows the filesystem of logical volume if it is mounted, a failed one is retried in next reconcile
+func (lrm *ResourceManager) growFS(lvConfig *LvConfig, message string) *model.ResourceResult {
+	key := lvConfig.VolumeGroup + "/" + lvConfig.Name
+	target, err := lrm.mounter.GrowFS(filepath.Join("/dev", lvConfig.VolumeGroup, lvConfig.Name))
+	if err != nil {
+		klog.Errorf("growFS:: grow filesystem of %s error: %v", key, err)
+		return model.NewResourceResult(config.ResourceKindLogicalVolume, lvConfig.Name, model.ResourceFailed,
+			"GrowFilesystemFailed", fmt.Sprintf("%s, grow filesystem error: %v", message, err))
+	}
+	delete(lrm.growFSPending, key)
+	if target != "" {
+		message = fmt.Sprintf("%s, filesystem at %s grown", message, target)
+	}
+	return model.NewResourceResult(config.ResourceKindLogicalVolume, lvConfig.Name, model.ResourceReady,
+		"LogicalVolumeExtended", message)
+}
+
+// RecordStatus record all visible logical volumes in current node into status
+func (lrm *ResourceManager) RecordStatus(status *v1alpha1.NodeLocalResourceStatus) error {
+	vgs, err := lrm.lvmer.ListVG()
+	if err != nil {
+		klog.Errorf("RecordStatus:: list volume groups error: %v", err)
+		return err
+	}
+	lvStatusList := []v1alpha1.LogicalVolumeStatus{}
+	for _, vg := range vgs {
+		lvs, err := lrm.listLV(vg.Name)
+		if err != nil {
+			klog.Errorf("RecordStatus:: list logical volumes of %s error: %v", vg.Name, err)
+			return err
+		}
+		for _, lv := range lvs {
+			lvStatus := v1alpha1.LogicalVolumeStatus{
+				Name:        lv.Name,
+				VolumeGroup: vg.Name,
+				Size:        int64(lv.Size),
+			}
+			for _, tag := range lv.Tags {
+				if tag != "" {
+					lvStatus.Tags = append(lvStatus.Tags, tag)
+				}
+			}
+			lvStatusList = append(lvStatusList, lvStatus)
+		}
+	}
+	sort.Slice(lvStatusList, func(i, j int) bool {
+		if lvStatusList[i].VolumeGroup != lvStatusList[j].VolumeGroup {
+			return lvStatusList[i].VolumeGroup < lvStatusList[j].VolumeGroup
+		}
+		return lvStatusList[i].Name < lvStatusList[j].Name
+	})
+	status.LogicalVolumes = lvStatusList
+	return nil
+}
+
+// isMirrored returns true if logical volume is mirror or raid
+func isMirrored(lv *model.LV) bool {
+	switch lv.Attributes.Type {
+	case model.VolumeTypeMirrored, model.VolumeTypeMirroredWithoutSync, model.VolumeTypeRAID, model.VolumeTypeRAIDWithoutSync:
+		return true
+	}
+	return false
+}
+
+// hasVgTag returns true if volume group has tag
+func hasVgTag(vg *model.VG, tag string) bool {
+	for _, t := range vg.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// hasTag returns true if logical volume has tag
+func hasTag(lv *model.LV, tag string) bool {
+	for _, t := range lv.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
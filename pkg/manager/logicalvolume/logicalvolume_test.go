@@ -0,0 +1,295 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logicalvolume
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/openyurtio/node-resource-manager/pkg/apis/nrm/v1alpha1"
+	"github.com/openyurtio/node-resource-manager/pkg/config"
+	"github.com/openyurtio/node-resource-manager/pkg/model"
+	"github.com/openyurtio/node-resource-manager/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func makeLV(name string, size uint64, lvType model.VolumeType, tags ...string) *model.LV {
+	if len(tags) == 0 {
+		tags = []string{""}
+	}
+	return &model.LV{Name: name, Size: size, Attributes: model.LVAttributes{Type: lvType}, Tags: tags}
+}
+
+func TestAnalyseConfigMap(t *testing.T) {
+	config.GlobalConfigVar.NodeInfo = &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "foo",
+			Labels: map[string]string{"bar": "foo"},
+		},
+	}
+	configDir, err := ioutil.TempDir("", "logicalvolume")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(configDir)
+
+	lvList := LvList{LogicalVolumes: []model.ResourceYaml{
+		{Name: "lv1", Key: "bar", Operator: metav1.LabelSelectorOpIn, Value: "foo",
+			Topology: model.Topology{VolumeGroup: "volumegroup1", Size: "10Gi", Tags: []string{"foo"}}},
+		{Name: "lv2", Key: "bar", Operator: metav1.LabelSelectorOpIn, Value: "foo",
+			Topology: model.Topology{VolumeGroup: "volumegroup1", Size: "ten"}},
+		{Name: "lv3", Key: "bar", Operator: metav1.LabelSelectorOpNotIn, Value: "foo",
+			Topology: model.Topology{VolumeGroup: "volumegroup1", Size: "1Gi"}},
+	}}
+	d, err := yaml.Marshal(&lvList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(configDir, config.ResourceKindLogicalVolume), d, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	resourceManager := &ResourceManager{source: &config.FileSource{ConfigDir: configDir}}
+	assert.Nil(t, resourceManager.AnalyseConfigMap())
+	assert.Equal(t, []*LvConfig{{Name: "lv1", VolumeGroup: "volumegroup1", Size: 10 * 1024 * 1024 * 1024, Tags: []string{"foo"}}}, resourceManager.LogicalVolumes)
+	assert.Equal(t, 1, len(resourceManager.invalid))
+	assert.Equal(t, "lv2", resourceManager.invalid[0].Name)
+	assert.Equal(t, "InvalidSize", resourceManager.invalid[0].Reason)
+}
+
+func TestApplyResourceDiff(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+	mockLVM := utils.NewMockLVM(mockCtl)
+	resourceManager := &ResourceManager{
+		lvmer: mockLVM,
+		LogicalVolumes: []*LvConfig{
+			{Name: "lv1", VolumeGroup: "volumegroup1", Size: 1024 * 1024 * 1024, Tags: []string{"foo"}},
+			{Name: "lv2", VolumeGroup: "volumegroup1", Size: 1000, Tags: []string{"foo"}},
+			{Name: "lv3", VolumeGroup: "volumegroup1", Size: 1024 * 1024 * 1024, Mirrors: 1},
+			{Name: "lv4", VolumeGroup: "volumegroup1", Size: 1024 * 1024 * 1024, Tags: []string{"foo"}},
+			{Name: "lv5", VolumeGroup: "volumegroup2", Size: 1024 * 1024 * 1024},
+		},
+	}
+
+	gomock.InOrder(
+		mockLVM.EXPECT().ListVG().Return([]*model.VG{{Name: "volumegroup1", Tags: []string{utils.ManagedTagName}}}, nil),
+		mockLVM.EXPECT().ListLV(gomock.Eq("volumegroup1")).Return([]*model.LV{
+			makeLV("lv2", 4*1024*1024, '-'),
+			makeLV("lv3", 2*1024*1024*1024, '-'),
+			makeLV("lv4", 1024*1024*1024, '-', utils.ProtectedTagName),
+			makeLV("[lv1_rimage_0]", 1024*1024*1024, 'i'),
+		}, nil),
+		mockLVM.EXPECT().CreateLV(gomock.Eq("volumegroup1"), gomock.Eq("lv1"), gomock.Eq(uint64(1024*1024*1024)), gomock.Eq(uint32(0)), gomock.Eq([]string{"foo"})).Return("", nil),
+		mockLVM.EXPECT().AddTagLV(gomock.Eq("volumegroup1"), gomock.Eq("lv2"), gomock.Eq([]string{"foo"})).Return("", nil),
+	)
+	results, err := resourceManager.ApplyResourceDiff()
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(results))
+	assert.Equal(t, model.ResourceReady, results[0].Condition)
+	assert.Equal(t, "LogicalVolumeCreated", results[0].Reason)
+	// size is rounded up to extent
+	assert.Equal(t, model.ResourceReady, results[1].Condition)
+	assert.Equal(t, "TagsAdded", results[1].Reason)
+	assert.Equal(t, model.ResourceFailed, results[2].Condition)
+	assert.Equal(t, "LogicalVolumeDrifted", results[2].Reason)
+	assert.Equal(t, "mirrored is false, expect 1 mirrors", results[2].Message)
+	// protected logical volume is never changed
+	assert.Equal(t, model.ResourceFailed, results[3].Condition)
+	assert.Equal(t, "protected, missing tags [foo]", results[3].Message)
+	assert.Equal(t, model.ResourceProgressing, results[4].Condition)
+	assert.Equal(t, "VolumeGroupNotReady", results[4].Reason)
+}
+
+func TestApplyResourceDiffNotOwned(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+	mockLVM := utils.NewMockLVM(mockCtl)
+	resourceManager := &ResourceManager{
+		lvmer: mockLVM,
+		LogicalVolumes: []*LvConfig{
+			{Name: "lv1", VolumeGroup: "volumegroup1", Size: 1024 * 1024 * 1024},
+			{Name: "lv2", VolumeGroup: "volumegroup1", Size: 2 * 1024 * 1024 * 1024},
+		},
+	}
+
+	// volume group created by hand is never changed, neither logical volume created nor grown in it
+	mockLVM.EXPECT().ListVG().Return([]*model.VG{{Name: "volumegroup1", FreeSize: 10 * 1024 * 1024 * 1024, Tags: []string{"foo"}}}, nil)
+	results, err := resourceManager.ApplyResourceDiff()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(results))
+	for _, result := range results {
+		assert.Equal(t, model.ResourceFailed, result.Condition)
+		assert.Equal(t, "VolumeGroupNotOwned", result.Reason)
+		assert.Equal(t, "volume group volumegroup1 is not managed by node-resource-manager", result.Message)
+	}
+}
+
+func TestApplyResourceDiffExtentSize(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+	mockLVM := utils.NewMockLVM(mockCtl)
+	mib := uint64(1024 * 1024)
+	resourceManager := &ResourceManager{
+		lvmer: mockLVM,
+		LogicalVolumes: []*LvConfig{
+			{Name: "lv1", VolumeGroup: "volumegroup1", Size: 10 * mib},
+			{Name: "lv2", VolumeGroup: "volumegroup1", Size: 40 * mib},
+		},
+	}
+
+	// lvm rounds sizes up to the 32MiB extent of volume group, which is not a shrink or growth
+	gomock.InOrder(
+		mockLVM.EXPECT().ListVG().Return([]*model.VG{{Name: "volumegroup1", ExtentSize: 32 * mib, Tags: []string{utils.ManagedTagName}}}, nil),
+		mockLVM.EXPECT().ListLV(gomock.Eq("volumegroup1")).Return([]*model.LV{
+			makeLV("lv1", 32*mib, '-'),
+			makeLV("lv2", 64*mib, '-'),
+		}, nil),
+	)
+	results, err := resourceManager.ApplyResourceDiff()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(results))
+	for _, result := range results {
+		assert.Equal(t, model.ResourceReady, result.Condition)
+		assert.Equal(t, "LogicalVolumeReady", result.Reason)
+	}
+}
+
+func TestApplyResourceDiffThin(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+	mockLVM := utils.NewMockLVM(mockCtl)
+	resourceManager := &ResourceManager{
+		lvmer: mockLVM,
+		LogicalVolumes: []*LvConfig{
+			{Name: "lv1", VolumeGroup: "volumegroup1", Size: 20 * 1024 * 1024 * 1024, Pool: "thinpool"},
+			{Name: "lv2", VolumeGroup: "volumegroup1", Size: 1024 * 1024 * 1024, Pool: "thinpool"},
+			{Name: "lv3", VolumeGroup: "volumegroup1", Size: 1024 * 1024 * 1024, Pool: "pool2"},
+		},
+	}
+
+	gomock.InOrder(
+		mockLVM.EXPECT().ListVG().Return([]*model.VG{{Name: "volumegroup1", Tags: []string{utils.ManagedTagName}}}, nil),
+		mockLVM.EXPECT().ListLV(gomock.Eq("volumegroup1")).Return([]*model.LV{
+			makeLV("thinpool", 10*1024*1024*1024, model.VolumeTypeThinPool),
+			makeLV("lv2", 1024*1024*1024, '-'),
+			makeLV("[thinpool_tdata]", 10*1024*1024*1024, model.VolumeTypeThinPoolData),
+		}, nil),
+		// thin logical volume can be larger than its pool
+		mockLVM.EXPECT().CreateThinLV(gomock.Eq("volumegroup1"), gomock.Eq("thinpool"), gomock.Eq("lv1"), gomock.Eq(uint64(20*1024*1024*1024)), gomock.Nil()).Return("", nil),
+	)
+	results, err := resourceManager.ApplyResourceDiff()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(results))
+	assert.Equal(t, "LogicalVolumeCreated", results[0].Reason)
+	assert.Equal(t, "LogicalVolumeDrifted", results[1].Reason)
+	assert.Equal(t, `thin is false, expect pool "thinpool"`, results[1].Message)
+	assert.Equal(t, model.ResourceProgressing, results[2].Condition)
+	assert.Equal(t, "ThinPoolNotReady", results[2].Reason)
+}
+
+func TestApplyResourceDiffResize(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+	mockLVM := utils.NewMockLVM(mockCtl)
+	mockMounter := utils.NewMockMounter(mockCtl)
+	gib := uint64(1024 * 1024 * 1024)
+	resourceManager := &ResourceManager{
+		lvmer:         mockLVM,
+		mounter:       mockMounter,
+		growFSPending: map[string]bool{},
+		LogicalVolumes: []*LvConfig{
+			{Name: "lv1", VolumeGroup: "volumegroup1", Size: 10 * gib},
+			{Name: "lv2", VolumeGroup: "volumegroup1", Size: 4 * gib, Mirrors: 1},
+			{Name: "lv3", VolumeGroup: "volumegroup1", Size: gib},
+			{Name: "lv4", VolumeGroup: "volumegroup1", Size: 2 * gib},
+			{Name: "lv5", VolumeGroup: "volumegroup1", Size: 100 * gib, Pool: "thinpool"},
+		},
+	}
+
+	gomock.InOrder(
+		mockLVM.EXPECT().ListVG().Return([]*model.VG{{Name: "volumegroup1", FreeSize: 4 * gib, Tags: []string{utils.ManagedTagName}}}, nil),
+		mockLVM.EXPECT().ListLV(gomock.Eq("volumegroup1")).Return([]*model.LV{
+			makeLV("lv1", 8*gib, '-'),
+			makeLV("lv2", 2*gib, model.VolumeTypeRAID),
+			makeLV("lv3", 2*gib, '-'),
+			makeLV("lv4", gib, '-', utils.ProtectedTagName),
+			makeLV("lv5", 50*gib, model.VolumeTypeThin),
+			makeLV("thinpool", 10*gib, model.VolumeTypeThinPool),
+		}, nil),
+		mockLVM.EXPECT().ExtendLV(gomock.Eq("volumegroup1"), gomock.Eq("lv1"), gomock.Eq(10*gib)).Return("", nil),
+		mockMounter.EXPECT().GrowFS(gomock.Eq("/dev/volumegroup1/lv1")).Return("/mnt/lv1", nil),
+		mockLVM.EXPECT().ExtendThinLV(gomock.Eq("volumegroup1"), gomock.Eq("lv5"), gomock.Eq(100*gib)).Return("", nil),
+		mockMounter.EXPECT().GrowFS(gomock.Eq("/dev/volumegroup1/lv5")).Return("", fmt.Errorf("resize2fs failed")),
+	)
+	results, err := resourceManager.ApplyResourceDiff()
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(results))
+	assert.Equal(t, "LogicalVolumeExtended", results[0].Reason)
+	assert.Equal(t, fmt.Sprintf("extended from %d to %d, filesystem at /mnt/lv1 grown", 8*gib, 10*gib), results[0].Message)
+	// lv1 took the free space, mirrored lv2 needs twice the growth
+	assert.Equal(t, "InsufficientFreeSpace", results[1].Reason)
+	assert.Equal(t, fmt.Sprintf("growing from %d to %d needs %d bytes, volume group volumegroup1 has %d free", 2*gib, 4*gib, 4*gib, 2*gib), results[1].Message)
+	assert.Equal(t, "ShrinkNotSupported", results[2].Reason)
+	assert.Equal(t, "LogicalVolumeDrifted", results[3].Reason)
+	assert.Equal(t, "GrowFilesystemFailed", results[4].Reason)
+
+	// filesystem failed to grow is retried
+	resourceManager.LogicalVolumes = resourceManager.LogicalVolumes[4:]
+	gomock.InOrder(
+		mockLVM.EXPECT().ListVG().Return([]*model.VG{{Name: "volumegroup1", Tags: []string{utils.ManagedTagName}}}, nil),
+		mockLVM.EXPECT().ListLV(gomock.Eq("volumegroup1")).Return([]*model.LV{
+			makeLV("lv5", 100*gib, model.VolumeTypeThin),
+			makeLV("thinpool", 10*gib, model.VolumeTypeThinPool),
+		}, nil),
+		mockMounter.EXPECT().GrowFS(gomock.Eq("/dev/volumegroup1/lv5")).Return("/mnt/lv5", nil),
+	)
+	results, err = resourceManager.ApplyResourceDiff()
+	assert.Nil(t, err)
+	assert.Equal(t, "LogicalVolumeExtended", results[0].Reason)
+	assert.Equal(t, 0, len(resourceManager.growFSPending))
+}
+
+func TestRecordStatus(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+	mockLVM := utils.NewMockLVM(mockCtl)
+	resourceManager := &ResourceManager{lvmer: mockLVM}
+
+	gomock.InOrder(
+		mockLVM.EXPECT().ListVG().Return([]*model.VG{{Name: "volumegroup2"}, {Name: "volumegroup1"}}, nil),
+		mockLVM.EXPECT().ListLV(gomock.Eq("volumegroup2")).Return([]*model.LV{makeLV("lv3", 300, 'r')}, nil),
+		mockLVM.EXPECT().ListLV(gomock.Eq("volumegroup1")).Return([]*model.LV{
+			makeLV("lv2", 200, '-', "foo", "protected"),
+			makeLV("lv1", 100, '-'),
+		}, nil),
+	)
+	status := &v1alpha1.NodeLocalResourceStatus{}
+	assert.Nil(t, resourceManager.RecordStatus(status))
+	assert.Equal(t, []v1alpha1.LogicalVolumeStatus{
+		{Name: "lv1", VolumeGroup: "volumegroup1", Size: 100},
+		{Name: "lv2", VolumeGroup: "volumegroup1", Size: 200, Tags: []string{"foo", "protected"}},
+		{Name: "lv3", VolumeGroup: "volumegroup2", Size: 300},
+	}, status.LogicalVolumes)
+}
@@ -0,0 +1,37 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logicalvolume
+
+import (
+	"github.com/openyurtio/node-resource-manager/pkg/model"
+)
+
+// LvConfig is one desired logical volume, Size is in bytes
+type LvConfig struct {
+	Name        string
+	VolumeGroup string
+	Size        uint64
+	Mirrors     uint32
+	Tags        []string
+	// Pool is the thin pool of thin logical volume, Size is the virtual size
+	Pool string
+}
+
+// LvList ...
+type LvList struct {
+	LogicalVolumes []model.ResourceYaml `yaml:"logicalvolume,omitempty"`
+}
@@ -20,10 +20,22 @@ import (
 	"context"
 	"time"
 
+	"github.com/openyurtio/node-resource-manager/pkg/apis/nrm/v1alpha1"
 	"github.com/openyurtio/node-resource-manager/pkg/config"
+	"github.com/openyurtio/node-resource-manager/pkg/health"
+	"github.com/openyurtio/node-resource-manager/pkg/manager/logicalvolume"
 	"github.com/openyurtio/node-resource-manager/pkg/manager/memory"
 	"github.com/openyurtio/node-resource-manager/pkg/manager/quotapath"
 	"github.com/openyurtio/node-resource-manager/pkg/manager/volumegroup"
+	"github.com/openyurtio/node-resource-manager/pkg/metrics"
+	"github.com/openyurtio/node-resource-manager/pkg/model"
+	"github.com/openyurtio/node-resource-manager/pkg/utils"
+	v1 "k8s.io/api/core/v1"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 	klog "k8s.io/klog/v2"
 )
@@ -31,7 +43,10 @@ import (
 // Manager interface define local resource manager's action
 type Manager interface {
 	AnalyseConfigMap() error
-	ApplyResourceDiff() error
+	// ApplyResourceDiff apply desired resources of current node, returns the result of every desired resource
+	ApplyResourceDiff() ([]*model.ResourceResult, error)
+	// RecordStatus fill the actual resources of current node into status
+	RecordStatus(status *v1alpha1.NodeLocalResourceStatus) error
 }
 
 // UnifiedResourceManager is global resource manager struct
@@ -39,16 +54,57 @@ type UnifiedResourceManager struct {
 	KubeClientSet  *kubernetes.Clientset
 	UpdateInterval int
 	NodeID         string
+	// node is the node info on startup, NodeInfo of global config is refreshed by controller in another goroutine
+	node   *v1.Node
+	source config.TopologySource
+	rms    map[string]Manager
+	// plan is not nil in dry-run mode, collects the actions instead of executing them
+	plan *utils.Plan
+	// drifts is not nil in audit mode, keeps the drifts found by auditors
+	drifts *driftStore
+	// Health tracks the progress of reconcile loop
+	Health *health.Checker
+	// cancel kills the running commands of managers
+	cancel context.CancelFunc
 }
 
+// ResourceKinds is the order of managers in plan
+var ResourceKinds = []string{config.ResourceKindVolumeGroup, config.ResourceKindLogicalVolume, config.ResourceKindQuotaPath, config.ResourceKindMemory}
+
 // NewDriver create a cpfs driver object
-func NewManager(nodeID, cmName, cmNameSpace string, updateInterval int, masterURL, kubeconfig string) *UnifiedResourceManager {
+func NewManager(nodeID, cmName, cmNameSpace, configSource string, updateInterval, healthTimeout, commandTimeout int, dryRun, audit bool, masterURL, kubeconfig string) *UnifiedResourceManager {
 	manager := &UnifiedResourceManager{
 		NodeID:         nodeID,
 		UpdateInterval: updateInterval,
+		Health:         health.NewChecker(ResourceKinds, time.Duration(healthTimeout)*time.Second),
 	}
 	// Config GlobalVar
 	config.GlobalConfigSet(nodeID, masterURL, kubeconfig)
+	manager.KubeClientSet = config.GlobalConfigVar.KubeClient
+	manager.node = config.GlobalConfigVar.NodeInfo
+
+	source, err := config.NewTopologySource(configSource, config.GlobalConfigVar.NrmClient)
+	if err != nil {
+		klog.Fatalf("NewManager:: create topology source error: %v", err)
+	}
+	manager.source = source
+	if dryRun {
+		manager.plan = utils.NewPlan()
+	}
+	if audit {
+		manager.drifts = newDriftStore()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	manager.cancel = cancel
+	executor := utils.NewExecutor(ctx, time.Duration(commandTimeout)*time.Second)
+	// a long mkfs or fsck within its timeout does not fail the liveness probe
+	manager.Health.TrackCommands(executor)
+	manager.rms = map[string]Manager{
+		config.ResourceKindVolumeGroup:   volumegroup.NewResourceManager(source, executor, manager.plan),
+		config.ResourceKindLogicalVolume: logicalvolume.NewResourceManager(source, executor, manager.plan),
+		config.ResourceKindQuotaPath:     quotapath.NewResourceManager(source, executor, manager.plan),
+		config.ResourceKindMemory:        memory.NewResourceManager(source, executor, manager.plan),
+	}
 
 	return manager
 }
@@ -60,54 +116,146 @@ func NewManager(nodeID, cmName, cmNameSpace string, updateInterval int, masterUR
 func (urm *UnifiedResourceManager) Run(stopCh <-chan struct{}) {
 	ctx := context.Background()
 
-	// Create UnifiedResource CR if not exist
-	urm.CreateUnifiedResourceCRD(ctx)
+	// Create UnifiedResource CRD if not exist, or update it to the schema of this version
+	if err := urm.CreateUnifiedResourceCRD(ctx); err != nil {
+		klog.Errorf("Run:: create unified resource CRD error: %v", err)
+	}
 
-	// Maintain VolumeGroup if set in configMap
-	go urm.BuildUnifiedResource()
+	// Kill the running commands on stop, so a hung tool does not block the shutdown
+	go func() {
+		<-stopCh
+		urm.cancel()
+	}()
 
 	// UpdateUnifiedStorage
-	// go wait.Until(urm.RecordUnifiedResources, time.Duration(urm.UpdateInterval)*time.Second, stopCh)
+	go wait.Until(urm.RecordUnifiedResources, time.Duration(urm.UpdateInterval)*time.Second, stopCh)
 
+	// Maintain resources defined in resource topology until stopped
 	klog.V(3).Infof("Starting to update node storage on %s...", urm.NodeID)
-	<-stopCh
+	if err := NewController(urm).Run(stopCh); err != nil {
+		klog.Errorf("Run:: run controller error: %v", err)
+		return
+	}
 	klog.V(3).Infof("Stop to update node storage...")
 }
 
-// CreateUnifiedResourceCRD ...
+// CreateUnifiedResourceCRD create NodeLocalResource and NodeResourceTopology CRD if not exist, or update them if outdated
 func (urm *UnifiedResourceManager) CreateUnifiedResourceCRD(ctx context.Context) error {
+	for _, crd := range []*apiextv1.CustomResourceDefinition{nodeLocalResourceCRD(), nodeResourceTopologyCRD()} {
+		if err := ensureCRD(ctx, crd); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// BuildUnifiedResource ...
-func (urm *UnifiedResourceManager) BuildUnifiedResource() {
-	klog.Infof("BuildUnifiedResource:: Starting to maintain unified storage...")
-	rms := []Manager{volumegroup.NewResourceManager(), quotapath.NewResourceManager(), memory.NewResourceManager()}
+// ensureCRD create CRD if not exist, update it if its versions differ, and wait it to be established
+func ensureCRD(ctx context.Context, crd *apiextv1.CustomResourceDefinition) error {
+	crdClient := config.GlobalConfigVar.APIExtClient.ApiextensionsV1().CustomResourceDefinitions()
+	existing, err := crdClient.Get(ctx, crd.Name, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		_, err = crdClient.Create(ctx, crd, metav1.CreateOptions{})
+		if err != nil && !apierrors.IsAlreadyExists(err) {
+			return err
+		}
+		klog.Infof("ensureCRD:: create crd %s successful", crd.Name)
+	} else if !equality.Semantic.DeepEqual(existing.Spec.Versions, crd.Spec.Versions) {
+		// a CRD created by an older version lacks the new fields, which are pruned from the objects
+		existing.Spec = crd.Spec
+		if _, err = crdClient.Update(ctx, existing, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+		klog.Infof("ensureCRD:: update crd %s successful", crd.Name)
+	}
 
-	for {
-		for _, rm := range rms {
-			err := BuildResource(rm)
-			if err != nil {
-				continue
+	return wait.PollImmediate(time.Second, 30*time.Second, func() (bool, error) {
+		crd, err := crdClient.Get(ctx, crd.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		for _, cond := range crd.Status.Conditions {
+			if cond.Type == apiextv1.Established && cond.Status == apiextv1.ConditionTrue {
+				return true, nil
 			}
 		}
-		time.Sleep(time.Duration(20) * time.Second)
-	}
+		return false, nil
+	})
 }
 
 // BuildResource ...
-func BuildResource(m Manager) error {
+func BuildResource(m Manager) ([]*model.ResourceResult, error) {
 
 	// Get Desired VolumeGroup from ConfigMap
 	err := m.AnalyseConfigMap()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	return m.ApplyResourceDiff()
 
 }
 
-// Update Unified Storage CRD every internal seconds
+// RecordUnifiedResources update NodeLocalResource status every internal seconds
 func (urm *UnifiedResourceManager) RecordUnifiedResources() {
+	ctx := context.Background()
+	nlrClient := config.GlobalConfigVar.NrmClient.NodeLocalResources()
+
 	// Get Unified Storage Object
+	nlr, err := nlrClient.Get(ctx, urm.NodeID, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			klog.Errorf("RecordUnifiedResources:: get NodeLocalResource %s error: %v", urm.NodeID, err)
+			return
+		}
+		nlr, err = nlrClient.Create(ctx, newNodeLocalResource(urm.NodeID, urm.node), metav1.CreateOptions{})
+		if err != nil {
+			klog.Errorf("RecordUnifiedResources:: create NodeLocalResource %s error: %v", urm.NodeID, err)
+			return
+		}
+		klog.Infof("RecordUnifiedResources:: create NodeLocalResource %s successful", urm.NodeID)
+	}
+
+	// a failed manager only leaves its own section empty, e.g. pmem tools are absent on node
+	status := v1alpha1.NodeLocalResourceStatus{}
+	for _, rm := range urm.rms {
+		if err := rm.RecordStatus(&status); err != nil {
+			klog.Errorf("RecordUnifiedResources:: record status error: %v", err)
+		}
+	}
+	if urm.drifts != nil {
+		status.Drifts = urm.drifts.List()
+	}
+	status.LastUpdateTime = metav1.Now()
+	metrics.RecordNodeResources(&status)
+
+	nlr.Status = status
+	_, err = nlrClient.UpdateStatus(ctx, nlr, metav1.UpdateOptions{})
+	if err != nil {
+		klog.Errorf("RecordUnifiedResources:: update NodeLocalResource %s status error: %v", urm.NodeID, err)
+		return
+	}
+	klog.V(3).Infof("RecordUnifiedResources:: update NodeLocalResource %s status successful", urm.NodeID)
+}
+
+// newNodeLocalResource returns a NodeLocalResource owned by node, so it is removed with the node
+func newNodeLocalResource(nodeID string, node *v1.Node) *v1alpha1.NodeLocalResource {
+	nlr := &v1alpha1.NodeLocalResource{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: nodeID,
+		},
+		Spec: v1alpha1.NodeLocalResourceSpec{
+			NodeName: nodeID,
+		},
+	}
+	if node != nil {
+		nlr.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: "v1",
+			Kind:       "Node",
+			Name:       node.Name,
+			UID:        node.UID,
+		}}
+	}
+	return nlr
 }
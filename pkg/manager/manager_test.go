@@ -0,0 +1,102 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openyurtio/node-resource-manager/pkg/config"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newFakeAPIExtClient returns a clientset whose created CRDs are established at once
+func newFakeAPIExtClient(objects ...runtime.Object) *apiextfake.Clientset {
+	client := apiextfake.NewSimpleClientset(objects...)
+	client.PrependReactor("create", "customresourcedefinitions", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		crd := action.(k8stesting.CreateAction).GetObject().(*apiextv1.CustomResourceDefinition)
+		crd.Status.Conditions = establishedConditions()
+		return false, nil, nil
+	})
+	return client
+}
+
+func establishedConditions() []apiextv1.CustomResourceDefinitionCondition {
+	return []apiextv1.CustomResourceDefinitionCondition{{Type: apiextv1.Established, Status: apiextv1.ConditionTrue}}
+}
+
+func TestCreateUnifiedResourceCRD(t *testing.T) {
+	client := newFakeAPIExtClient()
+	config.GlobalConfigVar.APIExtClient = client
+	defer func() { config.GlobalConfigVar.APIExtClient = nil }()
+
+	urm := &UnifiedResourceManager{}
+	assert.Nil(t, urm.CreateUnifiedResourceCRD(context.Background()))
+
+	for _, want := range []*apiextv1.CustomResourceDefinition{nodeLocalResourceCRD(), nodeResourceTopologyCRD()} {
+		crd, err := client.ApiextensionsV1().CustomResourceDefinitions().Get(context.Background(), want.Name, metav1.GetOptions{})
+		assert.Nil(t, err)
+		assert.Equal(t, want.Spec, crd.Spec)
+	}
+}
+
+func TestCreateUnifiedResourceCRDUpdateOutdated(t *testing.T) {
+	outdated := nodeLocalResourceCRD()
+	outdated.Spec.Versions[0].Schema = &apiextv1.CustomResourceValidation{
+		OpenAPIV3Schema: &apiextv1.JSONSchemaProps{Type: "object"},
+	}
+	outdated.Status.Conditions = establishedConditions()
+	current := nodeResourceTopologyCRD()
+	current.Status.Conditions = establishedConditions()
+	client := newFakeAPIExtClient(outdated, current)
+	config.GlobalConfigVar.APIExtClient = client
+	defer func() { config.GlobalConfigVar.APIExtClient = nil }()
+
+	urm := &UnifiedResourceManager{}
+	assert.Nil(t, urm.CreateUnifiedResourceCRD(context.Background()))
+
+	crd, err := client.ApiextensionsV1().CustomResourceDefinitions().Get(context.Background(), outdated.Name, metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, nodeLocalResourceCRD().Spec, crd.Spec)
+
+	// an up to date CRD is left untouched
+	updates := 0
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "update" {
+			updates++
+			assert.Equal(t, outdated.Name, action.(k8stesting.UpdateAction).GetObject().(*apiextv1.CustomResourceDefinition).Name)
+		}
+	}
+	assert.Equal(t, 1, updates)
+}
+
+func TestNewNodeLocalResource(t *testing.T) {
+	nlr := newNodeLocalResource("node1", nil)
+	assert.Equal(t, "node1", nlr.Name)
+	assert.Equal(t, "node1", nlr.Spec.NodeName)
+	assert.Empty(t, nlr.OwnerReferences)
+
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1", UID: "uid1"}}
+	nlr = newNodeLocalResource("node1", node)
+	assert.Equal(t, []metav1.OwnerReference{{APIVersion: "v1", Kind: "Node", Name: "node1", UID: "uid1"}}, nlr.OwnerReferences)
+}
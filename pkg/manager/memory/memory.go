@@ -17,56 +17,53 @@ limitations under the License.
 package memory
 
 import (
-	"io/ioutil"
-	"os"
+	"fmt"
+	"sort"
 
+	"github.com/openyurtio/node-resource-manager/pkg/apis/nrm/v1alpha1"
 	"github.com/openyurtio/node-resource-manager/pkg/config"
+	"github.com/openyurtio/node-resource-manager/pkg/model"
 	"github.com/openyurtio/node-resource-manager/pkg/utils"
-	"gopkg.in/yaml.v2"
 	"k8s.io/client-go/tools/record"
 	klog "k8s.io/klog/v2"
 )
 
 // ResourceManager ...
 type ResourceManager struct {
-	Memory     []*MConfig
-	pmem       utils.Pmemer
-	configPath string
-	recorder   record.EventRecorder
+	Memory   []*MConfig
+	pmem     utils.Pmemer
+	source   config.TopologySource
+	recorder record.EventRecorder
 }
 
-// NewResourceManager ...
-func NewResourceManager() *ResourceManager {
-	return &ResourceManager{
-		Memory:     []*MConfig{},
-		pmem:       utils.NewNodePmemer(),
-		configPath: "/etc/unified-config/memory",
-		recorder:   utils.NewEventRecorder(),
+// NewResourceManager create manager, commands are run by executor, changes are recorded into plan instead of executed if plan is not nil
+func NewResourceManager(source config.TopologySource, executor utils.CommandExecutor, plan *utils.Plan) *ResourceManager {
+	mrm := &ResourceManager{
+		Memory: []*MConfig{},
+		source: source,
 	}
+	if plan != nil {
+		// dry-run changes nothing on node and emits no event
+		mrm.pmem = utils.NewDryRunPmemer(utils.NewNodePmemer(executor), plan)
+		mrm.recorder = &record.FakeRecorder{}
+	} else {
+		mrm.pmem = utils.NewNodePmemer(executor)
+		mrm.recorder = utils.NewEventRecorder()
+	}
+	return mrm
 }
 
 // AnalyseConfigMap analyse memory resource config
 func (mrm *ResourceManager) AnalyseConfigMap() error {
 	memoryConfig := []*MConfig{}
-	memoryList := &MList{}
-
-	yamlFile, err := ioutil.ReadFile(mrm.configPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			klog.Errorf("memory config file %s not exist", mrm.configPath)
-			return nil
-		}
-		klog.Errorf("AnalyseConfigMap:: yamlFile.Get memory error %v", err)
-		return err
-	}
-	err = yaml.Unmarshal(yamlFile, memoryList)
+	memories, err := mrm.source.GetResources(config.ResourceKindMemory)
 	if err != nil {
-		klog.Errorf("AnalyseConfigMap:: parse yaml file error: %v", err)
+		klog.Errorf("AnalyseConfigMap:: get memory config error: %v", err)
 		return err
 	}
 
 	nodeInfo := config.GlobalConfigVar.NodeInfo
-	for _, memConfig := range memoryList.Memories {
+	for _, memConfig := range memories {
 		isMatched := utils.NodeFilter(memConfig.Operator, memConfig.Key, memConfig.Value, nodeInfo)
 		if isMatched {
 			conf := &MConfig{}
@@ -74,6 +71,7 @@ func (mrm *ResourceManager) AnalyseConfigMap() error {
 				klog.Errorf("AnalyseConfigMap:: regions has multi config %s", memConfig.Topology.Regions)
 				continue
 			}
+			conf.Name = memConfig.Name
 			conf.Region = memConfig.Topology.Regions[0]
 			conf.Type = memConfig.Topology.Type
 			memoryConfig = append(memoryConfig, conf)
@@ -83,35 +81,94 @@ func (mrm *ResourceManager) AnalyseConfigMap() error {
 	return nil
 }
 
-// ApplyResourceDiff apply memory resource to current node
-func (mrm *ResourceManager) ApplyResourceDiff() error {
+// ApplyResourceDiff apply memory resource to current node, returns the result of every expected memory
+func (mrm *ResourceManager) ApplyResourceDiff() ([]*model.ResourceResult, error) {
 	klog.Infof("ApplyResourceDiff: matched node resources mrm.Memory: %v", mrm.Memory)
+	results := []*model.ResourceResult{}
 	for _, memConfig := range mrm.Memory {
 		devicePath, _, err := mrm.pmem.GetPmemNamespaceDeivcePath(memConfig.Region, "devdax")
 		if err != nil {
 			err := mrm.pmem.CreateNamespace(memConfig.Region, "dax")
 			if err != nil {
 				klog.Errorf("applyResourceDiff:: create kmem namespace for region [%s], error: %v", memConfig.Region, err)
+				results = append(results, model.NewResourceResult(config.ResourceKindMemory, memConfig.Name, model.ResourceFailed,
+					"CreateNamespaceFailed", err.Error()))
 				continue
 			}
 			devicePath, _, err = mrm.pmem.GetPmemNamespaceDeivcePath(memConfig.Region, "devdax")
 			if err != nil {
 				klog.Errorf("applyResourceDiff:: list kmem namespace for region [%s], error: %v", memConfig.Region, err)
+				results = append(results, model.NewResourceResult(config.ResourceKindMemory, memConfig.Name, model.ResourceProgressing,
+					"NamespaceNotReady", err.Error()))
 				continue
 			}
 		}
 		isCreated, err := mrm.pmem.CheckKMEMCreated(devicePath[5:])
 		if err != nil {
 			klog.Errorf("applyResourceDiff:: check kmem create error: %v", err)
+			results = append(results, model.NewResourceResult(config.ResourceKindMemory, memConfig.Name, model.ResourceFailed,
+				"CheckKMEMFailed", err.Error()))
 			continue
 		}
 		if !isCreated {
 			err := mrm.pmem.MakeNamespaceMemory(devicePath[5:])
 			if err != nil {
 				klog.Errorf("applyRegionQuotaPath:: make kmem memory failed %v", err)
+				results = append(results, model.NewResourceResult(config.ResourceKindMemory, memConfig.Name, model.ResourceFailed,
+					"MakeKMEMFailed", err.Error()))
 				continue
 			}
+			results = append(results, model.NewResourceResult(config.ResourceKindMemory, memConfig.Name, model.ResourceReady,
+				"MemoryOnlined", fmt.Sprintf("%s onlined as system memory", devicePath)))
+			continue
+		}
+		results = append(results, model.NewResourceResult(config.ResourceKindMemory, memConfig.Name, model.ResourceReady,
+			"MemoryReady", ""))
+	}
+	return results, nil
+}
+
+// RecordStatus record all pmem regions, and pmem devices onlined as system memory into status
+func (mrm *ResourceManager) RecordStatus(status *v1alpha1.NodeLocalResourceStatus) error {
+	regions, err := mrm.pmem.GetRegions()
+	if err != nil {
+		klog.Errorf("RecordStatus:: get pmem regions error: %v", err)
+		return err
+	}
+	regionStatusList := []v1alpha1.PmemRegionStatus{}
+	for _, region := range regions.Regions {
+		regionStatus := v1alpha1.PmemRegionStatus{
+			Name:          region.Dev,
+			Size:          region.Size,
+			AvailableSize: region.AvailableSize,
+		}
+		for _, namespace := range region.Namespaces {
+			regionStatus.Namespaces = append(regionStatus.Namespaces, namespace.Dev)
+		}
+		regionStatusList = append(regionStatusList, regionStatus)
+	}
+	sort.Slice(regionStatusList, func(i, j int) bool { return regionStatusList[i].Name < regionStatusList[j].Name })
+	status.PmemRegions = regionStatusList
+
+	memList, err := mrm.pmem.ListDaxMemory()
+	if err != nil {
+		klog.Errorf("RecordStatus:: list dax devices error: %v", err)
+		return err
+	}
+	memoryStatusList := []v1alpha1.MemoryStatus{}
+	for _, mem := range memList {
+		if mem.Mode != "system-ram" {
+			continue
 		}
+		memoryStatusList = append(memoryStatusList, v1alpha1.MemoryStatus{
+			Region:     utils.ConvertDaxChardev2Region(mem.Chardev),
+			Chardev:    mem.Chardev,
+			Size:       mem.Size,
+			TargetNode: mem.TargetNode,
+			Movable:    mem.Movable,
+		})
 	}
+	sort.Slice(memoryStatusList, func(i, j int) bool { return memoryStatusList[i].Chardev < memoryStatusList[j].Chardev })
+	status.Memories = memoryStatusList
 	return nil
 }
@@ -25,6 +25,7 @@ import (
 	"testing"
 
 	"github.com/golang/mock/gomock"
+	"github.com/openyurtio/node-resource-manager/pkg/apis/nrm/v1alpha1"
 	"github.com/openyurtio/node-resource-manager/pkg/config"
 	"github.com/openyurtio/node-resource-manager/pkg/model"
 	"github.com/openyurtio/node-resource-manager/pkg/utils"
@@ -74,7 +75,7 @@ func EnsureVolumeGroupEnv() (string, error, *ResourceManager) {
 
 	newMockVolumegroupResourceManager := func() *ResourceManager {
 		return &ResourceManager{
-			configPath: configPath,
+			source: &config.FileSource{ConfigDir: filepath.Dir(configPath)},
 		}
 	}
 	return configPath, nil, newMockVolumegroupResourceManager()
@@ -121,6 +122,36 @@ func TestAnalyseConfigMap(t *testing.T) {
 		mockPmemer.EXPECT().GetPmemNamespaceDeivcePath(gomock.Eq("region0"), gomock.Eq("devdax")).Return("/dev/dax0.0", "", nil),
 		mockPmemer.EXPECT().CheckKMEMCreated(gomock.Eq("dax0.0")).Return(true, nil),
 	)
-	assert.Nil(t, resourceManager.ApplyResourceDiff())
+	results, err := resourceManager.ApplyResourceDiff()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(results))
+	assert.Equal(t, "foo", results[0].Name)
+	assert.Equal(t, model.ResourceReady, results[0].Condition)
 
 }
+
+func TestRecordStatus(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+	mockPmemer := utils.NewMockPmemer(mockCtl)
+	resourceManager := &ResourceManager{pmem: mockPmemer}
+
+	gomock.InOrder(
+		mockPmemer.EXPECT().GetRegions().Return(&model.PmemRegions{Regions: []model.PmemRegion{
+			{Dev: "region1", Size: 200, AvailableSize: 200},
+			{Dev: "region0", Size: 100, Namespaces: []model.PmemNameSpace{{Dev: "namespace0.0", Mode: "devdax", CharDev: "dax0.0"}}},
+		}}, nil),
+		mockPmemer.EXPECT().ListDaxMemory().Return([]*model.DaxctrlMem{
+			{Chardev: "dax0.0", Size: 100, TargetNode: 1, Mode: "system-ram"},
+		}, nil),
+	)
+	status := &v1alpha1.NodeLocalResourceStatus{}
+	assert.Nil(t, resourceManager.RecordStatus(status))
+	assert.Equal(t, []v1alpha1.PmemRegionStatus{
+		{Name: "region0", Size: 100, Namespaces: []string{"namespace0.0"}},
+		{Name: "region1", Size: 200, AvailableSize: 200},
+	}, status.PmemRegions)
+	assert.Equal(t, []v1alpha1.MemoryStatus{
+		{Region: "region0", Chardev: "dax0.0", Size: 100, TargetNode: 1},
+	}, status.Memories)
+}
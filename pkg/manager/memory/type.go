@@ -22,6 +22,7 @@ import (
 
 // MConfig ...
 type MConfig struct {
+	Name   string
 	Type   string
 	Region string
 }
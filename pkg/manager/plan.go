@@ -0,0 +1,98 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/openyurtio/node-resource-manager/pkg/model"
+)
+
+const (
+	// PlanOutputText prints plan in human readable text
+	PlanOutputText = "text"
+	// PlanOutputJSON prints plan in json
+	PlanOutputJSON = "json"
+)
+
+// PlanResult is the actions would be taken on node, and the expected result of every resource
+type PlanResult struct {
+	Node      string                  `json:"node"`
+	Actions   []model.Action          `json:"actions"`
+	Resources []*model.ResourceResult `json:"resources"`
+}
+
+// Plan analyses resource topology and diffs it with node once, nothing is executed.
+// Must be used with a manager created in dry-run mode.
+func (urm *UnifiedResourceManager) Plan() (*PlanResult, error) {
+	if urm.plan == nil {
+		return nil, fmt.Errorf("plan is only supported in dry-run mode")
+	}
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	if err := urm.source.Start(stopCh); err != nil {
+		return nil, fmt.Errorf("start resource topology source error: %v", err)
+	}
+
+	result := &PlanResult{Node: urm.NodeID, Actions: []model.Action{}, Resources: []*model.ResourceResult{}}
+	for _, kind := range ResourceKinds {
+		resources, err := BuildResource(urm.rms[kind])
+		if err != nil {
+			return nil, fmt.Errorf("plan %s error: %v", kind, err)
+		}
+		result.Actions = append(result.Actions, urm.plan.Take(kind)...)
+		result.Resources = append(result.Resources, resources...)
+	}
+	return result, nil
+}
+
+// PrintPlan writes plan to w in output format
+func PrintPlan(w io.Writer, plan *PlanResult, output string) error {
+	switch output {
+	case PlanOutputJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(plan)
+	case PlanOutputText:
+		if len(plan.Actions) == 0 {
+			fmt.Fprintf(w, "No changes on node %s.\n", plan.Node)
+		} else {
+			fmt.Fprintf(w, "Plan: %d actions on node %s\n", len(plan.Actions), plan.Node)
+			for i, action := range plan.Actions {
+				fmt.Fprintf(w, "%3d. [%s] %s\n", i+1, action.Kind, action.Command)
+			}
+		}
+		if len(plan.Resources) != 0 {
+			fmt.Fprintf(w, "\nResources:\n")
+			for _, resource := range plan.Resources {
+				line := fmt.Sprintf("  %s %s: %s", resource.Kind, resource.Name, resource.Condition)
+				if resource.Reason != "" {
+					line += fmt.Sprintf(" (%s)", resource.Reason)
+				}
+				if resource.Message != "" {
+					line += ", " + resource.Message
+				}
+				fmt.Fprintln(w, line)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format: %s", output)
+	}
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quotapath
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/openyurtio/node-resource-manager/pkg/apis/nrm/v1alpha1"
+	"github.com/openyurtio/node-resource-manager/pkg/config"
+	klog "k8s.io/klog/v2"
+)
+
+// Audit reports the project quota mounts on node not in topology,
+// and the quota paths mounted with fstype or mount options different from topology
+func (qrm *ResourceManager) Audit() ([]v1alpha1.DriftStatus, error) {
+	mountPoints, err := qrm.mounter.ListHostMounts()
+	if err != nil {
+		klog.Errorf("Audit:: list host mounts error: %v", err)
+		return nil, err
+	}
+	drifts := []v1alpha1.DriftStatus{}
+	for _, mountPoint := range mountPoints {
+		qpConfig := qrm.quotaPathConfig(mountPoint.Path)
+		if qpConfig == nil {
+			if isProjectQuotaMount(mountPoint.Opts) {
+				drifts = append(drifts, v1alpha1.DriftStatus{Kind: config.ResourceKindQuotaPath, Name: mountPoint.Path,
+					Reason: "NotInTopology", Message: fmt.Sprintf("mounted from %s with project quota", mountPoint.Device)})
+			}
+			continue
+		}
+
+		expectFstype := qpConfig.Fstype
+		if expectFstype == "" {
+			expectFstype = "ext4"
+		}
+		if mountPoint.Type != expectFstype {
+			drifts = append(drifts, v1alpha1.DriftStatus{Kind: config.ResourceKindQuotaPath, Name: mountPoint.Path,
+				Reason: "FstypeMismatch", Message: fmt.Sprintf("fstype is %s, expect %s", mountPoint.Type, expectFstype)})
+		}
+		if missing := missingMountOptions(qpConfig.Options, mountPoint.Opts); len(missing) > 0 {
+			drifts = append(drifts, v1alpha1.DriftStatus{Kind: config.ResourceKindQuotaPath, Name: mountPoint.Path,
+				Reason: "MountOptionsMismatch", Message: fmt.Sprintf("mount options %v are missing in %s", missing, strings.Join(mountPoint.Opts, ","))})
+		}
+	}
+	return drifts, nil
+}
+
+// missingMountOptions returns the options in comma separated expect not in actual, defaults is ignored,
+// and project quota options are aliases as xfs shows pquota as prjquota
+func missingMountOptions(expect string, actual []string) []string {
+	actualOptions := map[string]bool{}
+	for _, option := range actual {
+		actualOptions[option] = true
+	}
+	missing := []string{}
+	for _, option := range strings.Split(expect, ",") {
+		if option == "" || option == "defaults" || actualOptions[option] {
+			continue
+		}
+		if isProjectQuotaMount([]string{option}) && isProjectQuotaMount(actual) {
+			continue
+		}
+		missing = append(missing, option)
+	}
+	return missing
+}
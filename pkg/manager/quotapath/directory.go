@@ -0,0 +1,274 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quotapath
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/openyurtio/node-resource-manager/pkg/apis/nrm/v1alpha1"
+	"github.com/openyurtio/node-resource-manager/pkg/config"
+	"github.com/openyurtio/node-resource-manager/pkg/model"
+	"github.com/openyurtio/node-resource-manager/pkg/utils"
+	"k8s.io/apimachinery/pkg/api/resource"
+	klog "k8s.io/klog/v2"
+	k8smount "k8s.io/utils/mount"
+)
+
+const (
+	// minProjectID is the first project id allocated to directories, lower ids are left to the administrator
+	minProjectID = 10000
+)
+
+// parseDirectories converts the directories of quota path topology to config, block limits are rounded up to KiB
+func parseDirectories(mountPath string, directories []model.QuotaDirectory) ([]*DirectoryConfig, error) {
+	dirConfigs := []*DirectoryConfig{}
+	for _, directory := range directories {
+		name := directory.Name
+		if name == "" || name == "." || filepath.IsAbs(name) || filepath.Clean(name) != name || name == ".." || strings.HasPrefix(name, "../") {
+			return nil, fmt.Errorf("invalid directory %q, must be a clean relative path in quota path", name)
+		}
+		path := filepath.Join(mountPath, name)
+		if err := utils.ValidateMountPath(path); err != nil {
+			return nil, err
+		}
+		// project quota is inherited by new files, so a directory can not be in another one
+		for _, dirConfig := range dirConfigs {
+			if path == dirConfig.Path || strings.HasPrefix(path, dirConfig.Path+"/") || strings.HasPrefix(dirConfig.Path, path+"/") {
+				return nil, fmt.Errorf("directory %s overlaps with %s", path, dirConfig.Path)
+			}
+		}
+		quota := model.ProjectQuota{InodeSoftLimit: directory.InodeSoftLimit, InodeHardLimit: directory.InodeHardLimit}
+		var err error
+		if quota.BlockSoftLimit, err = parseLimit(directory.BlockSoftLimit); err != nil {
+			return nil, fmt.Errorf("invalid block soft limit %q of directory %s", directory.BlockSoftLimit, name)
+		}
+		if quota.BlockHardLimit, err = parseLimit(directory.BlockHardLimit); err != nil {
+			return nil, fmt.Errorf("invalid block hard limit %q of directory %s", directory.BlockHardLimit, name)
+		}
+		if quota.BlockHardLimit > 0 && quota.BlockSoftLimit > quota.BlockHardLimit {
+			return nil, fmt.Errorf("block soft limit of directory %s is larger than hard limit", name)
+		}
+		if quota.InodeHardLimit > 0 && quota.InodeSoftLimit > quota.InodeHardLimit {
+			return nil, fmt.Errorf("inode soft limit of directory %s is larger than hard limit", name)
+		}
+		dirConfigs = append(dirConfigs, &DirectoryConfig{Path: path, Quota: quota})
+	}
+	return dirConfigs, nil
+}
+
+// parseLimit parses quantity to bytes rounded up to KiB, empty is 0
+func parseLimit(limit string) (uint64, error) {
+	if limit == "" {
+		return 0, nil
+	}
+	quantity, err := resource.ParseQuantity(limit)
+	if err != nil {
+		return 0, err
+	}
+	if quantity.Sign() < 0 {
+		return 0, fmt.Errorf("negative limit %q", limit)
+	}
+	return (uint64(quantity.Value()) + 1023) / 1024 * 1024, nil
+}
+
+// quotaPathConfig returns the config of quota path, nil if not in topology
+func (qrm *ResourceManager) quotaPathConfig(mountPath string) *QpConfig {
+	if qpConfig, ok := qrm.DeviceQuotaPath[mountPath]; ok {
+		return qpConfig
+	}
+	if qpConfig, ok := qrm.RegionQuotaPath[mountPath]; ok {
+		return qpConfig
+	}
+	return qrm.LvmQuotaPath[mountPath]
+}
+
+// applyDirectories ensures the project quota of directories in every ready quota path, a failed directory fails its quota path.
+// mountedPaths are the mount points before apply, a quota path just mounted has the fstype of config.
+func (qrm *ResourceManager) applyDirectories(results []*model.ResourceResult, mountedPaths map[string]k8smount.MountPoint) []*model.ResourceResult {
+	var projects []model.Project
+	var listErr error
+	listed := false
+	for i, result := range results {
+		qpConfig := qrm.quotaPathConfig(result.Name)
+		if result.Condition != model.ResourceReady || qpConfig == nil {
+			continue
+		}
+		if qpConfig.DirectoryError != "" {
+			results[i] = model.NewResourceResult(config.ResourceKindQuotaPath, result.Name, model.ResourceFailed, "InvalidQuotaDirectory", qpConfig.DirectoryError)
+			continue
+		}
+		if len(qpConfig.Directories) == 0 {
+			continue
+		}
+		if !listed {
+			projects, listErr = qrm.quotaer.ListProjects()
+			listed = true
+		}
+		if listErr != nil {
+			klog.Errorf("applyDirectories:: list projects error: %v", listErr)
+			results[i] = model.NewResourceResult(config.ResourceKindQuotaPath, result.Name, model.ResourceFailed, "ListProjectsFailed", listErr.Error())
+			continue
+		}
+		fstype := qpConfig.Fstype
+		if mountPoint, ok := mountedPaths[result.Name]; ok {
+			fstype = mountPoint.Type
+		}
+		if dirResult := qrm.ensureDirectories(result.Name, fstype, qpConfig.Directories, &projects); dirResult != nil {
+			results[i] = dirResult
+		}
+	}
+	return results
+}
+
+// ensureDirectories creates the directories missing a project, and sets the limits of projects differing from config.
+// New projects are appended to projects, returns nil if nothing changed.
+func (qrm *ResourceManager) ensureDirectories(mountPath, fstype string, dirConfigs []*DirectoryConfig, projects *[]model.Project) *model.ResourceResult {
+	quotas, err := qrm.quotaer.ListProjectQuotas(fstype, mountPath)
+	if err != nil {
+		klog.Errorf("ensureDirectories:: list project quotas of %s error: %v", mountPath, err)
+		return model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceFailed, "ListProjectQuotaFailed", err.Error())
+	}
+	quotaByID := map[uint32]model.ProjectQuota{}
+	for _, quota := range quotas {
+		quotaByID[quota.ID] = quota
+	}
+	updated := []string{}
+	for _, dirConfig := range dirConfigs {
+		project, err := qrm.ensureProject(dirConfig.Path, *projects, quotaByID)
+		if err != nil {
+			klog.Errorf("ensureDirectories:: add project of %s error: %v", dirConfig.Path, err)
+			return model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceFailed,
+				"ProjectQuotaFailed", fmt.Sprintf("add project of %s error: %v", dirConfig.Path, err))
+		}
+		if !containsProject(*projects, project.ID) {
+			*projects = append(*projects, project)
+		}
+		expect := dirConfig.Quota
+		expect.ID = project.ID
+		quota, ok := quotaByID[project.ID]
+		// a project not reported owns no file yet
+		if !ok {
+			if err := qrm.mounter.EnsureFolder(dirConfig.Path); err != nil {
+				klog.Errorf("ensureDirectories:: ensure directory %s error: %v", dirConfig.Path, err)
+				return model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceFailed, "EnsureFolderFailed", err.Error())
+			}
+			if err := qrm.quotaer.SetProject(fstype, mountPath, project); err != nil {
+				return model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceFailed,
+					"ProjectQuotaFailed", fmt.Sprintf("set project %d of %s error: %v", project.ID, dirConfig.Path, err))
+			}
+		}
+		if ok && sameLimits(quota, expect) {
+			continue
+		}
+		if err := qrm.quotaer.SetProjectLimits(fstype, mountPath, expect); err != nil {
+			return model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceFailed,
+				"ProjectQuotaFailed", fmt.Sprintf("set limits of project %d of %s error: %v", project.ID, dirConfig.Path, err))
+		}
+		klog.Infof("ensureDirectories:: Successful set project quota of %s: %+v", dirConfig.Path, expect)
+		updated = append(updated, dirConfig.Path)
+	}
+	if len(updated) == 0 {
+		return nil
+	}
+	return model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceReady,
+		"ProjectQuotaUpdated", fmt.Sprintf("project quota of %v updated", updated))
+}
+
+// ensureProject returns the project of path, a new project is allocated an id larger than any project or quota and added
+func (qrm *ResourceManager) ensureProject(path string, projects []model.Project, quotaByID map[uint32]model.ProjectQuota) (model.Project, error) {
+	nextID := uint32(minProjectID)
+	for _, project := range projects {
+		if project.Path == path {
+			return project, nil
+		}
+		if project.ID >= nextID {
+			nextID = project.ID + 1
+		}
+	}
+	for id := range quotaByID {
+		if id >= nextID {
+			nextID = id + 1
+		}
+	}
+	project := model.Project{ID: nextID, Name: projectName(path), Path: path}
+	if err := qrm.quotaer.AddProject(project); err != nil {
+		return project, err
+	}
+	return project, nil
+}
+
+// projectName is the name of project in projid, e.g. nrm_mnt_path1_app1 for /mnt/path1/app1
+func projectName(path string) string {
+	return "nrm" + strings.NewReplacer("/", "_", ":", "_", "@", "_", "+", "_").Replace(path)
+}
+
+// containsProject ...
+func containsProject(projects []model.Project, id uint32) bool {
+	for _, project := range projects {
+		if project.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// sameLimits compares the limits of quotas
+func sameLimits(actual, expect model.ProjectQuota) bool {
+	return actual.BlockSoftLimit == expect.BlockSoftLimit && actual.BlockHardLimit == expect.BlockHardLimit &&
+		actual.InodeSoftLimit == expect.InodeSoftLimit && actual.InodeHardLimit == expect.InodeHardLimit
+}
+
+// directoryStatus returns the project quota of projects in directories of mount point, nil if there is no project
+func (qrm *ResourceManager) directoryStatus(mountPoint k8smount.MountPoint, projects []model.Project) []v1alpha1.QuotaDirectoryStatus {
+	inMount := []model.Project{}
+	for _, project := range projects {
+		if strings.HasPrefix(project.Path, mountPoint.Path+"/") {
+			inMount = append(inMount, project)
+		}
+	}
+	if len(inMount) == 0 {
+		return nil
+	}
+	quotas, err := qrm.quotaer.ListProjectQuotas(mountPoint.Type, mountPoint.Path)
+	if err != nil {
+		klog.Errorf("directoryStatus:: list project quotas of %s error: %v", mountPoint.Path, err)
+		return nil
+	}
+	quotaByID := map[uint32]model.ProjectQuota{}
+	for _, quota := range quotas {
+		quotaByID[quota.ID] = quota
+	}
+	statuses := []v1alpha1.QuotaDirectoryStatus{}
+	for _, project := range inMount {
+		quota := quotaByID[project.ID]
+		statuses = append(statuses, v1alpha1.QuotaDirectoryStatus{
+			Path:           project.Path,
+			ProjectID:      int64(project.ID),
+			BlockUsed:      int64(quota.BlockUsed),
+			BlockSoftLimit: int64(quota.BlockSoftLimit),
+			BlockHardLimit: int64(quota.BlockHardLimit),
+			InodeUsed:      int64(quota.InodeUsed),
+			InodeSoftLimit: int64(quota.InodeSoftLimit),
+			InodeHardLimit: int64(quota.InodeHardLimit),
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Path < statuses[j].Path })
+	return statuses
+}
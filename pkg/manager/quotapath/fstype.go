@@ -0,0 +1,71 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quotapath
+
+import (
+	"fmt"
+	"strings"
+)
+
+// filesystems supporting project quota
+const (
+	FstypeExt4 = "ext4"
+	FstypeXfs  = "xfs"
+)
+
+// quotaOptions returns the mkfs options and mount options enabling project quota on fstype, ext4 by default.
+// prjquota is appended to options if no project quota option is set.
+func quotaOptions(fstype, options string) ([]string, string, error) {
+	if fstype == "" {
+		fstype = FstypeExt4
+	}
+	var mkfsOptions, supported []string
+	switch fstype {
+	case FstypeExt4:
+		// ext4 needs the project and quota features at mkfs time
+		mkfsOptions = []string{"-O", "project,quota"}
+		supported = []string{"prjquota"}
+	case FstypeXfs:
+		// project quota of xfs is enabled by mount option only
+		supported = []string{"prjquota", "pquota", "pqnoenforce"}
+	default:
+		return nil, "", fmt.Errorf("fstype %s does not support project quota, use %s or %s", fstype, FstypeExt4, FstypeXfs)
+	}
+
+	hasQuota := false
+	for _, option := range strings.Split(options, ",") {
+		if !isProjectQuotaMount([]string{option}) {
+			continue
+		}
+		found := false
+		for _, s := range supported {
+			found = found || s == option
+		}
+		if !found {
+			return nil, "", fmt.Errorf("mount option %s is not supported by %s", option, fstype)
+		}
+		hasQuota = true
+	}
+	switch {
+	case hasQuota:
+	case options == "":
+		options = "prjquota"
+	default:
+		options += ",prjquota"
+	}
+	return mkfsOptions, options, nil
+}
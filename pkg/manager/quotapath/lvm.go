@@ -0,0 +1,225 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quotapath
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/openyurtio/node-resource-manager/pkg/config"
+	"github.com/openyurtio/node-resource-manager/pkg/model"
+	"github.com/openyurtio/node-resource-manager/pkg/utils"
+	"k8s.io/apimachinery/pkg/api/resource"
+	klog "k8s.io/klog/v2"
+	k8smount "k8s.io/utils/mount"
+)
+
+const (
+	// QpTypeLvm is the quota path on a logical volume of volume group managed by node-resource-manager
+	QpTypeLvm = "lvm"
+)
+
+// newLvmConfig converts the topology of lvm quota path to config,
+// the error of invalid topology is kept in LvmError
+func newLvmConfig(mountPath string, topology model.Topology) *QpConfig {
+	conf := &QpConfig{
+		Type:          topology.Type,
+		Fstype:        topology.Fstype,
+		Options:       topology.Options,
+		Persistence:   topology.Persistence,
+		VolumeGroup:   topology.VolumeGroup,
+		LogicalVolume: lvmQuotaPathName(mountPath),
+	}
+	if err := utils.ValidateLVMName(conf.VolumeGroup); err != nil {
+		conf.LvmError = err.Error()
+		return conf
+	}
+	if err := utils.ValidateLVMName(conf.LogicalVolume); err != nil {
+		conf.LvmError = err.Error()
+		return conf
+	}
+	size, err := resource.ParseQuantity(topology.Size)
+	if err != nil || size.Sign() <= 0 {
+		conf.LvmError = fmt.Sprintf("invalid size %q", topology.Size)
+		return conf
+	}
+	conf.Size = uint64(size.Value())
+	return conf
+}
+
+// lvmQuotaPathName returns the logical volume name of quota path, e.g. quotapath_mnt_path1 for /mnt/path1
+func lvmQuotaPathName(mountPath string) string {
+	return "quotapath" + strings.NewReplacer("/", "_", ":", "_", "@", "_").Replace(mountPath)
+}
+
+// applyLvmQuotaPath creates the logical volume of quota path in a managed volume group, formats and mounts it with project quota,
+// and extends the logical volume and its filesystem of a mounted quota path if size is increased
+func (qrm *ResourceManager) applyLvmQuotaPath(mountedPaths map[string]k8smount.MountPoint) []*model.ResourceResult {
+	results := []*model.ResourceResult{}
+	if len(qrm.LvmQuotaPath) == 0 {
+		return results
+	}
+	vgs, listErr := qrm.lvmer.ListVG()
+	if listErr != nil {
+		klog.Errorf("applyLvmQuotaPath:: list volume groups error: %v", listErr)
+	}
+	// free size of volume group is reduced by every logical volume created or extended in this loop
+	actualVgs := map[string]*model.VG{}
+	for _, vg := range vgs {
+		actualVgs[vg.Name] = vg
+	}
+	for mountPath, lvmQuotaPathConfig := range qrm.LvmQuotaPath {
+		if lvmQuotaPathConfig.LvmError != "" {
+			results = append(results, model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceFailed,
+				"InvalidQuotaConfig", lvmQuotaPathConfig.LvmError))
+			continue
+		}
+		if listErr != nil {
+			results = append(results, model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceFailed,
+				"ListVolumeGroupFailed", listErr.Error()))
+			continue
+		}
+		vg, ok := actualVgs[lvmQuotaPathConfig.VolumeGroup]
+		if !ok {
+			results = append(results, model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceProgressing,
+				"VolumeGroupNotReady", fmt.Sprintf("volume group %s not exists", lvmQuotaPathConfig.VolumeGroup)))
+			continue
+		}
+		if !hasVgTag(vg, utils.ManagedTagName) {
+			results = append(results, model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceFailed,
+				"VolumeGroupNotOwned", fmt.Sprintf("volume group %s is not managed by node-resource-manager", vg.Name)))
+			continue
+		}
+		// config is rebuilt in every reconcile, so the size is rounded up to the extent of current volume group
+		lvmQuotaPathConfig.Size = utils.RoundUpToExtent(lvmQuotaPathConfig.Size, vg)
+		lv, err := qrm.getLv(vg.Name, lvmQuotaPathConfig.LogicalVolume)
+		if err != nil {
+			klog.Errorf("applyLvmQuotaPath:: list logical volumes of %s error: %v", vg.Name, err)
+			results = append(results, model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceFailed,
+				"ListLogicalVolumeFailed", err.Error()))
+			continue
+		}
+		if mountPoint, ok := mountedPaths[mountPath]; ok {
+			results = append(results, qrm.checkLvmQuotaPath(mountPoint, lvmQuotaPathConfig, lv, vg))
+			continue
+		}
+		results = append(results, qrm.mountLvmQuotaPath(mountPath, lvmQuotaPathConfig, lv, vg))
+	}
+	return results
+}
+
+// getLv returns the logical volume in volume group, nil if not exists
+func (qrm *ResourceManager) getLv(vgName, lvName string) (*model.LV, error) {
+	lvs, err := qrm.lvmer.ListLV(vgName)
+	if err != nil {
+		return nil, err
+	}
+	for _, lv := range lvs {
+		if lv.Name == lvName {
+			return lv, nil
+		}
+	}
+	return nil, nil
+}
+
+// mountLvmQuotaPath creates the logical volume if lv is nil, then formats and mounts it
+func (qrm *ResourceManager) mountLvmQuotaPath(mountPath string, qpConfig *QpConfig, lv *model.LV, vg *model.VG) *model.ResourceResult {
+	mkfsOptions, mountOptions, err := quotaOptions(qpConfig.Fstype, qpConfig.Options)
+	if err != nil {
+		return model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceFailed, "InvalidQuotaConfig", err.Error())
+	}
+	if lv == nil {
+		if qpConfig.Size > vg.FreeSize {
+			klog.Errorf("mountLvmQuotaPath:: volume group %s has %d free, creating %s needs %d", vg.Name, vg.FreeSize, qpConfig.LogicalVolume, qpConfig.Size)
+			return model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceFailed, "InsufficientFreeSpace",
+				fmt.Sprintf("creating logical volume %s of %d bytes, volume group %s has %d free", qpConfig.LogicalVolume, qpConfig.Size, vg.Name, vg.FreeSize))
+		}
+		out, err := qrm.lvmer.CreateLV(vg.Name, qpConfig.LogicalVolume, qpConfig.Size, 0, []string{utils.ManagedTagName})
+		if err != nil {
+			klog.Errorf("mountLvmQuotaPath:: create logical volume %s/%s error: %v", vg.Name, qpConfig.LogicalVolume, err)
+			return model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceFailed, "CreateLogicalVolumeFailed", err.Error())
+		}
+		vg.FreeSize -= qpConfig.Size
+		klog.Infof("mountLvmQuotaPath:: Successful create logical volume %s/%s with out: %s", vg.Name, qpConfig.LogicalVolume, out)
+	}
+	if err := qrm.mounter.EnsureFolder(mountPath); err != nil {
+		klog.Errorf("mountLvmQuotaPath:: ensure quotapath error: %v", err)
+		return model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceFailed, "EnsureFolderFailed", err.Error())
+	}
+	devicePath := filepath.Join("/dev", vg.Name, qpConfig.LogicalVolume)
+	if err := qrm.mounter.FormatAndMount(devicePath, mountPath, qpConfig.Fstype, mkfsOptions, mountOptions); err != nil {
+		klog.Errorf("mountLvmQuotaPath:: mounter FormatAndMount error: %v", err)
+		return model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceFailed,
+			"MountFailed", fmt.Sprintf("mount device %s error: %v", devicePath, err))
+	}
+	return qrm.verifyProjectQuota(mountPath, devicePath)
+}
+
+// checkLvmQuotaPath extends the logical volume of mounted quota path if size is increased, then grows its filesystem,
+// a failed grow is retried in next reconcile. Shrinking is refused.
+func (qrm *ResourceManager) checkLvmQuotaPath(mountPoint k8smount.MountPoint, qpConfig *QpConfig, lv *model.LV, vg *model.VG) *model.ResourceResult {
+	result := mountedResult(mountPoint)
+	if result.Condition != model.ResourceReady || lv == nil {
+		return result
+	}
+	if lv.Size > qpConfig.Size {
+		klog.Errorf("checkLvmQuotaPath:: refuse to shrink logical volume %s/%s from %d to %d", vg.Name, lv.Name, lv.Size, qpConfig.Size)
+		return model.NewResourceResult(config.ResourceKindQuotaPath, mountPoint.Path, model.ResourceFailed,
+			"ShrinkNotSupported", fmt.Sprintf("logical volume %s size is %d, shrinking to %d is not supported", lv.Name, lv.Size, qpConfig.Size))
+	}
+	devicePath := filepath.Join("/dev", vg.Name, lv.Name)
+	message := fmt.Sprintf("logical volume %s size is %d", lv.Name, lv.Size)
+	if lv.Size < qpConfig.Size {
+		if qpConfig.Size-lv.Size > vg.FreeSize {
+			klog.Errorf("checkLvmQuotaPath:: volume group %s has %d free, growing %s needs %d", vg.Name, vg.FreeSize, lv.Name, qpConfig.Size-lv.Size)
+			return model.NewResourceResult(config.ResourceKindQuotaPath, mountPoint.Path, model.ResourceFailed, "InsufficientFreeSpace",
+				fmt.Sprintf("growing logical volume %s from %d to %d needs %d bytes, volume group %s has %d free",
+					lv.Name, lv.Size, qpConfig.Size, qpConfig.Size-lv.Size, vg.Name, vg.FreeSize))
+		}
+		out, err := qrm.lvmer.ExtendLV(vg.Name, lv.Name, qpConfig.Size)
+		if err != nil {
+			klog.Errorf("checkLvmQuotaPath:: extend logical volume %s/%s to %d error: %v", vg.Name, lv.Name, qpConfig.Size, err)
+			return model.NewResourceResult(config.ResourceKindQuotaPath, mountPoint.Path, model.ResourceFailed, "ExtendLogicalVolumeFailed", err.Error())
+		}
+		klog.Infof("checkLvmQuotaPath:: Successful extend logical volume %s/%s to %d with out: %s", vg.Name, lv.Name, qpConfig.Size, out)
+		vg.FreeSize -= qpConfig.Size - lv.Size
+		qrm.growFSPending[mountPoint.Path] = true
+		message = fmt.Sprintf("logical volume %s extended from %d to %d", lv.Name, lv.Size, qpConfig.Size)
+	}
+	if !qrm.growFSPending[mountPoint.Path] {
+		return result
+	}
+	if _, err := qrm.mounter.GrowFS(devicePath); err != nil {
+		klog.Errorf("checkLvmQuotaPath:: grow filesystem of %s error: %v", mountPoint.Path, err)
+		return model.NewResourceResult(config.ResourceKindQuotaPath, mountPoint.Path, model.ResourceFailed,
+			"GrowFilesystemFailed", fmt.Sprintf("%s, grow filesystem error: %v", message, err))
+	}
+	delete(qrm.growFSPending, mountPoint.Path)
+	return model.NewResourceResult(config.ResourceKindQuotaPath, mountPoint.Path, model.ResourceReady,
+		"QuotaPathExtended", fmt.Sprintf("%s, filesystem grown", message))
+}
+
+// hasVgTag returns true if volume group has tag
+func hasVgTag(vg *model.VG, tag string) bool {
+	for _, t := range vg.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
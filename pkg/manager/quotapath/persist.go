@@ -0,0 +1,113 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package quotapath
+
+import (
+	"fmt"
+
+	"github.com/openyurtio/node-resource-manager/pkg/config"
+	"github.com/openyurtio/node-resource-manager/pkg/model"
+	klog "k8s.io/klog/v2"
+	k8smount "k8s.io/utils/mount"
+)
+
+// applyPersistence persists the mount of every ready quota path with persistence, so it is mounted on boot,
+// and removes the mounts persisted for quota paths removed from topology or persisted another way.
+// A failed persistence fails its quota path.
+func (qrm *ResourceManager) applyPersistence(results []*model.ResourceResult) []*model.ResourceResult {
+	persisted, err := qrm.persister.ListPersistedMounts()
+	if err != nil {
+		klog.Errorf("applyPersistence:: list persisted mounts error: %v", err)
+		for i, result := range results {
+			if qpConfig := qrm.quotaPathConfig(result.Name); result.Condition == model.ResourceReady && qpConfig != nil && qpConfig.Persistence != "" {
+				results[i] = model.NewResourceResult(config.ResourceKindQuotaPath, result.Name, model.ResourceFailed, "ListPersistedMountsFailed", err.Error())
+			}
+		}
+		return results
+	}
+	removeErrors := map[string]error{}
+	for _, mount := range persisted {
+		if qpConfig := qrm.quotaPathConfig(mount.Path); qpConfig != nil && qpConfig.Persistence == mount.Persistence {
+			continue
+		}
+		if err := qrm.persister.RemovePersistedMount(mount); err != nil {
+			klog.Errorf("applyPersistence:: remove %s of %s error: %v", mount.Persistence, mount.Path, err)
+			removeErrors[mount.Path] = err
+		}
+	}
+
+	var mountedPaths map[string]k8smount.MountPoint
+	var listErr error
+	for i, result := range results {
+		if err, ok := removeErrors[result.Name]; ok {
+			results[i] = model.NewResourceResult(config.ResourceKindQuotaPath, result.Name, model.ResourceFailed, "RemovePersistedMountFailed", err.Error())
+			continue
+		}
+		qpConfig := qrm.quotaPathConfig(result.Name)
+		if result.Condition != model.ResourceReady || qpConfig == nil || qpConfig.Persistence == "" {
+			continue
+		}
+		// the quota path may be just mounted, so mounts are listed again for its device
+		if mountedPaths == nil {
+			mountedPaths = map[string]k8smount.MountPoint{}
+			var mountPoints []k8smount.MountPoint
+			mountPoints, listErr = qrm.mounter.ListHostMounts()
+			for _, mountPoint := range mountPoints {
+				mountedPaths[mountPoint.Path] = mountPoint
+			}
+		}
+		if listErr != nil {
+			klog.Errorf("applyPersistence:: list host mounts error: %v", listErr)
+			results[i] = model.NewResourceResult(config.ResourceKindQuotaPath, result.Name, model.ResourceFailed, "PersistMountFailed", listErr.Error())
+			continue
+		}
+		mountPoint, ok := mountedPaths[result.Name]
+		if !ok {
+			// the planned mount is not found in dry-run
+			continue
+		}
+		if persistResult := qrm.persistMount(mountPoint, qpConfig); persistResult != nil {
+			results[i] = persistResult
+		}
+	}
+	return results
+}
+
+// persistMount persists the mount point of quota path by filesystem uuid with the mount options of config, returns nil on success
+func (qrm *ResourceManager) persistMount(mountPoint k8smount.MountPoint, qpConfig *QpConfig) *model.ResourceResult {
+	_, mountOptions, err := quotaOptions(qpConfig.Fstype, qpConfig.Options)
+	if err != nil {
+		return model.NewResourceResult(config.ResourceKindQuotaPath, mountPoint.Path, model.ResourceFailed, "InvalidQuotaConfig", err.Error())
+	}
+	uuid, err := qrm.persister.GetFsUUID(mountPoint.Device)
+	if err != nil {
+		klog.Errorf("persistMount:: get filesystem uuid of %s error: %v", mountPoint.Device, err)
+		return model.NewResourceResult(config.ResourceKindQuotaPath, mountPoint.Path, model.ResourceFailed, "PersistMountFailed",
+			fmt.Sprintf("get filesystem uuid of %s error: %v", mountPoint.Device, err))
+	}
+	mount := model.PersistedMount{
+		Persistence: qpConfig.Persistence,
+		UUID:        uuid,
+		Path:        mountPoint.Path,
+		Fstype:      mountPoint.Type,
+		Options:     mountOptions,
+	}
+	if err := qrm.persister.PersistMount(mount); err != nil {
+		klog.Errorf("persistMount:: persist %s of %s error: %v", mount.Persistence, mount.Path, err)
+		return model.NewResourceResult(config.ResourceKindQuotaPath, mountPoint.Path, model.ResourceFailed, "PersistMountFailed", err.Error())
+	}
+	return nil
+}
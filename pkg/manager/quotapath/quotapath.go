@@ -18,64 +18,89 @@ package quotapath
 
 import (
 	"errors"
-	"io/ioutil"
+	"fmt"
 	"os"
+	"sort"
 	"strings"
 
+	"github.com/openyurtio/node-resource-manager/pkg/apis/nrm/v1alpha1"
 	"github.com/openyurtio/node-resource-manager/pkg/config"
 	CusErr "github.com/openyurtio/node-resource-manager/pkg/err"
+	"github.com/openyurtio/node-resource-manager/pkg/model"
 	"github.com/openyurtio/node-resource-manager/pkg/utils"
-	"gopkg.in/yaml.v2"
+	"github.com/openyurtio/node-resource-manager/pkg/utils/blockdev"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/tools/record"
 	klog "k8s.io/klog/v2"
+	k8smount "k8s.io/utils/mount"
 )
 
 // ResourceManager ...
 type ResourceManager struct {
 	DeviceQuotaPath map[string]*QpConfig
 	RegionQuotaPath map[string]*QpConfig
-	mounter         utils.Mounter
-	mkfsOption      []string
-	pmemer          utils.Pmemer
-	configPath      string
-	recorder        record.EventRecorder
+	LvmQuotaPath    map[string]*QpConfig
+	// growFSPending is the lvm quota paths extended whose filesystem is not grown yet
+	growFSPending map[string]bool
+	mounter       utils.Mounter
+	lvmer         utils.LVM
+	raider        utils.Raider
+	checker       blockdev.Checker
+	pmemer        utils.Pmemer
+	quotaer       utils.Quotaer
+	persister     utils.MountPersister
+	discoverer    blockdev.Discoverer
+	source        config.TopologySource
+	recorder      record.EventRecorder
 }
 
-// NewResourceManager ...
-func NewResourceManager() *ResourceManager {
-	return &ResourceManager{
+// NewResourceManager create manager, commands are run by executor, changes are recorded into plan instead of executed if plan is not nil
+func NewResourceManager(source config.TopologySource, executor utils.CommandExecutor, plan *utils.Plan) *ResourceManager {
+	qrm := &ResourceManager{
 		DeviceQuotaPath: make(map[string]*QpConfig),
 		RegionQuotaPath: make(map[string]*QpConfig),
-		mounter:         utils.NewMounter(),
-		pmemer:          utils.NewNodePmemer(),
-		configPath:      "/etc/unified-config/quotapath",
-		recorder:        utils.NewEventRecorder(),
+		LvmQuotaPath:    make(map[string]*QpConfig),
+		growFSPending:   map[string]bool{},
+		discoverer:      blockdev.NewDiscoverer(),
+		source:          source,
 	}
+	if plan != nil {
+		// dry-run changes nothing on node and emits no event
+		qrm.mounter = utils.NewDryRunMounter(utils.NewMounter(executor), executor, plan)
+		qrm.lvmer = utils.NewDryRunLVM(utils.NewNodeLVM(executor), plan)
+		qrm.raider = utils.NewDryRunRaider(utils.NewNodeRaider(executor), plan)
+		qrm.checker = blockdev.NewDryRunChecker(blockdev.NewChecker(executor), plan)
+		qrm.pmemer = utils.NewDryRunPmemer(utils.NewNodePmemer(executor), plan)
+		qrm.quotaer = utils.NewDryRunQuotaer(utils.NewQuotaer(executor), plan)
+		qrm.persister = utils.NewDryRunMountPersister(utils.NewMountPersister(executor), plan)
+		qrm.recorder = &record.FakeRecorder{}
+	} else {
+		qrm.mounter = utils.NewMounter(executor)
+		qrm.lvmer = utils.NewNodeLVM(executor)
+		qrm.raider = utils.NewNodeRaider(executor)
+		qrm.checker = blockdev.NewChecker(executor)
+		qrm.pmemer = utils.NewNodePmemer(executor)
+		qrm.quotaer = utils.NewQuotaer(executor)
+		qrm.persister = utils.NewMountPersister(executor)
+		qrm.recorder = utils.NewEventRecorder()
+	}
+	return qrm
 }
 
 // AnalyseConfigMap analyse quotapath resource config
 func (qrm *ResourceManager) AnalyseConfigMap() error {
 	deviceQuotaConfig := map[string]*QpConfig{}
 	regionQuotaConfig := map[string]*QpConfig{}
-	quotaPathList := &QPList{}
-	yamlFile, err := ioutil.ReadFile(qrm.configPath)
+	lvmQuotaConfig := map[string]*QpConfig{}
+	quotaPaths, err := qrm.source.GetResources(config.ResourceKindQuotaPath)
 	if err != nil {
-		if os.IsNotExist(err) {
-			klog.Errorf("quota config file %s not exist", qrm.configPath)
-			return nil
-		}
-		klog.Errorf("AnalyseConfigMap:: yamlFile.Get error %v", err)
-		return err
-	}
-	err = yaml.Unmarshal(yamlFile, quotaPathList)
-	if err != nil {
-		klog.Errorf("AnalyseConfigMap:: parse yaml file error: %v", err)
+		klog.Errorf("AnalyseConfigMap:: get quotapath config error: %v", err)
 		return err
 	}
+	resolver := blockdev.NewResolver(qrm.discoverer)
 	mountPathMap := map[string]string{}
 	nodeInfo := config.GlobalConfigVar.NodeInfo
-	for _, quotaConfig := range quotaPathList.QuotaPaths {
+	for _, quotaConfig := range quotaPaths {
 		isMatched := utils.NodeFilter(quotaConfig.Operator, quotaConfig.Key, quotaConfig.Value, nodeInfo)
 		if isMatched {
 			if _, ok := mountPathMap[quotaConfig.Name]; ok {
@@ -86,9 +111,21 @@ func (qrm *ResourceManager) AnalyseConfigMap() error {
 			case "device":
 				conf := &QpConfig{}
 				conf.Devices = quotaConfig.Topology.Devices
+				if quotaConfig.Topology.DeviceSelector != nil {
+					if conf.Devices, err = resolver.Resolve(conf.Devices, quotaConfig.Topology.DeviceSelector); err != nil {
+						klog.Errorf("AnalyseConfigMap:: select devices of quotapath %s error: %v", quotaConfig.Name, err)
+						conf.SelectorError = err.Error()
+					}
+				}
 				conf.Fstype = quotaConfig.Topology.Fstype
 				conf.Options = quotaConfig.Topology.Options
 				conf.Type = quotaConfig.Topology.Type
+				conf.Layout = quotaConfig.Topology.Layout
+				conf.Persistence = quotaConfig.Topology.Persistence
+				if conf.Directories, err = parseDirectories(quotaConfig.Name, quotaConfig.Topology.Directories); err != nil {
+					klog.Errorf("AnalyseConfigMap:: directories of quotapath %s error: %v", quotaConfig.Name, err)
+					conf.DirectoryError = err.Error()
+				}
 				deviceQuotaConfig[quotaConfig.Name] = conf
 			case "pmem":
 				conf := &QpConfig{}
@@ -100,7 +137,19 @@ func (qrm *ResourceManager) AnalyseConfigMap() error {
 				conf.Fstype = quotaConfig.Topology.Fstype
 				conf.Options = quotaConfig.Topology.Options
 				conf.Type = quotaConfig.Topology.Type
+				conf.Persistence = quotaConfig.Topology.Persistence
+				if conf.Directories, err = parseDirectories(quotaConfig.Name, quotaConfig.Topology.Directories); err != nil {
+					klog.Errorf("AnalyseConfigMap:: directories of quotapath %s error: %v", quotaConfig.Name, err)
+					conf.DirectoryError = err.Error()
+				}
 				regionQuotaConfig[quotaConfig.Name] = conf
+			case QpTypeLvm:
+				conf := newLvmConfig(quotaConfig.Name, quotaConfig.Topology)
+				if conf.Directories, err = parseDirectories(quotaConfig.Name, quotaConfig.Topology.Directories); err != nil {
+					klog.Errorf("AnalyseConfigMap:: directories of quotapath %s error: %v", quotaConfig.Name, err)
+					conf.DirectoryError = err.Error()
+				}
+				lvmQuotaConfig[quotaConfig.Name] = conf
 			default:
 				klog.Errorf("AnalyseConfigMap:: not support quotapath config type: [%v]", quotaConfig.Topology.Type)
 				continue
@@ -111,87 +160,233 @@ func (qrm *ResourceManager) AnalyseConfigMap() error {
 
 	qrm.DeviceQuotaPath = deviceQuotaConfig
 	qrm.RegionQuotaPath = regionQuotaConfig
+	qrm.LvmQuotaPath = lvmQuotaConfig
 	return nil
 }
 
-// ApplyResourceDiff apply quotapath resource to current node
-func (qrm *ResourceManager) ApplyResourceDiff() error {
-	klog.Infof("ApplyResourceDiff: matched node resources qrm.DeviceQuotaPath: %v, qrm.RegionQuotaPath: %v", qrm.DeviceQuotaPath, qrm.RegionQuotaPath)
-	qrm.mkfsOption = strings.Split("-O project,quota", " ")
-	err := qrm.applyDeivceQuotaPath()
-	if err != nil {
-		klog.Errorf("ApplyResourceDiff:: apply deivce quotapath error: %v", err)
+// ApplyResourceDiff apply quotapath resource to current node, returns the result of every expected quotapath
+func (qrm *ResourceManager) ApplyResourceDiff() ([]*model.ResourceResult, error) {
+	klog.Infof("ApplyResourceDiff: matched node resources qrm.DeviceQuotaPath: %v, qrm.RegionQuotaPath: %v, qrm.LvmQuotaPath: %v",
+		qrm.DeviceQuotaPath, qrm.RegionQuotaPath, qrm.LvmQuotaPath)
+	if len(qrm.DeviceQuotaPath) == 0 && len(qrm.RegionQuotaPath) == 0 && len(qrm.LvmQuotaPath) == 0 {
+		// mounts persisted for quota paths removed from topology are still cleaned up
+		return qrm.applyPersistence([]*model.ResourceResult{}), nil
 	}
-	err = qrm.applyRegionQuotaPath()
+
+	// quotapath already mounted is not mounted again
+	mountPoints, err := qrm.mounter.ListHostMounts()
 	if err != nil {
-		klog.Errorf("ApplyResourceDiff:: apply region quotapath error: %v", err)
+		klog.Errorf("ApplyResourceDiff:: list host mounts error: %v", err)
+		return nil, err
 	}
-	return err
+	mountedPaths := map[string]k8smount.MountPoint{}
+	for _, mountPoint := range mountPoints {
+		mountedPaths[mountPoint.Path] = mountPoint
+	}
+
+	results := qrm.applyDeivceQuotaPath(mountedPaths)
+	results = append(results, qrm.applyRegionQuotaPath(mountedPaths)...)
+	results = append(results, qrm.applyLvmQuotaPath(mountedPaths)...)
+	results = qrm.applyDirectories(results, mountedPaths)
+	results = qrm.applyPersistence(results)
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	return results, nil
 }
 
-func (qrm *ResourceManager) applyDeivceQuotaPath() error {
+func (qrm *ResourceManager) applyDeivceQuotaPath(mountedPaths map[string]k8smount.MountPoint) []*model.ResourceResult {
 
 	ref := &v1.ObjectReference{
 		Kind:      "pods",
 		Name:      os.Getenv("POD_NAME"),
 		Namespace: "kube-system",
 	}
+	results := []*model.ResourceResult{}
 	for mountPath, deivceQuotaPathConfig := range qrm.DeviceQuotaPath {
-		err := qrm.mounter.EnsureFolder(mountPath)
+		if mountPoint, ok := mountedPaths[mountPath]; ok {
+			results = append(results, mountedResult(mountPoint))
+			continue
+		}
+		if deivceQuotaPathConfig.SelectorError != "" {
+			results = append(results, model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceFailed,
+				"DeviceSelectorFailed", deivceQuotaPathConfig.SelectorError))
+			continue
+		}
+		mkfsOptions, mountOptions, err := quotaOptions(deivceQuotaPathConfig.Fstype, deivceQuotaPathConfig.Options)
+		if err != nil {
+			results = append(results, model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceFailed,
+				"InvalidQuotaConfig", err.Error()))
+			continue
+		}
+		if layout := deivceQuotaPathConfig.Layout; layout != "" && layout != LayoutSingle {
+			results = append(results, qrm.mountRaidQuotaPath(mountPath, deivceQuotaPathConfig, mkfsOptions, mountOptions))
+			continue
+		}
+		err = qrm.mounter.EnsureFolder(mountPath)
 		if err != nil {
 			klog.Errorf("applyDeivceQuotaPath:: ensure quotapath error: %v", err)
+			results = append(results, model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceFailed,
+				"EnsureFolderFailed", err.Error()))
 			continue
 		}
 		klog.Infof("applyDeivceQuotaPath:: device quotapath config devices: %v", deivceQuotaPathConfig.Devices)
+		// the first device mounted successfully is used
+		result := model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceFailed,
+			"DeviceNotExists", fmt.Sprintf("none of devices %v exists in current node", deivceQuotaPathConfig.Devices))
 		for _, device := range deivceQuotaPathConfig.Devices {
 			if !qrm.mounter.FileExists(device) {
 				klog.Errorf("applyDeivceQuotaPath:: device %v not exists", device)
 				continue
 			}
-			err = qrm.mounter.FormatAndMount(device, mountPath, deivceQuotaPathConfig.Fstype, qrm.mkfsOption, deivceQuotaPathConfig.Options)
+			err = qrm.mounter.FormatAndMount(device, mountPath, deivceQuotaPathConfig.Fstype, mkfsOptions, mountOptions)
 			if err != nil {
 				if errors.Is(err, &CusErr.ExistsFormatErr{}) {
 					qrm.recorder.Event(ref, v1.EventTypeWarning, "ExistsFormatErr", err.Error())
 				}
 				klog.Errorf("applyDeivceQuotaPath:: device: %v, mounter FormatAndMount error: %v", device, err)
+				result = model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceFailed,
+					"MountFailed", fmt.Sprintf("mount device %s error: %v", device, err))
 				continue
 			}
+			result = qrm.verifyProjectQuota(mountPath, device)
 			break
 		}
+		results = append(results, result)
 	}
-	return nil
+	return results
 }
 
-func (qrm *ResourceManager) applyRegionQuotaPath() error {
+func (qrm *ResourceManager) applyRegionQuotaPath(mountedPaths map[string]k8smount.MountPoint) []*model.ResourceResult {
+	results := []*model.ResourceResult{}
 	for mountPath, regionQuotaPathConfig := range qrm.RegionQuotaPath {
+		if mountPoint, ok := mountedPaths[mountPath]; ok {
+			results = append(results, mountedResult(mountPoint))
+			continue
+		}
 		devicePath, _, err := qrm.pmemer.GetPmemNamespaceDeivcePath(regionQuotaPathConfig.Region, "fsdax")
 		if err != nil {
 			if strings.Contains(err.Error(), "list Namespace for region get 0 or multi namespaces") {
 				err := qrm.pmemer.CreateNamespace(regionQuotaPathConfig.Region, "lvm")
 				if err != nil {
 					klog.Errorf("applyRegionQuotaPath:: create namespace for region [%s], error: %v", regionQuotaPathConfig.Region, err)
+					results = append(results, model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceFailed,
+						"CreateNamespaceFailed", err.Error()))
 					continue
 				}
 				devicePath, _, err = qrm.pmemer.GetPmemNamespaceDeivcePath(regionQuotaPathConfig.Region, "fsdax")
 				if err != nil {
 					klog.Errorf("applyRegionQuotaPath:: get namespace device path for region [%s], error: %v", regionQuotaPathConfig.Region, err)
+					results = append(results, model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceProgressing,
+						"NamespaceNotReady", err.Error()))
 					continue
 				}
 			} else {
 				klog.Errorf("applyRegionQuotaPath:: get region [%s] namespace device path error: %v", regionQuotaPathConfig.Region, err)
+				results = append(results, model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceFailed,
+					"GetNamespaceFailed", err.Error()))
 				continue
 			}
 		}
+		mkfsOptions, mountOptions, err := quotaOptions(regionQuotaPathConfig.Fstype, regionQuotaPathConfig.Options)
+		if err != nil {
+			results = append(results, model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceFailed,
+				"InvalidQuotaConfig", err.Error()))
+			continue
+		}
 		err = qrm.mounter.EnsureFolder(mountPath)
 		if err != nil {
 			klog.Errorf("applyRegionQuotaPath:: ensure quotapath error: %v", err)
+			results = append(results, model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceFailed,
+				"EnsureFolderFailed", err.Error()))
 			continue
 		}
-		err = qrm.mounter.FormatAndMount(devicePath, mountPath, regionQuotaPathConfig.Fstype, qrm.mkfsOption, regionQuotaPathConfig.Options)
+		err = qrm.mounter.FormatAndMount(devicePath, mountPath, regionQuotaPathConfig.Fstype, mkfsOptions, mountOptions)
 		if err != nil {
 			klog.Errorf("applyRegionQuotaPath:: mounter FormatAndMount error: %v", err)
+			results = append(results, model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceFailed,
+				"MountFailed", fmt.Sprintf("mount device %s error: %v", devicePath, err)))
 			continue
 		}
+		results = append(results, qrm.verifyProjectQuota(mountPath, devicePath))
+	}
+	return results
+}
+
+// mountedResult is ready if the quota path already mounted has project quota enabled
+func mountedResult(mountPoint k8smount.MountPoint) *model.ResourceResult {
+	if !isProjectQuotaMount(mountPoint.Opts) {
+		return model.NewResourceResult(config.ResourceKindQuotaPath, mountPoint.Path, model.ResourceFailed, "ProjectQuotaNotEnabled",
+			fmt.Sprintf("mounted from %s without project quota, options: %s", mountPoint.Device, strings.Join(mountPoint.Opts, ",")))
+	}
+	return model.NewResourceResult(config.ResourceKindQuotaPath, mountPoint.Path, model.ResourceReady,
+		"QuotaPathReady", fmt.Sprintf("mounted from %s", mountPoint.Device))
+}
+
+// verifyProjectQuota checks the options of quota path just mounted from device, the kernel shows the quota really enabled,
+// e.g. xfs shows noquota if the filesystem is mounted without project quota
+func (qrm *ResourceManager) verifyProjectQuota(mountPath, device string) *model.ResourceResult {
+	mounted := model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceReady,
+		"QuotaPathMounted", fmt.Sprintf("mounted from %s", device))
+	mountPoints, err := qrm.mounter.ListHostMounts()
+	if err != nil {
+		klog.Errorf("verifyProjectQuota:: list host mounts error: %v", err)
+		return model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceProgressing,
+			"VerifyProjectQuotaFailed", err.Error())
+	}
+	for _, mountPoint := range mountPoints {
+		if mountPoint.Path == mountPath && !isProjectQuotaMount(mountPoint.Opts) {
+			return mountedResult(mountPoint)
+		}
 	}
+	// the planned mount is not found in dry-run
+	return mounted
+}
+
+// RecordStatus record all project quota mount points in current node into status
+func (qrm *ResourceManager) RecordStatus(status *v1alpha1.NodeLocalResourceStatus) error {
+	mountPoints, err := qrm.mounter.ListHostMounts()
+	if err != nil {
+		klog.Errorf("RecordStatus:: list host mounts error: %v", err)
+		return err
+	}
+	// projects are only used for the usage of directories, status is recorded without them on error
+	projects, err := qrm.quotaer.ListProjects()
+	if err != nil {
+		klog.Errorf("RecordStatus:: list projects error: %v", err)
+	}
+	quotaPathStatusList := []v1alpha1.QuotaPathStatus{}
+	for _, mountPoint := range mountPoints {
+		if !isProjectQuotaMount(mountPoint.Opts) {
+			continue
+		}
+		quotaPathStatus := v1alpha1.QuotaPathStatus{
+			MountPath: mountPoint.Path,
+			Device:    mountPoint.Device,
+			Fstype:    mountPoint.Type,
+			Options:   strings.Join(mountPoint.Opts, ","),
+		}
+		fsStats, err := qrm.mounter.GetFsStats(mountPoint.Path)
+		if err != nil {
+			klog.Errorf("RecordStatus:: get filesystem stats of %s error: %v", mountPoint.Path, err)
+		} else {
+			quotaPathStatus.Capacity = int64(fsStats.Capacity)
+			quotaPathStatus.Used = int64(fsStats.Used)
+			quotaPathStatus.Available = int64(fsStats.Available)
+		}
+		quotaPathStatus.Directories = qrm.directoryStatus(mountPoint, projects)
+		quotaPathStatusList = append(quotaPathStatusList, quotaPathStatus)
+	}
+	sort.Slice(quotaPathStatusList, func(i, j int) bool { return quotaPathStatusList[i].MountPath < quotaPathStatusList[j].MountPath })
+	status.QuotaPaths = quotaPathStatusList
 	return nil
 }
+
+// isProjectQuotaMount check whether mount options enable project quota
+func isProjectQuotaMount(options []string) bool {
+	for _, option := range options {
+		switch option {
+		case "prjquota", "pquota", "pqnoenforce":
+			return true
+		}
+	}
+	return false
+}
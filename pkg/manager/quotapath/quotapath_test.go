@@ -25,13 +25,16 @@ import (
 	"testing"
 
 	"github.com/golang/mock/gomock"
+	"github.com/openyurtio/node-resource-manager/pkg/apis/nrm/v1alpha1"
 	"github.com/openyurtio/node-resource-manager/pkg/config"
 	"github.com/openyurtio/node-resource-manager/pkg/model"
 	"github.com/openyurtio/node-resource-manager/pkg/utils"
+	"github.com/openyurtio/node-resource-manager/pkg/utils/blockdev"
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/yaml.v2"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8smount "k8s.io/utils/mount"
 )
 
 func makeValidResourceYaml() *model.ResourceYaml {
@@ -75,12 +78,19 @@ func EnsureVolumeGroupEnv() (string, error, *ResourceManager) {
 
 	newMockVolumegroupResourceManager := func() *ResourceManager {
 		return &ResourceManager{
-			configPath: configPath,
+			source: &config.FileSource{ConfigDir: filepath.Dir(configPath)},
 		}
 	}
 	return configPath, nil, newMockVolumegroupResourceManager()
 }
 
+// newMockPersister returns a MountPersister without persisted mounts
+func newMockPersister(mockCtl *gomock.Controller) *utils.MockMountPersister {
+	mockPersister := utils.NewMockMountPersister(mockCtl)
+	mockPersister.EXPECT().ListPersistedMounts().Return([]model.PersistedMount{}, nil).AnyTimes()
+	return mockPersister
+}
+
 func TestAnalyseDiff(t *testing.T) {
 	mockCtl := gomock.NewController(t)
 	defer mockCtl.Finish()
@@ -92,6 +102,7 @@ func TestAnalyseDiff(t *testing.T) {
 	mockPmemer := utils.NewMockPmemer(mockCtl)
 	mockMounter := utils.NewMockMounter(mockCtl)
 	resourceManager.mounter = mockMounter
+	resourceManager.persister = newMockPersister(mockCtl)
 	resourceManager.pmemer = mockPmemer
 	setOpInOperatorElement := func(m *model.ResourceYaml) {
 		m.Key = "bar"
@@ -136,18 +147,553 @@ func TestAnalyseDiff(t *testing.T) {
 	assert.Equal(t, 1, len(resourceManager.RegionQuotaPath))
 	assert.Equal(t, 1, len(resourceManager.DeviceQuotaPath))
 	gomock.InOrder(
+		mockMounter.EXPECT().ListHostMounts().Return([]k8smount.MountPoint{}, nil),
 		mockMounter.EXPECT().EnsureFolder(
 			gomock.Eq("/tmp/foo1")).Return(nil),
 		mockMounter.EXPECT().FileExists(
 			gomock.Eq("/dev/vdc")).Return(true),
 		mockMounter.EXPECT().FormatAndMount(
 			gomock.Eq("/dev/vdc"), gomock.Eq("/tmp/foo1"), gomock.Eq("ext4"), gomock.Eq([]string{"-O", "project,quota"}), gomock.Eq("prjquota")).Return(nil),
+		mockMounter.EXPECT().ListHostMounts().Return([]k8smount.MountPoint{
+			{Device: "/dev/vdc", Path: "/tmp/foo1", Type: "ext4", Opts: []string{"rw", "relatime", "prjquota"}},
+		}, nil),
 		mockPmemer.EXPECT().GetPmemNamespaceDeivcePath(
 			gomock.Eq("region0"), gomock.Eq("fsdax")).Return("/dev/pmem0", "", nil),
 		mockMounter.EXPECT().EnsureFolder(
 			gomock.Eq("/tmp/foo")).Return(nil),
 		mockMounter.EXPECT().FormatAndMount(
 			gomock.Eq("/dev/pmem0"), gomock.Eq("/tmp/foo"), gomock.Eq("ext4"), gomock.Eq([]string{"-O", "project,quota"}), gomock.Eq("prjquota,shared")).Return(nil),
+		mockMounter.EXPECT().ListHostMounts().Return([]k8smount.MountPoint{
+			{Device: "/dev/pmem0", Path: "/tmp/foo", Type: "ext4", Opts: []string{"rw", "relatime", "prjquota"}},
+		}, nil),
+	)
+	results, err := resourceManager.ApplyResourceDiff()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(results))
+	assert.Equal(t, "/tmp/foo", results[0].Name)
+	assert.Equal(t, model.ResourceReady, results[0].Condition)
+	assert.Equal(t, "/tmp/foo1", results[1].Name)
+	assert.Equal(t, model.ResourceReady, results[1].Condition)
+}
+
+func TestQuotaOptions(t *testing.T) {
+	for _, c := range []struct {
+		fstype, options string
+		mkfsOptions     []string
+		mountOptions    string
+	}{
+		{"", "", []string{"-O", "project,quota"}, "prjquota"},
+		{"ext4", "noatime", []string{"-O", "project,quota"}, "noatime,prjquota"},
+		{"xfs", "", nil, "prjquota"},
+		{"xfs", "pquota,noatime", nil, "pquota,noatime"},
+		{"xfs", "pqnoenforce", nil, "pqnoenforce"},
+	} {
+		mkfsOptions, mountOptions, err := quotaOptions(c.fstype, c.options)
+		assert.Nil(t, err)
+		assert.Equal(t, c.mkfsOptions, mkfsOptions)
+		assert.Equal(t, c.mountOptions, mountOptions)
+	}
+	for _, c := range [][]string{{"ext4", "pquota"}, {"ext3", "prjquota"}, {"btrfs", ""}} {
+		_, _, err := quotaOptions(c[0], c[1])
+		assert.NotNil(t, err, "%v", c)
+	}
+}
+
+func TestApplyResourceDiffXfs(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+	mockMounter := utils.NewMockMounter(mockCtl)
+	resourceManager := &ResourceManager{
+		mounter:   mockMounter,
+		persister: newMockPersister(mockCtl),
+		DeviceQuotaPath: map[string]*QpConfig{
+			"/mnt/path1": {Type: "device", Fstype: "xfs", Options: "pquota", Devices: []string{"/dev/vdb"}},
+			"/mnt/path2": {Type: "device", Fstype: "xfs", Devices: []string{"/dev/vdc"}},
+			"/mnt/path3": {Type: "device", Fstype: "xfs", Devices: []string{"/dev/vdd"}},
+			"/mnt/path4": {Type: "device", Fstype: "ext4", Options: "pquota", Devices: []string{"/dev/vde"}},
+		},
+	}
+
+	mounted := []k8smount.MountPoint{
+		{Device: "/dev/vdd", Path: "/mnt/path3", Type: "xfs", Opts: []string{"rw", "relatime", "noquota"}},
+	}
+	mockMounter.EXPECT().ListHostMounts().Return(mounted, nil)
+	mockMounter.EXPECT().EnsureFolder(gomock.Any()).Return(nil).AnyTimes()
+	mockMounter.EXPECT().FileExists(gomock.Any()).Return(true).AnyTimes()
+	// xfs is formatted without the ext4 features, and mounted with project quota
+	mockMounter.EXPECT().FormatAndMount(gomock.Eq("/dev/vdb"), gomock.Eq("/mnt/path1"), gomock.Eq("xfs"), gomock.Nil(), gomock.Eq("pquota")).
+		DoAndReturn(func(source, target, fstype string, mkfsOptions []string, mountOptions string) error {
+			mounted = append(mounted, k8smount.MountPoint{Device: source, Path: target, Type: fstype, Opts: []string{"rw", "relatime", "prjquota"}})
+			return nil
+		})
+	// the kernel shows noquota if project quota is not enabled
+	mockMounter.EXPECT().FormatAndMount(gomock.Eq("/dev/vdc"), gomock.Eq("/mnt/path2"), gomock.Eq("xfs"), gomock.Nil(), gomock.Eq("prjquota")).
+		DoAndReturn(func(source, target, fstype string, mkfsOptions []string, mountOptions string) error {
+			mounted = append(mounted, k8smount.MountPoint{Device: source, Path: target, Type: fstype, Opts: []string{"rw", "relatime", "noquota"}})
+			return nil
+		})
+	mockMounter.EXPECT().ListHostMounts().DoAndReturn(func() ([]k8smount.MountPoint, error) { return mounted, nil }).Times(2)
+
+	results, err := resourceManager.ApplyResourceDiff()
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(results))
+	assert.Equal(t, model.ResourceReady, results[0].Condition)
+	assert.Equal(t, "QuotaPathMounted", results[0].Reason)
+	assert.Equal(t, model.ResourceFailed, results[1].Condition)
+	assert.Equal(t, "ProjectQuotaNotEnabled", results[1].Reason)
+	assert.Equal(t, "mounted from /dev/vdc without project quota, options: rw,relatime,noquota", results[1].Message)
+	assert.Equal(t, "ProjectQuotaNotEnabled", results[2].Reason)
+	assert.Equal(t, model.ResourceFailed, results[3].Condition)
+	assert.Equal(t, "InvalidQuotaConfig", results[3].Reason)
+}
+
+func TestApplyResourceDiffRaid(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+	mockMounter := utils.NewMockMounter(mockCtl)
+	mockRaider := utils.NewMockRaider(mockCtl)
+	mockChecker := blockdev.NewMockChecker(mockCtl)
+	resourceManager := &ResourceManager{
+		mounter:   mockMounter,
+		persister: newMockPersister(mockCtl),
+		raider:    mockRaider,
+		checker:   mockChecker,
+		DeviceQuotaPath: map[string]*QpConfig{
+			"/mnt/path1": {Type: "device", Layout: "raid0", Devices: []string{"/dev/vdb", "/dev/vdc"}},
+		},
+	}
+
+	// the array is created on all devices, then formatted and mounted
+	gomock.InOrder(
+		mockMounter.EXPECT().ListHostMounts().Return([]k8smount.MountPoint{}, nil),
+		mockRaider.EXPECT().ListArrays().Return([]*model.RaidArray{}, nil),
+		mockMounter.EXPECT().FileExists(gomock.Eq("/dev/vdb")).Return(true),
+		mockMounter.EXPECT().FileExists(gomock.Eq("/dev/vdc")).Return(true),
+		mockChecker.EXPECT().Check(gomock.Eq("/dev/vdb")).Return([]blockdev.Usage{}, nil),
+		mockChecker.EXPECT().Check(gomock.Eq("/dev/vdc")).Return([]blockdev.Usage{}, nil),
+		mockRaider.EXPECT().CreateArray(gomock.Eq("qp_mnt_path1"), gomock.Eq("raid0"), gomock.Eq([]string{"/dev/vdb", "/dev/vdc"})).Return("", nil),
+		mockMounter.EXPECT().EnsureFolder(gomock.Eq("/mnt/path1")).Return(nil),
+		mockMounter.EXPECT().FormatAndMount(gomock.Eq("/dev/md/qp_mnt_path1"), gomock.Eq("/mnt/path1"), gomock.Eq(""),
+			gomock.Eq([]string{"-O", "project,quota"}), gomock.Eq("prjquota")).Return(nil),
+		mockMounter.EXPECT().ListHostMounts().Return([]k8smount.MountPoint{
+			{Device: "/dev/md127", Path: "/mnt/path1", Type: "ext4", Opts: []string{"rw", "prjquota"}},
+		}, nil),
+	)
+	results, err := resourceManager.ApplyResourceDiff()
+	assert.Nil(t, err)
+	assert.Equal(t, "QuotaPathMounted", results[0].Reason)
+	assert.Equal(t, "mounted from /dev/md/qp_mnt_path1", results[0].Message)
+
+	// an existing array of another level is not used
+	gomock.InOrder(
+		mockMounter.EXPECT().ListHostMounts().Return([]k8smount.MountPoint{}, nil),
+		mockRaider.EXPECT().ListArrays().Return([]*model.RaidArray{
+			{Path: "/dev/md/qp_mnt_path1", Name: "qp_mnt_path1", Level: "raid1", Devices: []string{"/dev/vdb", "/dev/vdc"}},
+		}, nil),
+	)
+	results, err = resourceManager.ApplyResourceDiff()
+	assert.Nil(t, err)
+	assert.Equal(t, "RaidLayoutMismatch", results[0].Reason)
+
+	// no array is created if any device is in use
+	gomock.InOrder(
+		mockMounter.EXPECT().ListHostMounts().Return([]k8smount.MountPoint{}, nil),
+		mockRaider.EXPECT().ListArrays().Return([]*model.RaidArray{}, nil),
+		mockMounter.EXPECT().FileExists(gomock.Any()).Return(true).Times(2),
+		mockChecker.EXPECT().Check(gomock.Eq("/dev/vdb")).Return([]blockdev.Usage{{Message: "ext4 signature"}}, nil),
+		mockChecker.EXPECT().Check(gomock.Eq("/dev/vdc")).Return([]blockdev.Usage{}, nil),
+	)
+	results, err = resourceManager.ApplyResourceDiff()
+	assert.Nil(t, err)
+	assert.Equal(t, model.ResourceFailed, results[0].Condition)
+	assert.Equal(t, "DeviceInUse", results[0].Reason)
+	assert.Equal(t, "devices are in use: /dev/vdb (ext4 signature)", results[0].Message)
+
+	// raid needs at least 2 devices, and layout must be valid
+	resourceManager.DeviceQuotaPath["/mnt/path1"].Devices = []string{"/dev/vdb"}
+	resourceManager.DeviceQuotaPath["/mnt/path2"] = &QpConfig{Type: "device", Layout: "raid5", Devices: []string{"/dev/vdd", "/dev/vde"}}
+	gomock.InOrder(
+		mockMounter.EXPECT().ListHostMounts().Return([]k8smount.MountPoint{}, nil),
+		mockRaider.EXPECT().ListArrays().Return([]*model.RaidArray{}, nil),
+	)
+	results, err = resourceManager.ApplyResourceDiff()
+	assert.Nil(t, err)
+	assert.Equal(t, "InvalidQuotaConfig", results[0].Reason)
+	assert.Equal(t, "layout raid0 needs at least 2 devices, got [/dev/vdb]", results[0].Message)
+	assert.Equal(t, "InvalidQuotaConfig", results[1].Reason)
+}
+
+func TestAnalyseConfigMapDeviceSelector(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+	configPath, err, resourceManager := EnsureVolumeGroupEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(configPath)
+	mockDiscoverer := blockdev.NewMockDiscoverer(mockCtl)
+	resourceManager.discoverer = mockDiscoverer
+
+	testYamls := QPList{QuotaPaths: []model.ResourceYaml{
+		{Name: "/mnt/path1", Key: "bar", Operator: metav1.LabelSelectorOpIn, Value: "foo", Topology: model.Topology{
+			Type: "device", DeviceSelector: &model.DeviceSelector{Model: []string{"INTEL*"}, MinSize: "1Ti"},
+		}},
+	}}
+	d, err := yaml.Marshal(&testYamls)
+	if err != nil {
+		t.Error()
+	}
+	err = ioutil.WriteFile(configPath, d, 0777)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tib := uint64(1024 * 1024 * 1024 * 1024)
+	mockDiscoverer.EXPECT().ListDisks().Return([]*blockdev.Disk{
+		{Name: "nvme0n1", Path: "/dev/nvme0n1", Size: 4 * tib, Model: "INTEL SSDPE2KX040T8"},
+		{Name: "nvme1n1", Path: "/dev/nvme1n1", Size: tib / 2, Model: "INTEL SSDPE2KX005T8"},
+	}, nil)
+	assert.Nil(t, resourceManager.AnalyseConfigMap())
+	assert.Equal(t, []string{"/dev/nvme0n1"}, resourceManager.DeviceQuotaPath["/mnt/path1"].Devices)
+}
+
+func TestNewLvmConfig(t *testing.T) {
+	conf := newLvmConfig("/mnt/path1", model.Topology{Type: "lvm", Fstype: "xfs", VolumeGroup: "volumegroup1", Size: "10G"})
+	assert.Equal(t, "", conf.LvmError)
+	assert.Equal(t, "quotapath_mnt_path1", conf.LogicalVolume)
+	// size is rounded up to the extent of volume group on apply
+	assert.Equal(t, uint64(10000000000), conf.Size)
+
+	invalid := []model.Topology{
+		{Type: "lvm", Size: "10Gi"},
+		{Type: "lvm", VolumeGroup: "volumegroup1"},
+		{Type: "lvm", VolumeGroup: "volumegroup1", Size: "-1Gi"},
+		{Type: "lvm", VolumeGroup: "-vg", Size: "10Gi"},
+	}
+	for _, topology := range invalid {
+		assert.NotEqual(t, "", newLvmConfig("/mnt/path1", topology).LvmError, "%v", topology)
+	}
+}
+
+func TestApplyResourceDiffLvm(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+	mockMounter := utils.NewMockMounter(mockCtl)
+	mockLvm := utils.NewMockLVM(mockCtl)
+	gib := uint64(1 << 30)
+	resourceManager := &ResourceManager{
+		mounter:       mockMounter,
+		persister:     newMockPersister(mockCtl),
+		lvmer:         mockLvm,
+		growFSPending: map[string]bool{},
+		LvmQuotaPath: map[string]*QpConfig{
+			"/mnt/path1": {Type: "lvm", VolumeGroup: "volumegroup1", LogicalVolume: "quotapath_mnt_path1", Size: 10 * gib},
+			"/mnt/path2": {Type: "lvm", VolumeGroup: "volumegroup1", LogicalVolume: "quotapath_mnt_path2", Size: 20 * gib},
+			"/mnt/path3": {Type: "lvm", VolumeGroup: "volumegroup2", LogicalVolume: "quotapath_mnt_path3", Size: 10 * gib},
+			"/mnt/path4": {Type: "lvm", VolumeGroup: "volumegroup3", LogicalVolume: "quotapath_mnt_path4", Size: 10 * gib},
+			"/mnt/path5": {Type: "lvm", LvmError: "invalid size \"0\""},
+		},
+	}
+
+	mounted := []k8smount.MountPoint{
+		{Device: "/dev/mapper/volumegroup1-quotapath_mnt_path2", Path: "/mnt/path2", Type: "ext4", Opts: []string{"rw", "prjquota"}},
+	}
+	// host mounts are listed again to verify the quota path just mounted
+	mockMounter.EXPECT().ListHostMounts().DoAndReturn(func() ([]k8smount.MountPoint, error) { return mounted, nil }).MinTimes(1).MaxTimes(2)
+	mockLvm.EXPECT().ListVG().Return([]*model.VG{
+		{Name: "volumegroup1", Size: 100 * gib, FreeSize: 15 * gib, Tags: []string{utils.ManagedTagName}},
+		{Name: "volumegroup2", Size: 100 * gib, FreeSize: 100 * gib},
+	}, nil)
+	mockLvm.EXPECT().ListLV(gomock.Eq("volumegroup1")).Return([]*model.LV{{Name: "quotapath_mnt_path2", Size: 10 * gib}}, nil).Times(2)
+	// path1 is created and mounted, path2 is extended and its filesystem grown
+	mockLvm.EXPECT().CreateLV(gomock.Eq("volumegroup1"), gomock.Eq("quotapath_mnt_path1"), gomock.Eq(10*gib), gomock.Eq(uint32(0)),
+		gomock.Eq([]string{utils.ManagedTagName})).Return("", nil).MaxTimes(1)
+	mockMounter.EXPECT().EnsureFolder(gomock.Eq("/mnt/path1")).Return(nil).MaxTimes(1)
+	mockMounter.EXPECT().FormatAndMount(gomock.Eq("/dev/volumegroup1/quotapath_mnt_path1"), gomock.Eq("/mnt/path1"), gomock.Eq(""),
+		gomock.Eq([]string{"-O", "project,quota"}), gomock.Eq("prjquota")).
+		DoAndReturn(func(source, target, fstype string, mkfsOptions []string, mountOptions string) error {
+			mounted = append(mounted, k8smount.MountPoint{Device: source, Path: target, Type: "ext4", Opts: []string{"rw", "prjquota"}})
+			return nil
+		}).MaxTimes(1)
+	mockLvm.EXPECT().ExtendLV(gomock.Eq("volumegroup1"), gomock.Eq("quotapath_mnt_path2"), gomock.Eq(20*gib)).Return("", nil).MaxTimes(1)
+	mockMounter.EXPECT().GrowFS(gomock.Eq("/dev/volumegroup1/quotapath_mnt_path2")).Return("/mnt/path2", nil).MaxTimes(1)
+
+	results, err := resourceManager.ApplyResourceDiff()
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(results))
+	// the volume group has 15Gi free, which is only enough for the first of path1 and path2
+	byName := map[string]*model.ResourceResult{}
+	for _, result := range results {
+		byName[result.Name] = result
+	}
+	if byName["/mnt/path1"].Condition == model.ResourceReady {
+		assert.Equal(t, "QuotaPathMounted", byName["/mnt/path1"].Reason)
+		assert.Equal(t, "InsufficientFreeSpace", byName["/mnt/path2"].Reason)
+	} else {
+		assert.Equal(t, "InsufficientFreeSpace", byName["/mnt/path1"].Reason)
+		assert.Equal(t, "QuotaPathExtended", byName["/mnt/path2"].Reason)
+		assert.Equal(t, "logical volume quotapath_mnt_path2 extended from 10737418240 to 21474836480, filesystem grown", byName["/mnt/path2"].Message)
+	}
+	assert.Equal(t, "VolumeGroupNotOwned", byName["/mnt/path3"].Reason)
+	assert.Equal(t, model.ResourceProgressing, byName["/mnt/path4"].Condition)
+	assert.Equal(t, "VolumeGroupNotReady", byName["/mnt/path4"].Reason)
+	assert.Equal(t, "InvalidQuotaConfig", byName["/mnt/path5"].Reason)
+}
+
+func TestCheckLvmQuotaPath(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+	mockMounter := utils.NewMockMounter(mockCtl)
+	mockLvm := utils.NewMockLVM(mockCtl)
+	resourceManager := &ResourceManager{mounter: mockMounter, lvmer: mockLvm, growFSPending: map[string]bool{}}
+	gib := uint64(1 << 30)
+	mountPoint := k8smount.MountPoint{Device: "/dev/mapper/vg1-quotapath_mnt_path1", Path: "/mnt/path1", Type: "ext4", Opts: []string{"prjquota"}}
+	qpConfig := &QpConfig{Type: "lvm", VolumeGroup: "vg1", LogicalVolume: "quotapath_mnt_path1", Size: 20 * gib}
+	vg := &model.VG{Name: "vg1", FreeSize: 50 * gib, Tags: []string{utils.ManagedTagName}}
+
+	result := resourceManager.checkLvmQuotaPath(mountPoint, qpConfig, &model.LV{Name: "quotapath_mnt_path1", Size: 30 * gib}, vg)
+	assert.Equal(t, "ShrinkNotSupported", result.Reason)
+
+	// a failed grow is retried until it succeeds
+	gomock.InOrder(
+		mockLvm.EXPECT().ExtendLV(gomock.Eq("vg1"), gomock.Eq("quotapath_mnt_path1"), gomock.Eq(20*gib)).Return("", nil),
+		mockMounter.EXPECT().GrowFS(gomock.Eq("/dev/vg1/quotapath_mnt_path1")).Return("", fmt.Errorf("resize2fs failed")),
+		mockMounter.EXPECT().GrowFS(gomock.Eq("/dev/vg1/quotapath_mnt_path1")).Return("/mnt/path1", nil),
+	)
+	result = resourceManager.checkLvmQuotaPath(mountPoint, qpConfig, &model.LV{Name: "quotapath_mnt_path1", Size: 10 * gib}, vg)
+	assert.Equal(t, "GrowFilesystemFailed", result.Reason)
+	assert.Equal(t, 40*gib, vg.FreeSize)
+	result = resourceManager.checkLvmQuotaPath(mountPoint, qpConfig, &model.LV{Name: "quotapath_mnt_path1", Size: 20 * gib}, vg)
+	assert.Equal(t, "QuotaPathExtended", result.Reason)
+	result = resourceManager.checkLvmQuotaPath(mountPoint, qpConfig, &model.LV{Name: "quotapath_mnt_path1", Size: 20 * gib}, vg)
+	assert.Equal(t, "QuotaPathReady", result.Reason)
+}
+
+func TestApplyResourceDiffPersistence(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+	mockMounter := utils.NewMockMounter(mockCtl)
+	mockPersister := utils.NewMockMountPersister(mockCtl)
+	resourceManager := &ResourceManager{
+		mounter:   mockMounter,
+		persister: mockPersister,
+		DeviceQuotaPath: map[string]*QpConfig{
+			"/mnt/path1": {Type: "device", Persistence: "fstab", Devices: []string{"/dev/vdb"}},
+			"/mnt/path2": {Type: "device", Fstype: "xfs", Persistence: "systemd", Devices: []string{"/dev/vdc"}},
+		},
+	}
+
+	mounted := []k8smount.MountPoint{
+		{Device: "/dev/vdb", Path: "/mnt/path1", Type: "ext4", Opts: []string{"rw", "relatime", "prjquota"}},
+		{Device: "/dev/vdc", Path: "/mnt/path2", Type: "xfs", Opts: []string{"rw", "relatime", "prjquota"}},
+	}
+	// path1 is persisted another way and path3 is removed from topology
+	persisted := []model.PersistedMount{
+		{Persistence: "systemd", UUID: "1111", Path: "/mnt/path1", Fstype: "ext4", Options: "prjquota"},
+		{Persistence: "fstab", UUID: "3333", Path: "/mnt/path3", Fstype: "ext4", Options: "prjquota,nofail"},
+	}
+	mockMounter.EXPECT().ListHostMounts().Return(mounted, nil).Times(2)
+	mockPersister.EXPECT().ListPersistedMounts().Return(persisted, nil)
+	mockPersister.EXPECT().RemovePersistedMount(gomock.Eq(persisted[0])).Return(nil)
+	mockPersister.EXPECT().RemovePersistedMount(gomock.Eq(persisted[1])).Return(nil)
+	mockPersister.EXPECT().GetFsUUID(gomock.Eq("/dev/vdb")).Return("1111", nil)
+	mockPersister.EXPECT().PersistMount(gomock.Eq(model.PersistedMount{
+		Persistence: "fstab", UUID: "1111", Path: "/mnt/path1", Fstype: "ext4", Options: "prjquota"})).Return(nil)
+	mockPersister.EXPECT().GetFsUUID(gomock.Eq("/dev/vdc")).Return("", fmt.Errorf("device /dev/vdc has no filesystem uuid"))
+	results, err := resourceManager.ApplyResourceDiff()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(results))
+	assert.Equal(t, model.ResourceReady, results[0].Condition)
+	assert.Equal(t, model.ResourceFailed, results[1].Condition)
+	assert.Equal(t, "PersistMountFailed", results[1].Reason)
+
+	// mounts persisted are removed on node without quota path
+	resourceManager.DeviceQuotaPath = map[string]*QpConfig{}
+	mockPersister.EXPECT().ListPersistedMounts().Return(persisted[1:], nil)
+	mockPersister.EXPECT().RemovePersistedMount(gomock.Eq(persisted[1])).Return(nil)
+	results, err = resourceManager.ApplyResourceDiff()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(results))
+}
+
+func TestParseDirectories(t *testing.T) {
+	dirConfigs, err := parseDirectories("/mnt/path1", []model.QuotaDirectory{
+		{Name: "app1", BlockSoftLimit: "1000", BlockHardLimit: "1Gi", InodeHardLimit: 1000},
+		{Name: "data/app2", InodeSoftLimit: 10},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []*DirectoryConfig{
+		{Path: "/mnt/path1/app1", Quota: model.ProjectQuota{BlockSoftLimit: 1024, BlockHardLimit: 1 << 30, InodeHardLimit: 1000}},
+		{Path: "/mnt/path1/data/app2", Quota: model.ProjectQuota{InodeSoftLimit: 10}},
+	}, dirConfigs)
+
+	invalid := [][]model.QuotaDirectory{
+		{{Name: ""}},
+		{{Name: "."}},
+		{{Name: "/mnt/path1/app1"}},
+		{{Name: "../app1"}},
+		{{Name: "app1/"}},
+		{{Name: "app 1"}},
+		{{Name: "app1"}, {Name: "app1/data"}},
+		{{Name: "app1", BlockHardLimit: "-1Gi"}},
+		{{Name: "app1", BlockSoftLimit: "2Gi", BlockHardLimit: "1Gi"}},
+		{{Name: "app1", InodeSoftLimit: 20, InodeHardLimit: 10}},
+	}
+	for _, directories := range invalid {
+		_, err := parseDirectories("/mnt/path1", directories)
+		assert.NotNil(t, err, "%v", directories)
+	}
+}
+
+func TestApplyResourceDiffDirectories(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+	mockMounter := utils.NewMockMounter(mockCtl)
+	mockQuotaer := utils.NewMockQuotaer(mockCtl)
+	gib := uint64(1 << 30)
+	resourceManager := &ResourceManager{
+		mounter:   mockMounter,
+		persister: newMockPersister(mockCtl),
+		quotaer:   mockQuotaer,
+		DeviceQuotaPath: map[string]*QpConfig{
+			"/mnt/path1": {Type: "device", Devices: []string{"/dev/vdb"}, Directories: []*DirectoryConfig{
+				{Path: "/mnt/path1/app1", Quota: model.ProjectQuota{BlockHardLimit: gib}},
+				{Path: "/mnt/path1/app2", Quota: model.ProjectQuota{BlockHardLimit: 2 * gib, InodeHardLimit: 1000}},
+				{Path: "/mnt/path1/app3", Quota: model.ProjectQuota{BlockSoftLimit: gib, BlockHardLimit: 2 * gib}},
+			}},
+			"/mnt/path2": {Type: "device", Devices: []string{"/dev/vdc"}, DirectoryError: "invalid directory \"/app1\""},
+		},
+	}
+
+	gomock.InOrder(
+		mockMounter.EXPECT().ListHostMounts().Return([]k8smount.MountPoint{
+			{Device: "/dev/vdb", Path: "/mnt/path1", Type: "xfs", Opts: []string{"rw", "prjquota"}},
+			{Device: "/dev/vdc", Path: "/mnt/path2", Type: "ext4", Opts: []string{"rw", "prjquota"}},
+		}, nil),
+		// app1 is unchanged, app2 has other limits, app3 is new and allocated the next id of projects and quotas
+		mockQuotaer.EXPECT().ListProjects().Return([]model.Project{
+			{ID: 10000, Name: "nrm_mnt_path1_app1", Path: "/mnt/path1/app1"},
+			{ID: 10001, Name: "nrm_mnt_path1_app2", Path: "/mnt/path1/app2"},
+		}, nil),
+		mockQuotaer.EXPECT().ListProjectQuotas(gomock.Eq("xfs"), gomock.Eq("/mnt/path1")).Return([]model.ProjectQuota{
+			{ID: 10000, BlockUsed: 4096, BlockHardLimit: gib, InodeUsed: 1},
+			{ID: 10001, BlockUsed: 4096, BlockHardLimit: gib, InodeUsed: 1},
+			{ID: 10005, BlockUsed: 4096, InodeUsed: 1},
+		}, nil),
+		mockQuotaer.EXPECT().SetProjectLimits(gomock.Eq("xfs"), gomock.Eq("/mnt/path1"),
+			gomock.Eq(model.ProjectQuota{ID: 10001, BlockHardLimit: 2 * gib, InodeHardLimit: 1000})).Return(nil),
+		mockQuotaer.EXPECT().AddProject(gomock.Eq(model.Project{ID: 10006, Name: "nrm_mnt_path1_app3", Path: "/mnt/path1/app3"})).Return(nil),
+		mockMounter.EXPECT().EnsureFolder(gomock.Eq("/mnt/path1/app3")).Return(nil),
+		mockQuotaer.EXPECT().SetProject(gomock.Eq("xfs"), gomock.Eq("/mnt/path1"),
+			gomock.Eq(model.Project{ID: 10006, Name: "nrm_mnt_path1_app3", Path: "/mnt/path1/app3"})).Return(nil),
+		mockQuotaer.EXPECT().SetProjectLimits(gomock.Eq("xfs"), gomock.Eq("/mnt/path1"),
+			gomock.Eq(model.ProjectQuota{ID: 10006, BlockSoftLimit: gib, BlockHardLimit: 2 * gib})).Return(nil),
+	)
+	results, err := resourceManager.ApplyResourceDiff()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(results))
+	assert.Equal(t, model.ResourceReady, results[0].Condition)
+	assert.Equal(t, "ProjectQuotaUpdated", results[0].Reason)
+	assert.Equal(t, "project quota of [/mnt/path1/app2 /mnt/path1/app3] updated", results[0].Message)
+	assert.Equal(t, model.ResourceFailed, results[1].Condition)
+	assert.Equal(t, "InvalidQuotaDirectory", results[1].Reason)
+
+	// nothing is changed if all directories have the project and limits
+	resourceManager.DeviceQuotaPath["/mnt/path1"].Directories = resourceManager.DeviceQuotaPath["/mnt/path1"].Directories[:1]
+	gomock.InOrder(
+		mockMounter.EXPECT().ListHostMounts().Return([]k8smount.MountPoint{
+			{Device: "/dev/vdb", Path: "/mnt/path1", Type: "xfs", Opts: []string{"rw", "prjquota"}},
+		}, nil),
+		mockQuotaer.EXPECT().ListProjects().Return([]model.Project{{ID: 10000, Name: "nrm_mnt_path1_app1", Path: "/mnt/path1/app1"}}, nil),
+		mockQuotaer.EXPECT().ListProjectQuotas(gomock.Eq("xfs"), gomock.Eq("/mnt/path1")).Return([]model.ProjectQuota{
+			{ID: 10000, BlockUsed: 4096, BlockHardLimit: gib, InodeUsed: 1},
+		}, nil),
 	)
-	assert.Nil(t, resourceManager.ApplyResourceDiff())
+	delete(resourceManager.DeviceQuotaPath, "/mnt/path2")
+	results, err = resourceManager.ApplyResourceDiff()
+	assert.Nil(t, err)
+	assert.Equal(t, "QuotaPathReady", results[0].Reason)
+
+	// a failed directory fails the quota path
+	gomock.InOrder(
+		mockMounter.EXPECT().ListHostMounts().Return([]k8smount.MountPoint{
+			{Device: "/dev/vdb", Path: "/mnt/path1", Type: "ext4", Opts: []string{"rw", "prjquota"}},
+		}, nil),
+		mockQuotaer.EXPECT().ListProjects().Return([]model.Project{}, nil),
+		mockQuotaer.EXPECT().ListProjectQuotas(gomock.Eq("ext4"), gomock.Eq("/mnt/path1")).Return([]model.ProjectQuota{}, nil),
+		mockQuotaer.EXPECT().AddProject(gomock.Any()).Return(nil),
+		mockMounter.EXPECT().EnsureFolder(gomock.Eq("/mnt/path1/app1")).Return(nil),
+		mockQuotaer.EXPECT().SetProject(gomock.Eq("ext4"), gomock.Eq("/mnt/path1"), gomock.Any()).Return(fmt.Errorf("chattr: Operation not supported")),
+	)
+	results, err = resourceManager.ApplyResourceDiff()
+	assert.Nil(t, err)
+	assert.Equal(t, model.ResourceFailed, results[0].Condition)
+	assert.Equal(t, "ProjectQuotaFailed", results[0].Reason)
+	assert.Equal(t, "set project 10000 of /mnt/path1/app1 error: chattr: Operation not supported", results[0].Message)
+}
+
+func TestRecordStatus(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+	mockMounter := utils.NewMockMounter(mockCtl)
+	mockQuotaer := utils.NewMockQuotaer(mockCtl)
+	resourceManager := &ResourceManager{mounter: mockMounter, quotaer: mockQuotaer}
+
+	gomock.InOrder(
+		mockMounter.EXPECT().ListHostMounts().Return([]k8smount.MountPoint{
+			{Device: "/dev/vda1", Path: "/", Type: "ext4", Opts: []string{"rw", "relatime"}},
+			{Device: "/dev/vdb", Path: "/mnt/path1", Type: "ext4", Opts: []string{"rw", "prjquota"}},
+			{Device: "/dev/vdc", Path: "/mnt/path2", Type: "xfs", Opts: []string{"rw", "prjquota"}},
+		}, nil),
+		mockQuotaer.EXPECT().ListProjects().Return([]model.Project{
+			{ID: 10000, Name: "nrm_mnt_path2_app1", Path: "/mnt/path2/app1"},
+			{ID: 10001, Name: "nrm_mnt_path20_app1", Path: "/mnt/path20/app1"},
+		}, nil),
+		mockMounter.EXPECT().GetFsStats(gomock.Eq("/mnt/path1")).Return(&model.FsStats{Capacity: 100, Used: 40, Available: 60}, nil),
+		mockMounter.EXPECT().GetFsStats(gomock.Eq("/mnt/path2")).Return(&model.FsStats{Capacity: 100, Used: 10, Available: 90}, nil),
+		mockQuotaer.EXPECT().ListProjectQuotas(gomock.Eq("xfs"), gomock.Eq("/mnt/path2")).Return([]model.ProjectQuota{
+			{ID: 0, BlockUsed: 4096, InodeUsed: 3},
+			{ID: 10000, BlockUsed: 8192, BlockHardLimit: 1048576, InodeUsed: 2, InodeHardLimit: 100},
+		}, nil),
+	)
+	status := &v1alpha1.NodeLocalResourceStatus{}
+	assert.Nil(t, resourceManager.RecordStatus(status))
+	assert.Equal(t, []v1alpha1.QuotaPathStatus{
+		{MountPath: "/mnt/path1", Device: "/dev/vdb", Fstype: "ext4", Options: "rw,prjquota", Capacity: 100, Used: 40, Available: 60},
+		{MountPath: "/mnt/path2", Device: "/dev/vdc", Fstype: "xfs", Options: "rw,prjquota", Capacity: 100, Used: 10, Available: 90,
+			Directories: []v1alpha1.QuotaDirectoryStatus{
+				{Path: "/mnt/path2/app1", ProjectID: 10000, BlockUsed: 8192, BlockHardLimit: 1048576, InodeUsed: 2, InodeHardLimit: 100},
+			}},
+	}, status.QuotaPaths)
+}
+
+func TestAudit(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+	mockMounter := utils.NewMockMounter(mockCtl)
+	resourceManager := &ResourceManager{
+		mounter: mockMounter,
+		DeviceQuotaPath: map[string]*QpConfig{
+			"/mnt/path1": {Type: "device", Options: "prjquota", Devices: []string{"/dev/vdb"}},
+			"/mnt/path2": {Type: "device", Fstype: "xfs", Options: "defaults,prjquota,noatime", Devices: []string{"/dev/vdc"}},
+		},
+		RegionQuotaPath: map[string]*QpConfig{
+			"/mnt/path3": {Type: "pmem", Region: "region0"},
+		},
+	}
+
+	mockMounter.EXPECT().ListHostMounts().Return([]k8smount.MountPoint{
+		{Device: "/dev/vda1", Path: "/", Type: "ext4", Opts: []string{"rw", "relatime"}},
+		{Device: "/dev/vdb", Path: "/mnt/path1", Type: "ext4", Opts: []string{"rw", "relatime", "prjquota"}},
+		{Device: "/dev/vdc", Path: "/mnt/path2", Type: "ext4", Opts: []string{"rw", "relatime", "prjquota"}},
+		{Device: "/dev/pmem0", Path: "/mnt/path3", Type: "ext4", Opts: []string{"rw"}},
+		{Device: "/dev/vdd", Path: "/mnt/manual", Type: "xfs", Opts: []string{"rw", "prjquota"}},
+	}, nil)
+	drifts, err := resourceManager.Audit()
+	assert.Nil(t, err)
+	assert.Equal(t, []v1alpha1.DriftStatus{
+		{Kind: "quotapath", Name: "/mnt/path2", Reason: "FstypeMismatch", Message: "fstype is ext4, expect xfs"},
+		{Kind: "quotapath", Name: "/mnt/path2", Reason: "MountOptionsMismatch", Message: "mount options [noatime] are missing in rw,relatime,prjquota"},
+		{Kind: "quotapath", Name: "/mnt/manual", Reason: "NotInTopology", Message: "mounted from /dev/vdd with project quota"},
+	}, drifts)
 }
@@ -0,0 +1,130 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quotapath
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/openyurtio/node-resource-manager/pkg/config"
+	"github.com/openyurtio/node-resource-manager/pkg/model"
+	"github.com/openyurtio/node-resource-manager/pkg/utils"
+	klog "k8s.io/klog/v2"
+)
+
+const (
+	// LayoutSingle mounts the first existing device of quota path
+	LayoutSingle = "single"
+	// LayoutRaid0 stripes quota path across all devices
+	LayoutRaid0 = "raid0"
+	// LayoutRaid1 mirrors quota path on all devices
+	LayoutRaid1 = "raid1"
+)
+
+// raidName returns the md array name of quota path, e.g. qp_mnt_path1 for /mnt/path1
+func raidName(mountPath string) string {
+	return "qp" + strings.NewReplacer("/", "_", ":", "_", "@", "_", "+", "_").Replace(mountPath)
+}
+
+// mountRaidQuotaPath formats and mounts the md array built on all devices of quota path
+func (qrm *ResourceManager) mountRaidQuotaPath(mountPath string, qpConfig *QpConfig, mkfsOptions []string, mountOptions string) *model.ResourceResult {
+	devicePath, failed := qrm.ensureRaid(mountPath, qpConfig)
+	if failed != nil {
+		return failed
+	}
+	if err := qrm.mounter.EnsureFolder(mountPath); err != nil {
+		klog.Errorf("mountRaidQuotaPath:: ensure quotapath error: %v", err)
+		return model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceFailed, "EnsureFolderFailed", err.Error())
+	}
+	if err := qrm.mounter.FormatAndMount(devicePath, mountPath, qpConfig.Fstype, mkfsOptions, mountOptions); err != nil {
+		klog.Errorf("mountRaidQuotaPath:: mounter FormatAndMount error: %v", err)
+		return model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceFailed,
+			"MountFailed", fmt.Sprintf("mount device %s error: %v", devicePath, err))
+	}
+	return qrm.verifyProjectQuota(mountPath, devicePath)
+}
+
+// ensureRaid returns the path of md array of quota path, the array is created on all devices if not exists.
+// Every device must exist and be unused before the array is created, returns the failed result otherwise.
+func (qrm *ResourceManager) ensureRaid(mountPath string, qpConfig *QpConfig) (string, *model.ResourceResult) {
+	if qpConfig.Layout != LayoutRaid0 && qpConfig.Layout != LayoutRaid1 {
+		return "", model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceFailed,
+			"InvalidQuotaConfig", fmt.Sprintf("invalid layout %q, must be one of single, raid0, raid1", qpConfig.Layout))
+	}
+	name := raidName(mountPath)
+	if err := utils.ValidateRaidName(name); err != nil {
+		return "", model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceFailed, "InvalidQuotaConfig", err.Error())
+	}
+	arrays, err := qrm.raider.ListArrays()
+	if err != nil {
+		return "", model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceFailed, "ListRaidArrayFailed", err.Error())
+	}
+	for _, array := range arrays {
+		if array.Name != name {
+			continue
+		}
+		if array.Level != qpConfig.Layout {
+			return "", model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceFailed,
+				"RaidLayoutMismatch", fmt.Sprintf("md array %s is %s, expect %s", array.Path, array.Level, qpConfig.Layout))
+		}
+		klog.Infof("ensureRaid:: md array %s of quotapath %s exists on %v", array.Path, mountPath, array.Devices)
+		return array.Path, nil
+	}
+
+	if len(qpConfig.Devices) < 2 {
+		return "", model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceFailed,
+			"InvalidQuotaConfig", fmt.Sprintf("layout %s needs at least 2 devices, got %v", qpConfig.Layout, qpConfig.Devices))
+	}
+	missing := []string{}
+	for _, device := range qpConfig.Devices {
+		if !qrm.mounter.FileExists(device) {
+			missing = append(missing, device)
+		}
+	}
+	if len(missing) > 0 {
+		return "", model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceFailed,
+			"DeviceNotExists", fmt.Sprintf("devices %v not exist in current node", missing))
+	}
+	// mdadm runs without prompt, so devices with any signature are refused
+	refused := []string{}
+	for _, device := range qpConfig.Devices {
+		usages, err := qrm.checker.Check(device)
+		if err != nil {
+			klog.Errorf("ensureRaid:: check device %s of quotapath %s error: %v", device, mountPath, err)
+			return "", model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceFailed, "PreflightCheckFailed", err.Error())
+		}
+		if len(usages) == 0 {
+			continue
+		}
+		messages := []string{}
+		for _, usage := range usages {
+			messages = append(messages, usage.Message)
+		}
+		refused = append(refused, fmt.Sprintf("%s (%s)", device, strings.Join(messages, ", ")))
+	}
+	if len(refused) > 0 {
+		klog.Errorf("ensureRaid:: devices of quotapath %s are in use: %v", mountPath, refused)
+		return "", model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceFailed, "DeviceInUse",
+			fmt.Sprintf("devices are in use: %s", strings.Join(refused, "; ")))
+	}
+	out, err := qrm.raider.CreateArray(name, qpConfig.Layout, qpConfig.Devices)
+	if err != nil {
+		return "", model.NewResourceResult(config.ResourceKindQuotaPath, mountPath, model.ResourceFailed, "CreateRaidArrayFailed", err.Error())
+	}
+	klog.Infof("ensureRaid:: Successful create %s array %s on %v with out: %s", qpConfig.Layout, name, qpConfig.Devices, out)
+	return "/dev/md/" + name, nil
+}
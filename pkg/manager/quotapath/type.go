@@ -27,6 +27,27 @@ type QpConfig struct {
 	Fstype  string
 	Region  string
 	Devices []string
+	// Layout is one of single, raid0, raid1 for device quota path, empty is single
+	Layout string
+	// Persistence is fstab or systemd to mount quota path on boot, empty is not persisted
+	Persistence string
+	// VolumeGroup, LogicalVolume and Size define the logical volume of lvm quota path, Size is in bytes,
+	// LvmError is the error of invalid lvm topology
+	VolumeGroup   string
+	LogicalVolume string
+	Size          uint64
+	LvmError      string
+	// SelectorError is the error of device selector, the quota path fails if set and not mounted
+	SelectorError string
+	// Directories are the directories limited by project quota, DirectoryError is the error of invalid directories
+	Directories    []*DirectoryConfig
+	DirectoryError string
+}
+
+// DirectoryConfig is a directory of quota path with the limits of its project, block limits are in bytes
+type DirectoryConfig struct {
+	Path  string
+	Quota model.ProjectQuota
 }
 
 // QPList ...
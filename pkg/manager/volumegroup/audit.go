@@ -0,0 +1,71 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package volumegroup
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/openyurtio/node-resource-manager/pkg/apis/nrm/v1alpha1"
+	"github.com/openyurtio/node-resource-manager/pkg/config"
+	klog "k8s.io/klog/v2"
+)
+
+// DriftKindPhysicalVolume is the kind of drift of physical volume not in any volume group
+const DriftKindPhysicalVolume = "physicalvolume"
+
+// Audit reports the volume groups on node not in topology, the physical volumes not in any volume group,
+// and the physical volumes of device volume groups not in topology devices
+func (vrm *ResourceManager) Audit() ([]v1alpha1.DriftStatus, error) {
+	pvs, err := vrm.lvmer.ListPhysicalVolume()
+	if err != nil {
+		klog.Errorf("Audit:: list physical volumes error: %v", err)
+		return nil, err
+	}
+	orphanPvs, err := vrm.lvmer.ListOrphanPhysicalVolume()
+	if err != nil {
+		klog.Errorf("Audit:: list orphan physical volumes error: %v", err)
+		return nil, err
+	}
+	nodeVgs := map[string][]string{}
+	drifts := []v1alpha1.DriftStatus{}
+	for _, pv := range orphanPvs {
+		drifts = append(drifts, v1alpha1.DriftStatus{Kind: DriftKindPhysicalVolume, Name: pv.Name,
+			Reason: "NotInTopology", Message: "physical volume is not in any volume group"})
+	}
+	for _, pv := range pvs {
+		nodeVgs[pv.VgName] = append(nodeVgs[pv.VgName], pv.Name)
+	}
+
+	for vgName, vgPvs := range nodeVgs {
+		sort.Strings(vgPvs)
+		_, isRegion := vrm.volumeGroupRegionMap[vgName]
+		deviceConfig, isDevice := vrm.volumeGroupDeviceMap[vgName]
+		switch {
+		case isDevice:
+			if extraPvs := difference(vgPvs, deviceConfig.PhysicalVolumes); len(extraPvs) > 0 {
+				drifts = append(drifts, v1alpha1.DriftStatus{Kind: config.ResourceKindVolumeGroup, Name: vgName,
+					Reason: "ExtraPhysicalVolumes", Message: fmt.Sprintf("physical volumes %v are not in topology devices", extraPvs)})
+			}
+		case isRegion, vrm.absentVolumeGroups[vgName]:
+		default:
+			drifts = append(drifts, v1alpha1.DriftStatus{Kind: config.ResourceKindVolumeGroup, Name: vgName,
+				Reason: "NotInTopology", Message: fmt.Sprintf("volume group with physical volumes %v is not in topology", vgPvs)})
+		}
+	}
+	return drifts, nil
+}
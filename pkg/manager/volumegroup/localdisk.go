@@ -0,0 +1,95 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package volumegroup
+
+import (
+	"fmt"
+
+	"github.com/openyurtio/node-resource-manager/pkg/model"
+	"github.com/openyurtio/node-resource-manager/pkg/utils/blockdev"
+	klog "k8s.io/klog/v2"
+)
+
+// getLocalDisks returns the physical volumes of localdisk volume group vgName: the disks already in it,
+// and the unused whole disks on node, both filtered by include, exclude and device selector of topology.
+// Disks of other volume groups, with partitions, mounted, held by dm or md, or with a signature are never taken.
+func (vrm *ResourceManager) getLocalDisks(vgName string, topology model.Topology, resolver *blockdev.Resolver) ([]string, error) {
+	if err := blockdev.ValidatePatterns(topology.Include); err != nil {
+		return nil, fmt.Errorf("include: %v", err)
+	}
+	if err := blockdev.ValidatePatterns(topology.Exclude); err != nil {
+		return nil, fmt.Errorf("exclude: %v", err)
+	}
+	disks, err := resolver.Disks()
+	if err != nil {
+		return nil, err
+	}
+	var selected map[string]bool
+	if topology.DeviceSelector != nil {
+		paths, err := blockdev.Select(disks, topology.DeviceSelector)
+		if err != nil {
+			return nil, err
+		}
+		selected = map[string]bool{}
+		for _, path := range paths {
+			selected[path] = true
+		}
+	}
+	pvs, err := vrm.lvmer.ListPhysicalVolume()
+	if err != nil {
+		return nil, fmt.Errorf("list physical volumes error: %v", err)
+	}
+	orphanPvs, err := vrm.lvmer.ListOrphanPhysicalVolume()
+	if err != nil {
+		return nil, fmt.Errorf("list orphan physical volumes error: %v", err)
+	}
+	// orphan physical volumes are kept with empty volume group
+	pvVgs := map[string]string{}
+	for _, pv := range append(pvs, orphanPvs...) {
+		pvVgs[pv.Name] = pv.VgName
+	}
+
+	localDisks := []string{}
+	for _, disk := range disks {
+		switch {
+		case len(topology.Include) > 0 && !blockdev.MatchDevice(disk, topology.Include):
+		case blockdev.MatchDevice(disk, topology.Exclude):
+		case selected != nil && !selected[disk.Path]:
+		default:
+			vg, isPv := pvVgs[disk.Path]
+			reason := disk.InUse()
+			switch {
+			case isPv && vg == vgName:
+				reason = ""
+			case isPv && vg != "":
+				reason = fmt.Sprintf("disk is in volume group %s", vg)
+			case isPv:
+				// the lvm signature of an orphan physical volume is reused by vgcreate
+				orphan := *disk
+				orphan.FSType = ""
+				reason = orphan.InUse()
+			}
+			if reason != "" {
+				klog.V(3).Infof("getLocalDisks:: skip %s: %s", disk.Path, reason)
+				continue
+			}
+			localDisks = append(localDisks, disk.Path)
+		}
+	}
+	klog.V(3).Infof("getLocalDisks:: local disks of volume group %s: %v", vgName, localDisks)
+	return localDisks, nil
+}
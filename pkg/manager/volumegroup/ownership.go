@@ -0,0 +1,209 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package volumegroup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/openyurtio/node-resource-manager/pkg/config"
+	"github.com/openyurtio/node-resource-manager/pkg/model"
+	"github.com/openyurtio/node-resource-manager/pkg/utils"
+	"gopkg.in/yaml.v2"
+	klog "k8s.io/klog/v2"
+)
+
+// specHash returns the short hash of topology, which changes when the spec of volume group changes
+func specHash(topology model.Topology) string {
+	data, err := yaml.Marshal(&topology)
+	if err != nil {
+		klog.Errorf("specHash:: marshal topology %+v error: %v", topology, err)
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// ownerTags returns the tags of volume group created by node-resource-manager, spec hash is omitted if empty
+func ownerTags(vgName, hash string) []string {
+	tags := []string{utils.ManagedTagName, utils.OwnerTagPrefix + vgName}
+	if hash != "" {
+		tags = append(tags, utils.SpecHashTagPrefix+hash)
+	}
+	return tags
+}
+
+// pvTags returns the tags of physical volumes in volume group created by node-resource-manager
+func pvTags(vgName string) []string {
+	return []string{utils.ManagedTagName, utils.OwnerTagPrefix + vgName}
+}
+
+// isManaged returns whether volume group has the managed tag
+func isManaged(vg *model.VG) bool {
+	return vg != nil && hasTag(vg, utils.ManagedTagName)
+}
+
+// listVgs lists the volume groups on node once per reconcile
+func (vrm *ResourceManager) listVgs() ([]*model.VG, error) {
+	if vrm.nodeVgs != nil {
+		return vrm.nodeVgs, nil
+	}
+	vgs, err := vrm.lvmer.ListVG()
+	if err != nil {
+		klog.Errorf("listVgs:: list volume groups error: %v", err)
+		return nil, err
+	}
+	if vgs == nil {
+		vgs = []*model.VG{}
+	}
+	vrm.nodeVgs = vgs
+	return vgs, nil
+}
+
+// findVg returns the volume group listed on node, nil if not found
+func (vrm *ResourceManager) findVg(vgName string) (*model.VG, error) {
+	vgs, err := vrm.listVgs()
+	if err != nil {
+		return nil, err
+	}
+	for _, vg := range vgs {
+		if vg.Name == vgName {
+			return vg, nil
+		}
+	}
+	return nil, nil
+}
+
+// tagPvs tags the physical volumes added to volume group, a failure is only logged as the volume group tags decide the ownership
+func (vrm *ResourceManager) tagPvs(vgName string, pvs []string) {
+	if _, err := vrm.lvmer.AddTagPV(pvs, pvTags(vgName)); err != nil {
+		klog.Errorf("tagPvs:: tag physical volumes %v of volume group %s error: %v", pvs, vgName, err)
+	}
+}
+
+// adoptVg tags an existing volume group and its physical volumes as managed
+func (vrm *ResourceManager) adoptVg(vg *model.VG) error {
+	hash := ""
+	if owner := vrm.owners[vg.Name]; owner != nil {
+		hash = owner.SpecHash
+	}
+	tags := ownerTags(vg.Name, hash)
+	if _, err := vrm.lvmer.AddTagVG(vg.Name, tags); err != nil {
+		klog.Errorf("adoptVg:: tag volume group %s error: %v", vg.Name, err)
+		return err
+	}
+	vg.Tags = append(vg.Tags, tags...)
+	if pvs := vrm.nodePvs[vg.Name]; len(pvs) > 0 {
+		vrm.tagPvs(vg.Name, pvs)
+	}
+	klog.Infof("adoptVg:: Successful adopt volume group %s", vg.Name)
+	return nil
+}
+
+// mayAdopt returns whether the volume group without the managed tag is adopted, i.e. adopt is set in topology,
+// or it matches the spec exactly in the first reconcile after upgrading from a version without ownership tags
+func (vrm *ResourceManager) mayAdopt(vgName string) bool {
+	owner := vrm.owners[vgName]
+	if owner == nil {
+		return false
+	}
+	return owner.Adopt || (!vrm.reconciled && vrm.matchedVgs[vgName])
+}
+
+// checkOwner returns nil if the existing volume group may be changed, i.e. it has the managed tag,
+// or it is adopted now as mayAdopt allows; otherwise returns the failed result.
+func (vrm *ResourceManager) checkOwner(vgName string) *model.ResourceResult {
+	vg, err := vrm.findVg(vgName)
+	if err != nil {
+		return model.NewResourceResult(config.ResourceKindVolumeGroup, vgName, model.ResourceFailed, "ListVolumeGroupFailed", err.Error())
+	}
+	if isManaged(vg) {
+		return nil
+	}
+	if vg != nil && vrm.mayAdopt(vgName) {
+		if err := vrm.adoptVg(vg); err != nil {
+			return model.NewResourceResult(config.ResourceKindVolumeGroup, vgName, model.ResourceFailed, "AdoptVolumeGroupFailed", err.Error())
+		}
+		return nil
+	}
+	klog.Errorf("checkOwner:: refuse to change volume group %s without tag %s", vgName, utils.ManagedTagName)
+	return model.NewResourceResult(config.ResourceKindVolumeGroup, vgName, model.ResourceFailed,
+		"VolumeGroupNotOwned", fmt.Sprintf("volume group has no tag %s, e.g. created by hand or by an older node-resource-manager, set adopt to manage it",
+			utils.ManagedTagName))
+}
+
+// refreshOwnerTags adopts the ready volume groups if mayAdopt allows, and updates the spec hash tag of managed ones,
+// volume groups created in this reconcile are tagged already
+func (vrm *ResourceManager) refreshOwnerTags(results []*model.ResourceResult) []*model.ResourceResult {
+	for i, result := range results {
+		owner := vrm.owners[result.Name]
+		if result.Condition != model.ResourceReady || owner == nil || vrm.absentVolumeGroups[result.Name] {
+			continue
+		}
+		if _, isVgExist := vrm.nodePvs[result.Name]; !isVgExist {
+			continue
+		}
+		vg, err := vrm.findVg(result.Name)
+		if err != nil || vg == nil {
+			continue
+		}
+		if !isManaged(vg) {
+			if vrm.mayAdopt(result.Name) {
+				if err := vrm.adoptVg(vg); err != nil {
+					results[i] = model.NewResourceResult(config.ResourceKindVolumeGroup, result.Name, model.ResourceFailed, "AdoptVolumeGroupFailed", err.Error())
+				}
+			}
+			continue
+		}
+		if owner.SpecHash == "" {
+			continue
+		}
+		staleTags := []string{}
+		for _, tag := range vg.Tags {
+			if strings.HasPrefix(tag, utils.SpecHashTagPrefix) && tag != utils.SpecHashTagPrefix+owner.SpecHash {
+				staleTags = append(staleTags, tag)
+			}
+		}
+		if len(staleTags) == 0 && hasTag(vg, utils.SpecHashTagPrefix+owner.SpecHash) {
+			continue
+		}
+		if len(staleTags) > 0 {
+			if _, err := vrm.lvmer.RemoveTagVG(vg.Name, staleTags); err != nil {
+				klog.Errorf("refreshOwnerTags:: remove tags %v of volume group %s error: %v", staleTags, vg.Name, err)
+				continue
+			}
+		}
+		if _, err := vrm.lvmer.AddTagVG(vg.Name, []string{utils.SpecHashTagPrefix + owner.SpecHash}); err != nil {
+			klog.Errorf("refreshOwnerTags:: tag volume group %s error: %v", vg.Name, err)
+			continue
+		}
+		klog.Infof("refreshOwnerTags:: Successful update spec hash of volume group %s to %s", vg.Name, owner.SpecHash)
+	}
+	return results
+}
+
+// hasTag returns whether volume group has tag
+func hasTag(vg *model.VG, tag string) bool {
+	for _, vgTag := range vg.Tags {
+		if vgTag == tag {
+			return true
+		}
+	}
+	return false
+}
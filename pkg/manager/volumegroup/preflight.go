@@ -0,0 +1,67 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package volumegroup
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/openyurtio/node-resource-manager/pkg/config"
+	"github.com/openyurtio/node-resource-manager/pkg/model"
+	klog "k8s.io/klog/v2"
+)
+
+// preflight checks devices before they are added to volume group vgName, and wipes their signatures if wipe is set.
+// Returns the failed result if any device is in use, then no device is touched.
+func (vrm *ResourceManager) preflight(vgName string, devices []string, wipe bool) *model.ResourceResult {
+	refused := []string{}
+	wipeDevices := []string{}
+	for _, device := range devices {
+		usages, err := vrm.checker.Check(device)
+		if err != nil {
+			klog.Errorf("preflight:: check device %s of volume group %s error: %v", device, vgName, err)
+			return model.NewResourceResult(config.ResourceKindVolumeGroup, vgName, model.ResourceFailed, "PreflightCheckFailed", err.Error())
+		}
+		if len(usages) == 0 {
+			continue
+		}
+		messages := []string{}
+		active := false
+		for _, usage := range usages {
+			messages = append(messages, usage.Message)
+			active = active || usage.Active
+		}
+		// devices in use are never wiped
+		if active || !wipe {
+			klog.Errorf("preflight:: device %s of volume group %s is in use: %v", device, vgName, messages)
+			refused = append(refused, fmt.Sprintf("%s (%s)", device, strings.Join(messages, ", ")))
+			continue
+		}
+		wipeDevices = append(wipeDevices, device)
+	}
+	if len(refused) > 0 {
+		return model.NewResourceResult(config.ResourceKindVolumeGroup, vgName, model.ResourceFailed, "DeviceInUse",
+			fmt.Sprintf("devices are in use: %s", strings.Join(refused, "; ")))
+	}
+	for _, device := range wipeDevices {
+		if err := vrm.checker.Wipe(device); err != nil {
+			return model.NewResourceResult(config.ResourceKindVolumeGroup, vgName, model.ResourceFailed, "WipeDeviceFailed", err.Error())
+		}
+		klog.Infof("preflight:: wiped signatures of device %s for volume group %s", device, vgName)
+	}
+	return nil
+}
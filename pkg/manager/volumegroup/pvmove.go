@@ -0,0 +1,172 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package volumegroup
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/openyurtio/node-resource-manager/pkg/config"
+	"github.com/openyurtio/node-resource-manager/pkg/model"
+	"github.com/openyurtio/node-resource-manager/pkg/utils"
+	klog "k8s.io/klog/v2"
+)
+
+// droppedPvs returns the physical volumes of volume group explicitly dropped from spec, i.e. not in the listed devices
+// or in the excluded devices, and present in current node. A missing device, or one filtered out by selector or patterns,
+// is kept in volume group, so a transient failure of discovery never shrinks the volume group.
+func (vrm *ResourceManager) droppedPvs(vgName string, expectVg *VgDeviceConfig, realPvs []string) []string {
+	dropped := []string{}
+	for _, pv := range difference(realPvs, expectVg.PhysicalVolumes) {
+		listed := len(expectVg.ListedDevices) == 0 || utils.IsPart(expectVg.ListedDevices, []string{pv})
+		if listed && !utils.IsPart(expectVg.ExcludedDevices, []string{pv}) {
+			continue
+		}
+		if !vrm.mounter.FileExists(pv) {
+			klog.Infof("droppedPvs:: physical volume %s of volume group %s not exists, keep it", pv, vgName)
+			continue
+		}
+		dropped = append(dropped, pv)
+	}
+	return dropped
+}
+
+// excludedDevices returns the exclude patterns which are device paths instead of globs
+func excludedDevices(patterns []string) []string {
+	devices := []string{}
+	for _, pattern := range patterns {
+		if !strings.ContainsAny(pattern, "*?[") {
+			devices = append(devices, pattern)
+		}
+	}
+	return devices
+}
+
+// shrinkVg removes the physical volumes not in spec from volume group one step per reconcile:
+// moves the data off a used physical volume with pvmove in background, reports the progress until the move finishes,
+// then removes the empty physical volumes from volume group and wipes their lvm labels.
+func (vrm *ResourceManager) shrinkVg(vgName string, removePvs []string) *model.ResourceResult {
+	sort.Strings(removePvs)
+	failed := func(reason, message string) *model.ResourceResult {
+		klog.Errorf("shrinkVg:: remove %v from volume group %s: %s", removePvs, vgName, message)
+		return model.NewResourceResult(config.ResourceKindVolumeGroup, vgName, model.ResourceFailed, reason, message)
+	}
+
+	vg, err := vrm.findVg(vgName)
+	if err != nil {
+		return failed("ListVolumeGroupFailed", err.Error())
+	}
+	if vg != nil && hasTag(vg, utils.ProtectedTagName) {
+		return failed("VolumeGroupProtected", "volume group is protected")
+	}
+
+	// a running pvmove is shown as a hidden logical volume
+	lvs, err := vrm.lvmer.ListLV(vgName)
+	if err != nil {
+		return failed("ListLogicalVolumeFailed", err.Error())
+	}
+	for _, lv := range lvs {
+		if lv.Attributes.Type == model.VolumeTypePVMove {
+			klog.Infof("shrinkVg:: moving data off %v in volume group %s, %s%% copied", removePvs, vgName, lv.CopyPercent)
+			return model.NewResourceResult(config.ResourceKindVolumeGroup, vgName, model.ResourceProgressing,
+				"MovingPhysicalVolume", fmt.Sprintf("moving data off %v, %s%% copied", removePvs, lv.CopyPercent))
+		}
+	}
+
+	pvs, err := vrm.lvmer.ListPhysicalVolume()
+	if err != nil {
+		return failed("ListPhysicalVolumeFailed", err.Error())
+	}
+	removing := map[string]bool{}
+	for _, pv := range removePvs {
+		removing[pv] = true
+	}
+	destinations := []string{}
+	var destinationFree uint64
+	for _, pv := range pvs {
+		if pv.VgName == vgName && !removing[pv.Name] {
+			destinations = append(destinations, pv.Name)
+			destinationFree += pv.FreeSize
+		}
+	}
+	for _, pv := range pvs {
+		if pv.VgName != vgName || !removing[pv.Name] || pv.Size == pv.FreeSize {
+			continue
+		}
+		used := pv.Size - pv.FreeSize
+		if len(destinations) == 0 || used > destinationFree {
+			return failed("InsufficientFreeSpace", fmt.Sprintf("moving %d bytes off %s needs free extents, the other physical volumes %v have %d free",
+				used, pv.Name, destinations, destinationFree))
+		}
+		if _, err := vrm.lvmer.MovePV(pv.Name, destinations); err != nil {
+			return failed("MovePhysicalVolumeFailed", err.Error())
+		}
+		klog.Infof("shrinkVg:: Successful start moving %d bytes off %s to %v", used, pv.Name, destinations)
+		return model.NewResourceResult(config.ResourceKindVolumeGroup, vgName, model.ResourceProgressing,
+			"PhysicalVolumeMoveStarted", fmt.Sprintf("moving %d bytes off %s to %v", used, pv.Name, destinations))
+	}
+
+	// every removed physical volume is empty now, the labels are wiped again by removePendingPvs if pvremove fails,
+	// as the physical volumes are not in volume group any more
+	if _, err := vrm.lvmer.ReduceVG(vgName, removePvs); err != nil {
+		return failed("ReduceVolumeGroupFailed", err.Error())
+	}
+	vrm.pvRemovePending[vgName] = removePvs
+	if _, err := vrm.lvmer.RemovePV(removePvs); err != nil {
+		return failed("RemovePhysicalVolumeFailed", err.Error())
+	}
+	delete(vrm.pvRemovePending, vgName)
+	klog.Infof("shrinkVg:: Successful remove %v from volume group %s", removePvs, vgName)
+	return model.NewResourceResult(config.ResourceKindVolumeGroup, vgName, model.ResourceReady,
+		"VolumeGroupReduced", fmt.Sprintf("physical volumes %v removed", removePvs))
+}
+
+// removePendingPvs retries wiping the lvm labels of physical volumes removed from volume group by shrinkVg,
+// only the physical volumes still labeled without volume group are wiped, returns nil if none is pending
+func (vrm *ResourceManager) removePendingPvs(vgName string) *model.ResourceResult {
+	pending := vrm.pvRemovePending[vgName]
+	if len(pending) == 0 {
+		return nil
+	}
+	pvs, err := vrm.lvmer.ListOrphanPhysicalVolume()
+	if err != nil {
+		klog.Errorf("removePendingPvs:: list orphan physical volumes error: %v", err)
+		return model.NewResourceResult(config.ResourceKindVolumeGroup, vgName, model.ResourceFailed, "ListPhysicalVolumeFailed", err.Error())
+	}
+	orphans := []string{}
+	for _, pv := range pvs {
+		for _, name := range pending {
+			if pv.Name == name {
+				orphans = append(orphans, name)
+			}
+		}
+	}
+	if len(orphans) == 0 {
+		delete(vrm.pvRemovePending, vgName)
+		return nil
+	}
+	sort.Strings(orphans)
+	if _, err := vrm.lvmer.RemovePV(orphans); err != nil {
+		klog.Errorf("removePendingPvs:: remove %v of volume group %s error: %v", orphans, vgName, err)
+		return model.NewResourceResult(config.ResourceKindVolumeGroup, vgName, model.ResourceFailed, "RemovePhysicalVolumeFailed", err.Error())
+	}
+	delete(vrm.pvRemovePending, vgName)
+	klog.Infof("removePendingPvs:: Successful remove %v of volume group %s", orphans, vgName)
+	return model.NewResourceResult(config.ResourceKindVolumeGroup, vgName, model.ResourceReady,
+		"VolumeGroupReduced", fmt.Sprintf("physical volumes %v removed", orphans))
+}
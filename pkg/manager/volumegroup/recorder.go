@@ -15,3 +15,42 @@ limitations under the License.
 */
 
 package volumegroup
+
+import (
+	"sort"
+
+	"github.com/openyurtio/node-resource-manager/pkg/apis/nrm/v1alpha1"
+	klog "k8s.io/klog/v2"
+)
+
+// RecordStatus record all volume groups in current node into status
+func (vrm *ResourceManager) RecordStatus(status *v1alpha1.NodeLocalResourceStatus) error {
+	vgs, err := vrm.lvmer.ListVG()
+	if err != nil {
+		klog.Errorf("RecordStatus:: list volume groups error: %v", err)
+		return err
+	}
+	pvs, err := vrm.lvmer.ListPhysicalVolume()
+	if err != nil {
+		klog.Errorf("RecordStatus:: list physical volumes error: %v", err)
+		return err
+	}
+
+	vgStatusList := []v1alpha1.VolumeGroupStatus{}
+	for _, vg := range vgs {
+		vgStatus := v1alpha1.VolumeGroupStatus{
+			Name:     vg.Name,
+			Size:     int64(vg.Size),
+			FreeSize: int64(vg.FreeSize),
+		}
+		for _, pv := range pvs {
+			if pv.VgName == vg.Name {
+				vgStatus.PhysicalVolumes = append(vgStatus.PhysicalVolumes, v1alpha1.PhysicalVolumeStatus{Name: pv.Name, Size: int64(pv.Size)})
+			}
+		}
+		vgStatusList = append(vgStatusList, vgStatus)
+	}
+	sort.Slice(vgStatusList, func(i, j int) bool { return vgStatusList[i].Name < vgStatusList[j].Name })
+	status.VolumeGroups = vgStatusList
+	return nil
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package volumegroup
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/openyurtio/node-resource-manager/pkg/config"
+	"github.com/openyurtio/node-resource-manager/pkg/model"
+	"github.com/openyurtio/node-resource-manager/pkg/utils"
+	"k8s.io/apimachinery/pkg/api/resource"
+	klog "k8s.io/klog/v2"
+)
+
+const (
+	// DefaultThinPoolName is the name of thin pool if not set in topology
+	DefaultThinPoolName = "thinpool"
+)
+
+// parseThinPool converts thin pool topology to config
+func parseThinPool(thinPool *model.ThinPool) (*ThinPoolConfig, error) {
+	tpConfig := &ThinPoolConfig{Name: thinPool.Name}
+	if tpConfig.Name == "" {
+		tpConfig.Name = DefaultThinPoolName
+	}
+	if strings.HasSuffix(thinPool.Size, "%") {
+		percent, err := strconv.ParseUint(strings.TrimSuffix(thinPool.Size, "%"), 10, 32)
+		if err != nil || percent == 0 || percent > 100 {
+			return nil, fmt.Errorf("invalid size %q", thinPool.Size)
+		}
+		tpConfig.Percent = uint32(percent)
+	} else {
+		size, err := parseSize(thinPool.Size)
+		if err != nil || size == 0 {
+			return nil, fmt.Errorf("invalid size %q", thinPool.Size)
+		}
+		tpConfig.Size = size
+	}
+	var err error
+	if tpConfig.ChunkSize, err = parseSize(thinPool.ChunkSize); err != nil {
+		return nil, fmt.Errorf("invalid chunk size %q", thinPool.ChunkSize)
+	}
+	if tpConfig.MetadataSize, err = parseSize(thinPool.MetadataSize); err != nil {
+		return nil, fmt.Errorf("invalid metadata size %q", thinPool.MetadataSize)
+	}
+	return tpConfig, nil
+}
+
+// parseSize parses quantity to bytes, empty is 0
+func parseSize(size string) (uint64, error) {
+	if size == "" {
+		return 0, nil
+	}
+	quantity, err := resource.ParseQuantity(size)
+	if err != nil {
+		return 0, err
+	}
+	if quantity.Sign() < 0 {
+		return 0, fmt.Errorf("negative size %q", size)
+	}
+	return uint64(quantity.Value()), nil
+}
+
+// applyThinPools ensures the thin pool of every ready volume group, a failed thin pool fails its volume group
+func (vrm *ResourceManager) applyThinPools(results []*model.ResourceResult) []*model.ResourceResult {
+	if len(vrm.thinPools) == 0 && len(vrm.invalidThinPools) == 0 {
+		return results
+	}
+	vgs, err := vrm.listVgs()
+	for i, result := range results {
+		if result.Condition != model.ResourceReady {
+			continue
+		}
+		if message, ok := vrm.invalidThinPools[result.Name]; ok {
+			results[i] = model.NewResourceResult(config.ResourceKindVolumeGroup, result.Name, model.ResourceFailed, "InvalidThinPool", message)
+			continue
+		}
+		tpConfig, ok := vrm.thinPools[result.Name]
+		if !ok {
+			continue
+		}
+		if err != nil {
+			results[i] = model.NewResourceResult(config.ResourceKindVolumeGroup, result.Name, model.ResourceFailed, "ListVolumeGroupFailed", err.Error())
+			continue
+		}
+		var vg *model.VG
+		for _, actualVg := range vgs {
+			if actualVg.Name == result.Name {
+				vg = actualVg
+			}
+		}
+		if tpResult := vrm.ensureThinPool(result.Name, vg, tpConfig); tpResult != nil {
+			results[i] = tpResult
+		}
+	}
+	return results
+}
+
+// ensureThinPool creates the missing thin pool, or extends it to the desired size if volume group has enough free space,
+// thin pool is never shrunk.
+// vg is nil if volume group is just created and not listed, returns nil if thin pool is ready.
+func (vrm *ResourceManager) ensureThinPool(vgName string, vg *model.VG, tpConfig *ThinPoolConfig) *model.ResourceResult {
+	var pool *model.LV
+	if vg != nil {
+		lvs, err := vrm.lvmer.ListLV(vgName)
+		if err != nil {
+			klog.Errorf("ensureThinPool:: list logical volumes of %s error: %v", vgName, err)
+			return model.NewResourceResult(config.ResourceKindVolumeGroup, vgName, model.ResourceFailed, "ListLogicalVolumeFailed", err.Error())
+		}
+		for _, lv := range lvs {
+			if lv.Name == tpConfig.Name {
+				pool = lv
+			}
+		}
+	}
+
+	if pool == nil {
+		if vg != nil {
+			if result := vrm.checkOwner(vgName); result != nil {
+				return result
+			}
+		}
+		out, err := vrm.lvmer.CreateThinPool(vgName, tpConfig.Name, tpConfig.Size, tpConfig.Percent, tpConfig.ChunkSize, tpConfig.MetadataSize, nil)
+		if err != nil {
+			klog.Errorf("ensureThinPool:: create thin pool %s/%s error: %v", vgName, tpConfig.Name, err)
+			return model.NewResourceResult(config.ResourceKindVolumeGroup, vgName, model.ResourceFailed, "CreateThinPoolFailed", err.Error())
+		}
+		klog.Infof("ensureThinPool:: Successful create thin pool %s/%s with out: %s", vgName, tpConfig.Name, out)
+		return model.NewResourceResult(config.ResourceKindVolumeGroup, vgName, model.ResourceReady,
+			"ThinPoolCreated", fmt.Sprintf("thin pool %s created", tpConfig.Name))
+	}
+	if pool.Attributes.Type != model.VolumeTypeThinPool {
+		return model.NewResourceResult(config.ResourceKindVolumeGroup, vgName, model.ResourceFailed,
+			"ThinPoolConflict", fmt.Sprintf("logical volume %s exists and is not a thin pool", tpConfig.Name))
+	}
+
+	// lvm rounds size up to extent, and may take the metadata from percent of volume group,
+	// so the pool of percent is only extended if volume group grows by more than 1%
+	extentSize := utils.ExtentSize(vg)
+	expectSize, tolerance := tpConfig.Size, extentSize
+	if tpConfig.Percent > 0 {
+		expectSize = vg.Size * uint64(tpConfig.Percent) / 100 / extentSize * extentSize
+		if vg.Size/100 > tolerance {
+			tolerance = vg.Size / 100
+		}
+	}
+	if tpConfig.Percent == 0 && pool.Size > expectSize+tolerance {
+		klog.Errorf("ensureThinPool:: refuse to shrink thin pool %s/%s from %d to %d", vgName, tpConfig.Name, pool.Size, expectSize)
+		return model.NewResourceResult(config.ResourceKindVolumeGroup, vgName, model.ResourceFailed,
+			"ShrinkNotSupported", fmt.Sprintf("thin pool %s size is %d, shrinking to %d is not supported", tpConfig.Name, pool.Size, expectSize))
+	}
+	if pool.Size+tolerance > expectSize {
+		return nil
+	}
+	if expectSize-pool.Size > vg.FreeSize {
+		klog.Errorf("ensureThinPool:: volume group %s has %d free, growing thin pool %s to %d needs %d", vgName, vg.FreeSize, tpConfig.Name, expectSize, expectSize-pool.Size)
+		return model.NewResourceResult(config.ResourceKindVolumeGroup, vgName, model.ResourceFailed,
+			"InsufficientFreeSpace", fmt.Sprintf("growing thin pool %s from %d to %d needs %d bytes, volume group has %d free",
+				tpConfig.Name, pool.Size, expectSize, expectSize-pool.Size, vg.FreeSize))
+	}
+	if result := vrm.checkOwner(vgName); result != nil {
+		return result
+	}
+	out, err := vrm.lvmer.ExtendThinPool(vgName, tpConfig.Name, expectSize)
+	if err != nil {
+		klog.Errorf("ensureThinPool:: extend thin pool %s/%s error: %v", vgName, tpConfig.Name, err)
+		return model.NewResourceResult(config.ResourceKindVolumeGroup, vgName, model.ResourceFailed, "ExtendThinPoolFailed", err.Error())
+	}
+	klog.Infof("ensureThinPool:: Successful extend thin pool %s/%s to %d with out: %s", vgName, tpConfig.Name, expectSize, out)
+	return model.NewResourceResult(config.ResourceKindVolumeGroup, vgName, model.ResourceReady,
+		"ThinPoolExtended", fmt.Sprintf("thin pool %s extended to %d", tpConfig.Name, expectSize))
+}
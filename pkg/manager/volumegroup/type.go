@@ -24,6 +24,32 @@ import (
 type VgDeviceConfig struct {
 	Name            string   `json:"name,omitempty"`
 	PhysicalVolumes []string `json:"physicalVolumes,omitempty"`
+	// AllowShrink allows removing physical volumes not in PhysicalVolumes
+	AllowShrink bool `json:"allowShrink,omitempty"`
+	// Wipe allows wiping the signatures of devices before they are added
+	Wipe bool `json:"wipe,omitempty"`
+	// SelectorError is the error of device selector, the volume group fails if set
+	SelectorError string `json:"-"`
+	// ListedDevices is the devices listed in spec, a physical volume dropped from them is removed on shrink
+	ListedDevices []string `json:"-"`
+	// ExcludedDevices is the device paths excluded in spec, such physical volumes are removed on shrink
+	ExcludedDevices []string `json:"-"`
+}
+
+// ThinPoolConfig is the desired thin pool of volume group, sizes are in bytes,
+// Size is 0 if Percent of volume group is set, ChunkSize and MetadataSize are chosen by lvm if 0
+type ThinPoolConfig struct {
+	Name         string
+	Size         uint64
+	Percent      uint32
+	ChunkSize    uint64
+	MetadataSize uint64
+}
+
+// ownerConfig is the ownership of volume group in topology, SpecHash is empty for absent volume group
+type ownerConfig struct {
+	SpecHash string
+	Adopt    bool
 }
 
 // VgList ...
@@ -21,7 +21,6 @@ import (
 	"crypto/cipher"
 	"encoding/base64"
 	"encoding/json"
-	"fmt"
 	"io/ioutil"
 	"net/http"
 	"os"
@@ -41,8 +40,6 @@ const (
 	InstanceID = "instance-id"
 	// RegionIDTag is the region id tag
 	RegionIDTag = "region-id"
-	// NsenterCmd is the nsenter command
-	NsenterCmd = "/usr/bin/nsenter --mount=/proc/1/ns/mnt "
 )
 
 const (
@@ -74,20 +71,16 @@ type RoleAuth struct {
 	Code            string
 }
 
-func ListDevice(vgName string) []string {
-	cmd := fmt.Sprintf("%s pvscan | grep \"VG %s\" | awk '{print $2}'", NsenterCmd, vgName)
-	out, err := utils.Run(cmd)
+// ListDevice returns the physical volumes of volume group
+func ListDevice(lvm utils.LVM, vgName string) []string {
+	deviceList := []string{}
+	pvs, err := lvm.ListPhysicalVolume()
 	if err != nil {
-		devs := make([]string, 0)
-		return devs
+		return deviceList
 	}
-
-	outLines := strings.Split(out, "\n")
-	deviceList := []string{}
-	for _, line := range outLines {
-		line = strings.TrimSpace(line)
-		if line != "" && !strings.HasPrefix(line, "WARNING") {
-			deviceList = append(deviceList, line)
+	for _, pv := range pvs {
+		if pv.VgName == vgName {
+			deviceList = append(deviceList, pv.Name)
 		}
 	}
 	return deviceList
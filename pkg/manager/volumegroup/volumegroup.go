@@ -19,16 +19,16 @@ package volumegroup
 import (
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
-	"strings"
+	"sort"
 
 	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
 	"github.com/openyurtio/node-resource-manager/pkg/config"
 	CusErr "github.com/openyurtio/node-resource-manager/pkg/err"
+	"github.com/openyurtio/node-resource-manager/pkg/model"
 	"github.com/openyurtio/node-resource-manager/pkg/utils"
-	yaml "gopkg.in/yaml.v2"
+	"github.com/openyurtio/node-resource-manager/pkg/utils/blockdev"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/tools/record"
 	klog "k8s.io/klog/v2"
@@ -40,35 +40,68 @@ const (
 	VGConfigKey        = "volumegroup.json"
 	AliyunLocalDisk    = "aliyun-local-disk"
 
-	VgConfigFile = "/etc/unified-config/volumegroup"
-	VgTypeDevice = "device"
-	VgTypePvc    = "pvc"
-	VgTypeLocal  = "alibabacloud-local-disk"
-	VgTypePmem   = "pmem"
+	VgConfigFile    = "/etc/unified-config/volumegroup"
+	VgTypeDevice    = "device"
+	VgTypePvc       = "pvc"
+	VgTypeLocal     = "alibabacloud-local-disk"
+	VgTypeLocalDisk = "localdisk"
+	VgTypePmem      = "pmem"
 )
 
 // ResourceManager ...
 type ResourceManager struct {
 	volumeGroupDeviceMap map[string]*VgDeviceConfig
 	volumeGroupRegionMap map[string][]string
-	mounter              utils.Mounter
-	pmemer               utils.Pmemer
-	lvmer                utils.LVM
-	configPath           string
-	recorder             record.EventRecorder
+	// thinPools is the thin pool of volume group by name, invalidThinPools is the error of invalid ones
+	thinPools        map[string]*ThinPoolConfig
+	invalidThinPools map[string]string
+	// absentVolumeGroups is the volume groups to remove
+	absentVolumeGroups map[string]bool
+	// owners is the ownership of every volume group in topology
+	owners map[string]*ownerConfig
+	// matchedVgs is the existing volume groups whose physical volumes are exactly the expected ones in this reconcile,
+	// they are adopted without the adopt option in the first reconcile, e.g. created by an older node-resource-manager
+	matchedVgs map[string]bool
+	// reconciled is set once the first reconcile is done
+	reconciled bool
+	// pvRemovePending is the physical volumes removed from volume group by shrinkVg whose labels are not wiped yet
+	pvRemovePending map[string][]string
+	// nodeVgs and nodePvs are the volume groups and their physical volumes on node, listed once per reconcile
+	nodeVgs    []*model.VG
+	nodePvs    map[string][]string
+	mounter    utils.Mounter
+	pmemer     utils.Pmemer
+	lvmer      utils.LVM
+	discoverer blockdev.Discoverer
+	checker    blockdev.Checker
+	source     config.TopologySource
+	recorder   record.EventRecorder
 }
 
-// NewResourceManager ...
-func NewResourceManager() *ResourceManager {
-	return &ResourceManager{
+// NewResourceManager create manager, commands are run by executor, changes are recorded into plan instead of executed if plan is not nil
+func NewResourceManager(source config.TopologySource, executor utils.CommandExecutor, plan *utils.Plan) *ResourceManager {
+	vrm := &ResourceManager{
 		volumeGroupDeviceMap: make(map[string]*VgDeviceConfig),
 		volumeGroupRegionMap: make(map[string][]string),
-		pmemer:               utils.NewNodePmemer(),
-		mounter:              utils.NewMounter(),
-		lvmer:                utils.NewNodeLVM(),
-		configPath:           "/etc/unified-config/volumegroup",
-		recorder:             utils.NewEventRecorder(),
-	}
+		pvRemovePending:      map[string][]string{},
+		discoverer:           blockdev.NewDiscoverer(),
+		source:               source,
+	}
+	if plan != nil {
+		// dry-run changes nothing on node and emits no event
+		vrm.pmemer = utils.NewDryRunPmemer(utils.NewNodePmemer(executor), plan)
+		vrm.mounter = utils.NewDryRunMounter(utils.NewMounter(executor), executor, plan)
+		vrm.lvmer = utils.NewDryRunLVM(utils.NewNodeLVM(executor), plan)
+		vrm.checker = blockdev.NewDryRunChecker(blockdev.NewChecker(executor), plan)
+		vrm.recorder = &record.FakeRecorder{}
+	} else {
+		vrm.pmemer = utils.NewNodePmemer(executor)
+		vrm.mounter = utils.NewMounter(executor)
+		vrm.lvmer = utils.NewNodeLVM(executor)
+		vrm.checker = blockdev.NewChecker(executor)
+		vrm.recorder = utils.NewEventRecorder()
+	}
+	return vrm
 }
 
 // DeviceChars ...
@@ -76,29 +109,25 @@ var DeviceChars = []string{"b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l"
 
 // Create VolumeGroup
 func (vrm *ResourceManager) createVg(vgName string, desirePvList []string) error {
-	pvListStr := strings.Join(desirePvList, " ")
-	tagList := []string{}
-	out, err := vrm.lvmer.CreateVG(vgName, pvListStr, tagList)
+	hash := ""
+	if owner := vrm.owners[vgName]; owner != nil {
+		hash = owner.SpecHash
+	}
+	out, err := vrm.lvmer.CreateVG(vgName, desirePvList, ownerTags(vgName, hash))
 	if err != nil {
 		klog.Errorf("createVg:: Create Vg(%s) error with: %s", vgName, err.Error())
 		return err
 	}
+	vrm.tagPvs(vgName, desirePvList)
 	klog.Infof("createVg:: Successful Create Vg(%s) with out: %s", vgName, out)
 	return nil
 }
 
-// Upgrade VolumeGroup, only support extend pv;
-func (vrm *ResourceManager) updateVg(vgName string, expectPvList, realPvList []string) error {
-	if len(expectPvList) < len(realPvList) {
-		msg := fmt.Sprintf("updateVg:: VolumeGroup: %s, expected pv list should be more than current pv list when update volume group: %v, %v", vgName, expectPvList, realPvList)
-		klog.Errorf(msg)
-		return errors.New(msg)
-	}
-
-	// removed pv: pv exist in current node, but not in expect
-	removePv := difference(realPvList, expectPvList)
-	if len(removePv) > 0 {
-		msg := fmt.Sprintf("updateVg:: VolumeGroup: %s, expected pv list should be more than current pv list when update volume group: expect %v, current %v, and not support remove pv now", vgName, expectPvList, realPvList)
+// Upgrade VolumeGroup, extend the added pv, the removed pv is removed by shrinkVg if allowShrink is set;
+func (vrm *ResourceManager) updateVg(vgName string, expectPvList, realPvList, removePv []string, allowShrink bool) error {
+	// removed pv: pv dropped from spec and present in current node
+	if len(removePv) > 0 && !allowShrink {
+		msg := fmt.Sprintf("updateVg:: VolumeGroup: %s, expected pv list should be more than current pv list when update volume group: expect %v, current %v, set allowShrink to remove pv", vgName, expectPvList, realPvList)
 		klog.Errorf(msg)
 		return errors.New(msg)
 	}
@@ -111,13 +140,13 @@ func (vrm *ResourceManager) updateVg(vgName string, expectPvList, realPvList []s
 		return errors.New(msg)
 	}
 
-	pvListStr := strings.Join(addedPv, " ")
-	_, err := vrm.lvmer.ExtendVG(vgName, pvListStr)
+	_, err := vrm.lvmer.ExtendVG(vgName, addedPv)
 	if err != nil {
 		msg := fmt.Sprintf("updateVg:: Extend vg(%s) error: %v", vgName, err)
 		klog.Errorf(msg)
 		return errors.New(msg)
 	}
+	vrm.tagPvs(vgName, addedPv)
 	klog.Infof("updateVg:: Successful Add pvs %s to VolumeGroup %s", addedPv, vgName)
 	return nil
 }
@@ -274,41 +303,65 @@ func (vrm *ResourceManager) AnalyseConfigMap() error {
 		}
 		return
 	}
+	resolver := blockdev.NewResolver(vrm.discoverer)
 
 	vgDeviceMap := map[string]*VgDeviceConfig{}
 	vgRegionMap := map[string][]string{}
+	thinPools := map[string]*ThinPoolConfig{}
+	invalidThinPools := map[string]string{}
+	absentVgs := map[string]bool{}
+	owners := map[string]*ownerConfig{}
 
-	volumeGroupList := &VgList{}
-	yamlFile, err := ioutil.ReadFile(vrm.configPath)
+	volumeGroups, err := vrm.source.GetResources(config.ResourceKindVolumeGroup)
 	if err != nil {
-		if os.IsNotExist(err) {
-			klog.Errorf("volume config file %s not exist", vrm.configPath)
-			return nil
-		}
-		klog.Errorf("AnalyseConfigMap:: ReadFile: yamlFile.Get error #%v ", err)
-		return err
-	}
-
-	err = yaml.Unmarshal(yamlFile, volumeGroupList)
-	if err != nil {
-		klog.Errorf("AnalyseConfigMap:: Unmarshal: parse yaml file error: %v", err)
+		klog.Errorf("AnalyseConfigMap:: get volumegroup config error: %v", err)
 		return err
 	}
 
 	nodeInfo := config.GlobalConfigVar.NodeInfo
-	for _, devConfig := range volumeGroupList.VolumeGroups {
+	for _, devConfig := range volumeGroups {
 		vgDeviceConfig := &VgDeviceConfig{}
 
 		isMatched := utils.NodeFilter(devConfig.Operator, devConfig.Key, devConfig.Value, nodeInfo)
 		klog.V(3).Infof("AnalyseConfigMap:: isMatched: %v, devConfig: %+v", isMatched, devConfig)
 
 		if isMatched {
+			switch devConfig.State {
+			case "", model.ResourceStatePresent:
+			case model.ResourceStateAbsent:
+				absentVgs[devConfig.Name] = true
+				if _, ok := owners[devConfig.Name]; !ok {
+					owners[devConfig.Name] = &ownerConfig{Adopt: devConfig.Topology.Adopt}
+				}
+				continue
+			default:
+				klog.Errorf("AnalyseConfigMap:: Get unsupported volumegroup state: %s", devConfig.State)
+				continue
+			}
 			switch devConfig.Topology.Type {
 			case VgTypeDevice:
 				vgDeviceConfig.PhysicalVolumes = getExistDevices(devConfig.Topology.Devices)
+				if devConfig.Topology.DeviceSelector != nil {
+					if vgDeviceConfig.PhysicalVolumes, err = resolver.Resolve(vgDeviceConfig.PhysicalVolumes, devConfig.Topology.DeviceSelector); err != nil {
+						klog.Errorf("AnalyseConfigMap:: select devices of volume group %s error: %v", devConfig.Name, err)
+						vgDeviceConfig.SelectorError = err.Error()
+					}
+				}
+				vgDeviceConfig.AllowShrink = devConfig.Topology.AllowShrink
+				vgDeviceConfig.Wipe = devConfig.Topology.Wipe
+				vgDeviceConfig.ListedDevices = devConfig.Topology.Devices
+				vgDeviceMap[devConfig.Name] = vgDeviceConfig
+			case VgTypeLocalDisk:
+				if vgDeviceConfig.PhysicalVolumes, err = vrm.getLocalDisks(devConfig.Name, devConfig.Topology, resolver); err != nil {
+					klog.Errorf("AnalyseConfigMap:: select local disks of volume group %s error: %v", devConfig.Name, err)
+					vgDeviceConfig.SelectorError = err.Error()
+				}
+				vgDeviceConfig.AllowShrink = devConfig.Topology.AllowShrink
+				vgDeviceConfig.Wipe = devConfig.Topology.Wipe
+				vgDeviceConfig.ExcludedDevices = excludedDevices(devConfig.Topology.Exclude)
 				vgDeviceMap[devConfig.Name] = vgDeviceConfig
 			case VgTypeLocal:
-				tmpConfig := &VgDeviceConfig{}
+				tmpConfig := &VgDeviceConfig{AllowShrink: devConfig.Topology.AllowShrink, Wipe: devConfig.Topology.Wipe}
 				tmpConfig.PhysicalVolumes = getPvListForLocalDisk(vrm.mounter)
 				vgDeviceMap[devConfig.Name] = tmpConfig
 			case VgTypePvc:
@@ -320,102 +373,215 @@ func (vrm *ResourceManager) AnalyseConfigMap() error {
 				klog.Errorf("AnalyseConfigMap:: Get unsupported volumegroup type: %s", devConfig.Topology.Type)
 				continue
 			}
+			owners[devConfig.Name] = &ownerConfig{SpecHash: specHash(devConfig.Topology), Adopt: devConfig.Topology.Adopt}
+			if devConfig.Topology.ThinPool != nil {
+				tpConfig, err := parseThinPool(devConfig.Topology.ThinPool)
+				if err != nil {
+					klog.Errorf("AnalyseConfigMap:: volume group %s has invalid thin pool: %v", devConfig.Name, err)
+					invalidThinPools[devConfig.Name] = err.Error()
+					continue
+				}
+				thinPools[devConfig.Name] = tpConfig
+			}
 		}
 	}
 	vrm.volumeGroupDeviceMap = vgDeviceMap
 	vrm.volumeGroupRegionMap = vgRegionMap
+	vrm.thinPools = thinPools
+	vrm.invalidThinPools = invalidThinPools
+	// a volume group also defined as present on this node is kept
+	for vgName := range absentVgs {
+		_, isDevice := vgDeviceMap[vgName]
+		_, isRegion := vgRegionMap[vgName]
+		if isDevice || isRegion {
+			klog.Errorf("AnalyseConfigMap:: volume group %s is defined both present and absent, keep it", vgName)
+			delete(absentVgs, vgName)
+		}
+	}
+	vrm.absentVolumeGroups = absentVgs
+	vrm.owners = owners
 	return nil
 }
 
-// ApplyResourceDiff apply volume group resource to current node
-func (vrm *ResourceManager) ApplyResourceDiff() error {
+// ApplyResourceDiff apply volume group resource to current node, returns the result of every expected volume group
+func (vrm *ResourceManager) ApplyResourceDiff() ([]*model.ResourceResult, error) {
 
 	// Get Actual VolumeGroup on node.
 	actualVgConfig, err := vrm.getRealVgList()
 	if err != nil {
 		klog.Errorf("ApplyResourceDiff:: Get Node Actual VolumeGroup Error: %s", err.Error())
-		return err
+		return nil, err
 	}
+	vrm.nodeVgs = nil
+	vrm.nodePvs = map[string][]string{}
+	vrm.matchedVgs = map[string]bool{}
+	for _, realVg := range actualVgConfig {
+		vrm.nodePvs[realVg.Name] = realVg.PhysicalVolumes
+	}
+	results := []*model.ResourceResult{}
 	if len(vrm.volumeGroupDeviceMap) > 0 {
-		vrm.applyDeivce(actualVgConfig)
+		results = append(results, vrm.applyDeivce(actualVgConfig)...)
 	}
 	if len(vrm.volumeGroupRegionMap) > 0 {
-		vrm.applyRegion(actualVgConfig)
+		results = append(results, vrm.applyRegion(actualVgConfig)...)
+	}
+	results = vrm.applyThinPools(results)
+	if len(vrm.absentVolumeGroups) > 0 {
+		results = append(results, vrm.applyAbsent(actualVgConfig)...)
 	}
+	results = vrm.refreshOwnerTags(results)
+	vrm.reconciled = true
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
 
 	klog.Infof("ApplyResourceDiff:: Finish volumegroup loop...")
-	return nil
+	return results, nil
 }
 
-func (vrm *ResourceManager) applyDeivce(actualVgConfig []*VgDeviceConfig) error {
-	// process each expect volume group
+func (vrm *ResourceManager) applyDeivce(actualVgConfig []*VgDeviceConfig) []*model.ResourceResult {
+	results := []*model.ResourceResult{}
+	// process each expect volume group, a failed one does not block the others
 	for expectVgName, expectVg := range vrm.volumeGroupDeviceMap {
 		klog.Infof("applyDevice:: expectName: %s, expectVgDevices: %v", expectVgName, expectVg.PhysicalVolumes)
+		if expectVg.SelectorError != "" {
+			results = append(results, model.NewResourceResult(config.ResourceKindVolumeGroup, expectVgName, model.ResourceFailed,
+				"DeviceSelectorFailed", expectVg.SelectorError))
+			continue
+		}
 		isVgExist := false
 		isVgNeedUpdate := false
 		realPhysicalVolumeList := []string{}
+		removePvs := []string{}
 
 		for _, realVg := range actualVgConfig {
 			if expectVgName == realVg.Name {
 				isVgExist = true
+				realPhysicalVolumeList = realVg.PhysicalVolumes
 				diffs := difference(expectVg.PhysicalVolumes, realVg.PhysicalVolumes)
 				if len(diffs) != 0 {
-					realPhysicalVolumeList = realVg.PhysicalVolumes
 					isVgNeedUpdate = true
+				} else if len(difference(realVg.PhysicalVolumes, expectVg.PhysicalVolumes)) == 0 {
+					vrm.matchedVgs[expectVgName] = true
 				}
+				removePvs = vrm.droppedPvs(expectVgName, expectVg, realVg.PhysicalVolumes)
 				break
 			}
 		}
 		if !isVgExist {
+			if len(expectVg.PhysicalVolumes) == 0 {
+				results = append(results, model.NewResourceResult(config.ResourceKindVolumeGroup, expectVgName, model.ResourceFailed,
+					"DeviceNotExists", "none of the expected devices exists in current node"))
+				continue
+			}
 			klog.Infof("Create VolumeGroup:: %+v, %+v", expectVgName, expectVg.PhysicalVolumes)
-			return vrm.createVg(expectVgName, expectVg.PhysicalVolumes)
+			if result := vrm.preflight(expectVgName, expectVg.PhysicalVolumes, expectVg.Wipe); result != nil {
+				results = append(results, result)
+				continue
+			}
+			if err := vrm.createVg(expectVgName, expectVg.PhysicalVolumes); err != nil {
+				results = append(results, model.NewResourceResult(config.ResourceKindVolumeGroup, expectVgName, model.ResourceFailed,
+					"CreateVolumeGroupFailed", err.Error()))
+				continue
+			}
+			results = append(results, model.NewResourceResult(config.ResourceKindVolumeGroup, expectVgName, model.ResourceReady,
+				"VolumeGroupCreated", fmt.Sprintf("volume group created with %v", expectVg.PhysicalVolumes)))
 		} else if isVgNeedUpdate {
 			klog.Infof("Update VolumeGroup:: %+v, %+v", expectVgName, expectVg.PhysicalVolumes)
-			return vrm.updateVg(expectVgName, expectVg.PhysicalVolumes, realPhysicalVolumeList)
+			if result := vrm.checkOwner(expectVgName); result != nil {
+				results = append(results, result)
+				continue
+			}
+			if result := vrm.preflight(expectVgName, difference(expectVg.PhysicalVolumes, realPhysicalVolumeList), expectVg.Wipe); result != nil {
+				results = append(results, result)
+				continue
+			}
+			if err := vrm.updateVg(expectVgName, expectVg.PhysicalVolumes, realPhysicalVolumeList, removePvs, expectVg.AllowShrink); err != nil {
+				results = append(results, model.NewResourceResult(config.ResourceKindVolumeGroup, expectVgName, model.ResourceFailed,
+					"ExtendVolumeGroupFailed", err.Error()))
+				continue
+			}
+			if len(removePvs) > 0 {
+				// the added pvs take the data moved off the removed ones
+				results = append(results, vrm.shrinkVg(expectVgName, removePvs))
+				continue
+			}
+			results = append(results, model.NewResourceResult(config.ResourceKindVolumeGroup, expectVgName, model.ResourceReady,
+				"VolumeGroupExtended", fmt.Sprintf("volume group extended to %v", expectVg.PhysicalVolumes)))
+		} else if len(removePvs) > 0 && expectVg.AllowShrink {
+			klog.Infof("Shrink VolumeGroup:: %+v, %+v", expectVgName, removePvs)
+			if result := vrm.checkOwner(expectVgName); result != nil {
+				results = append(results, result)
+				continue
+			}
+			results = append(results, vrm.shrinkVg(expectVgName, removePvs))
+		} else if result := vrm.removePendingPvs(expectVgName); result != nil {
+			results = append(results, result)
+		} else {
+			results = append(results, model.NewResourceResult(config.ResourceKindVolumeGroup, expectVgName, model.ResourceReady,
+				"VolumeGroupReady", ""))
 		}
 	}
-	return nil
+	return results
 }
 
-func (vrm *ResourceManager) applyRegion(actualVgConfig []*VgDeviceConfig) error {
+func (vrm *ResourceManager) applyRegion(actualVgConfig []*VgDeviceConfig) []*model.ResourceResult {
+	// regionFailed marks all volume groups with the same failure
+	regionFailed := func(reason, message string) []*model.ResourceResult {
+		results := []*model.ResourceResult{}
+		for expectVgName := range vrm.volumeGroupRegionMap {
+			results = append(results, model.NewResourceResult(config.ResourceKindVolumeGroup, expectVgName, model.ResourceFailed, reason, message))
+		}
+		return results
+	}
+
 	regions, err := vrm.pmemer.GetRegions()
 	if err != nil {
 		klog.Errorf("applyRegion: get pmem regions error: %v", err)
-		return err
+		return regionFailed("ListRegionsFailed", err.Error())
 	}
 
-	for _, expectRegions := range vrm.volumeGroupRegionMap {
-		expectNamespaces := []string{}
+	results := []*model.ResourceResult{}
+	failedVgs := map[string]bool{}
+	for expectVgName, expectRegions := range vrm.volumeGroupRegionMap {
 		for _, expectRegion := range expectRegions {
 			expectRegionExists := false
 			for _, region := range regions.Regions {
 				if expectRegion == region.Dev {
 					expectRegionExists = true
 					if len(region.Namespaces) == 0 {
-						vrm.pmemer.CreateNamespace(region.Dev, "lvm")
+						if err := vrm.pmemer.CreateNamespace(region.Dev, "lvm"); err != nil {
+							klog.Errorf("applyRegion:: create namespace for region %s error: %v", region.Dev, err)
+						}
 					}
 				}
 			}
 			if !expectRegionExists {
-				err := fmt.Errorf("applyRegion:: expect region %s not exists", expectRegion)
-				return err
+				klog.Errorf("applyRegion:: expect region %s not exists", expectRegion)
+				results = append(results, model.NewResourceResult(config.ResourceKindVolumeGroup, expectVgName, model.ResourceFailed,
+					"RegionNotExists", fmt.Sprintf("expect region %s not exists", expectRegion)))
+				failedVgs[expectVgName] = true
+				break
 			}
-			expectNamespaces = append(expectNamespaces, utils.ConvertRegion2Namespace(expectRegion))
 		}
 	}
 	updatedRegions, err := vrm.pmemer.GetRegions()
 	if err != nil {
 		klog.Errorf("applyRegion: get pmem regions error: %v", err)
-		return err
+		return regionFailed("ListRegionsFailed", err.Error())
 	}
 	for expectVgName, expectRegions := range vrm.volumeGroupRegionMap {
+		if failedVgs[expectVgName] {
+			continue
+		}
 		klog.Infof("applyDevice:: expectVgName: %v, expectRegions: %v", expectVgName, expectRegions)
 		expectLvmInUseDevices := []string{}
 		expectLvmNotInUseDevices := []string{}
+		missingRegions := []string{}
 		for _, expectRegion := range expectRegions {
 			devicePath := utils.ConvertNamespace2LVMDevicePath(utils.ConvertRegion2Namespace(expectRegion), updatedRegions)
 			if devicePath == "" {
 				klog.Errorf("applyRegion:: did not get namespace.Blockdev from expectRegion: %s, regions: %v", expectRegion, updatedRegions)
+				missingRegions = append(missingRegions, expectRegion)
+				continue
 			}
 			if vrm.pmemer.CheckNamespaceUsed(devicePath) {
 				klog.Errorf("NameSpace heen used region: %v, devicePath: %s", expectRegion, devicePath)
@@ -423,43 +589,138 @@ func (vrm *ResourceManager) applyRegion(actualVgConfig []*VgDeviceConfig) error
 			}
 			expectLvmNotInUseDevices = append(expectLvmNotInUseDevices, devicePath)
 		}
+		if len(missingRegions) != 0 {
+			results = append(results, model.NewResourceResult(config.ResourceKindVolumeGroup, expectVgName, model.ResourceProgressing,
+				"NamespaceNotReady", fmt.Sprintf("block device of regions %v not found", missingRegions)))
+			continue
+		}
 
 		isVgNeedCreate := true
+		result := model.NewResourceResult(config.ResourceKindVolumeGroup, expectVgName, model.ResourceReady, "VolumeGroupReady", "")
 		for _, actualVg := range actualVgConfig {
 			if expectVgName == actualVg.Name {
 				isVgNeedCreate = false
 				if otherUsage := difference(expectLvmInUseDevices, actualVg.PhysicalVolumes); len(otherUsage) != 0 {
 					klog.Errorf("applyRegion:: device [%s] is used in other usage", otherUsage)
+					result = model.NewResourceResult(config.ResourceKindVolumeGroup, expectVgName, model.ResourceFailed,
+						"DeviceInUse", fmt.Sprintf("devices %v are used in other usage", otherUsage))
 					break
 				}
 				updatePvs := difference(expectLvmNotInUseDevices, actualVg.PhysicalVolumes)
 				if len(updatePvs) == 0 {
+					if len(difference(actualVg.PhysicalVolumes, expectLvmNotInUseDevices)) == 0 {
+						vrm.matchedVgs[expectVgName] = true
+					}
+					break
+				}
+				if ownerResult := vrm.checkOwner(expectVgName); ownerResult != nil {
+					result = ownerResult
+					break
+				}
+				if err := vrm.updatePmemVg(expectVgName, updatePvs); err != nil {
+					result = model.NewResourceResult(config.ResourceKindVolumeGroup, expectVgName, model.ResourceFailed,
+						"ExtendVolumeGroupFailed", err.Error())
 					break
 				}
-				vrm.updatePmemVg(expectVgName, expectLvmNotInUseDevices)
+				result = model.NewResourceResult(config.ResourceKindVolumeGroup, expectVgName, model.ResourceReady,
+					"VolumeGroupExtended", fmt.Sprintf("volume group extended with %v", updatePvs))
 			}
 		}
 		if isVgNeedCreate {
 			if len(expectLvmInUseDevices) != 0 {
 				klog.Errorf("applyRegion:: attempt to use inused devices [%s] to create volumegroup", expectLvmInUseDevices)
+				results = append(results, model.NewResourceResult(config.ResourceKindVolumeGroup, expectVgName, model.ResourceFailed,
+					"DeviceInUse", fmt.Sprintf("devices %v are used in other usage", expectLvmInUseDevices)))
 				continue
 			}
-			vrm.createVg(expectVgName, expectLvmNotInUseDevices)
+			if err := vrm.createVg(expectVgName, expectLvmNotInUseDevices); err != nil {
+				result = model.NewResourceResult(config.ResourceKindVolumeGroup, expectVgName, model.ResourceFailed,
+					"CreateVolumeGroupFailed", err.Error())
+			} else {
+				result = model.NewResourceResult(config.ResourceKindVolumeGroup, expectVgName, model.ResourceReady,
+					"VolumeGroupCreated", fmt.Sprintf("volume group created with %v", expectLvmNotInUseDevices))
+			}
 		}
+		results = append(results, result)
 	}
 
-	return nil
+	return results
+}
+
+// applyAbsent removes the absent volume groups which have no logical volume and no protected tag
+func (vrm *ResourceManager) applyAbsent(actualVgConfig []*VgDeviceConfig) []*model.ResourceResult {
+	results := []*model.ResourceResult{}
+	refused := func(vgName, reason, message string) {
+		klog.Errorf("applyAbsent:: refuse to remove volume group %s: %s", vgName, message)
+		results = append(results, model.NewResourceResult(config.ResourceKindVolumeGroup, vgName, model.ResourceFailed, reason, message))
+	}
+
+	for vgName := range vrm.absentVolumeGroups {
+		isVgExist := false
+		for _, realVg := range actualVgConfig {
+			if realVg.Name == vgName {
+				isVgExist = true
+			}
+		}
+		if !isVgExist {
+			results = append(results, model.NewResourceResult(config.ResourceKindVolumeGroup, vgName, model.ResourceReady, "VolumeGroupAbsent", ""))
+			continue
+		}
+
+		vg, err := vrm.findVg(vgName)
+		if err != nil {
+			refused(vgName, "ListVolumeGroupFailed", err.Error())
+			continue
+		}
+		if vg != nil && hasTag(vg, utils.ProtectedTagName) {
+			refused(vgName, "VolumeGroupProtected", "volume group is protected")
+			continue
+		}
+		if result := vrm.checkOwner(vgName); result != nil {
+			results = append(results, result)
+			continue
+		}
+
+		lvs, err := vrm.lvmer.ListLV(vgName)
+		if err != nil {
+			refused(vgName, "ListLogicalVolumeFailed", err.Error())
+			continue
+		}
+		lvNames, openLvNames := []string{}, []string{}
+		for _, lv := range lvs {
+			lvNames = append(lvNames, lv.Name)
+			if lv.Attributes.Open == model.VolumeOpenIsOpen {
+				openLvNames = append(openLvNames, lv.Name)
+			}
+		}
+		if len(openLvNames) > 0 {
+			refused(vgName, "VolumeGroupInUse", fmt.Sprintf("logical volumes %v are open", openLvNames))
+			continue
+		}
+		if len(lvNames) > 0 {
+			refused(vgName, "VolumeGroupNotEmpty", fmt.Sprintf("logical volumes %v exist", lvNames))
+			continue
+		}
+
+		if _, err := vrm.lvmer.RemoveVG(vgName); err != nil {
+			refused(vgName, "RemoveVolumeGroupFailed", err.Error())
+			continue
+		}
+		klog.Infof("applyAbsent:: Successful remove volume group %s", vgName)
+		results = append(results, model.NewResourceResult(config.ResourceKindVolumeGroup, vgName, model.ResourceReady, "VolumeGroupRemoved", ""))
+	}
+	return results
 }
 
 func (vrm *ResourceManager) updatePmemVg(vgName string, addedPv []string) error {
 
-	pvListStr := strings.Join(addedPv, " ")
-	_, err := vrm.lvmer.ExtendVG(vgName, pvListStr)
+	_, err := vrm.lvmer.ExtendVG(vgName, addedPv)
 	if err != nil {
 		msg := fmt.Sprintf("updatePmemVg:: Extend vg(%s) error: %v", vgName, err)
 		klog.Errorf(msg)
 		return errors.New(msg)
 	}
+	vrm.tagPvs(vgName, addedPv)
 	klog.Infof("updatePmemVg:: Successful Add pvs %s to VolumeGroup %s", addedPv, vgName)
 	return nil
 }
@@ -22,13 +22,14 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strings"
 	"testing"
 
 	"github.com/golang/mock/gomock"
+	"github.com/openyurtio/node-resource-manager/pkg/apis/nrm/v1alpha1"
 	"github.com/openyurtio/node-resource-manager/pkg/config"
 	"github.com/openyurtio/node-resource-manager/pkg/model"
 	"github.com/openyurtio/node-resource-manager/pkg/utils"
+	"github.com/openyurtio/node-resource-manager/pkg/utils/blockdev"
 	"github.com/stretchr/testify/assert"
 	yaml "gopkg.in/yaml.v2"
 	v1 "k8s.io/api/core/v1"
@@ -66,7 +67,7 @@ func EnsureVolumeGroupEnv() (string, error, *ResourceManager) {
 
 	newMockVolumegroupResourceManager := func() *ResourceManager {
 		return &ResourceManager{
-			configPath: configPath,
+			source: &config.FileSource{ConfigDir: filepath.Dir(configPath)},
 		}
 	}
 	return configPath, nil, newMockVolumegroupResourceManager()
@@ -83,6 +84,7 @@ func TestAnalyseConfigMap(t *testing.T) {
 	defer os.Remove(configPath)
 	mockMounter := utils.NewMockMounter(mockCtl)
 	resourceManager.mounter = mockMounter
+	resourceManager.checker = newUnusedChecker(mockCtl)
 	resourceManager.recorder = record.NewFakeRecorder(10)
 	setOpInOperatorElement := func(m *model.ResourceYaml) {
 		m.Key = "bar"
@@ -145,6 +147,137 @@ func TestAnalyseConfigMap(t *testing.T) {
 	assert.Equal(t, "/dev/vdb", resourceManager.volumeGroupRegionMap["bar1"][0])
 }
 
+func TestAnalyseConfigMapDeviceSelector(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+	configPath, err, resourceManager := EnsureVolumeGroupEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(configPath)
+	mockMounter := utils.NewMockMounter(mockCtl)
+	resourceManager.mounter = mockMounter
+	mockDiscoverer := blockdev.NewMockDiscoverer(mockCtl)
+	resourceManager.discoverer = mockDiscoverer
+	resourceManager.recorder = record.NewFakeRecorder(10)
+
+	testYamls := VgList{VolumeGroups: []model.ResourceYaml{
+		{Name: "volumegroup1", Key: "bar", Operator: metav1.LabelSelectorOpIn, Value: "foo", Topology: model.Topology{
+			Type: "device", Devices: []string{"/dev/vdb"}, DeviceSelector: &model.DeviceSelector{Transport: []string{"nvme"}},
+		}},
+		{Name: "volumegroup2", Key: "bar", Operator: metav1.LabelSelectorOpIn, Value: "foo", Topology: model.Topology{
+			Type: "device", DeviceSelector: &model.DeviceSelector{Transport: []string{"ide"}},
+		}},
+	}}
+	d, err := yaml.Marshal(&testYamls)
+	if err != nil {
+		t.Error()
+	}
+	ioutil.WriteFile(configPath, d, 0777)
+
+	mockMounter.EXPECT().FileExists(gomock.Eq("/dev/vdb")).Return(true)
+	// disks are listed once for all selectors
+	mockDiscoverer.EXPECT().ListDisks().Return([]*blockdev.Disk{
+		{Name: "nvme0n1", Path: "/dev/nvme0n1", Transport: blockdev.TransportNVMe},
+		{Name: "nvme1n1", Path: "/dev/nvme1n1", Transport: blockdev.TransportNVMe, Partitioned: true},
+		{Name: "vdb", Path: "/dev/vdb", Transport: blockdev.TransportVirtio},
+	}, nil)
+
+	assert.Nil(t, resourceManager.AnalyseConfigMap())
+	assert.Equal(t, []string{"/dev/vdb", "/dev/nvme0n1"}, resourceManager.volumeGroupDeviceMap["volumegroup1"].PhysicalVolumes)
+	assert.Equal(t, `unsupported transport "ide"`, resourceManager.volumeGroupDeviceMap["volumegroup2"].SelectorError)
+
+	mockLVM := utils.NewMockLVM(mockCtl)
+	resourceManager.lvmer = mockLVM
+	mockLVM.EXPECT().ListPhysicalVolume().Return([]*model.PV{{Name: "/dev/vdb", VgName: "volumegroup1"}, {Name: "/dev/nvme0n1", VgName: "volumegroup1"}}, nil)
+	mockLVM.EXPECT().ListVG().Return([]*model.VG{{Name: "volumegroup1", Tags: []string{utils.ManagedTagName}}}, nil).AnyTimes()
+	mockLVM.EXPECT().RemoveTagVG(gomock.Any(), gomock.Any()).Return("", nil).AnyTimes()
+	mockLVM.EXPECT().AddTagVG(gomock.Any(), gomock.Any()).Return("", nil).AnyTimes()
+	results, err := resourceManager.ApplyResourceDiff()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(results))
+	assert.Equal(t, "VolumeGroupReady", results[0].Reason)
+	assert.Equal(t, model.ResourceFailed, results[1].Condition)
+	assert.Equal(t, "DeviceSelectorFailed", results[1].Reason)
+}
+
+func TestAnalyseConfigMapLocalDisk(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+	configPath, err, resourceManager := EnsureVolumeGroupEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(configPath)
+	mockLVM := utils.NewMockLVM(mockCtl)
+	resourceManager.lvmer = mockLVM
+	mockDiscoverer := blockdev.NewMockDiscoverer(mockCtl)
+	resourceManager.discoverer = mockDiscoverer
+
+	testYamls := VgList{VolumeGroups: []model.ResourceYaml{
+		{Name: "volumegroup1", Key: "bar", Operator: metav1.LabelSelectorOpIn, Value: "foo", Topology: model.Topology{
+			Type: "localdisk", Include: []string{"vd*", "nvme*"}, Exclude: []string{"/dev/nvme0n1"},
+		}},
+		{Name: "volumegroup2", Key: "bar", Operator: metav1.LabelSelectorOpIn, Value: "foo", Topology: model.Topology{
+			Type: "localdisk", Exclude: []string{"[vd"},
+		}},
+	}}
+	d, err := yaml.Marshal(&testYamls)
+	if err != nil {
+		t.Error()
+	}
+	ioutil.WriteFile(configPath, d, 0777)
+
+	mockDiscoverer.EXPECT().ListDisks().Return([]*blockdev.Disk{
+		{Name: "nvme0n1", Path: "/dev/nvme0n1"},
+		{Name: "sda", Path: "/dev/sda", Transport: blockdev.TransportUSB},
+		{Name: "vda", Path: "/dev/vda", Partitioned: true},
+		{Name: "vdb", Path: "/dev/vdb", Holders: []string{"dm-0"}, FSType: blockdev.FSTypeLVM},
+		{Name: "vdc", Path: "/dev/vdc", FSType: blockdev.FSTypeLVM},
+		{Name: "vdd", Path: "/dev/vdd", FSType: blockdev.FSTypeLVM},
+		{Name: "vde", Path: "/dev/vde", FSType: "ext4", Mounted: true},
+		{Name: "vdf", Path: "/dev/vdf"},
+	}, nil)
+	mockLVM.EXPECT().ListPhysicalVolume().Return([]*model.PV{
+		{Name: "/dev/vdb", VgName: "volumegroup1"}, {Name: "/dev/vdd", VgName: "vg-other"},
+	}, nil)
+	mockLVM.EXPECT().ListOrphanPhysicalVolume().Return([]*model.PV{{Name: "/dev/vdc"}}, nil)
+
+	assert.Nil(t, resourceManager.AnalyseConfigMap())
+	assert.Equal(t, []string{"/dev/vdb", "/dev/vdc", "/dev/vdf"}, resourceManager.volumeGroupDeviceMap["volumegroup1"].PhysicalVolumes)
+	assert.Equal(t, `exclude: invalid pattern "[vd"`, resourceManager.volumeGroupDeviceMap["volumegroup2"].SelectorError)
+}
+
+func TestGetLocalDisksOrphanPhysicalVolume(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+	pvsOutput, err := ioutil.ReadFile("../../utils/testdata/pvs.txt")
+	assert.Nil(t, err)
+	// /dev/vdb and /dev/vdc are in volumegroup1, /dev/vdd is labeled without volume group in pvs output
+	executor := utils.NewFakeExecutor()
+	executor.On("pvs", "...").Return(string(pvsOutput))
+	mockDiscoverer := blockdev.NewMockDiscoverer(mockCtl)
+	mockDiscoverer.EXPECT().ListDisks().Return([]*blockdev.Disk{
+		{Name: "vdb", Path: "/dev/vdb", FSType: blockdev.FSTypeLVM},
+		{Name: "vdc", Path: "/dev/vdc", FSType: blockdev.FSTypeLVM},
+		{Name: "vdd", Path: "/dev/vdd", FSType: blockdev.FSTypeLVM},
+		{Name: "vde", Path: "/dev/vde", FSType: blockdev.FSTypeLVM},
+	}, nil)
+	resourceManager := &ResourceManager{lvmer: utils.NewNodeLVM(executor)}
+
+	disks, err := resourceManager.getLocalDisks("volumegroup2", model.Topology{Type: "localdisk"}, blockdev.NewResolver(mockDiscoverer))
+	assert.Nil(t, err)
+	// the orphan physical volume is reused, the lvm signature not known by lvm is not
+	assert.Equal(t, []string{"/dev/vdd"}, disks)
+}
+
+// newUnusedChecker returns a checker finding every device unused
+func newUnusedChecker(mockCtl *gomock.Controller) *blockdev.MockChecker {
+	checker := blockdev.NewMockChecker(mockCtl)
+	checker.EXPECT().Check(gomock.Any()).Return(nil, nil).AnyTimes()
+	return checker
+}
+
 // EnsureFolder ...
 func EnsureFolder(target string) error {
 	mdkirCmd := "mkdir"
@@ -179,6 +312,7 @@ func TestAnalyseDiff(t *testing.T) {
 	resourceManager.lvmer = mockLVM
 	mockMounter := utils.NewMockMounter(mockCtl)
 	resourceManager.mounter = mockMounter
+	resourceManager.checker = newUnusedChecker(mockCtl)
 	resourceManager.recorder = record.NewFakeRecorder(10)
 	setOpInOperatorElement := func(m *model.ResourceYaml) {
 		m.Key = "bar"
@@ -206,15 +340,675 @@ func TestAnalyseDiff(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	prListStr := strings.Join([]string{"/dev/vdb", "/dev/vdc"}, " ")
 	gomock.InOrder(
 		mockMounter.EXPECT().FileExists(gomock.Eq("/dev/vdb")).Return(true),
 		mockMounter.EXPECT().FileExists(gomock.Eq("/dev/vdc")).Return(true),
 	)
 	assert.Nil(t, resourceManager.AnalyseConfigMap())
+	hash := specHash(model.Topology{Type: "device", Devices: []string{"/dev/vdb", "/dev/vdc"}})
+	gomock.InOrder(
+		mockLVM.EXPECT().ListPhysicalVolume().Return([]*model.PV{}, nil),
+		mockLVM.EXPECT().CreateVG(gomock.Eq("volumegroup1"), gomock.Eq([]string{"/dev/vdb", "/dev/vdc"}),
+			gomock.Eq([]string{utils.ManagedTagName, "nrm.openyurt.io/name=volumegroup1", "nrm.openyurt.io/spec-hash=" + hash})).Return("", nil),
+		mockLVM.EXPECT().AddTagPV(gomock.Eq([]string{"/dev/vdb", "/dev/vdc"}),
+			gomock.Eq([]string{utils.ManagedTagName, "nrm.openyurt.io/name=volumegroup1"})).Return("", nil),
+	)
+	results, err := resourceManager.ApplyResourceDiff()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(results))
+	assert.Equal(t, "volumegroup1", results[0].Name)
+	assert.Equal(t, model.ResourceReady, results[0].Condition)
+	assert.Equal(t, "VolumeGroupCreated", results[0].Reason)
+}
+
+func TestApplyResourceDiffFailed(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+	mockLVM := utils.NewMockLVM(mockCtl)
+	resourceManager := &ResourceManager{
+		checker: newUnusedChecker(mockCtl),
+		lvmer:   mockLVM,
+		volumeGroupDeviceMap: map[string]*VgDeviceConfig{
+			"volumegroup1": {PhysicalVolumes: []string{"/dev/vdb"}},
+			"volumegroup2": {PhysicalVolumes: []string{}},
+		},
+	}
+
 	gomock.InOrder(
 		mockLVM.EXPECT().ListPhysicalVolume().Return([]*model.PV{}, nil),
-		mockLVM.EXPECT().CreateVG(gomock.Eq("volumegroup1"), gomock.Eq(prListStr), gomock.Eq([]string{})).Return("", nil),
+		mockLVM.EXPECT().CreateVG(gomock.Eq("volumegroup1"), gomock.Eq([]string{"/dev/vdb"}),
+			gomock.Eq([]string{utils.ManagedTagName, "nrm.openyurt.io/name=volumegroup1"})).Return("", fmt.Errorf("device busy")),
+	)
+	results, err := resourceManager.ApplyResourceDiff()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(results))
+	assert.Equal(t, model.ResourceFailed, results[0].Condition)
+	assert.Equal(t, "CreateVolumeGroupFailed", results[0].Reason)
+	assert.Equal(t, model.ResourceFailed, results[1].Condition)
+	assert.Equal(t, "DeviceNotExists", results[1].Reason)
+}
+
+func TestApplyResourceDiffDryRun(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+	mockLVM := utils.NewMockLVM(mockCtl)
+	plan := utils.NewPlan()
+	resourceManager := &ResourceManager{
+		checker: newUnusedChecker(mockCtl),
+		lvmer:   utils.NewDryRunLVM(mockLVM, plan),
+		volumeGroupDeviceMap: map[string]*VgDeviceConfig{
+			"volumegroup1": {PhysicalVolumes: []string{"/dev/vdb", "/dev/vdc"}},
+			"volumegroup2": {PhysicalVolumes: []string{"/dev/vdd", "/dev/vde"}},
+		},
+	}
+
+	// only list is executed, create and extend are planned
+	mockLVM.EXPECT().ListPhysicalVolume().Return([]*model.PV{{Name: "/dev/vdd", VgName: "volumegroup2"}}, nil)
+	mockLVM.EXPECT().ListVG().Return([]*model.VG{{Name: "volumegroup2", Tags: []string{utils.ManagedTagName}}}, nil)
+	results, err := resourceManager.ApplyResourceDiff()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(results))
+	actions := plan.Take(config.ResourceKindVolumeGroup)
+	assert.ElementsMatch(t, []model.Action{
+		{Kind: "volumegroup", Name: "vgcreate", Command: "vgcreate volumegroup1 /dev/vdb /dev/vdc -v --add-tag nrm.openyurt.io/managed --add-tag nrm.openyurt.io/name=volumegroup1"},
+		{Kind: "volumegroup", Name: "pvchange", Command: "pvchange --addtag nrm.openyurt.io/managed --addtag nrm.openyurt.io/name=volumegroup1 /dev/vdb /dev/vdc"},
+		{Kind: "volumegroup", Name: "vgextend", Command: "vgextend volumegroup2 /dev/vde -v"},
+		{Kind: "volumegroup", Name: "pvchange", Command: "pvchange --addtag nrm.openyurt.io/managed --addtag nrm.openyurt.io/name=volumegroup2 /dev/vde"},
+	}, actions)
+	assert.Equal(t, 0, len(plan.Take(config.ResourceKindVolumeGroup)))
+}
+
+func TestApplyResourceDiffInvalidInput(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+	mockLVM := utils.NewMockLVM(mockCtl)
+	plan := utils.NewPlan()
+	resourceManager := &ResourceManager{
+		checker: newUnusedChecker(mockCtl),
+		lvmer:   utils.NewDryRunLVM(mockLVM, plan),
+		volumeGroupDeviceMap: map[string]*VgDeviceConfig{
+			"vg1;reboot":   {PhysicalVolumes: []string{"/dev/vdb"}},
+			"volumegroup2": {PhysicalVolumes: []string{"/dev/vdc $(reboot)"}},
+		},
+	}
+
+	// nothing is planned for names and devices rejected by validation
+	mockLVM.EXPECT().ListPhysicalVolume().Return([]*model.PV{}, nil)
+	results, err := resourceManager.ApplyResourceDiff()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(results))
+	for _, result := range results {
+		assert.Equal(t, model.ResourceFailed, result.Condition)
+		assert.Equal(t, "CreateVolumeGroupFailed", result.Reason)
+	}
+	assert.Equal(t, 0, len(plan.Take(config.ResourceKindVolumeGroup)))
+}
+
+func TestApplyResourceDiffShrink(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+	mockLVM := utils.NewMockLVM(mockCtl)
+	gib := uint64(1024 * 1024 * 1024)
+	mockMounter := utils.NewMockMounter(mockCtl)
+	resourceManager := &ResourceManager{
+		checker:         newUnusedChecker(mockCtl),
+		lvmer:           mockLVM,
+		mounter:         mockMounter,
+		pvRemovePending: map[string][]string{},
+		volumeGroupDeviceMap: map[string]*VgDeviceConfig{
+			"volumegroup1": {PhysicalVolumes: []string{"/dev/vdc"}, ListedDevices: []string{"/dev/vdc"}, AllowShrink: true},
+			"volumegroup2": {PhysicalVolumes: []string{"/dev/vdd"}, ListedDevices: []string{"/dev/vdd"}, AllowShrink: true},
+			"volumegroup3": {PhysicalVolumes: []string{"/dev/vdf"}, ListedDevices: []string{"/dev/vdf"}, AllowShrink: true},
+			"volumegroup4": {PhysicalVolumes: []string{"/dev/vdh"}, ListedDevices: []string{"/dev/vdh"}, AllowShrink: true},
+			"volumegroup5": {PhysicalVolumes: []string{"/dev/vdj", "/dev/vdl"}, ListedDevices: []string{"/dev/vdj", "/dev/vdl"}},
+		},
+	}
+	mockMounter.EXPECT().FileExists(gomock.Any()).Return(true).AnyTimes()
+
+	pvs := []*model.PV{
+		{Name: "/dev/vdb", VgName: "volumegroup1", Size: 10 * gib, FreeSize: 5 * gib},
+		{Name: "/dev/vdc", VgName: "volumegroup1", Size: 10 * gib, FreeSize: 10 * gib},
+		{Name: "/dev/vdd", VgName: "volumegroup2", Size: 10 * gib, FreeSize: 5 * gib},
+		{Name: "/dev/vde", VgName: "volumegroup2", Size: 10 * gib, FreeSize: 5 * gib},
+		{Name: "/dev/vdf", VgName: "volumegroup3", Size: 10 * gib, FreeSize: 10 * gib},
+		{Name: "/dev/vdg", VgName: "volumegroup3", Size: 10 * gib, FreeSize: 10 * gib},
+		{Name: "/dev/vdh", VgName: "volumegroup4", Size: 10 * gib, FreeSize: gib},
+		{Name: "/dev/vdi", VgName: "volumegroup4", Size: 10 * gib, FreeSize: 2 * gib},
+		{Name: "/dev/vdj", VgName: "volumegroup5", Size: 10 * gib, FreeSize: 10 * gib},
+		{Name: "/dev/vdk", VgName: "volumegroup5", Size: 10 * gib, FreeSize: 10 * gib},
+	}
+	mockLVM.EXPECT().ListPhysicalVolume().Return(pvs, nil).AnyTimes()
+	managed := []string{utils.ManagedTagName}
+	mockLVM.EXPECT().ListVG().Return([]*model.VG{
+		{Name: "volumegroup1", Tags: managed}, {Name: "volumegroup2", Tags: managed}, {Name: "volumegroup3", Tags: managed},
+		{Name: "volumegroup4", Tags: managed}, {Name: "volumegroup5", Tags: managed},
+	}, nil)
+	// a running pvmove is listed as hidden logical volume
+	mockLVM.EXPECT().ListLV(gomock.Eq("volumegroup2")).Return([]*model.LV{
+		{Name: "[pvmove0]", Attributes: model.LVAttributes{Type: model.VolumeTypePVMove}, CopyPercent: "42.00"},
+	}, nil)
+	mockLVM.EXPECT().ListLV(gomock.Any()).Return([]*model.LV{}, nil).AnyTimes()
+	mockLVM.EXPECT().MovePV(gomock.Eq("/dev/vdb"), gomock.Eq([]string{"/dev/vdc"})).Return("", nil)
+	gomock.InOrder(
+		mockLVM.EXPECT().ReduceVG(gomock.Eq("volumegroup3"), gomock.Eq([]string{"/dev/vdg"})).Return("", nil),
+		mockLVM.EXPECT().RemovePV(gomock.Eq([]string{"/dev/vdg"})).Return("", nil),
+	)
+
+	results, err := resourceManager.ApplyResourceDiff()
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(results))
+	assert.Equal(t, model.ResourceProgressing, results[0].Condition)
+	assert.Equal(t, "PhysicalVolumeMoveStarted", results[0].Reason)
+	assert.Equal(t, model.ResourceProgressing, results[1].Condition)
+	assert.Equal(t, "MovingPhysicalVolume", results[1].Reason)
+	assert.Equal(t, "moving data off [/dev/vde], 42.00% copied", results[1].Message)
+	assert.Equal(t, model.ResourceReady, results[2].Condition)
+	assert.Equal(t, "VolumeGroupReduced", results[2].Reason)
+	assert.Equal(t, model.ResourceFailed, results[3].Condition)
+	assert.Equal(t, "InsufficientFreeSpace", results[3].Reason)
+	// removing pv without allowShrink is refused
+	assert.Equal(t, model.ResourceFailed, results[4].Condition)
+	assert.Equal(t, "ExtendVolumeGroupFailed", results[4].Reason)
+}
+
+func TestApplyResourceDiffShrinkOnlyDropped(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+	mockLVM := utils.NewMockLVM(mockCtl)
+	mockMounter := utils.NewMockMounter(mockCtl)
+	gib := uint64(1024 * 1024 * 1024)
+	resourceManager := &ResourceManager{
+		checker:         newUnusedChecker(mockCtl),
+		lvmer:           mockLVM,
+		mounter:         mockMounter,
+		pvRemovePending: map[string][]string{},
+		volumeGroupDeviceMap: map[string]*VgDeviceConfig{
+			// /dev/vdc is listed but transiently missing or filtered out by selector
+			"volumegroup1": {PhysicalVolumes: []string{"/dev/vdb"}, ListedDevices: []string{"/dev/vdb", "/dev/vdc"}, AllowShrink: true},
+			// /dev/vde is dropped from listed devices but missing
+			"volumegroup2": {PhysicalVolumes: []string{"/dev/vdd"}, ListedDevices: []string{"/dev/vdd"}, AllowShrink: true},
+			// discovered local disks, /dev/vdg is no longer discovered and /dev/vdh is excluded by path
+			"volumegroup3": {PhysicalVolumes: []string{"/dev/vdf"}, ExcludedDevices: []string{"/dev/vdh"}, AllowShrink: true},
+		},
+	}
+
+	pvs := []*model.PV{
+		{Name: "/dev/vdb", VgName: "volumegroup1", Size: 10 * gib, FreeSize: 10 * gib},
+		{Name: "/dev/vdc", VgName: "volumegroup1", Size: 10 * gib, FreeSize: 10 * gib},
+		{Name: "/dev/vdd", VgName: "volumegroup2", Size: 10 * gib, FreeSize: 10 * gib},
+		{Name: "/dev/vde", VgName: "volumegroup2", Size: 10 * gib, FreeSize: 10 * gib},
+		{Name: "/dev/vdf", VgName: "volumegroup3", Size: 10 * gib, FreeSize: 10 * gib},
+		{Name: "/dev/vdg", VgName: "volumegroup3", Size: 10 * gib, FreeSize: 10 * gib},
+		{Name: "/dev/vdh", VgName: "volumegroup3", Size: 10 * gib, FreeSize: 10 * gib},
+	}
+	mockLVM.EXPECT().ListPhysicalVolume().Return(pvs, nil).AnyTimes()
+	managed := []string{utils.ManagedTagName}
+	mockLVM.EXPECT().ListVG().Return([]*model.VG{
+		{Name: "volumegroup1", Tags: managed}, {Name: "volumegroup2", Tags: managed}, {Name: "volumegroup3", Tags: managed},
+	}, nil).AnyTimes()
+	mockLVM.EXPECT().ListLV(gomock.Any()).Return([]*model.LV{}, nil).AnyTimes()
+	mockMounter.EXPECT().FileExists(gomock.Eq("/dev/vde")).Return(false)
+	mockMounter.EXPECT().FileExists(gomock.Eq("/dev/vdh")).Return(true)
+	gomock.InOrder(
+		mockLVM.EXPECT().ReduceVG(gomock.Eq("volumegroup3"), gomock.Eq([]string{"/dev/vdh"})).Return("", nil),
+		mockLVM.EXPECT().RemovePV(gomock.Eq([]string{"/dev/vdh"})).Return("", nil),
+	)
+
+	results, err := resourceManager.ApplyResourceDiff()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(results))
+	assert.Equal(t, "VolumeGroupReady", results[0].Reason)
+	assert.Equal(t, "VolumeGroupReady", results[1].Reason)
+	assert.Equal(t, "VolumeGroupReduced", results[2].Reason)
+}
+
+func TestApplyResourceDiffShrinkRetryRemovePV(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+	mockLVM := utils.NewMockLVM(mockCtl)
+	gib := uint64(1024 * 1024 * 1024)
+	mockMounter := utils.NewMockMounter(mockCtl)
+	resourceManager := &ResourceManager{
+		checker:         newUnusedChecker(mockCtl),
+		lvmer:           mockLVM,
+		mounter:         mockMounter,
+		pvRemovePending: map[string][]string{},
+		volumeGroupDeviceMap: map[string]*VgDeviceConfig{
+			"volumegroup1": {PhysicalVolumes: []string{"/dev/vdb"}, ListedDevices: []string{"/dev/vdb"}, AllowShrink: true},
+		},
+	}
+	mockMounter.EXPECT().FileExists(gomock.Any()).Return(true).AnyTimes()
+	mockLVM.EXPECT().ListVG().Return([]*model.VG{{Name: "volumegroup1", Tags: []string{utils.ManagedTagName}}}, nil).AnyTimes()
+	mockLVM.EXPECT().ListLV(gomock.Any()).Return([]*model.LV{}, nil).AnyTimes()
+
+	pvs := []*model.PV{
+		{Name: "/dev/vdb", VgName: "volumegroup1", Size: 10 * gib, FreeSize: 10 * gib},
+		{Name: "/dev/vdc", VgName: "volumegroup1", Size: 10 * gib, FreeSize: 10 * gib},
+	}
+	mockLVM.EXPECT().ListPhysicalVolume().DoAndReturn(func() ([]*model.PV, error) { return pvs, nil }).AnyTimes()
+
+	// pvremove fails after the pv left the volume group
+	mockLVM.EXPECT().ReduceVG(gomock.Eq("volumegroup1"), gomock.Eq([]string{"/dev/vdc"})).Return("", nil)
+	mockLVM.EXPECT().RemovePV(gomock.Eq([]string{"/dev/vdc"})).Return("", fmt.Errorf("device busy"))
+	results, err := resourceManager.ApplyResourceDiff()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(results))
+	assert.Equal(t, "RemovePhysicalVolumeFailed", results[0].Reason)
+
+	// the orphan pv label is wiped on next reconcile
+	pvs = pvs[:1]
+	mockLVM.EXPECT().ListOrphanPhysicalVolume().Return([]*model.PV{{Name: "/dev/vdc", Size: 10 * gib, FreeSize: 10 * gib}}, nil)
+	mockLVM.EXPECT().RemovePV(gomock.Eq([]string{"/dev/vdc"})).Return("", nil)
+	results, err = resourceManager.ApplyResourceDiff()
+	assert.Nil(t, err)
+	assert.Equal(t, model.ResourceReady, results[0].Condition)
+	assert.Equal(t, "VolumeGroupReduced", results[0].Reason)
+	assert.Equal(t, 0, len(resourceManager.pvRemovePending))
+
+	results, err = resourceManager.ApplyResourceDiff()
+	assert.Nil(t, err)
+	assert.Equal(t, "VolumeGroupReady", results[0].Reason)
+}
+
+func TestRemovePendingPvs(t *testing.T) {
+	pvsOutput, err := ioutil.ReadFile("../../utils/testdata/pvs.txt")
+	assert.Nil(t, err)
+	// /dev/vdd is labeled without volume group in pvs output, /dev/vdc is still in volumegroup1
+	executor := utils.NewFakeExecutor()
+	executor.On("pvs", "...").Return(string(pvsOutput))
+	executor.On("pvremove", "...")
+	resourceManager := &ResourceManager{
+		lvmer:           utils.NewNodeLVM(executor),
+		pvRemovePending: map[string][]string{"volumegroup2": {"/dev/vdc", "/dev/vdd"}},
+	}
+	result := resourceManager.removePendingPvs("volumegroup2")
+	assert.Equal(t, model.ResourceReady, result.Condition)
+	assert.Equal(t, "physical volumes [/dev/vdd] removed", result.Message)
+	calls := executor.Calls()
+	assert.Equal(t, 2, len(calls))
+	assert.Equal(t, "pvremove", calls[1][0])
+	assert.Contains(t, calls[1], "/dev/vdd")
+	assert.NotContains(t, calls[1], "/dev/vdc")
+	assert.Equal(t, 0, len(resourceManager.pvRemovePending))
+	assert.Nil(t, resourceManager.removePendingPvs("volumegroup2"))
+}
+
+func TestApplyResourceDiffAbsent(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+	mockLVM := utils.NewMockLVM(mockCtl)
+	resourceManager := &ResourceManager{
+		checker: newUnusedChecker(mockCtl),
+		lvmer:   mockLVM,
+		absentVolumeGroups: map[string]bool{
+			"volumegroup1": true, "volumegroup2": true, "volumegroup3": true, "volumegroup4": true, "volumegroup5": true,
+			"volumegroup6": true, "volumegroup7": true,
+		},
+		owners: map[string]*ownerConfig{"volumegroup7": {Adopt: true}},
+	}
+
+	mockLVM.EXPECT().ListPhysicalVolume().Return([]*model.PV{
+		{Name: "/dev/vdc", VgName: "volumegroup2"},
+		{Name: "/dev/vdd", VgName: "volumegroup3"},
+		{Name: "/dev/vde", VgName: "volumegroup4"},
+		{Name: "/dev/vdf", VgName: "volumegroup5"},
+		{Name: "/dev/vdg", VgName: "volumegroup6"},
+		{Name: "/dev/vdh", VgName: "volumegroup7"},
+	}, nil)
+	managed := []string{utils.ManagedTagName}
+	mockLVM.EXPECT().ListVG().Return([]*model.VG{
+		{Name: "volumegroup2", Tags: []string{utils.ProtectedTagName, utils.ManagedTagName}},
+		{Name: "volumegroup3", Tags: managed},
+		{Name: "volumegroup4", Tags: managed},
+		{Name: "volumegroup5", Tags: managed},
+		{Name: "volumegroup6"},
+		{Name: "volumegroup7"},
+	}, nil)
+	mockLVM.EXPECT().ListLV(gomock.Eq("volumegroup3")).Return([]*model.LV{
+		{Name: "lv1", Attributes: model.LVAttributes{Open: model.VolumeOpenIsOpen}},
+		{Name: "lv2", Attributes: model.LVAttributes{Open: model.VolumeOpenIsNotOpen}},
+	}, nil)
+	mockLVM.EXPECT().ListLV(gomock.Eq("volumegroup4")).Return([]*model.LV{
+		{Name: "lv3", Attributes: model.LVAttributes{Open: model.VolumeOpenIsNotOpen}},
+	}, nil)
+	mockLVM.EXPECT().ListLV(gomock.Eq("volumegroup5")).Return([]*model.LV{}, nil)
+	mockLVM.EXPECT().RemoveVG(gomock.Eq("volumegroup5")).Return("", nil)
+	// a volume group not created by node-resource-manager is removed only if adopted
+	gomock.InOrder(
+		mockLVM.EXPECT().AddTagVG(gomock.Eq("volumegroup7"), gomock.Eq([]string{utils.ManagedTagName, "nrm.openyurt.io/name=volumegroup7"})).Return("", nil),
+		mockLVM.EXPECT().AddTagPV(gomock.Eq([]string{"/dev/vdh"}), gomock.Eq([]string{utils.ManagedTagName, "nrm.openyurt.io/name=volumegroup7"})).Return("", nil),
+		mockLVM.EXPECT().ListLV(gomock.Eq("volumegroup7")).Return([]*model.LV{}, nil),
+		mockLVM.EXPECT().RemoveVG(gomock.Eq("volumegroup7")).Return("", nil),
+	)
+
+	results, err := resourceManager.ApplyResourceDiff()
+	assert.Nil(t, err)
+	assert.Equal(t, 7, len(results))
+	assert.Equal(t, model.ResourceReady, results[0].Condition)
+	assert.Equal(t, "VolumeGroupAbsent", results[0].Reason)
+	assert.Equal(t, model.ResourceFailed, results[1].Condition)
+	assert.Equal(t, "VolumeGroupProtected", results[1].Reason)
+	assert.Equal(t, "VolumeGroupInUse", results[2].Reason)
+	assert.Equal(t, "logical volumes [lv1] are open", results[2].Message)
+	assert.Equal(t, "VolumeGroupNotEmpty", results[3].Reason)
+	assert.Equal(t, model.ResourceReady, results[4].Condition)
+	assert.Equal(t, "VolumeGroupRemoved", results[4].Reason)
+	assert.Equal(t, model.ResourceFailed, results[5].Condition)
+	assert.Equal(t, "VolumeGroupNotOwned", results[5].Reason)
+	assert.Equal(t, "VolumeGroupRemoved", results[6].Reason)
+}
+
+func TestApplyResourceDiffOwnership(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+	mockLVM := utils.NewMockLVM(mockCtl)
+	resourceManager := &ResourceManager{
+		checker: newUnusedChecker(mockCtl),
+		lvmer:   mockLVM,
+		volumeGroupDeviceMap: map[string]*VgDeviceConfig{
+			"volumegroup1": {PhysicalVolumes: []string{"/dev/vdb", "/dev/vdc"}},
+			"volumegroup2": {PhysicalVolumes: []string{"/dev/vdd", "/dev/vde"}},
+			"volumegroup3": {PhysicalVolumes: []string{"/dev/vdf"}},
+			"volumegroup4": {PhysicalVolumes: []string{"/dev/vdg"}},
+		},
+		owners: map[string]*ownerConfig{
+			"volumegroup1": {SpecHash: "1111"},
+			"volumegroup2": {SpecHash: "2222", Adopt: true},
+			"volumegroup3": {SpecHash: "3333"},
+			"volumegroup4": {SpecHash: "4444"},
+		},
+		reconciled: true,
+	}
+
+	mockLVM.EXPECT().ListPhysicalVolume().Return([]*model.PV{
+		{Name: "/dev/vdb", VgName: "volumegroup1"},
+		{Name: "/dev/vdd", VgName: "volumegroup2"},
+		{Name: "/dev/vdf", VgName: "volumegroup3"},
+		{Name: "/dev/vdg", VgName: "volumegroup4"},
+	}, nil)
+	mockLVM.EXPECT().ListVG().Return([]*model.VG{
+		{Name: "volumegroup1"},
+		{Name: "volumegroup2", Tags: []string{"app=edge"}},
+		{Name: "volumegroup3", Tags: []string{utils.ManagedTagName, "nrm.openyurt.io/name=volumegroup3", "nrm.openyurt.io/spec-hash=0000"}},
+		{Name: "volumegroup4"},
+	}, nil)
+	// adopted volume group is tagged before it is extended
+	gomock.InOrder(
+		mockLVM.EXPECT().AddTagVG(gomock.Eq("volumegroup2"),
+			gomock.Eq([]string{utils.ManagedTagName, "nrm.openyurt.io/name=volumegroup2", "nrm.openyurt.io/spec-hash=2222"})).Return("", nil),
+		mockLVM.EXPECT().AddTagPV(gomock.Eq([]string{"/dev/vdd"}), gomock.Eq([]string{utils.ManagedTagName, "nrm.openyurt.io/name=volumegroup2"})).Return("", nil),
+		mockLVM.EXPECT().ExtendVG(gomock.Eq("volumegroup2"), gomock.Eq([]string{"/dev/vde"})).Return("", nil),
+		mockLVM.EXPECT().AddTagPV(gomock.Eq([]string{"/dev/vde"}), gomock.Eq([]string{utils.ManagedTagName, "nrm.openyurt.io/name=volumegroup2"})).Return("", nil),
+	)
+	// spec hash of managed volume group is updated
+	gomock.InOrder(
+		mockLVM.EXPECT().RemoveTagVG(gomock.Eq("volumegroup3"), gomock.Eq([]string{"nrm.openyurt.io/spec-hash=0000"})).Return("", nil),
+		mockLVM.EXPECT().AddTagVG(gomock.Eq("volumegroup3"), gomock.Eq([]string{"nrm.openyurt.io/spec-hash=3333"})).Return("", nil),
+	)
+
+	results, err := resourceManager.ApplyResourceDiff()
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(results))
+	assert.Equal(t, model.ResourceFailed, results[0].Condition)
+	assert.Equal(t, "VolumeGroupNotOwned", results[0].Reason)
+	assert.Equal(t, model.ResourceReady, results[1].Condition)
+	assert.Equal(t, "VolumeGroupExtended", results[1].Reason)
+	assert.Equal(t, model.ResourceReady, results[2].Condition)
+	// a volume group not owned is left untouched if nothing changes after the first reconcile
+	assert.Equal(t, model.ResourceReady, results[3].Condition)
+	assert.Equal(t, "VolumeGroupReady", results[3].Reason)
+}
+
+func TestApplyResourceDiffAdoptMatched(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+	mockLVM := utils.NewMockLVM(mockCtl)
+	resourceManager := &ResourceManager{
+		checker: newUnusedChecker(mockCtl),
+		lvmer:   mockLVM,
+		volumeGroupDeviceMap: map[string]*VgDeviceConfig{
+			"volumegroup1": {PhysicalVolumes: []string{"/dev/vdb", "/dev/vdc"}},
+			"volumegroup2": {PhysicalVolumes: []string{"/dev/vdd", "/dev/vde"}},
+			"volumegroup3": {PhysicalVolumes: []string{"/dev/vdf"}},
+		},
+		owners: map[string]*ownerConfig{
+			"volumegroup1": {SpecHash: "1111"},
+			"volumegroup2": {SpecHash: "2222"},
+			"volumegroup3": {SpecHash: "3333"},
+		},
+	}
+
+	mockLVM.EXPECT().ListPhysicalVolume().Return([]*model.PV{
+		{Name: "/dev/vdb", VgName: "volumegroup1"},
+		{Name: "/dev/vdc", VgName: "volumegroup1"},
+		{Name: "/dev/vdd", VgName: "volumegroup2"},
+		{Name: "/dev/vdf", VgName: "volumegroup3"},
+		{Name: "/dev/vdg", VgName: "volumegroup3"},
+	}, nil).Times(2)
+	mockLVM.EXPECT().ListVG().Return([]*model.VG{
+		{Name: "volumegroup1"},
+		{Name: "volumegroup2"},
+		{Name: "volumegroup3"},
+	}, nil).Times(2)
+	// only the volume group matching spec exactly is adopted in the first reconcile
+	gomock.InOrder(
+		mockLVM.EXPECT().AddTagVG(gomock.Eq("volumegroup1"),
+			gomock.Eq([]string{utils.ManagedTagName, "nrm.openyurt.io/name=volumegroup1", "nrm.openyurt.io/spec-hash=1111"})).Return("", nil),
+		mockLVM.EXPECT().AddTagPV(gomock.Eq([]string{"/dev/vdb", "/dev/vdc"}), gomock.Eq([]string{utils.ManagedTagName, "nrm.openyurt.io/name=volumegroup1"})).Return("", nil),
+	)
+
+	results, err := resourceManager.ApplyResourceDiff()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(results))
+	assert.Equal(t, model.ResourceReady, results[0].Condition)
+	assert.Equal(t, "VolumeGroupReady", results[0].Reason)
+	assert.Equal(t, model.ResourceFailed, results[1].Condition)
+	assert.Equal(t, "VolumeGroupNotOwned", results[1].Reason)
+	assert.Equal(t, model.ResourceReady, results[2].Condition)
+	assert.Equal(t, "VolumeGroupReady", results[2].Reason)
+
+	// a volume group without tag is never adopted in later reconciles, e.g. created by hand meanwhile
+	results, err = resourceManager.ApplyResourceDiff()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(results))
+	assert.Equal(t, "VolumeGroupReady", results[0].Reason)
+	assert.Equal(t, "VolumeGroupNotOwned", results[1].Reason)
+}
+
+func TestApplyResourceDiffPreflight(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+	mockLVM := utils.NewMockLVM(mockCtl)
+	mockChecker := blockdev.NewMockChecker(mockCtl)
+	resourceManager := &ResourceManager{
+		checker: mockChecker,
+		lvmer:   mockLVM,
+		volumeGroupDeviceMap: map[string]*VgDeviceConfig{
+			"volumegroup1": {PhysicalVolumes: []string{"/dev/vdb", "/dev/vdc"}},
+			"volumegroup2": {PhysicalVolumes: []string{"/dev/vdd"}, Wipe: true},
+			"volumegroup3": {PhysicalVolumes: []string{"/dev/vde", "/dev/vdf"}, Wipe: true},
+		},
+	}
+
+	mockLVM.EXPECT().ListPhysicalVolume().Return([]*model.PV{{Name: "/dev/vde", VgName: "volumegroup3"}}, nil)
+	mockLVM.EXPECT().ListVG().Return([]*model.VG{{Name: "volumegroup3", Tags: []string{utils.ManagedTagName}}}, nil).AnyTimes()
+	mockChecker.EXPECT().Check(gomock.Eq("/dev/vdb")).Return(nil, nil)
+	mockChecker.EXPECT().Check(gomock.Eq("/dev/vdc")).Return([]blockdev.Usage{{Message: "gpt signature found"}}, nil)
+	mockChecker.EXPECT().Check(gomock.Eq("/dev/vdd")).Return([]blockdev.Usage{{Message: "ext4 signature found"}}, nil)
+	mockChecker.EXPECT().Check(gomock.Eq("/dev/vdf")).Return([]blockdev.Usage{
+		{Message: "vdf is mounted", Active: true}, {Message: "ext4 signature found"},
+	}, nil)
+	// signatures are wiped only if wipe is set, devices in use are never wiped
+	gomock.InOrder(
+		mockChecker.EXPECT().Wipe(gomock.Eq("/dev/vdd")).Return(nil),
+		mockLVM.EXPECT().CreateVG(gomock.Eq("volumegroup2"), gomock.Eq([]string{"/dev/vdd"}), gomock.Any()).Return("", nil),
+		mockLVM.EXPECT().AddTagPV(gomock.Eq([]string{"/dev/vdd"}), gomock.Any()).Return("", nil),
+	)
+
+	results, err := resourceManager.ApplyResourceDiff()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(results))
+	assert.Equal(t, model.ResourceFailed, results[0].Condition)
+	assert.Equal(t, "DeviceInUse", results[0].Reason)
+	assert.Equal(t, "devices are in use: /dev/vdc (gpt signature found)", results[0].Message)
+	assert.Equal(t, "VolumeGroupCreated", results[1].Reason)
+	assert.Equal(t, "DeviceInUse", results[2].Reason)
+	assert.Equal(t, "devices are in use: /dev/vdf (vdf is mounted, ext4 signature found)", results[2].Message)
+}
+
+func TestAudit(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+	mockLVM := utils.NewMockLVM(mockCtl)
+	resourceManager := &ResourceManager{
+		checker: newUnusedChecker(mockCtl),
+		lvmer:   mockLVM,
+		volumeGroupDeviceMap: map[string]*VgDeviceConfig{
+			"volumegroup1": {PhysicalVolumes: []string{"/dev/vdb"}},
+			"volumegroup2": {PhysicalVolumes: []string{"/dev/vdd"}},
+		},
+		volumeGroupRegionMap: map[string][]string{"volumegroup3": {"region0"}},
+		absentVolumeGroups:   map[string]bool{"volumegroup4": true},
+	}
+
+	// audit only lists, nothing is changed
+	mockLVM.EXPECT().ListPhysicalVolume().Return([]*model.PV{
+		{Name: "/dev/vdb", VgName: "volumegroup1"},
+		{Name: "/dev/vdc", VgName: "volumegroup1"},
+		{Name: "/dev/vdd", VgName: "volumegroup2"},
+		{Name: "/dev/pmem0", VgName: "volumegroup3"},
+		{Name: "/dev/vde", VgName: "volumegroup4"},
+		{Name: "/dev/vdf", VgName: "vg-manual"},
+	}, nil)
+	mockLVM.EXPECT().ListOrphanPhysicalVolume().Return([]*model.PV{{Name: "/dev/vdg"}}, nil)
+	drifts, err := resourceManager.Audit()
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []v1alpha1.DriftStatus{
+		{Kind: "volumegroup", Name: "volumegroup1", Reason: "ExtraPhysicalVolumes", Message: "physical volumes [/dev/vdc] are not in topology devices"},
+		{Kind: "volumegroup", Name: "vg-manual", Reason: "NotInTopology", Message: "volume group with physical volumes [/dev/vdf] is not in topology"},
+		{Kind: "physicalvolume", Name: "/dev/vdg", Reason: "NotInTopology", Message: "physical volume is not in any volume group"},
+	}, drifts)
+}
+
+func TestAuditOrphanPhysicalVolume(t *testing.T) {
+	pvsOutput, err := ioutil.ReadFile("../../utils/testdata/pvs.txt")
+	assert.Nil(t, err)
+	executor := utils.NewFakeExecutor()
+	executor.On("pvs", "...").Return(string(pvsOutput))
+	resourceManager := &ResourceManager{
+		lvmer: utils.NewNodeLVM(executor),
+		volumeGroupDeviceMap: map[string]*VgDeviceConfig{
+			"volumegroup1": {PhysicalVolumes: []string{"/dev/vdb", "/dev/vdc"}},
+		},
+	}
+	// /dev/vdd is labeled without volume group in pvs output
+	drifts, err := resourceManager.Audit()
+	assert.Nil(t, err)
+	assert.Equal(t, []v1alpha1.DriftStatus{
+		{Kind: "physicalvolume", Name: "/dev/vdd", Reason: "NotInTopology", Message: "physical volume is not in any volume group"},
+	}, drifts)
+}
+
+func TestParseThinPool(t *testing.T) {
+	tpConfig, err := parseThinPool(&model.ThinPool{Size: "80%", ChunkSize: "256Ki"})
+	assert.Nil(t, err)
+	assert.Equal(t, &ThinPoolConfig{Name: DefaultThinPoolName, Percent: 80, ChunkSize: 256 * 1024}, tpConfig)
+	tpConfig, err = parseThinPool(&model.ThinPool{Name: "pool1", Size: "10Gi", MetadataSize: "1Gi"})
+	assert.Nil(t, err)
+	assert.Equal(t, &ThinPoolConfig{Name: "pool1", Size: 10 * 1024 * 1024 * 1024, MetadataSize: 1024 * 1024 * 1024}, tpConfig)
+	for _, size := range []string{"", "0", "0%", "101%", "ten"} {
+		_, err = parseThinPool(&model.ThinPool{Size: size})
+		assert.NotNil(t, err, size)
+	}
+}
+
+func TestApplyResourceDiffThinPool(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+	mockLVM := utils.NewMockLVM(mockCtl)
+	gib := uint64(1024 * 1024 * 1024)
+	resourceManager := &ResourceManager{
+		checker: newUnusedChecker(mockCtl),
+		lvmer:   mockLVM,
+		volumeGroupDeviceMap: map[string]*VgDeviceConfig{
+			"volumegroup1": {PhysicalVolumes: []string{"/dev/vdb"}},
+			"volumegroup2": {PhysicalVolumes: []string{"/dev/vdc"}},
+			"volumegroup3": {PhysicalVolumes: []string{"/dev/vdd"}},
+			"volumegroup4": {PhysicalVolumes: []string{"/dev/vde"}},
+			"volumegroup5": {PhysicalVolumes: []string{"/dev/vdf"}},
+		},
+		thinPools: map[string]*ThinPoolConfig{
+			"volumegroup1": {Name: "thinpool", Percent: 80},
+			"volumegroup2": {Name: "thinpool", Size: 2 * gib},
+			"volumegroup3": {Name: "thinpool", Percent: 50},
+			"volumegroup5": {Name: "thinpool", Size: gib},
+		},
+		invalidThinPools: map[string]string{"volumegroup4": `invalid size "ten"`},
+	}
+
+	mockLVM.EXPECT().ListPhysicalVolume().Return([]*model.PV{
+		{Name: "/dev/vdb", VgName: "volumegroup1"},
+		{Name: "/dev/vdc", VgName: "volumegroup2"},
+		{Name: "/dev/vdd", VgName: "volumegroup3"},
+		{Name: "/dev/vde", VgName: "volumegroup4"},
+		{Name: "/dev/vdf", VgName: "volumegroup5"},
+	}, nil)
+	mockLVM.EXPECT().ListVG().Return([]*model.VG{
+		{Name: "volumegroup1", Size: 10 * gib, FreeSize: 10 * gib, Tags: []string{utils.ManagedTagName}},
+		{Name: "volumegroup2", Size: 10 * gib, FreeSize: 9 * gib, Tags: []string{utils.ManagedTagName}},
+		{Name: "volumegroup3", Size: 10 * gib, FreeSize: 5 * gib, Tags: []string{utils.ManagedTagName}},
+		{Name: "volumegroup4", Size: 10 * gib, FreeSize: 10 * gib, Tags: []string{utils.ManagedTagName}},
+		{Name: "volumegroup5", Size: 10 * gib, FreeSize: 8 * gib, Tags: []string{utils.ManagedTagName}},
+	}, nil)
+	mockLVM.EXPECT().ListLV(gomock.Eq("volumegroup1")).Return([]*model.LV{}, nil)
+	mockLVM.EXPECT().ListLV(gomock.Eq("volumegroup2")).Return([]*model.LV{
+		{Name: "thinpool", Size: gib, Attributes: model.LVAttributes{Type: model.VolumeTypeThinPool}},
+	}, nil)
+	// the pool of percent may be smaller by its metadata
+	mockLVM.EXPECT().ListLV(gomock.Eq("volumegroup3")).Return([]*model.LV{
+		{Name: "thinpool", Size: 5*gib - utils.DefaultExtentSize, Attributes: model.LVAttributes{Type: model.VolumeTypeThinPool}},
+	}, nil)
+	mockLVM.EXPECT().ListLV(gomock.Eq("volumegroup5")).Return([]*model.LV{
+		{Name: "thinpool", Size: 2 * gib, Attributes: model.LVAttributes{Type: model.VolumeTypeThinPool}},
+	}, nil)
+	mockLVM.EXPECT().CreateThinPool(gomock.Eq("volumegroup1"), gomock.Eq("thinpool"), gomock.Eq(uint64(0)), gomock.Eq(uint32(80)),
+		gomock.Eq(uint64(0)), gomock.Eq(uint64(0)), gomock.Nil()).Return("", nil)
+	mockLVM.EXPECT().ExtendThinPool(gomock.Eq("volumegroup2"), gomock.Eq("thinpool"), gomock.Eq(2*gib)).Return("", nil)
+
+	results, err := resourceManager.ApplyResourceDiff()
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(results))
+	assert.Equal(t, "ThinPoolCreated", results[0].Reason)
+	assert.Equal(t, "ThinPoolExtended", results[1].Reason)
+	assert.Equal(t, model.ResourceReady, results[2].Condition)
+	assert.Equal(t, "VolumeGroupReady", results[2].Reason)
+	assert.Equal(t, model.ResourceFailed, results[3].Condition)
+	assert.Equal(t, "InvalidThinPool", results[3].Reason)
+	assert.Equal(t, model.ResourceFailed, results[4].Condition)
+	assert.Equal(t, "ShrinkNotSupported", results[4].Reason)
+}
+
+func TestRecordStatus(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+	mockLVM := utils.NewMockLVM(mockCtl)
+	resourceManager := &ResourceManager{lvmer: mockLVM}
+
+	gomock.InOrder(
+		mockLVM.EXPECT().ListVG().Return([]*model.VG{
+			{Name: "volumegroup2", Size: 200, FreeSize: 200},
+			{Name: "volumegroup1", Size: 300, FreeSize: 100},
+		}, nil),
+		mockLVM.EXPECT().ListPhysicalVolume().Return([]*model.PV{
+			{Name: "/dev/vdb", VgName: "volumegroup1", Size: 100},
+			{Name: "/dev/vdc", VgName: "volumegroup1", Size: 200},
+			{Name: "/dev/vdd", VgName: "volumegroup2", Size: 200},
+		}, nil),
 	)
-	assert.Nil(t, resourceManager.ApplyResourceDiff())
+	status := &v1alpha1.NodeLocalResourceStatus{}
+	assert.Nil(t, resourceManager.RecordStatus(status))
+	assert.Equal(t, []v1alpha1.VolumeGroupStatus{
+		{Name: "volumegroup1", Size: 300, FreeSize: 100, PhysicalVolumes: []v1alpha1.PhysicalVolumeStatus{{Name: "/dev/vdb", Size: 100}, {Name: "/dev/vdc", Size: 200}}},
+		{Name: "volumegroup2", Size: 200, FreeSize: 200, PhysicalVolumes: []v1alpha1.PhysicalVolumeStatus{{Name: "/dev/vdd", Size: 200}}},
+	}, status.VolumeGroups)
 }
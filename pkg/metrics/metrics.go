@@ -0,0 +1,179 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/openyurtio/node-resource-manager/pkg/apis/nrm/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	klog "k8s.io/klog/v2"
+)
+
+const (
+	// namespace of all metrics
+	namespace = "nrm"
+)
+
+var (
+	// VolumeGroupSize is the size of volume group in bytes
+	VolumeGroupSize = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "volume_group_size_bytes",
+		Help:      "Size of lvm volume group in bytes.",
+	}, []string{"volume_group"})
+	// VolumeGroupFree is the free size of volume group in bytes
+	VolumeGroupFree = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "volume_group_free_bytes",
+		Help:      "Free size of lvm volume group in bytes.",
+	}, []string{"volume_group"})
+	// VolumeGroupPhysicalVolumes is the number of physical volumes in volume group
+	VolumeGroupPhysicalVolumes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "volume_group_physical_volumes",
+		Help:      "Number of physical volumes in lvm volume group.",
+	}, []string{"volume_group"})
+
+	// PmemRegionSize is the size of pmem region in bytes
+	PmemRegionSize = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "pmem_region_size_bytes",
+		Help:      "Size of pmem region in bytes.",
+	}, []string{"region"})
+	// PmemRegionAvailable is the size of pmem region not used by namespaces in bytes
+	PmemRegionAvailable = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "pmem_region_available_bytes",
+		Help:      "Available size of pmem region not used by namespaces in bytes.",
+	}, []string{"region"})
+
+	// QuotaPathCapacity is the capacity of filesystem mounted at quota path in bytes
+	QuotaPathCapacity = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "quota_path_capacity_bytes",
+		Help:      "Capacity of filesystem mounted at quota path in bytes.",
+	}, []string{"mount_path", "device"})
+	// QuotaPathUsed is the used size of filesystem mounted at quota path in bytes
+	QuotaPathUsed = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "quota_path_used_bytes",
+		Help:      "Used size of filesystem mounted at quota path in bytes.",
+	}, []string{"mount_path", "device"})
+	// QuotaPathAvailable is the available size of filesystem mounted at quota path in bytes
+	QuotaPathAvailable = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "quota_path_available_bytes",
+		Help:      "Available size of filesystem mounted at quota path in bytes.",
+	}, []string{"mount_path", "device"})
+
+	// ReconcileDuration is the duration of reconcile of each manager
+	ReconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "reconcile_duration_seconds",
+		Help:      "Duration of reconciling resources of one manager in seconds.",
+		Buckets:   []float64{0.1, 0.5, 1, 5, 10, 30, 60, 120, 300},
+	}, []string{"manager"})
+	// ReconcileErrors is the number of failed reconciles of each manager
+	ReconcileErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reconcile_errors_total",
+		Help:      "Number of failed reconciles of one manager.",
+	}, []string{"manager"})
+
+	// CommandsTotal is the number of executed commands
+	CommandsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "commands_total",
+		Help:      "Number of executed commands by binary and exit code, exit code is -1 if the command can not be started.",
+	}, []string{"binary", "exit_code"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		VolumeGroupSize,
+		VolumeGroupFree,
+		VolumeGroupPhysicalVolumes,
+		PmemRegionSize,
+		PmemRegionAvailable,
+		QuotaPathCapacity,
+		QuotaPathUsed,
+		QuotaPathAvailable,
+		ReconcileDuration,
+		ReconcileErrors,
+		CommandsTotal,
+	)
+}
+
+// RecordNodeResources set resource gauges from status, resources removed from node are removed from gauges
+func RecordNodeResources(status *v1alpha1.NodeLocalResourceStatus) {
+	VolumeGroupSize.Reset()
+	VolumeGroupFree.Reset()
+	VolumeGroupPhysicalVolumes.Reset()
+	for _, vg := range status.VolumeGroups {
+		VolumeGroupSize.WithLabelValues(vg.Name).Set(float64(vg.Size))
+		VolumeGroupFree.WithLabelValues(vg.Name).Set(float64(vg.FreeSize))
+		VolumeGroupPhysicalVolumes.WithLabelValues(vg.Name).Set(float64(len(vg.PhysicalVolumes)))
+	}
+
+	PmemRegionSize.Reset()
+	PmemRegionAvailable.Reset()
+	for _, region := range status.PmemRegions {
+		PmemRegionSize.WithLabelValues(region.Name).Set(float64(region.Size))
+		PmemRegionAvailable.WithLabelValues(region.Name).Set(float64(region.AvailableSize))
+	}
+
+	QuotaPathCapacity.Reset()
+	QuotaPathUsed.Reset()
+	QuotaPathAvailable.Reset()
+	for _, qp := range status.QuotaPaths {
+		QuotaPathCapacity.WithLabelValues(qp.MountPath, qp.Device).Set(float64(qp.Capacity))
+		QuotaPathUsed.WithLabelValues(qp.MountPath, qp.Device).Set(float64(qp.Used))
+		QuotaPathAvailable.WithLabelValues(qp.MountPath, qp.Device).Set(float64(qp.Available))
+	}
+}
+
+// RecordReconcile records duration of one reconcile of manager, and counts it if failed
+func RecordReconcile(manager string, start time.Time, err error) {
+	ReconcileDuration.WithLabelValues(manager).Observe(time.Since(start).Seconds())
+	if err != nil {
+		ReconcileErrors.WithLabelValues(manager).Inc()
+	}
+}
+
+// RecordCommand counts one executed command
+func RecordCommand(binary string, exitCode int) {
+	CommandsTotal.WithLabelValues(binary, strconv.Itoa(exitCode)).Inc()
+}
+
+// Serve serves /metrics on address until the process exits, address empty disables it
+func Serve(address string) {
+	if address == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	go func() {
+		klog.Infof("Serve:: serving metrics on %s", address)
+		if err := http.ListenAndServe(address, mux); err != nil {
+			klog.Errorf("Serve:: serve metrics on %s error: %v", address, err)
+		}
+	}()
+}
@@ -171,18 +171,139 @@ type ResourceYaml struct {
 	Key      string                       `yaml:"key,omitempty"`
 	Operator metav1.LabelSelectorOperator `yaml:"operator,omitempty"`
 	Value    string                       `yaml:"value,omitempty"`
-	Topology Topology                     `yaml:"topology,omitempty"`
+	// State is present by default, only volume group supports absent
+	State    string   `yaml:"state,omitempty"`
+	Topology Topology `yaml:"topology,omitempty"`
 }
 
+// states of resource
+const (
+	// ResourceStatePresent means the resource is created on matched nodes
+	ResourceStatePresent = "present"
+	// ResourceStateAbsent means the resource is removed from matched nodes
+	ResourceStateAbsent = "absent"
+)
+
 // Topology ...
 type Topology struct {
 	Type    string `yaml:"type,omitempty"`
 	Options string `yaml:"options,omitempty"`
 	Fstype  string `yaml:"fstype,omitempty"`
+	// Layout is how quota path of type device uses its devices, one of single, raid0, raid1
+	Layout string `yaml:"layout,omitempty"`
+	// Persistence writes the quota path mount into host fstab or systemd mount unit to mount it on boot, one of fstab, systemd
+	Persistence string `yaml:"persistence,omitempty"`
+
+	Devices []string `yaml:"devices,omitempty"`
+	// DeviceSelector selects disks on node by attributes, the selected disks are added to devices
+	DeviceSelector *DeviceSelector     `yaml:"deviceSelector,omitempty"`
+	Volumes        []map[string]string `yaml:"volumes,omitempty"`
+	Regions        []string            `yaml:"regions,omitempty"`
+	// Include and Exclude filter the local disks by glob of name, path or /dev/disk link, e.g. nvme*
+	Include []string `yaml:"include,omitempty"`
+	Exclude []string `yaml:"exclude,omitempty"`
+
+	// VolumeGroup, Size, Mirrors and Tags define logical volume, VolumeGroup and Size also define the logical volume of lvm quota path
+	VolumeGroup string   `yaml:"volumeGroup,omitempty"`
+	Size        string   `yaml:"size,omitempty"`
+	Mirrors     uint32   `yaml:"mirrors,omitempty"`
+	Tags        []string `yaml:"tags,omitempty"`
+	// Pool is the thin pool which a thin logical volume is created in
+	Pool string `yaml:"pool,omitempty"`
+
+	// ThinPool is the thin pool created in volume group
+	ThinPool *ThinPool `yaml:"thinPool,omitempty"`
+	// AllowShrink allows removing physical volumes not in devices from volume group
+	AllowShrink bool `yaml:"allowShrink,omitempty"`
+	// Adopt allows changing an existing volume group not created by node-resource-manager, it is tagged as managed then
+	Adopt bool `yaml:"adopt,omitempty"`
+	// Wipe allows wiping the filesystem, partition table and lvm signatures of devices before they are added to volume group
+	Wipe bool `yaml:"wipe,omitempty"`
+
+	// Directories are the directories in quota path limited by project quota
+	Directories []QuotaDirectory `yaml:"directories,omitempty"`
+}
+
+// QuotaDirectory is a directory in quota path with its own project quota, a limit not set or 0 is unlimited
+type QuotaDirectory struct {
+	// Name is the path relative to quota path, e.g. app1 or data/app1
+	Name string `yaml:"name"`
+	// BlockSoftLimit and BlockHardLimit are quantities, e.g. 10Gi
+	BlockSoftLimit string `yaml:"blockSoftLimit,omitempty"`
+	BlockHardLimit string `yaml:"blockHardLimit,omitempty"`
+	InodeSoftLimit uint64 `yaml:"inodeSoftLimit,omitempty"`
+	InodeHardLimit uint64 `yaml:"inodeHardLimit,omitempty"`
+}
+
+// ThinPool defines the lvm thin pool of volume group
+type ThinPool struct {
+	Name string `yaml:"name,omitempty"`
+	// Size is a quantity, e.g. 10Gi, or a percent of volume group, e.g. 80%
+	Size         string `yaml:"size,omitempty"`
+	ChunkSize    string `yaml:"chunkSize,omitempty"`
+	MetadataSize string `yaml:"metadataSize,omitempty"`
+}
+
+// DeviceSelector matches whole disks on node, a disk is selected if it matches every field set,
+// and any value of a list field. String values may be glob patterns.
+type DeviceSelector struct {
+	// ByID and ByPath are names of links in /dev/disk/by-id and /dev/disk/by-path, with or without the directory
+	ByID   []string `yaml:"byId,omitempty"`
+	ByPath []string `yaml:"byPath,omitempty"`
+	Serial []string `yaml:"serial,omitempty"`
+	WWN    []string `yaml:"wwn,omitempty"`
+	Model  []string `yaml:"model,omitempty"`
+	// MinSize and MaxSize are quantities, e.g. 100Gi, the range is inclusive
+	MinSize    string `yaml:"minSize,omitempty"`
+	MaxSize    string `yaml:"maxSize,omitempty"`
+	Rotational *bool  `yaml:"rotational,omitempty"`
+	// Transport is one of nvme, sata, scsi, virtio, usb
+	Transport []string `yaml:"transport,omitempty"`
+}
+
+// ResourceCondition is the reconcile condition of one desired resource
+type ResourceCondition string
+
+// conditions
+const (
+	// ResourceReady means the resource on node matches its spec
+	ResourceReady ResourceCondition = "Ready"
+	// ResourceProgressing means the resource is being changed, or waits for something to be done
+	ResourceProgressing ResourceCondition = "Progressing"
+	// ResourceFailed means the resource can not be applied
+	ResourceFailed ResourceCondition = "Failed"
+)
+
+// ResourceResult is the reconcile result of one desired resource
+type ResourceResult struct {
+	// Kind is volumegroup, logicalvolume, quotapath or memory
+	Kind      string            `json:"kind"`
+	Name      string            `json:"name"`
+	Condition ResourceCondition `json:"condition"`
+	// Reason is a CamelCase word, used as event reason
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// NewResourceResult ...
+func NewResourceResult(kind, name string, condition ResourceCondition, reason, message string) *ResourceResult {
+	return &ResourceResult{
+		Kind:      kind,
+		Name:      name,
+		Condition: condition,
+		Reason:    reason,
+		Message:   message,
+	}
+}
 
-	Devices []string            `yaml:"devices,omitempty"`
-	Volumes []map[string]string `yaml:"volumes,omitempty"`
-	Regions []string            `yaml:"regions,omitempty"`
+// Action is one change planned on node in dry-run mode
+type Action struct {
+	// Kind is the resource kind planning the action: volumegroup, logicalvolume, quotapath or memory
+	Kind string `json:"kind,omitempty"`
+	// Name is the tool of the action, e.g. vgcreate, mkfs
+	Name string `json:"name"`
+	// Command is the command line would be executed
+	Command string `json:"command"`
 }
 
 // PmemRegions list all regions
@@ -225,6 +346,50 @@ type DaxctrlMem struct {
 	Movable    bool   `json:"movable"`
 }
 
+// FsStats is the capacity info of one mounted filesystem, in bytes
+type FsStats struct {
+	Capacity  uint64
+	Used      uint64
+	Available uint64
+}
+
+// Project is one project in /etc/projects and /etc/projid
+type Project struct {
+	ID   uint32
+	Name string
+	Path string
+}
+
+// ProjectQuota is the usage and limits of one project in quota report, block sizes are in bytes, 0 limit is unlimited
+type ProjectQuota struct {
+	ID             uint32
+	BlockUsed      uint64
+	BlockSoftLimit uint64
+	BlockHardLimit uint64
+	InodeUsed      uint64
+	InodeSoftLimit uint64
+	InodeHardLimit uint64
+}
+
+// RaidArray is one md software raid array, Name is without homehost
+type RaidArray struct {
+	Path    string
+	Name    string
+	Level   string
+	Devices []string
+}
+
+// PersistedMount is a quota path mount persisted by node-resource-manager in host fstab or systemd mount unit,
+// the filesystem is mounted by UUID
+type PersistedMount struct {
+	// Persistence is fstab or systemd
+	Persistence string
+	UUID        string
+	Path        string
+	Fstype      string
+	Options     string
+}
+
 // LV is a logical volume
 type LV struct {
 	Name               string
@@ -242,16 +407,19 @@ type VG struct {
 	Name     string
 	Size     uint64
 	FreeSize uint64
-	UUID     string
-	Tags     []string
+	// ExtentSize is the physical extent size, lvm rounds the sizes of logical volumes up to it
+	ExtentSize uint64
+	UUID       string
+	Tags       []string
 }
 
 // PV is Physica lVolume
 type PV struct {
-	Name   string
-	VgName string
-	Size   uint64
-	UUID   string
+	Name     string
+	VgName   string
+	Size     uint64
+	FreeSize uint64
+	UUID     string
 }
 
 // LVAttributes is attributes
@@ -313,8 +481,8 @@ func ParseLV(line string) (*LV, error) {
 
 // ParseVG parse volume group
 func ParseVG(line string) (*VG, error) {
-	// vgs --units=b --separator="<:SEP:>" --nosuffix --noheadings -o vg_name,vg_size,vg_free,vg_uuid,vg_tags --nameprefixes -a
-	fields, err := parse(line, 5)
+	// vgs --units=b --separator="<:SEP:>" --nosuffix --noheadings -o vg_name,vg_size,vg_free,vg_extent_size,vg_uuid,vg_tags --nameprefixes -a
+	fields, err := parse(line, 6)
 	if err != nil {
 		return nil, err
 	}
@@ -329,19 +497,25 @@ func ParseVG(line string) (*VG, error) {
 		return nil, err
 	}
 
+	extentSize, err := strconv.ParseUint(fields["LVM2_VG_EXTENT_SIZE"], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
 	return &VG{
-		Name:     fields["LVM2_VG_NAME"],
-		Size:     size,
-		FreeSize: freeSize,
-		UUID:     fields["LVM2_VG_UUID"],
-		Tags:     strings.Split(fields["LVM2_VG_TAGS"], ","),
+		Name:       fields["LVM2_VG_NAME"],
+		Size:       size,
+		FreeSize:   freeSize,
+		ExtentSize: extentSize,
+		UUID:       fields["LVM2_VG_UUID"],
+		Tags:       strings.Split(fields["LVM2_VG_TAGS"], ","),
 	}, nil
 }
 
 // ParsePV parse volume group
 func ParsePV(line string) (*PV, error) {
-	// vgs --units=b --separator="<:SEP:>" --nosuffix --noheadings -o vg_name,vg_size,vg_free,vg_uuid,vg_tags --nameprefixes -a
-	fields, err := parse(line, 4)
+	// pvs --units=b --separator="<:SEP:>" --nosuffix --noheadings -o vg_name,pv_name,pv_size,pv_free,pv_uuid --nameprefixes -a
+	fields, err := parse(line, 5)
 	if err != nil {
 		return nil, err
 	}
@@ -351,11 +525,17 @@ func ParsePV(line string) (*PV, error) {
 		return nil, err
 	}
 
+	freeSize, err := strconv.ParseUint(fields["LVM2_PV_FREE"], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
 	return &PV{
-		Name:   fields["LVM2_PV_NAME"],
-		VgName: fields["LVM2_VG_NAME"],
-		Size:   size,
-		UUID:   fields["LVM2_PV_UUID"],
+		Name:     fields["LVM2_PV_NAME"],
+		VgName:   fields["LVM2_VG_NAME"],
+		Size:     size,
+		FreeSize: freeSize,
+		UUID:     fields["LVM2_PV_UUID"],
 	}, nil
 }
 
@@ -0,0 +1,276 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package blockdev
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	klog "k8s.io/klog/v2"
+)
+
+// transports of disk
+const (
+	TransportNVMe   = "nvme"
+	TransportSATA   = "sata"
+	TransportSCSI   = "scsi"
+	TransportVirtio = "virtio"
+	TransportUSB    = "usb"
+)
+
+// Disk is one whole block device on node, sizes are in bytes
+type Disk struct {
+	// Name is the kernel name, e.g. vdb, Path is the device path, e.g. /dev/vdb
+	Name       string
+	Path       string
+	Size       uint64
+	Rotational bool
+	Model      string
+	Serial     string
+	WWN        string
+	Transport  string
+	// ByID and ByPath are the names of links to disk in /dev/disk/by-id and /dev/disk/by-path
+	ByID   []string
+	ByPath []string
+	// Partitioned is true if disk has partitions
+	Partitioned bool
+	// Holders are the devices built on disk, e.g. dm-0 of a logical volume or md0 of a raid
+	Holders []string
+	// FSType is the signature on disk known by udev, e.g. ext4 or LVM2_member
+	FSType string
+	// Mounted is true if disk is mounted on node
+	Mounted bool
+}
+
+// Discoverer lists disks on node
+type Discoverer interface {
+	ListDisks() ([]*Disk, error)
+}
+
+// SysfsDiscoverer reads disks from sysfs, udev database and the links in devRoot/disk,
+// and the mounted devices from mountInfo
+type SysfsDiscoverer struct {
+	sysRoot   string
+	devRoot   string
+	udevRoot  string
+	mountInfo string
+}
+
+// NewDiscoverer returns a discoverer of node, /run/udev is mounted from host,
+// mounts of host are read from the init process as the pod runs in host pid namespace
+func NewDiscoverer() *SysfsDiscoverer {
+	return NewSysfsDiscoverer("/sys", "/dev", "/run/udev/data", "/proc/1/mountinfo")
+}
+
+// NewSysfsDiscoverer ...
+func NewSysfsDiscoverer(sysRoot, devRoot, udevRoot, mountInfo string) *SysfsDiscoverer {
+	return &SysfsDiscoverer{sysRoot: sysRoot, devRoot: devRoot, udevRoot: udevRoot, mountInfo: mountInfo}
+}
+
+// ListDisks returns the whole disks sorted by name, virtual devices like loop, dm and md are skipped
+func (sd *SysfsDiscoverer) ListDisks() ([]*Disk, error) {
+	blockDir := filepath.Join(sd.sysRoot, "block")
+	entries, err := ioutil.ReadDir(blockDir)
+	if err != nil {
+		return nil, err
+	}
+	byID := sd.readLinks("by-id")
+	byPath := sd.readLinks("by-path")
+	mounted := readMounted(sd.mountInfo)
+
+	disks := []*Disk{}
+	for _, entry := range entries {
+		name := entry.Name()
+		sysPath, err := filepath.EvalSymlinks(filepath.Join(blockDir, name))
+		if err != nil {
+			klog.Errorf("ListDisks:: resolve sysfs path of %s error: %v", name, err)
+			continue
+		}
+		if strings.Contains(sysPath, "/devices/virtual/") {
+			continue
+		}
+		disk := &Disk{
+			Name:        name,
+			Path:        filepath.Join("/dev", name),
+			Rotational:  readString(filepath.Join(sysPath, "queue", "rotational")) == "1",
+			Model:       readString(filepath.Join(sysPath, "device", "model")),
+			Serial:      readString(filepath.Join(sysPath, "device", "serial")),
+			WWN:         readString(filepath.Join(sysPath, "device", "wwid")),
+			ByID:        byID[name],
+			ByPath:      byPath[name],
+			Partitioned: len(partitions(sysPath, name)) > 0,
+			Holders:     readNames(filepath.Join(sysPath, "holders")),
+		}
+		if disk.Serial == "" {
+			disk.Serial = readString(filepath.Join(sysPath, "serial"))
+		}
+		if sectors, err := strconv.ParseUint(readString(filepath.Join(sysPath, "size")), 10, 64); err == nil {
+			disk.Size = sectors * 512
+		}
+
+		// udev knows the ata and scsi attributes not exposed in sysfs
+		devNumber := readString(filepath.Join(sysPath, "dev"))
+		disk.Mounted = mounted[devNumber]
+		udev := sd.readUdev(devNumber)
+		if disk.Serial == "" {
+			disk.Serial = firstNonEmpty(udev["ID_SERIAL_SHORT"], udev["ID_SERIAL"])
+		}
+		if disk.WWN == "" {
+			disk.WWN = firstNonEmpty(udev["ID_WWN_WITH_EXTENSION"], udev["ID_WWN"])
+		}
+		if disk.Model == "" {
+			disk.Model = udev["ID_MODEL"]
+		}
+		disk.Transport = transport(name, sysPath, udev["ID_BUS"])
+		disk.FSType = udev["ID_FS_TYPE"]
+		disks = append(disks, disk)
+	}
+	sort.Slice(disks, func(i, j int) bool { return disks[i].Name < disks[j].Name })
+	return disks, nil
+}
+
+// readLinks returns the link names in /dev/disk/<dir> by the disk name they point to, links to partitions are skipped
+func (sd *SysfsDiscoverer) readLinks(dir string) map[string][]string {
+	links := map[string][]string{}
+	linkDir := filepath.Join(sd.devRoot, "disk", dir)
+	entries, err := ioutil.ReadDir(linkDir)
+	if err != nil {
+		klog.V(3).Infof("readLinks:: read %s error: %v", linkDir, err)
+		return links
+	}
+	for _, entry := range entries {
+		target, err := os.Readlink(filepath.Join(linkDir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		name := filepath.Base(target)
+		links[name] = append(links[name], entry.Name())
+	}
+	return links
+}
+
+// readUdev returns the properties of device in udev database, devNumber is major:minor
+func (sd *SysfsDiscoverer) readUdev(devNumber string) map[string]string {
+	properties := map[string]string{}
+	if devNumber == "" {
+		return properties
+	}
+	f, err := os.Open(filepath.Join(sd.udevRoot, "b"+devNumber))
+	if err != nil {
+		return properties
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "E:") {
+			continue
+		}
+		if kv := strings.SplitN(strings.TrimPrefix(line, "E:"), "=", 2); len(kv) == 2 {
+			properties[kv[0]] = kv[1]
+		}
+	}
+	return properties
+}
+
+// readMounted returns the major:minor of mounted devices, the third field of mountInfo
+func readMounted(mountInfo string) map[string]bool {
+	mounted := map[string]bool{}
+	f, err := os.Open(mountInfo)
+	if err != nil {
+		klog.Errorf("readMounted:: read %s error: %v", mountInfo, err)
+		return mounted
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) > 2 {
+			mounted[fields[2]] = true
+		}
+	}
+	return mounted
+}
+
+// transport guesses the transport of disk by name, sysfs path and udev bus
+func transport(name, sysPath, bus string) string {
+	switch {
+	case strings.HasPrefix(name, "nvme"):
+		return TransportNVMe
+	case strings.Contains(sysPath, "/virtio"):
+		return TransportVirtio
+	case bus == "usb" || strings.Contains(sysPath, "/usb"):
+		return TransportUSB
+	case bus == "ata" || strings.Contains(sysPath, "/ata"):
+		return TransportSATA
+	case bus == "scsi":
+		return TransportSCSI
+	}
+	return ""
+}
+
+// partitions returns the names of partition directories of disk in sysfs
+func partitions(sysPath, name string) []string {
+	entries, err := ioutil.ReadDir(sysPath)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), name) {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(sysPath, entry.Name(), "partition")); err == nil {
+			names = append(names, entry.Name())
+		}
+	}
+	return names
+}
+
+// readNames returns the names of entries in dir, nil if dir is empty or not exists
+func readNames(dir string) []string {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func readString(file string) string {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
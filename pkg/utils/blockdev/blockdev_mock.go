@@ -0,0 +1,113 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package blockdev
+
+import (
+	"reflect"
+
+	"github.com/golang/mock/gomock"
+)
+
+// MockDiscoverer ...
+type MockDiscoverer struct {
+	ctrl     *gomock.Controller
+	recorder *MockDiscovererMockRecorder
+}
+
+// MockDiscovererMockRecorder ...
+type MockDiscovererMockRecorder struct {
+	mock *MockDiscoverer
+}
+
+// NewMockDiscoverer ...
+func NewMockDiscoverer(ctrl *gomock.Controller) *MockDiscoverer {
+	mock := &MockDiscoverer{ctrl: ctrl}
+	mock.recorder = &MockDiscovererMockRecorder{mock}
+	return mock
+}
+
+// EXPECT ...
+func (m *MockDiscoverer) EXPECT() *MockDiscovererMockRecorder {
+	return m.recorder
+}
+
+// ListDisks ...
+func (m *MockDiscoverer) ListDisks() ([]*Disk, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListDisks")
+	ret0, _ := ret[0].([]*Disk)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListDisks ...
+func (mr *MockDiscovererMockRecorder) ListDisks() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListDisks", reflect.TypeOf((*MockDiscoverer)(nil).ListDisks))
+}
+
+// MockChecker ...
+type MockChecker struct {
+	ctrl     *gomock.Controller
+	recorder *MockCheckerMockRecorder
+}
+
+// MockCheckerMockRecorder ...
+type MockCheckerMockRecorder struct {
+	mock *MockChecker
+}
+
+// NewMockChecker ...
+func NewMockChecker(ctrl *gomock.Controller) *MockChecker {
+	mock := &MockChecker{ctrl: ctrl}
+	mock.recorder = &MockCheckerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT ...
+func (m *MockChecker) EXPECT() *MockCheckerMockRecorder {
+	return m.recorder
+}
+
+// Check ...
+func (m *MockChecker) Check(device string) ([]Usage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Check", device)
+	ret0, _ := ret[0].([]Usage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Check ...
+func (mr *MockCheckerMockRecorder) Check(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Check", reflect.TypeOf((*MockChecker)(nil).Check), arg0)
+}
+
+// Wipe ...
+func (m *MockChecker) Wipe(device string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Wipe", device)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Wipe ...
+func (mr *MockCheckerMockRecorder) Wipe(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Wipe", reflect.TypeOf((*MockChecker)(nil).Wipe), arg0)
+}
@@ -0,0 +1,219 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package blockdev
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openyurtio/node-resource-manager/pkg/model"
+	"github.com/openyurtio/node-resource-manager/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// makeDisk creates sysfs files of disk under root, the block link points to devicePath
+func makeDisk(t *testing.T, root, devicePath, name string, files map[string]string) {
+	sysPath := filepath.Join(root, "sys", devicePath, "block", name)
+	for file, content := range files {
+		assert.Nil(t, os.MkdirAll(filepath.Dir(filepath.Join(sysPath, file)), 0755))
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(sysPath, file), []byte(content+"\n"), 0644))
+	}
+	assert.Nil(t, os.MkdirAll(filepath.Join(root, "sys", "block"), 0755))
+	assert.Nil(t, os.Symlink(sysPath, filepath.Join(root, "sys", "block", name)))
+}
+
+func TestListDisks(t *testing.T) {
+	root, err := ioutil.TempDir("", "blockdev")
+	assert.Nil(t, err)
+	defer os.RemoveAll(root)
+
+	makeDisk(t, root, "devices/pci0000:00/0000:00:05.0/virtio2", "vda", map[string]string{
+		"size": "83886080", "dev": "253:0", "queue/rotational": "1", "serial": "bp1abc", "vda1/partition": "1",
+	})
+	makeDisk(t, root, "devices/pci0000:00/0000:00:06.0/virtio3", "vdb", map[string]string{
+		"size": "209715200", "dev": "253:16", "queue/rotational": "1", "serial": "bp1def",
+	})
+	makeDisk(t, root, "devices/pci0000:00/0000:00:1f.2/ata1/host0/target0:0:0/0:0:0:0", "sda", map[string]string{
+		"size": "1953525168", "dev": "8:0", "queue/rotational": "1", "device/model": "ST1000NM0033", "holders/dm-0": "",
+	})
+	makeDisk(t, root, "devices/pci0000:00/0000:00:04.0/nvme/nvme0", "nvme0n1", map[string]string{
+		"size": "3750748848", "dev": "259:0", "queue/rotational": "0", "device/model": "INTEL SSDPE2KX040T8",
+		"device/serial": "PHLJ000000", "device/wwid": "eui.01000000000000005cd2e4a1b2c3d4e5",
+	})
+	makeDisk(t, root, "devices/virtual", "loop0", map[string]string{"size": "8", "dev": "7:0"})
+
+	udevDir := filepath.Join(root, "run", "udev", "data")
+	assert.Nil(t, os.MkdirAll(udevDir, 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(udevDir, "b8:0"),
+		[]byte("S:disk/by-id/wwn-0x5000c500a1b2c3d4\nE:ID_BUS=ata\nE:ID_SERIAL=ST1000NM0033_Z1W0\nE:ID_SERIAL_SHORT=Z1W0\nE:ID_WWN=0x5000c500a1b2c3d4\nE:ID_FS_TYPE=LVM2_member\n"), 0644))
+	byID := filepath.Join(root, "dev", "disk", "by-id")
+	byPath := filepath.Join(root, "dev", "disk", "by-path")
+	assert.Nil(t, os.MkdirAll(byID, 0755))
+	assert.Nil(t, os.MkdirAll(byPath, 0755))
+	assert.Nil(t, os.Symlink("../../sda", filepath.Join(byID, "wwn-0x5000c500a1b2c3d4")))
+	assert.Nil(t, os.Symlink("../../sda", filepath.Join(byID, "ata-ST1000NM0033_Z1W0")))
+	assert.Nil(t, os.Symlink("../../vda1", filepath.Join(byID, "virtio-bp1abc-part1")))
+	assert.Nil(t, os.Symlink("../../vdb", filepath.Join(byPath, "pci-0000:00:06.0")))
+
+	mountInfo := filepath.Join(root, "mountinfo")
+	assert.Nil(t, ioutil.WriteFile(mountInfo, []byte("25 1 253:1 / / rw,relatime shared:1 - ext4 /dev/vda1 rw\n"+
+		"97 25 253:16 / /mnt/path1 rw,relatime shared:50 - ext4 /dev/vdb rw,prjquota\n"), 0644))
+
+	// path of disk is always under /dev
+	disks, err := NewSysfsDiscoverer(filepath.Join(root, "sys"), filepath.Join(root, "dev"), udevDir, mountInfo).ListDisks()
+	assert.Nil(t, err)
+	assert.Equal(t, []*Disk{
+		{Name: "nvme0n1", Path: "/dev/nvme0n1", Size: 3750748848 * 512, Model: "INTEL SSDPE2KX040T8", Serial: "PHLJ000000",
+			WWN: "eui.01000000000000005cd2e4a1b2c3d4e5", Transport: TransportNVMe},
+		{Name: "sda", Path: "/dev/sda", Size: 1953525168 * 512, Rotational: true, Model: "ST1000NM0033", Serial: "Z1W0",
+			WWN: "0x5000c500a1b2c3d4", Transport: TransportSATA, ByID: []string{"ata-ST1000NM0033_Z1W0", "wwn-0x5000c500a1b2c3d4"},
+			Holders: []string{"dm-0"}, FSType: FSTypeLVM},
+		{Name: "vda", Path: "/dev/vda", Size: 83886080 * 512, Rotational: true, Serial: "bp1abc", Transport: TransportVirtio, Partitioned: true},
+		{Name: "vdb", Path: "/dev/vdb", Size: 209715200 * 512, Rotational: true, Serial: "bp1def", Transport: TransportVirtio,
+			ByPath: []string{"pci-0000:00:06.0"}, Mounted: true},
+	}, disks)
+}
+
+func TestMatchDevice(t *testing.T) {
+	disk := &Disk{Name: "sda", Path: "/dev/sda", ByID: []string{"wwn-0x5000c500a1b2c3d4"}, ByPath: []string{"pci-0000:00:1f.2-ata-1"}}
+	for _, c := range []struct {
+		patterns []string
+		expect   bool
+	}{
+		{nil, false},
+		{[]string{"sd*"}, true},
+		{[]string{"nvme*", "/dev/sd[a-c]"}, true},
+		{[]string{"/dev/disk/by-id/wwn-*"}, true},
+		{[]string{"/dev/disk/by-path/*-ata-1"}, true},
+		{[]string{"wwn-*"}, false},
+		{[]string{"/dev/sdb"}, false},
+	} {
+		assert.Equal(t, c.expect, MatchDevice(disk, c.patterns), "%v", c.patterns)
+	}
+	assert.Nil(t, ValidatePatterns([]string{"nvme*"}))
+	assert.NotNil(t, ValidatePatterns([]string{"[nvme"}))
+
+	assert.Equal(t, "", (&Disk{Name: "vdb"}).InUse())
+	assert.Equal(t, "disk has partitions", (&Disk{Partitioned: true, Mounted: true}).InUse())
+	assert.Equal(t, "disk is mounted", (&Disk{Mounted: true}).InUse())
+	assert.Equal(t, "disk is held by [md0]", (&Disk{Holders: []string{"md0"}}).InUse())
+	assert.Equal(t, "disk has xfs signature", (&Disk{FSType: "xfs"}).InUse())
+}
+
+func TestSelect(t *testing.T) {
+	gib := uint64(1024 * 1024 * 1024)
+	disks := []*Disk{
+		{Name: "nvme0n1", Path: "/dev/nvme0n1", Size: 3726 * gib, Model: "INTEL SSDPE2KX040T8", Serial: "PHLJ000000",
+			WWN: "eui.01000000000000005cd2e4a1b2c3d4e5", Transport: TransportNVMe},
+		{Name: "nvme1n1", Path: "/dev/nvme1n1", Size: 3726 * gib, Model: "INTEL SSDPE2KX040T8", Serial: "PHLJ111111", Transport: TransportNVMe},
+		{Name: "sda", Path: "/dev/sda", Size: 931 * gib, Rotational: true, Model: "ST1000NM0033", Serial: "Z1W0",
+			WWN: "0x5000c500a1b2c3d4", Transport: TransportSATA, ByID: []string{"ata-ST1000NM0033_Z1W0", "wwn-0x5000c500a1b2c3d4"}},
+		{Name: "vda", Path: "/dev/vda", Size: 40 * gib, Rotational: true, Transport: TransportVirtio, Partitioned: true},
+		{Name: "vdb", Path: "/dev/vdb", Size: 100 * gib, Rotational: true, Transport: TransportVirtio, ByPath: []string{"pci-0000:00:06.0"}},
+	}
+	rotational, solid := true, false
+	for _, c := range []struct {
+		selector *model.DeviceSelector
+		expect   []string
+	}{
+		{&model.DeviceSelector{Transport: []string{"nvme"}}, []string{"/dev/nvme0n1", "/dev/nvme1n1"}},
+		{&model.DeviceSelector{Transport: []string{"virtio"}}, []string{"/dev/vdb"}},
+		{&model.DeviceSelector{Model: []string{"INTEL*"}, Serial: []string{"PHLJ1*"}}, []string{"/dev/nvme1n1"}},
+		{&model.DeviceSelector{ByID: []string{"/dev/disk/by-id/wwn-0x5000c500a1b2c3d4"}}, []string{"/dev/sda"}},
+		{&model.DeviceSelector{ByPath: []string{"pci-0000:00:06.*"}}, []string{"/dev/vdb"}},
+		{&model.DeviceSelector{WWN: []string{"5000C500A1B2C3D4"}}, []string{"/dev/sda"}},
+		{&model.DeviceSelector{WWN: []string{"0x01000000000000005cd2e4a1b2c3d4e5"}}, []string{"/dev/nvme0n1"}},
+		{&model.DeviceSelector{MinSize: "500Gi", MaxSize: "1Ti"}, []string{"/dev/sda"}},
+		{&model.DeviceSelector{Rotational: &rotational}, []string{"/dev/sda", "/dev/vdb"}},
+		{&model.DeviceSelector{Rotational: &solid, MaxSize: "1Ti"}, []string{}},
+	} {
+		selected, err := Select(disks, c.selector)
+		assert.Nil(t, err)
+		assert.Equal(t, c.expect, selected, "%+v", c.selector)
+	}
+
+	for _, selector := range []*model.DeviceSelector{
+		nil, {}, {Transport: []string{"ide"}}, {MinSize: "big"}, {Model: []string{"[INTEL"}},
+	} {
+		_, err := Select(disks, selector)
+		assert.NotNil(t, err, "%+v", selector)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	root, err := ioutil.TempDir("", "preflight")
+	assert.Nil(t, err)
+	defer os.RemoveAll(root)
+	for file, content := range map[string]string{
+		"class/block/vdb/dev":            "253:16",
+		"class/block/vdc/dev":            "253:32",
+		"class/block/vdc/vdc1/partition": "1",
+		"class/block/vdc1/dev":           "253:33",
+		"class/block/vdd/dev":            "253:48",
+		"class/block/vdd/holders/md0":    "",
+		"class/block/vde/dev":            "253:64",
+		"class/block/vdf/dev":            "253:80",
+		"class/block/vdg/dev":            "253:96",
+	} {
+		assert.Nil(t, os.MkdirAll(filepath.Dir(filepath.Join(root, "sys", file)), 0755))
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(root, "sys", file), []byte(content+"\n"), 0644))
+	}
+	mountInfo := filepath.Join(root, "mountinfo")
+	assert.Nil(t, ioutil.WriteFile(mountInfo, []byte("97 25 253:33 / /mnt/data rw,relatime shared:50 - ext4 /dev/vdc1 rw\n"), 0644))
+	swaps := filepath.Join(root, "swaps")
+	assert.Nil(t, ioutil.WriteFile(swaps, []byte("Filename\tType\tSize\tUsed\tPriority\n/dev/vde\tpartition\t8388604\t0\t-2\n"), 0644))
+
+	executor := utils.NewFakeExecutor()
+	executor.On("wipefs", "--noheadings", "--output", "TYPE", "/dev/vdc").Return("gpt\nPMBR\n")
+	executor.On("wipefs", "--noheadings", "--output", "TYPE", "/dev/vdf").Return("LVM2_member\n")
+	executor.On("wipefs", "--noheadings", "--output", "TYPE", "/dev/vdg").Return("LVM2_member\n")
+	executor.On("wipefs", "--noheadings", "--output", "TYPE", "/dev/vdh").Fail("wipefs: error: /dev/vdh: probing initialization failed", errors.New("exit status 1"))
+	executor.On("wipefs", "--noheadings", "--output", "TYPE", "...")
+	executor.On("pvs", "--noheadings", "-o", "vg_name", "/dev/vdf").Return("  vg-other\n")
+	executor.On("pvs", "--noheadings", "-o", "vg_name", "/dev/vdg").Return("  \n")
+	executor.On("wipefs", "--all", "/dev/vdc")
+	checker := NewNodeChecker(executor, filepath.Join(root, "sys"), mountInfo, swaps)
+
+	for _, c := range []struct {
+		device string
+		expect []Usage
+	}{
+		{"/dev/vdb", []Usage{}},
+		{"/dev/vdc", []Usage{{Message: "vdc1 is mounted", Active: true}, {Message: "gpt signature found"}, {Message: "PMBR signature found"}}},
+		{"/dev/vdd", []Usage{{Message: "vdd is held by [md0]", Active: true}}},
+		{"/dev/vde", []Usage{{Message: "vde is used as swap", Active: true}}},
+		{"/dev/vdf", []Usage{{Message: "physical volume of volume group vg-other"}}},
+		{"/dev/vdg", []Usage{}},
+	} {
+		usages, err := checker.Check(c.device)
+		assert.Nil(t, err)
+		assert.Equal(t, c.expect, usages, c.device)
+	}
+	_, err = checker.Check("/dev/vdh")
+	assert.NotNil(t, err)
+	_, err = checker.Check("/dev/vdb;reboot")
+	assert.NotNil(t, err)
+
+	assert.Nil(t, checker.Wipe("/dev/vdc"))
+	assert.Equal(t, []string{"wipefs", "--all", "/dev/vdc"}, executor.Calls()[len(executor.Calls())-1])
+	plan := utils.NewPlan()
+	assert.Nil(t, NewDryRunChecker(checker, plan).Wipe("/dev/vdd"))
+	assert.Equal(t, []model.Action{{Kind: "volumegroup", Name: "wipefs", Command: "wipefs --all /dev/vdd"}}, plan.Take("volumegroup"))
+}
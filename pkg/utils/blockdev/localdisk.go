@@ -0,0 +1,71 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package blockdev
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+)
+
+// FSTypeLVM is the udev signature of lvm physical volume
+const FSTypeLVM = "LVM2_member"
+
+// InUse returns why disk can not be taken as a new local disk, empty if it is unused
+func (d *Disk) InUse() string {
+	switch {
+	case d.Partitioned:
+		return "disk has partitions"
+	case d.Mounted:
+		return "disk is mounted"
+	case len(d.Holders) > 0:
+		return fmt.Sprintf("disk is held by %v", d.Holders)
+	case d.FSType != "":
+		return fmt.Sprintf("disk has %s signature", d.FSType)
+	}
+	return ""
+}
+
+// MatchDevice returns true if disk matches one of patterns, a pattern is a glob of the kernel name, e.g. nvme*,
+// the device path, e.g. /dev/sd[b-d], or a link in /dev/disk/by-id or /dev/disk/by-path
+func MatchDevice(disk *Disk, patterns []string) bool {
+	names := []string{disk.Name, disk.Path}
+	for _, link := range disk.ByID {
+		names = append(names, filepath.Join("/dev/disk/by-id", link))
+	}
+	for _, link := range disk.ByPath {
+		names = append(names, filepath.Join("/dev/disk/by-path", link))
+	}
+	for _, pattern := range patterns {
+		for _, name := range names {
+			if matched, _ := path.Match(pattern, name); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ValidatePatterns checks the glob patterns of MatchDevice
+func ValidatePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q", pattern)
+		}
+	}
+	return nil
+}
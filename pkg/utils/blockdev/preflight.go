@@ -0,0 +1,167 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package blockdev
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/openyurtio/node-resource-manager/pkg/utils"
+	klog "k8s.io/klog/v2"
+)
+
+// Usage is an existing usage of device found by preflight check, an active usage like mount can not be wiped
+type Usage struct {
+	Message string
+	Active  bool
+}
+
+// Checker checks devices before they are initialized, e.g. by pvcreate, and wipes their signatures
+type Checker interface {
+	// Check returns the usages of device, empty if device is safe to initialize
+	Check(device string) ([]Usage, error)
+	// Wipe removes the filesystem, partition table and lvm signatures of device
+	Wipe(device string) error
+}
+
+// NodeChecker checks signatures by wipefs and pvs, holders in sysfs, mounts in mountInfo and swaps
+type NodeChecker struct {
+	executor  utils.CommandExecutor
+	sysRoot   string
+	mountInfo string
+	swaps     string
+}
+
+// NewChecker returns the checker of node
+func NewChecker(executor utils.CommandExecutor) *NodeChecker {
+	return NewNodeChecker(executor, "/sys", "/proc/1/mountinfo", "/proc/swaps")
+}
+
+// NewNodeChecker ...
+func NewNodeChecker(executor utils.CommandExecutor, sysRoot, mountInfo, swaps string) *NodeChecker {
+	return &NodeChecker{executor: executor, sysRoot: sysRoot, mountInfo: mountInfo, swaps: swaps}
+}
+
+// Check returns the usages of device and its partitions: mounts, swaps, holders like dm and md,
+// signatures of filesystem and partition table, and lvm labels of other volume groups
+func (nc *NodeChecker) Check(device string) ([]Usage, error) {
+	if err := utils.ValidateDevicePath(device); err != nil {
+		return nil, err
+	}
+	name := filepath.Base(device)
+	if resolved, err := filepath.EvalSymlinks(device); err == nil {
+		name = filepath.Base(resolved)
+	}
+	sysPath := filepath.Join(nc.sysRoot, "class", "block", name)
+	names := append([]string{name}, partitions(sysPath, name)...)
+
+	usages := []Usage{}
+	mounted := readMounted(nc.mountInfo)
+	swaps := nc.readSwaps()
+	for _, n := range names {
+		if mounted[readString(filepath.Join(nc.sysRoot, "class", "block", n, "dev"))] {
+			usages = append(usages, Usage{Message: fmt.Sprintf("%s is mounted", n), Active: true})
+		}
+		if swaps[n] {
+			usages = append(usages, Usage{Message: fmt.Sprintf("%s is used as swap", n), Active: true})
+		}
+		if holders := readNames(filepath.Join(nc.sysRoot, "class", "block", n, "holders")); len(holders) > 0 {
+			usages = append(usages, Usage{Message: fmt.Sprintf("%s is held by %v", n, holders), Active: true})
+		}
+	}
+
+	out, err := nc.executor.Run(utils.HostCommand("wipefs", "--noheadings", "--output", "TYPE", device)...)
+	if err != nil {
+		return nil, fmt.Errorf("check signatures of %s error: %v", device, err)
+	}
+	for _, signature := range strings.Fields(out) {
+		if signature != FSTypeLVM {
+			usages = append(usages, Usage{Message: fmt.Sprintf("%s signature found", signature)})
+			continue
+		}
+		// pvs fails if device is not a physical volume, a physical volume in no volume group is free to use
+		vg, err := nc.executor.Run(utils.HostCommand("pvs", "--noheadings", "-o", "vg_name", device)...)
+		if err == nil && strings.TrimSpace(vg) != "" {
+			usages = append(usages, Usage{Message: fmt.Sprintf("physical volume of volume group %s", strings.TrimSpace(vg))})
+		}
+	}
+	return usages, nil
+}
+
+// Wipe ...
+func (nc *NodeChecker) Wipe(device string) error {
+	args, err := wipeArgs(device)
+	if err != nil {
+		return err
+	}
+	out, err := nc.executor.Run(utils.HostCommand(args...)...)
+	if err != nil {
+		klog.Errorf("Wipe:: wipe device %s error: %v", device, err)
+		return err
+	}
+	klog.Infof("Wipe:: wipe device %s with out: %s", device, out)
+	return nil
+}
+
+// readSwaps returns the names of devices used as swap, the first column of /proc/swaps
+func (nc *NodeChecker) readSwaps() map[string]bool {
+	swaps := map[string]bool{}
+	f, err := os.Open(nc.swaps)
+	if err != nil {
+		klog.Errorf("readSwaps:: read %s error: %v", nc.swaps, err)
+		return swaps
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) > 0 && strings.HasPrefix(fields[0], "/dev/") {
+			swaps[filepath.Base(fields[0])] = true
+		}
+	}
+	return swaps
+}
+
+func wipeArgs(device string) ([]string, error) {
+	if err := utils.ValidateDevicePath(device); err != nil {
+		return nil, err
+	}
+	return []string{"wipefs", "--all", device}, nil
+}
+
+// DryRunChecker checks devices on node, records the wipes into plan instead of executing them
+type DryRunChecker struct {
+	Checker
+	plan *utils.Plan
+}
+
+// NewDryRunChecker ...
+func NewDryRunChecker(checker Checker, plan *utils.Plan) *DryRunChecker {
+	return &DryRunChecker{Checker: checker, plan: plan}
+}
+
+// Wipe ...
+func (dc *DryRunChecker) Wipe(device string) error {
+	args, err := wipeArgs(device)
+	if err != nil {
+		return err
+	}
+	dc.plan.Add(args[0], args[1:]...)
+	return nil
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package blockdev
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/openyurtio/node-resource-manager/pkg/model"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Resolver resolves device selectors of one topology, disks are listed once on first use
+type Resolver struct {
+	discoverer Discoverer
+	disks      []*Disk
+}
+
+// NewResolver ...
+func NewResolver(discoverer Discoverer) *Resolver {
+	return &Resolver{discoverer: discoverer}
+}
+
+// Disks returns the disks on node
+func (r *Resolver) Disks() ([]*Disk, error) {
+	if r.disks == nil {
+		disks, err := r.discoverer.ListDisks()
+		if err != nil {
+			return nil, fmt.Errorf("list disks error: %v", err)
+		}
+		r.disks = disks
+	}
+	return r.disks, nil
+}
+
+// Resolve returns devices with the disks matched by selector appended, devices are returned unchanged on error
+func (r *Resolver) Resolve(devices []string, selector *model.DeviceSelector) ([]string, error) {
+	disks, err := r.Disks()
+	if err != nil {
+		return devices, err
+	}
+	selected, err := Select(disks, selector)
+	if err != nil {
+		return devices, err
+	}
+	resolved := append([]string{}, devices...)
+	for _, device := range selected {
+		found := false
+		for _, existing := range devices {
+			found = found || existing == device
+		}
+		if !found {
+			resolved = append(resolved, device)
+		}
+	}
+	return resolved, nil
+}
+
+// Select returns the paths of disks matched by selector, disks with partitions are never selected,
+// e.g. the system disk. Returns error if selector is empty or invalid.
+func Select(disks []*Disk, selector *model.DeviceSelector) ([]string, error) {
+	if err := validateSelector(selector); err != nil {
+		return nil, err
+	}
+	minSize, err := parseSize(selector.MinSize)
+	if err != nil {
+		return nil, fmt.Errorf("invalid minSize %q", selector.MinSize)
+	}
+	maxSize, err := parseSize(selector.MaxSize)
+	if err != nil {
+		return nil, fmt.Errorf("invalid maxSize %q", selector.MaxSize)
+	}
+
+	selected := []string{}
+	for _, disk := range disks {
+		switch {
+		case disk.Partitioned:
+		case !matchLinks(selector.ByID, disk.ByID, "/dev/disk/by-id/"):
+		case !matchLinks(selector.ByPath, disk.ByPath, "/dev/disk/by-path/"):
+		case !matchAny(selector.Serial, disk.Serial):
+		case !matchAny(normalizeWWNs(selector.WWN), normalizeWWN(disk.WWN)):
+		case !matchAny(selector.Model, disk.Model):
+		case minSize > 0 && disk.Size < minSize:
+		case maxSize > 0 && disk.Size > maxSize:
+		case selector.Rotational != nil && *selector.Rotational != disk.Rotational:
+		case !matchAny(selector.Transport, disk.Transport):
+		default:
+			selected = append(selected, disk.Path)
+		}
+	}
+	return selected, nil
+}
+
+// validateSelector rejects the selector matching every disk, and unknown transports
+func validateSelector(selector *model.DeviceSelector) error {
+	if selector == nil {
+		return errors.New("device selector is empty")
+	}
+	if len(selector.ByID) == 0 && len(selector.ByPath) == 0 && len(selector.Serial) == 0 && len(selector.WWN) == 0 &&
+		len(selector.Model) == 0 && selector.MinSize == "" && selector.MaxSize == "" && selector.Rotational == nil && len(selector.Transport) == 0 {
+		return errors.New("device selector is empty")
+	}
+	for _, t := range selector.Transport {
+		switch t {
+		case TransportNVMe, TransportSATA, TransportSCSI, TransportVirtio, TransportUSB:
+		default:
+			return fmt.Errorf("unsupported transport %q", t)
+		}
+	}
+	for _, patterns := range [][]string{selector.ByID, selector.ByPath, selector.Serial, selector.WWN, selector.Model} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid pattern %q", pattern)
+			}
+		}
+	}
+	return nil
+}
+
+// matchAny returns true if patterns is empty, or value matches one of the patterns
+func matchAny(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	if value == "" {
+		return false
+	}
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, value); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// matchLinks returns true if patterns is empty, or one of the links matches one of the patterns, dir prefix of pattern is ignored
+func matchLinks(patterns, links []string, dir string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, link := range links {
+		for _, pattern := range patterns {
+			if matched, _ := path.Match(strings.TrimPrefix(pattern, dir), link); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// normalizeWWN lowers wwn and strips the 0x prefix of udev, so naa.5000c500a1b2c3d4 and 0x5000c500a1b2c3d4 both match 5000c500a1b2c3d4*
+func normalizeWWN(wwn string) string {
+	wwn = strings.ToLower(wwn)
+	for _, prefix := range []string{"0x", "naa.", "eui.", "t10."} {
+		wwn = strings.TrimPrefix(wwn, prefix)
+	}
+	return wwn
+}
+
+func normalizeWWNs(wwns []string) []string {
+	normalized := []string{}
+	for _, wwn := range wwns {
+		normalized = append(normalized, normalizeWWN(wwn))
+	}
+	return normalized
+}
+
+// parseSize parses quantity to bytes, empty is 0
+func parseSize(size string) (uint64, error) {
+	if size == "" {
+		return 0, nil
+	}
+	quantity, err := resource.ParseQuantity(size)
+	if err != nil {
+		return 0, err
+	}
+	if quantity.Sign() < 0 {
+		return 0, fmt.Errorf("negative size %q", size)
+	}
+	return uint64(quantity.Value()), nil
+}
@@ -0,0 +1,459 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/openyurtio/node-resource-manager/pkg/model"
+	klog "k8s.io/klog/v2"
+)
+
+// Plan collects actions planned by dry-run tools in order
+type Plan struct {
+	lock    sync.Mutex
+	actions []model.Action
+}
+
+// NewPlan ...
+func NewPlan() *Plan {
+	return &Plan{actions: []model.Action{}}
+}
+
+// Add append an action, command is printed as args joined by space
+func (p *Plan) Add(name string, args ...string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	command := strings.Join(append([]string{name}, args...), " ")
+	klog.Infof("Plan:: dry-run action: %s", command)
+	p.actions = append(p.actions, model.Action{Name: name, Command: command})
+}
+
+// Take returns the actions planned since last Take, tagged with kind
+func (p *Plan) Take(kind string) []model.Action {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	actions := p.actions
+	p.actions = []model.Action{}
+	for i := range actions {
+		actions[i].Kind = kind
+	}
+	return actions
+}
+
+// DryRunLVM reads volume groups from node, records the changes into plan instead of executing them
+type DryRunLVM struct {
+	LVM
+	plan *Plan
+}
+
+// NewDryRunLVM ...
+func NewDryRunLVM(lvm LVM, plan *Plan) *DryRunLVM {
+	return &DryRunLVM{LVM: lvm, plan: plan}
+}
+
+// CreateLV ...
+func (dl *DryRunLVM) CreateLV(vg, name string, size uint64, mirrors uint32, tags []string) (string, error) {
+	return dl.add(createLVArgs(vg, name, size, mirrors, tags))
+}
+
+// RemoveLV ...
+func (dl *DryRunLVM) RemoveLV(vg, name string) (string, error) {
+	return dl.add(removeLVArgs(vg, name))
+}
+
+// CloneLV ...
+func (dl *DryRunLVM) CloneLV(src, dest string) (string, error) {
+	return dl.add(cloneLVArgs(src, dest))
+}
+
+// CreateVG ...
+func (dl *DryRunLVM) CreateVG(name string, physicalVolumes []string, tags []string) (string, error) {
+	return dl.add(createVGArgs(name, physicalVolumes, tags))
+}
+
+// ExtendVG ...
+func (dl *DryRunLVM) ExtendVG(name string, physicalVolumes []string) (string, error) {
+	return dl.add(extendVGArgs(name, physicalVolumes))
+}
+
+// RemoveVG ...
+func (dl *DryRunLVM) RemoveVG(name string) (string, error) {
+	return dl.add(removeVGArgs(name))
+}
+
+// AddTagVG ...
+func (dl *DryRunLVM) AddTagVG(name string, tags []string) (string, error) {
+	return dl.add(changeTagVGArgs("--addtag", name, tags))
+}
+
+// RemoveTagVG ...
+func (dl *DryRunLVM) RemoveTagVG(name string, tags []string) (string, error) {
+	return dl.add(changeTagVGArgs("--deltag", name, tags))
+}
+
+// AddTagPV ...
+func (dl *DryRunLVM) AddTagPV(physicalVolumes []string, tags []string) (string, error) {
+	return dl.add(addTagPVArgs(physicalVolumes, tags))
+}
+
+// MovePV ...
+func (dl *DryRunLVM) MovePV(source string, destinations []string) (string, error) {
+	return dl.add(movePVArgs(source, destinations))
+}
+
+// ReduceVG ...
+func (dl *DryRunLVM) ReduceVG(name string, physicalVolumes []string) (string, error) {
+	return dl.add(reduceVGArgs(name, physicalVolumes))
+}
+
+// RemovePV ...
+func (dl *DryRunLVM) RemovePV(physicalVolumes []string) (string, error) {
+	return dl.add(removePVArgs(physicalVolumes))
+}
+
+// AddTagLV ...
+func (dl *DryRunLVM) AddTagLV(vg, name string, tags []string) (string, error) {
+	return dl.add(changeTagLVArgs("--addtag", vg, name, tags))
+}
+
+// RemoveTagLV ...
+func (dl *DryRunLVM) RemoveTagLV(vg, name string, tags []string) (string, error) {
+	return dl.add(changeTagLVArgs("--deltag", vg, name, tags))
+}
+
+// ExtendLV ...
+func (dl *DryRunLVM) ExtendLV(vg, name string, size uint64) (string, error) {
+	return dl.add(extendLVArgs(vg, name, size))
+}
+
+// CreateThinPool ...
+func (dl *DryRunLVM) CreateThinPool(vg, name string, size uint64, percentVG uint32, chunkSize, metadataSize uint64, tags []string) (string, error) {
+	return dl.add(createThinPoolArgs(vg, name, size, percentVG, chunkSize, metadataSize, tags))
+}
+
+// ExtendThinPool ...
+func (dl *DryRunLVM) ExtendThinPool(vg, name string, size uint64) (string, error) {
+	return dl.add(extendLVArgs(vg, name, size))
+}
+
+// CreateThinLV ...
+func (dl *DryRunLVM) CreateThinLV(vg, pool, name string, virtualSize uint64, tags []string) (string, error) {
+	return dl.add(createThinLVArgs(vg, pool, name, virtualSize, tags))
+}
+
+// ExtendThinLV ...
+func (dl *DryRunLVM) ExtendThinLV(vg, name string, virtualSize uint64) (string, error) {
+	return dl.add(extendLVArgs(vg, name, virtualSize))
+}
+
+// add records the validated args into plan
+func (dl *DryRunLVM) add(args []string, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	dl.plan.Add(args[0], args[1:]...)
+	return "", nil
+}
+
+// DryRunPmemer reads pmem regions from node, records the changes into plan instead of executing them.
+// Namespaces planned to create are shown with placeholder device names, e.g. pmem0 and dax0.0 for region0.
+type DryRunPmemer struct {
+	Pmemer
+	plan *Plan
+	// planned is the mode of namespace planned to create for each region
+	planned map[string]string
+}
+
+// NewDryRunPmemer ...
+func NewDryRunPmemer(pmemer Pmemer, plan *Plan) *DryRunPmemer {
+	return &DryRunPmemer{Pmemer: pmemer, plan: plan, planned: map[string]string{}}
+}
+
+// GetRegions add placeholder namespaces planned to create to the regions on node
+func (dp *DryRunPmemer) GetRegions() (*model.PmemRegions, error) {
+	regions, err := dp.Pmemer.GetRegions()
+	if err != nil {
+		return regions, err
+	}
+	for i, region := range regions.Regions {
+		if pmemType, ok := dp.planned[region.Dev]; ok && len(region.Namespaces) == 0 {
+			regions.Regions[i].Namespaces = []model.PmemNameSpace{plannedNamespace(region.Dev, pmemType)}
+		}
+	}
+	return regions, nil
+}
+
+// CreateNamespace ...
+func (dp *DryRunPmemer) CreateNamespace(region, pmemType string) error {
+	args, err := createNamespaceArgs(region, pmemType)
+	if err != nil {
+		return err
+	}
+	dp.plan.Add(args[0], args[1:]...)
+	dp.planned[region] = pmemType
+	return nil
+}
+
+// CheckNamespaceUsed a planned namespace is not used
+func (dp *DryRunPmemer) CheckNamespaceUsed(devicePath string) bool {
+	for region, pmemType := range dp.planned {
+		namespace := plannedNamespace(region, pmemType)
+		if devicePath == "/dev/"+namespace.BlockDev || devicePath == "/dev/"+namespace.CharDev {
+			return false
+		}
+	}
+	return dp.Pmemer.CheckNamespaceUsed(devicePath)
+}
+
+// GetPmemNamespaceDeivcePath ...
+func (dp *DryRunPmemer) GetPmemNamespaceDeivcePath(region, mode string) (string, string, error) {
+	if pmemType, ok := dp.planned[region]; ok {
+		namespace := plannedNamespace(region, pmemType)
+		if namespace.Mode != mode {
+			return "", "", fmt.Errorf("GetPmemNamespaceDeivcePath pmem namespace wrong mode %s", namespace.Mode)
+		}
+		if mode == "fsdax" {
+			return "/dev/" + namespace.BlockDev, namespace.Dev, nil
+		}
+		return "/dev/" + namespace.CharDev, namespace.Dev, nil
+	}
+	return dp.Pmemer.GetPmemNamespaceDeivcePath(region, mode)
+}
+
+// MakeNamespaceMemory ...
+func (dp *DryRunPmemer) MakeNamespaceMemory(chardev string) error {
+	args, err := makeNamespaceMemoryArgs(chardev)
+	if err != nil {
+		return err
+	}
+	dp.plan.Add(args[0], args[1:]...)
+	return nil
+}
+
+// plannedNamespace returns the placeholder of namespace planned to create in region
+func plannedNamespace(region, pmemType string) model.PmemNameSpace {
+	regionIndex := strings.TrimPrefix(region, "region")
+	namespace := model.PmemNameSpace{Dev: ConvertRegion2Namespace(region)}
+	if pmemType == "lvm" {
+		namespace.Mode = "fsdax"
+		namespace.BlockDev = fmt.Sprintf("pmem%s", regionIndex)
+	} else {
+		namespace.Mode = "devdax"
+		namespace.CharDev = fmt.Sprintf("dax%s.0", regionIndex)
+	}
+	return namespace
+}
+
+// DryRunMounter reads mounts from node, records the changes into plan instead of executing them
+type DryRunMounter struct {
+	Mounter
+	executor CommandExecutor
+	plan     *Plan
+}
+
+// NewDryRunMounter ...
+func NewDryRunMounter(mounter Mounter, executor CommandExecutor, plan *Plan) *DryRunMounter {
+	return &DryRunMounter{Mounter: mounter, executor: executor, plan: plan}
+}
+
+// EnsureFolder ...
+func (dm *DryRunMounter) EnsureFolder(target string) error {
+	if err := ValidateMountPath(target); err != nil {
+		return err
+	}
+	dm.plan.Add("mkdir", "-p", target)
+	return nil
+}
+
+// FormatAndMount plans mkfs only if source is not formatted
+func (dm *DryRunMounter) FormatAndMount(source, target, fstype string, mkfsOptions []string, mountOptions string) error {
+	mountCmd, err := mountArgs(source, target, mountOptions)
+	if err != nil {
+		return err
+	}
+	mkfsCmd, err := mkfsArgs(source, fstype, mkfsOptions)
+	if err != nil {
+		return err
+	}
+	existingFormat, err := checkFSType(dm.executor, source)
+	if err != nil && dm.FileExists(source) {
+		return fmt.Errorf("FormatAndMount:: get format of %s error: %v", source, err)
+	}
+	if len(fstype) == 0 {
+		fstype = "ext4"
+	}
+	if existingFormat == "" {
+		dm.plan.Add(mkfsCmd[0], mkfsCmd[1:]...)
+	} else if existingFormat != fstype {
+		return fmt.Errorf("FormatAndMount:: %s is formatted as %s, expect %s", source, existingFormat, fstype)
+	}
+	dm.plan.Add(mountCmd[0], mountCmd[1:]...)
+	return nil
+}
+
+// GrowFS plans growing the filesystem if device is mounted
+func (dm *DryRunMounter) GrowFS(devicePath string) (string, error) {
+	target, growCmd, err := growFSArgs(dm.executor, devicePath)
+	if err != nil || target == "" {
+		return "", err
+	}
+	dm.plan.Add(growCmd[0], growCmd[1:]...)
+	return target, nil
+}
+
+// SafePathRemove ...
+func (dm *DryRunMounter) SafePathRemove(target string) error {
+	dm.plan.Add("rm", target)
+	return nil
+}
+
+// DryRunQuotaer reads projects and quotas from node, records the changes into plan instead of executing them
+type DryRunQuotaer struct {
+	Quotaer
+	plan *Plan
+}
+
+// NewDryRunQuotaer ...
+func NewDryRunQuotaer(quotaer Quotaer, plan *Plan) *DryRunQuotaer {
+	return &DryRunQuotaer{Quotaer: quotaer, plan: plan}
+}
+
+// AddProject plans appending the lines to host projects and projid files
+func (dq *DryRunQuotaer) AddProject(project model.Project) error {
+	projectsLine, projidLine, err := projectLines(project)
+	if err != nil {
+		return err
+	}
+	dq.plan.Add("echo", projectsLine, ">>", "/etc/projects")
+	dq.plan.Add("echo", projidLine, ">>", "/etc/projid")
+	return nil
+}
+
+// SetProject ...
+func (dq *DryRunQuotaer) SetProject(fstype, mountPath string, project model.Project) error {
+	args, err := setProjectArgs(fstype, mountPath, project)
+	if err != nil {
+		return err
+	}
+	dq.plan.Add(args[0], args[1:]...)
+	return nil
+}
+
+// SetProjectLimits ...
+func (dq *DryRunQuotaer) SetProjectLimits(fstype, mountPath string, quota model.ProjectQuota) error {
+	args, err := setProjectLimitsArgs(fstype, mountPath, quota)
+	if err != nil {
+		return err
+	}
+	dq.plan.Add(args[0], args[1:]...)
+	return nil
+}
+
+// ListProjectQuotas a quota path planned to mount is not mounted, so it has no project quota
+func (dq *DryRunQuotaer) ListProjectQuotas(fstype, mountPath string) ([]model.ProjectQuota, error) {
+	quotas, err := dq.Quotaer.ListProjectQuotas(fstype, mountPath)
+	if err != nil {
+		klog.Infof("ListProjectQuotas:: no project quota of %s in dry-run: %v", mountPath, err)
+		return []model.ProjectQuota{}, nil
+	}
+	return quotas, nil
+}
+
+// DryRunRaider reads md arrays from node, records the changes into plan instead of executing them
+type DryRunRaider struct {
+	Raider
+	plan *Plan
+}
+
+// NewDryRunRaider ...
+func NewDryRunRaider(raider Raider, plan *Plan) *DryRunRaider {
+	return &DryRunRaider{Raider: raider, plan: plan}
+}
+
+// CreateArray ...
+func (dr *DryRunRaider) CreateArray(name, level string, devices []string) (string, error) {
+	args, err := createArrayArgs(name, level, devices)
+	if err != nil {
+		return "", err
+	}
+	dr.plan.Add(args[0], args[1:]...)
+	return "", nil
+}
+
+// DryRunMountPersister reads persisted mounts from node, records the changes into plan instead of executing them
+type DryRunMountPersister struct {
+	MountPersister
+	plan *Plan
+}
+
+// NewDryRunMountPersister ...
+func NewDryRunMountPersister(persister MountPersister, plan *Plan) *DryRunMountPersister {
+	return &DryRunMountPersister{MountPersister: persister, plan: plan}
+}
+
+// PersistMount plans writing the fstab entry or mount unit if not persisted already
+func (dp *DryRunMountPersister) PersistMount(mount model.PersistedMount) error {
+	mounts, err := dp.MountPersister.ListPersistedMounts()
+	if err != nil {
+		return err
+	}
+	for _, existing := range mounts {
+		if samePersistedMount(existing, mount) {
+			return nil
+		}
+	}
+	switch mount.Persistence {
+	case PersistenceFstab:
+		entry, err := fstabEntry(mount)
+		if err != nil {
+			return err
+		}
+		dp.plan.Add("echo", entry, ">>", "/etc/fstab")
+	case PersistenceSystemd:
+		if _, err := mountUnitContent(mount); err != nil {
+			return err
+		}
+		unit := MountUnitName(mount.Path)
+		dp.plan.Add("tee", filepath.Join("/etc", systemdUnitDir, unit))
+		dp.plan.Add("systemctl", "enable", unit)
+	default:
+		return fmt.Errorf("invalid persistence %q of %s", mount.Persistence, mount.Path)
+	}
+	return nil
+}
+
+// RemovePersistedMount ...
+func (dp *DryRunMountPersister) RemovePersistedMount(mount model.PersistedMount) error {
+	if err := ValidateMountPath(mount.Path); err != nil {
+		return err
+	}
+	switch mount.Persistence {
+	case PersistenceFstab:
+		dp.plan.Add("sed", "-i", fmt.Sprintf(`\| %s |d`, mount.Path), "/etc/fstab")
+	case PersistenceSystemd:
+		unit := MountUnitName(mount.Path)
+		dp.plan.Add("systemctl", "disable", unit)
+		dp.plan.Add("rm", filepath.Join("/etc", systemdUnitDir, unit))
+	default:
+		return fmt.Errorf("invalid persistence %q of %s", mount.Persistence, mount.Path)
+	}
+	return nil
+}
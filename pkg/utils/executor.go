@@ -0,0 +1,170 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/openyurtio/node-resource-manager/pkg/metrics"
+	klog "k8s.io/klog/v2"
+)
+
+const (
+	// DefaultCommandTimeout is the timeout of one command
+	DefaultCommandTimeout = 2 * time.Minute
+	// FormatCommandTimeout is the timeout of commands scanning the whole device, e.g. mkfs and fsck
+	FormatCommandTimeout = 30 * time.Minute
+	// killWaitTimeout is the time waiting a killed command to exit, a command blocked in uninterruptible
+	// io can not be killed, it is left behind so the caller is not blocked
+	killWaitTimeout = 5 * time.Second
+)
+
+// CommandExecutor runs external commands by argv, NodeLVM, NodePmemer and NodeMounter run every command through it
+type CommandExecutor interface {
+	// Run runs args with default timeout, returns stdout, stderr is included in error
+	Run(args ...string) (string, error)
+	// RunWithTimeout runs args with timeout, returns stdout, stderr is included in error
+	RunWithTimeout(timeout time.Duration, args ...string) (string, error)
+	// Command runs binary with args and default timeout, returns stdout and stderr
+	Command(name string, args ...string) (string, string, error)
+	// CommandWithTimeout runs binary with args and timeout, returns stdout and stderr
+	CommandWithTimeout(timeout time.Duration, name string, args ...string) (string, string, error)
+}
+
+// Executor runs external commands, a command is killed with its children when it times out or ctx is done
+type Executor struct {
+	ctx     context.Context
+	timeout time.Duration
+	lock    sync.Mutex
+	// running is the deadline of every running command by id
+	running map[int]time.Time
+	nextID  int
+}
+
+// NewExecutor create executor, commands are canceled when ctx is done
+func NewExecutor(ctx context.Context, timeout time.Duration) *Executor {
+	if timeout <= 0 {
+		timeout = DefaultCommandTimeout
+	}
+	return &Executor{ctx: ctx, timeout: timeout, running: map[int]time.Time{}}
+}
+
+// RunningDeadline returns the latest deadline of running commands, false if no command is running.
+// A command returns by its deadline, killed if it times out, so one running past it is hung.
+func (e *Executor) RunningDeadline() (time.Time, bool) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	var latest time.Time
+	for _, deadline := range e.running {
+		if deadline.After(latest) {
+			latest = deadline
+		}
+	}
+	return latest, len(e.running) != 0
+}
+
+// track records a command running with timeout until the returned func is called
+func (e *Executor) track(timeout time.Duration) func() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	id := e.nextID
+	e.nextID++
+	e.running[id] = time.Now().Add(timeout + killWaitTimeout)
+	return func() {
+		e.lock.Lock()
+		defer e.lock.Unlock()
+		delete(e.running, id)
+	}
+}
+
+// Run runs args[0] with args[1:] as arguments and default timeout, returns stdout
+func (e *Executor) Run(args ...string) (string, error) {
+	return e.RunWithTimeout(e.timeout, args...)
+}
+
+// RunWithTimeout runs args[0] with args[1:] as arguments and timeout, returns stdout.
+// No shell is involved, so the arguments are never interpreted.
+func (e *Executor) RunWithTimeout(timeout time.Duration, args ...string) (string, error) {
+	if len(args) == 0 {
+		return "", errors.New("no command to run")
+	}
+	stdout, stderr, err := e.execute(timeout, commandBinary(args), args[0], args[1:]...)
+	if err != nil {
+		return "", fmt.Errorf("Failed to run cmd: %s, with out: %s, with error: %v", strings.Join(args, " "), stdout+stderr, err)
+	}
+	return stdout, nil
+}
+
+// Command runs binary with args without shell and default timeout, returns stdout and stderr
+func (e *Executor) Command(name string, args ...string) (string, string, error) {
+	return e.CommandWithTimeout(e.timeout, name, args...)
+}
+
+// CommandWithTimeout runs binary with args without shell and timeout, returns stdout and stderr
+func (e *Executor) CommandWithTimeout(timeout time.Duration, name string, args ...string) (string, string, error) {
+	return e.execute(timeout, filepath.Base(name), name, args...)
+}
+
+// execute runs command in a new process group, so the whole group is killed on timeout or cancel
+func (e *Executor) execute(timeout time.Duration, binary, name string, args ...string) (string, string, error) {
+	ctx, cancel := context.WithTimeout(e.ctx, timeout)
+	defer cancel()
+	defer e.track(timeout)()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		metrics.RecordCommand(binary, exitCode(err))
+		return "", "", err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		metrics.RecordCommand(binary, exitCode(err))
+		return stdout.String(), stderr.String(), err
+	case <-ctx.Done():
+		// negative pid kills the process group
+		if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != nil {
+			klog.Errorf("execute:: kill process group of %s error: %v", binary, err)
+		}
+		metrics.RecordCommand(binary, -1)
+		select {
+		case <-done:
+			return stdout.String(), stderr.String(), fmt.Errorf("%s killed: %v", binary, ctx.Err())
+		case <-time.After(killWaitTimeout):
+			klog.Errorf("execute:: %s not exit after killed, leave it behind", binary)
+			return "", "", fmt.Errorf("%s killed but not exit: %v", binary, ctx.Err())
+		}
+	}
+}
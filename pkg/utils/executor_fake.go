@@ -0,0 +1,142 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakeExecutor is a scriptable CommandExecutor for tests, it runs nothing and returns the canned output
+// of the first command matching argv, the nsenter prefix is skipped before matching and recording.
+type FakeExecutor struct {
+	lock     sync.Mutex
+	commands []*FakeCommand
+	calls    [][]string
+}
+
+// FakeCommand is the canned result of commands matching pattern.
+// Every element of pattern matches one arg by path.Match, e.g. "*", "/dev/vd?", and a trailing "..." matches any remaining args.
+type FakeCommand struct {
+	pattern []string
+	stdout  string
+	stderr  string
+	err     error
+	once    bool
+	used    bool
+}
+
+// NewFakeExecutor ...
+func NewFakeExecutor() *FakeExecutor {
+	return &FakeExecutor{commands: []*FakeCommand{}, calls: [][]string{}}
+}
+
+// On adds a command matching pattern, which returns empty output by default
+func (f *FakeExecutor) On(pattern ...string) *FakeCommand {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	command := &FakeCommand{pattern: pattern}
+	f.commands = append(f.commands, command)
+	return command
+}
+
+// Calls returns argv of all commands run, without nsenter prefix
+func (f *FakeExecutor) Calls() [][]string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([][]string{}, f.calls...)
+}
+
+// Return sets stdout of command
+func (c *FakeCommand) Return(stdout string) *FakeCommand {
+	c.stdout = stdout
+	return c
+}
+
+// Fail makes command fail with stderr and err
+func (c *FakeCommand) Fail(stderr string, err error) *FakeCommand {
+	c.stderr = stderr
+	c.err = err
+	return c
+}
+
+// Once makes command match only once, so the following commands can return different output
+func (c *FakeCommand) Once() *FakeCommand {
+	c.once = true
+	return c
+}
+
+// Run ...
+func (f *FakeExecutor) Run(args ...string) (string, error) {
+	return f.RunWithTimeout(0, args...)
+}
+
+// RunWithTimeout ...
+func (f *FakeExecutor) RunWithTimeout(timeout time.Duration, args ...string) (string, error) {
+	stdout, stderr, err := f.execute(args)
+	if err != nil {
+		return "", fmt.Errorf("Failed to run cmd: %s, with out: %s, with error: %v", strings.Join(args, " "), stdout+stderr, err)
+	}
+	return stdout, nil
+}
+
+// Command ...
+func (f *FakeExecutor) Command(name string, args ...string) (string, string, error) {
+	return f.CommandWithTimeout(0, name, args...)
+}
+
+// CommandWithTimeout ...
+func (f *FakeExecutor) CommandWithTimeout(timeout time.Duration, name string, args ...string) (string, string, error) {
+	return f.execute(append([]string{name}, args...))
+}
+
+// execute records args and returns the output of first matched command
+func (f *FakeExecutor) execute(args []string) (string, string, error) {
+	if len(args) > len(NsenterArgs) && args[0] == NsenterArgs[0] {
+		args = args[len(NsenterArgs):]
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.calls = append(f.calls, args)
+	for _, command := range f.commands {
+		if command.used || !matchArgs(command.pattern, args) {
+			continue
+		}
+		command.used = command.once
+		return command.stdout, command.stderr, command.err
+	}
+	return "", "", fmt.Errorf("FakeExecutor:: unexpected command: %s", strings.Join(args, " "))
+}
+
+// matchArgs returns true if every arg matches pattern
+func matchArgs(pattern, args []string) bool {
+	for i, p := range pattern {
+		if p == "..." && i == len(pattern)-1 {
+			return true
+		}
+		if i >= len(args) {
+			return false
+		}
+		if matched, err := path.Match(p, args[i]); err != nil || !matched {
+			return false
+		}
+	}
+	return len(pattern) == len(args)
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunningDeadline(t *testing.T) {
+	executor := NewExecutor(context.Background(), time.Minute)
+	_, running := executor.RunningDeadline()
+	assert.False(t, running)
+
+	// the latest deadline of running commands, including the wait for a killed one
+	start := time.Now()
+	doneShort := executor.track(time.Minute)
+	doneLong := executor.track(30 * time.Minute)
+	deadline, running := executor.RunningDeadline()
+	assert.True(t, running)
+	assert.WithinDuration(t, start.Add(30*time.Minute+killWaitTimeout), deadline, time.Second)
+
+	doneLong()
+	deadline, running = executor.RunningDeadline()
+	assert.True(t, running)
+	assert.WithinDuration(t, start.Add(time.Minute+killWaitTimeout), deadline, time.Second)
+
+	doneShort()
+	_, running = executor.RunningDeadline()
+	assert.False(t, running)
+}
+
+func TestRunningDeadlineCommand(t *testing.T) {
+	executor := NewExecutor(context.Background(), time.Minute)
+	done := make(chan error)
+	go func() {
+		_, _, err := executor.CommandWithTimeout(10*time.Minute, "sleep", "1")
+		done <- err
+	}()
+
+	// the command is tracked with its own timeout while running
+	assert.Eventually(t, func() bool {
+		_, running := executor.RunningDeadline()
+		return running
+	}, 5*time.Second, 10*time.Millisecond)
+	deadline, _ := executor.RunningDeadline()
+	assert.True(t, deadline.After(time.Now().Add(9*time.Minute)))
+
+	assert.Nil(t, <-done)
+	_, running := executor.RunningDeadline()
+	assert.False(t, running)
+}
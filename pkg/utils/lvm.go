@@ -26,6 +26,16 @@ import (
 
 const (
 	ProtectedTagName = "protected"
+	// ManagedTagName marks the volume groups and physical volumes managed by node-resource-manager,
+	// OwnerTagPrefix and SpecHashTagPrefix are followed by the topology entry name and the hash of its spec
+	ManagedTagName    = "nrm.openyurt.io/managed"
+	OwnerTagPrefix    = "nrm.openyurt.io/name="
+	SpecHashTagPrefix = "nrm.openyurt.io/spec-hash="
+	// DefaultExtentSize is the default physical extent size of volume group, used if the extent size of volume group is unknown
+	DefaultExtentSize = 4 * 1024 * 1024
+	// minThinPoolChunkSize and maxThinPoolChunkSize are the limits of thin pool chunk size, which must be a multiple of min
+	minThinPoolChunkSize = 64 * 1024
+	maxThinPoolChunkSize = 1024 * 1024 * 1024
 )
 
 // LVM ...
@@ -36,28 +46,71 @@ type LVM interface {
 	CloneLV(src, dest string) (string, error)
 	ListVG() ([]*model.VG, error)
 	ListPhysicalVolume() ([]*model.PV, error)
-	CreateVG(name, physicalVolume string, tags []string) (string, error)
-	ExtendVG(name, physicalVolume string) (string, error)
+	// ListOrphanPhysicalVolume returns the physical volumes not in any volume group, e.g. left by a failed pvremove
+	ListOrphanPhysicalVolume() ([]*model.PV, error)
+	CreateVG(name string, physicalVolumes []string, tags []string) (string, error)
+	ExtendVG(name string, physicalVolumes []string) (string, error)
 	RemoveVG(name string) (string, error)
 	AddTagLV(vg, name string, tags []string) (string, error)
 	RemoveTagLV(vg, name string, tags []string) (string, error)
+	// AddTagVG adds tags to volume group
+	AddTagVG(name string, tags []string) (string, error)
+	// RemoveTagVG removes tags from volume group
+	RemoveTagVG(name string, tags []string) (string, error)
+	// AddTagPV adds tags to physical volumes
+	AddTagPV(physicalVolumes []string, tags []string) (string, error)
+	// MovePV starts moving the allocated extents of source to destinations in background,
+	// the progress is the copy percent of the hidden pvmove logical volume
+	MovePV(source string, destinations []string) (string, error)
+	// ReduceVG removes empty physical volumes from volume group
+	ReduceVG(name string, physicalVolumes []string) (string, error)
+	// RemovePV wipes the lvm label of physical volumes not in any volume group
+	RemovePV(physicalVolumes []string) (string, error)
+	// ExtendLV extends logical volume to size bytes, lvm refuses to shrink it
+	ExtendLV(vg, name string, size uint64) (string, error)
+	// CreateThinPool creates thin pool of size bytes, or percentVG of volume group if percentVG is not 0,
+	// chunkSize and metadataSize are chosen by lvm if 0
+	CreateThinPool(vg, name string, size uint64, percentVG uint32, chunkSize, metadataSize uint64, tags []string) (string, error)
+	// ExtendThinPool extends data of thin pool to size bytes
+	ExtendThinPool(vg, name string, size uint64) (string, error)
+	// CreateThinLV creates thin logical volume of virtualSize bytes in thin pool
+	CreateThinLV(vg, pool, name string, virtualSize uint64, tags []string) (string, error)
+	// ExtendThinLV extends thin logical volume to virtualSize bytes
+	ExtendThinLV(vg, name string, virtualSize uint64) (string, error)
+}
+
+// ExtentSize returns the physical extent size of volume group, DefaultExtentSize if unknown
+func ExtentSize(vg *model.VG) uint64 {
+	if vg.ExtentSize == 0 {
+		return DefaultExtentSize
+	}
+	return vg.ExtentSize
+}
+
+// RoundUpToExtent rounds size up to the physical extent size of volume group, as lvm does on create and extend
+func RoundUpToExtent(size uint64, vg *model.VG) uint64 {
+	extentSize := ExtentSize(vg)
+	return (size + extentSize - 1) / extentSize * extentSize
 }
 
 // NodeLVM ...
 type NodeLVM struct {
+	executor CommandExecutor
 }
 
 // NewNodeLVM ...
-func NewNodeLVM() *NodeLVM {
-	return &NodeLVM{}
+func NewNodeLVM(executor CommandExecutor) *NodeLVM {
+	return &NodeLVM{executor: executor}
 }
 
 // ListLV ...
 func (nl *NodeLVM) ListLV(listSpec string) ([]*model.LV, error) {
-	cmdList := []string{NsenterCmd, "lvs", "--units=b", "--separator=\"<:SEP:>\"", "--nosuffix", "--noheadings",
-		"-o", "lv_name,lv_size,lv_uuid,lv_attr,copy_percent,lv_kernel_major,lv_kernel_minor,lv_tags", "--nameprefixes", "-a", listSpec}
-	cmd := strings.Join(cmdList, " ")
-	out, err := Run(cmd)
+	if err := validateListSpec(listSpec); err != nil {
+		return nil, err
+	}
+	args := HostCommand("lvs", "--units=b", "--separator=<:SEP:>", "--nosuffix", "--noheadings",
+		"-o", "lv_name,lv_size,lv_uuid,lv_attr,copy_percent,lv_kernel_major,lv_kernel_minor,lv_tags", "--nameprefixes", "-a", listSpec)
+	out, err := nl.executor.Run(args...)
 	if err != nil {
 		return nil, err
 	}
@@ -84,26 +137,19 @@ func (nl *NodeLVM) ListLV(listSpec string) ([]*model.LV, error) {
 
 // CreateLV ...
 func (nl *NodeLVM) CreateLV(vg, name string, size uint64, mirrors uint32, tags []string) (string, error) {
-	if size == 0 {
-		return "", errors.New("size must be greater than 0")
-	}
-
-	args := []string{"lvcreate", "-v", "-n", name, "-L", fmt.Sprintf("%db", size)}
-	if mirrors > 0 {
-		args = append(args, "-m", fmt.Sprintf("%d", mirrors), "--nosync")
-	}
-	for _, tag := range tags {
-		args = append(args, "--add-tag", tag)
+	args, err := createLVArgs(vg, name, size, mirrors, tags)
+	if err != nil {
+		return "", err
 	}
-
-	args = append(args, vg)
-	cmd := strings.Join(args, " ")
-	out, err := Run(cmd)
-	return string(out), err
+	return nl.executor.Run(HostCommand(args...)...)
 }
 
 // RemoveLV ...
 func (nl *NodeLVM) RemoveLV(vg, name string) (string, error) {
+	args, err := removeLVArgs(vg, name)
+	if err != nil {
+		return "", err
+	}
 	lvs, err := nl.ListLV(fmt.Sprintf("%s/%s", vg, name))
 	if err != nil {
 		return "", fmt.Errorf("failed to list LVs: %v", err)
@@ -119,30 +165,23 @@ func (nl *NodeLVM) RemoveLV(vg, name string) (string, error) {
 			return "", errors.New("volume is protected")
 		}
 	}
-
-	args := []string{NsenterCmd, "lvremove", "-v", "-f", fmt.Sprintf("%s/%s", vg, name)}
-	cmd := strings.Join(args, " ")
-	out, err := Run(cmd)
-
-	return string(out), err
-
+	return nl.executor.Run(HostCommand(args...)...)
 }
 
 // CloneLV ...
 func (nl *NodeLVM) CloneLV(src, dest string) (string, error) {
-	args := []string{NsenterCmd, "dd", fmt.Sprintf("if=%s", src), fmt.Sprintf("of=%s", dest), "bs=4M"}
-	cmd := strings.Join(args, " ")
-	out, err := Run(cmd)
-
-	return string(out), err
+	args, err := cloneLVArgs(src, dest)
+	if err != nil {
+		return "", err
+	}
+	return nl.executor.RunWithTimeout(FormatCommandTimeout, HostCommand(args...)...)
 }
 
 // ListVG ...
 func (nl *NodeLVM) ListVG() ([]*model.VG, error) {
-	args := []string{NsenterCmd, "vgs", "--units=b", "--separator=\"<:SEP:>\"", "--nosuffix", "--noheadings",
-		"-o", "vg_name,vg_size,vg_free,vg_uuid,vg_tags", "--nameprefixes", "-a"}
-	cmd := strings.Join(args, " ")
-	out, err := Run(cmd)
+	args := HostCommand("vgs", "--units=b", "--separator=<:SEP:>", "--nosuffix", "--noheadings",
+		"-o", "vg_name,vg_size,vg_free,vg_extent_size,vg_uuid,vg_tags", "--nameprefixes", "-a")
+	out, err := nl.executor.Run(args...)
 	if err != nil {
 		return nil, err
 	}
@@ -166,12 +205,21 @@ func (nl *NodeLVM) ListVG() ([]*model.VG, error) {
 	return vgs, nil
 }
 
-// ListPhysicalVolume ...
+// ListPhysicalVolume returns the physical volumes in volume groups
 func (nl *NodeLVM) ListPhysicalVolume() ([]*model.PV, error) {
-	args := []string{NsenterCmd, "pvs", "--units=b", "--separator=\"<:SEP:>\"", "--nosuffix", "--noheadings",
-		"-o", "vg_name,pv_name,pv_size,pv_uuid", "--nameprefixes", "-a"}
-	cmd := strings.Join(args, " ")
-	out, err := Run(cmd)
+	return nl.listPVs(func(pv *model.PV) bool { return pv.VgName != "" })
+}
+
+// ListOrphanPhysicalVolume ...
+func (nl *NodeLVM) ListOrphanPhysicalVolume() ([]*model.PV, error) {
+	return nl.listPVs(func(pv *model.PV) bool { return pv.VgName == "" })
+}
+
+// listPVs returns the physical volumes matching keep
+func (nl *NodeLVM) listPVs(keep func(*model.PV) bool) ([]*model.PV, error) {
+	args := HostCommand("pvs", "--units=b", "--separator=<:SEP:>", "--nosuffix", "--noheadings",
+		"-o", "vg_name,pv_name,pv_size,pv_free,pv_uuid", "--nameprefixes", "-a")
+	out, err := nl.executor.Run(args...)
 	if err != nil {
 		return nil, err
 	}
@@ -190,7 +238,7 @@ func (nl *NodeLVM) ListPhysicalVolume() ([]*model.PV, error) {
 		if err != nil {
 			return nil, err
 		}
-		if pv.VgName != "" && pv.Name != "" {
+		if pv.Name != "" && keep(pv) {
 			pvs = append(pvs, pv)
 		}
 	}
@@ -198,28 +246,29 @@ func (nl *NodeLVM) ListPhysicalVolume() ([]*model.PV, error) {
 }
 
 // CreateVG ...
-func (nl *NodeLVM) CreateVG(name, physicalVolume string, tags []string) (string, error) {
-	args := []string{NsenterCmd, "vgcreate", name, physicalVolume, "-v"}
-	for _, tag := range tags {
-		args = append(args, "--add-tag", tag)
+func (nl *NodeLVM) CreateVG(name string, physicalVolumes []string, tags []string) (string, error) {
+	args, err := createVGArgs(name, physicalVolumes, tags)
+	if err != nil {
+		return "", err
 	}
-	cmd := strings.Join(args, " ")
-	out, err := Run(cmd)
-
-	return string(out), err
+	return nl.executor.Run(HostCommand(args...)...)
 }
 
 // ExtendVG ...
-func (nl *NodeLVM) ExtendVG(name, physicalVolume string) (string, error) {
-	args := []string{NsenterCmd, "vgextend", name, physicalVolume, "-v"}
-	cmd := strings.Join(args, " ")
-	out, err := Run(cmd)
-
-	return string(out), err
+func (nl *NodeLVM) ExtendVG(name string, physicalVolumes []string) (string, error) {
+	args, err := extendVGArgs(name, physicalVolumes)
+	if err != nil {
+		return "", err
+	}
+	return nl.executor.Run(HostCommand(args...)...)
 }
 
 // RemoveVG ...
 func (nl *NodeLVM) RemoveVG(name string) (string, error) {
+	args, err := removeVGArgs(name)
+	if err != nil {
+		return "", err
+	}
 	vgs, err := nl.ListVG()
 	if err != nil {
 		return "", fmt.Errorf("failed to list VGs: %v", err)
@@ -239,17 +288,79 @@ func (nl *NodeLVM) RemoveVG(name string) (string, error) {
 			return "", errors.New("volume is protected")
 		}
 	}
+	return nl.executor.Run(HostCommand(args...)...)
+}
+
+// AddTagVG ...
+func (nl *NodeLVM) AddTagVG(name string, tags []string) (string, error) {
+	args, err := changeTagVGArgs("--addtag", name, tags)
+	if err != nil {
+		return "", err
+	}
+	return nl.executor.Run(HostCommand(args...)...)
+}
+
+// RemoveTagVG ...
+func (nl *NodeLVM) RemoveTagVG(name string, tags []string) (string, error) {
+	args, err := changeTagVGArgs("--deltag", name, tags)
+	if err != nil {
+		return "", err
+	}
+	return nl.executor.Run(HostCommand(args...)...)
+}
+
+// AddTagPV ...
+func (nl *NodeLVM) AddTagPV(physicalVolumes []string, tags []string) (string, error) {
+	args, err := addTagPVArgs(physicalVolumes, tags)
+	if err != nil {
+		return "", err
+	}
+	return nl.executor.Run(HostCommand(args...)...)
+}
 
-	args := []string{NsenterCmd, "vgremove", "-v", "-f", name}
-	cmd := strings.Join(args, " ")
-	out, err := Run(cmd)
+// MovePV ...
+func (nl *NodeLVM) MovePV(source string, destinations []string) (string, error) {
+	args, err := movePVArgs(source, destinations)
+	if err != nil {
+		return "", err
+	}
+	return nl.executor.Run(HostCommand(args...)...)
+}
 
-	return string(out), err
+// ReduceVG ...
+func (nl *NodeLVM) ReduceVG(name string, physicalVolumes []string) (string, error) {
+	args, err := reduceVGArgs(name, physicalVolumes)
+	if err != nil {
+		return "", err
+	}
+	return nl.executor.Run(HostCommand(args...)...)
+}
 
+// RemovePV ...
+func (nl *NodeLVM) RemovePV(physicalVolumes []string) (string, error) {
+	args, err := removePVArgs(physicalVolumes)
+	if err != nil {
+		return "", err
+	}
+	return nl.executor.Run(HostCommand(args...)...)
 }
 
 // AddTagLV ...
 func (nl *NodeLVM) AddTagLV(vg, name string, tags []string) (string, error) {
+	return nl.changeTagLV("--addtag", vg, name, tags)
+}
+
+// RemoveTagLV ....
+func (nl *NodeLVM) RemoveTagLV(vg, name string, tags []string) (string, error) {
+	return nl.changeTagLV("--deltag", vg, name, tags)
+}
+
+// changeTagLV adds or removes tags of an existing logical volume
+func (nl *NodeLVM) changeTagLV(option, vg, name string, tags []string) (string, error) {
+	args, err := changeTagLVArgs(option, vg, name, tags)
+	if err != nil {
+		return "", err
+	}
 	lvs, err := nl.ListLV(fmt.Sprintf("%s/%s", vg, name))
 	if err != nil {
 		return "", fmt.Errorf("failed to list LVs: %v", err)
@@ -257,41 +368,292 @@ func (nl *NodeLVM) AddTagLV(vg, name string, tags []string) (string, error) {
 	if len(lvs) != 1 {
 		return "", fmt.Errorf("expected 1 LV, got %d", len(lvs))
 	}
+	return nl.executor.Run(HostCommand(args...)...)
+}
 
-	args := make([]string, 0)
-	args = append(args, NsenterCmd)
-	args = append(args, "lvchange")
-	for _, tag := range tags {
-		args = append(args, "--addtag", tag)
+// ExtendLV ...
+func (nl *NodeLVM) ExtendLV(vg, name string, size uint64) (string, error) {
+	args, err := extendLVArgs(vg, name, size)
+	if err != nil {
+		return "", err
 	}
+	return nl.executor.Run(HostCommand(args...)...)
+}
 
-	args = append(args, fmt.Sprintf("%s/%s", vg, name))
-	cmd := strings.Join(args, " ")
-	out, err := Run(cmd)
+// CreateThinPool ...
+func (nl *NodeLVM) CreateThinPool(vg, name string, size uint64, percentVG uint32, chunkSize, metadataSize uint64, tags []string) (string, error) {
+	args, err := createThinPoolArgs(vg, name, size, percentVG, chunkSize, metadataSize, tags)
+	if err != nil {
+		return "", err
+	}
+	return nl.executor.Run(HostCommand(args...)...)
+}
 
-	return string(out), err
+// ExtendThinPool ...
+func (nl *NodeLVM) ExtendThinPool(vg, name string, size uint64) (string, error) {
+	args, err := extendLVArgs(vg, name, size)
+	if err != nil {
+		return "", err
+	}
+	return nl.executor.Run(HostCommand(args...)...)
 }
 
-// RemoveTagLV ....
-func (nl *NodeLVM) RemoveTagLV(vg, name string, tags []string) (string, error) {
+// CreateThinLV ...
+func (nl *NodeLVM) CreateThinLV(vg, pool, name string, virtualSize uint64, tags []string) (string, error) {
+	args, err := createThinLVArgs(vg, pool, name, virtualSize, tags)
+	if err != nil {
+		return "", err
+	}
+	return nl.executor.Run(HostCommand(args...)...)
+}
 
-	lvs, err := nl.ListLV(fmt.Sprintf("%s/%s", vg, name))
+// ExtendThinLV ...
+func (nl *NodeLVM) ExtendThinLV(vg, name string, virtualSize uint64) (string, error) {
+	args, err := extendLVArgs(vg, name, virtualSize)
 	if err != nil {
-		return "", fmt.Errorf("failed to list LVs: %v", err)
+		return "", err
 	}
-	if len(lvs) != 1 {
-		return "", fmt.Errorf("expected 1 LV, got %d", len(lvs))
+	return nl.executor.Run(HostCommand(args...)...)
+}
+
+// validateListSpec checks the volume group or vg/lv listed by lvs
+func validateListSpec(listSpec string) error {
+	for _, name := range strings.SplitN(listSpec, "/", 2) {
+		if err := ValidateLVMName(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createLVArgs validates inputs and returns the lvcreate args
+func createLVArgs(vg, name string, size uint64, mirrors uint32, tags []string) ([]string, error) {
+	if size == 0 {
+		return nil, errors.New("size must be greater than 0")
+	}
+	if err := validateListSpec(vg + "/" + name); err != nil {
+		return nil, err
+	}
+	if err := ValidateLVMTags(tags); err != nil {
+		return nil, err
+	}
+	args := []string{"lvcreate", "-v", "-n", name, "-L", fmt.Sprintf("%db", size)}
+	if mirrors > 0 {
+		args = append(args, "-m", fmt.Sprintf("%d", mirrors), "--nosync")
+	}
+	for _, tag := range tags {
+		args = append(args, "--add-tag", tag)
+	}
+	return append(args, vg), nil
+}
+
+// removeLVArgs validates inputs and returns the lvremove args
+func removeLVArgs(vg, name string) ([]string, error) {
+	if err := validateListSpec(vg + "/" + name); err != nil {
+		return nil, err
+	}
+	return []string{"lvremove", "-v", "-f", fmt.Sprintf("%s/%s", vg, name)}, nil
+}
+
+// cloneLVArgs validates inputs and returns the dd args
+func cloneLVArgs(src, dest string) ([]string, error) {
+	if err := ValidateDevicePaths([]string{src, dest}); err != nil {
+		return nil, err
+	}
+	return []string{"dd", fmt.Sprintf("if=%s", src), fmt.Sprintf("of=%s", dest), "bs=4M"}, nil
+}
+
+// createVGArgs validates inputs and returns the vgcreate args
+func createVGArgs(name string, physicalVolumes []string, tags []string) ([]string, error) {
+	if err := ValidateLVMName(name); err != nil {
+		return nil, err
+	}
+	if err := ValidateDevicePaths(physicalVolumes); err != nil {
+		return nil, err
+	}
+	if err := ValidateLVMTags(tags); err != nil {
+		return nil, err
+	}
+	args := append([]string{"vgcreate", name}, physicalVolumes...)
+	args = append(args, "-v")
+	for _, tag := range tags {
+		args = append(args, "--add-tag", tag)
+	}
+	return args, nil
+}
+
+// extendVGArgs validates inputs and returns the vgextend args
+func extendVGArgs(name string, physicalVolumes []string) ([]string, error) {
+	if err := ValidateLVMName(name); err != nil {
+		return nil, err
+	}
+	if err := ValidateDevicePaths(physicalVolumes); err != nil {
+		return nil, err
+	}
+	args := append([]string{"vgextend", name}, physicalVolumes...)
+	return append(args, "-v"), nil
+}
+
+// removeVGArgs validates inputs and returns the vgremove args
+func removeVGArgs(name string) ([]string, error) {
+	if err := ValidateLVMName(name); err != nil {
+		return nil, err
+	}
+	// without -f, vgremove asks before removing the logical volumes in it, which is answered no without a terminal
+	return []string{"vgremove", "-v", name}, nil
+}
+
+// movePVArgs validates inputs and returns the pvmove args, which returns once the move is started
+func movePVArgs(source string, destinations []string) ([]string, error) {
+	if err := ValidateDevicePath(source); err != nil {
+		return nil, err
+	}
+	if err := ValidateDevicePaths(destinations); err != nil {
+		return nil, err
+	}
+	for _, destination := range destinations {
+		if destination == source {
+			return nil, fmt.Errorf("can not move %s to itself", source)
+		}
+	}
+	return append([]string{"pvmove", "-b", "-v", source}, destinations...), nil
+}
+
+// reduceVGArgs validates inputs and returns the vgreduce args
+func reduceVGArgs(name string, physicalVolumes []string) ([]string, error) {
+	if err := ValidateLVMName(name); err != nil {
+		return nil, err
+	}
+	if err := ValidateDevicePaths(physicalVolumes); err != nil {
+		return nil, err
+	}
+	args := append([]string{"vgreduce", name}, physicalVolumes...)
+	return append(args, "-v"), nil
+}
+
+// removePVArgs validates inputs and returns the pvremove args
+func removePVArgs(physicalVolumes []string) ([]string, error) {
+	if err := ValidateDevicePaths(physicalVolumes); err != nil {
+		return nil, err
+	}
+	args := append([]string{"pvremove"}, physicalVolumes...)
+	return append(args, "-v"), nil
+}
+
+// changeTagLVArgs validates inputs and returns the lvchange args, option is --addtag or --deltag
+func changeTagLVArgs(option, vg, name string, tags []string) ([]string, error) {
+	if err := validateListSpec(vg + "/" + name); err != nil {
+		return nil, err
 	}
+	if err := ValidateLVMTags(tags); err != nil {
+		return nil, err
+	}
+	args := []string{"lvchange"}
+	for _, tag := range tags {
+		args = append(args, option, tag)
+	}
+	return append(args, fmt.Sprintf("%s/%s", vg, name)), nil
+}
+
+// changeTagVGArgs validates inputs and returns the vgchange args, option is --addtag or --deltag
+func changeTagVGArgs(option, name string, tags []string) ([]string, error) {
+	if err := ValidateLVMName(name); err != nil {
+		return nil, err
+	}
+	if len(tags) == 0 {
+		return nil, errors.New("no tag specified")
+	}
+	if err := ValidateLVMTags(tags); err != nil {
+		return nil, err
+	}
+	args := []string{"vgchange"}
+	for _, tag := range tags {
+		args = append(args, option, tag)
+	}
+	return append(args, name), nil
+}
+
+// addTagPVArgs validates inputs and returns the pvchange args
+func addTagPVArgs(physicalVolumes []string, tags []string) ([]string, error) {
+	if err := ValidateDevicePaths(physicalVolumes); err != nil {
+		return nil, err
+	}
+	if len(tags) == 0 {
+		return nil, errors.New("no tag specified")
+	}
+	if err := ValidateLVMTags(tags); err != nil {
+		return nil, err
+	}
+	args := []string{"pvchange"}
+	for _, tag := range tags {
+		args = append(args, "--addtag", tag)
+	}
+	return append(args, physicalVolumes...), nil
+}
+
+// createThinPoolArgs validates inputs and returns the lvcreate args of thin pool
+func createThinPoolArgs(vg, name string, size uint64, percentVG uint32, chunkSize, metadataSize uint64, tags []string) ([]string, error) {
+	if err := validateListSpec(vg + "/" + name); err != nil {
+		return nil, err
+	}
+	if err := ValidateLVMTags(tags); err != nil {
+		return nil, err
+	}
+	if percentVG > 100 {
+		return nil, fmt.Errorf("percent of volume group %d is greater than 100", percentVG)
+	}
+	if size == 0 && percentVG == 0 {
+		return nil, errors.New("size must be greater than 0")
+	}
+	if chunkSize != 0 && (chunkSize%minThinPoolChunkSize != 0 || chunkSize > maxThinPoolChunkSize) {
+		return nil, fmt.Errorf("chunk size %d must be a multiple of 64KiB and at most 1GiB", chunkSize)
+	}
+	args := []string{"lvcreate", "-v", "--type", "thin-pool", "-n", name}
+	if percentVG > 0 {
+		args = append(args, "-l", fmt.Sprintf("%d%%VG", percentVG))
+	} else {
+		args = append(args, "-L", fmt.Sprintf("%db", size))
+	}
+	if chunkSize > 0 {
+		args = append(args, "--chunksize", fmt.Sprintf("%dk", chunkSize/1024))
+	}
+	if metadataSize > 0 {
+		args = append(args, "--poolmetadatasize", fmt.Sprintf("%db", metadataSize))
+	}
+	for _, tag := range tags {
+		args = append(args, "--add-tag", tag)
+	}
+	return append(args, vg), nil
+}
 
-	args := make([]string, 0)
-	args = append(args, NsenterCmd)
-	args = append(args, "lvchange")
+// createThinLVArgs validates inputs and returns the lvcreate args of thin logical volume
+func createThinLVArgs(vg, pool, name string, virtualSize uint64, tags []string) ([]string, error) {
+	if virtualSize == 0 {
+		return nil, errors.New("size must be greater than 0")
+	}
+	if err := validateListSpec(vg + "/" + name); err != nil {
+		return nil, err
+	}
+	if err := ValidateLVMName(pool); err != nil {
+		return nil, err
+	}
+	if err := ValidateLVMTags(tags); err != nil {
+		return nil, err
+	}
+	args := []string{"lvcreate", "-v", "--type", "thin", "-n", name, "-V", fmt.Sprintf("%db", virtualSize), "--thinpool", pool}
 	for _, tag := range tags {
-		args = append(args, "--deltag", tag)
+		args = append(args, "--add-tag", tag)
 	}
+	return append(args, vg), nil
+}
 
-	args = append(args, fmt.Sprintf("%s/%s", vg, name))
-	cmd := strings.Join(args, " ")
-	out, err := Run(cmd)
-	return string(out), err
+// extendLVArgs validates inputs and returns the lvextend args
+func extendLVArgs(vg, name string, size uint64) ([]string, error) {
+	if size == 0 {
+		return nil, errors.New("size must be greater than 0")
+	}
+	if err := validateListSpec(vg + "/" + name); err != nil {
+		return nil, err
+	}
+	return []string{"lvextend", "-v", "-L", fmt.Sprintf("%db", size), fmt.Sprintf("%s/%s", vg, name)}, nil
 }
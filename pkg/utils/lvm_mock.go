@@ -137,10 +137,25 @@ func (mr *MockLVMMockRecorder) ListPhysicalVolume() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPhysicalVolume", reflect.TypeOf((*MockLVM)(nil).ListPhysicalVolume))
 }
 
+// ListOrphanPhysicalVolume ...
+func (m *MockLVM) ListOrphanPhysicalVolume() ([]*model.PV, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListOrphanPhysicalVolume")
+	ret0, _ := ret[0].([]*model.PV)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListOrphanPhysicalVolume ...
+func (mr *MockLVMMockRecorder) ListOrphanPhysicalVolume() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListOrphanPhysicalVolume", reflect.TypeOf((*MockLVM)(nil).ListOrphanPhysicalVolume))
+}
+
 // CreateVG ...
-func (m *MockLVM) CreateVG(name, physicalVolume string, tags []string) (string, error) {
+func (m *MockLVM) CreateVG(name string, physicalVolumes []string, tags []string) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateVG", name, physicalVolume, tags)
+	ret := m.ctrl.Call(m, "CreateVG", name, physicalVolumes, tags)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
@@ -154,9 +169,9 @@ func (mr *MockLVMMockRecorder) CreateVG(arg1, arg2, arg3 interface{}) *gomock.Ca
 }
 
 // ExtendVG ...
-func (m *MockLVM) ExtendVG(name, physicalVolume string) (string, error) {
+func (m *MockLVM) ExtendVG(name string, physicalVolumes []string) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ExtendVG", name, physicalVolume)
+	ret := m.ctrl.Call(m, "ExtendVG", name, physicalVolumes)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
@@ -171,7 +186,7 @@ func (mr *MockLVMMockRecorder) ExtendVG(arg1, arg2 interface{}) *gomock.Call {
 // RemoveVG ...
 func (m *MockLVM) RemoveVG(name string) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ExtendVG", name)
+	ret := m.ctrl.Call(m, "RemoveVG", name)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
@@ -212,3 +227,168 @@ func (mr *MockLVMMockRecorder) RemoveTagLV(arg1, arg2, arg3 interface{}) *gomock
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveTagLV", reflect.TypeOf((*MockLVM)(nil).RemoveTagLV), arg1, arg2, arg3)
 }
+
+// CreateThinPool ...
+func (m *MockLVM) CreateThinPool(vg, name string, size uint64, percentVG uint32, chunkSize, metadataSize uint64, tags []string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateThinPool", vg, name, size, percentVG, chunkSize, metadataSize, tags)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateThinPool ...
+func (mr *MockLVMMockRecorder) CreateThinPool(arg1, arg2, arg3, arg4, arg5, arg6, arg7 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateThinPool", reflect.TypeOf((*MockLVM)(nil).CreateThinPool), arg1, arg2, arg3, arg4, arg5, arg6, arg7)
+}
+
+// ExtendThinPool ...
+func (m *MockLVM) ExtendThinPool(vg, name string, size uint64) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExtendThinPool", vg, name, size)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExtendThinPool ...
+func (mr *MockLVMMockRecorder) ExtendThinPool(arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExtendThinPool", reflect.TypeOf((*MockLVM)(nil).ExtendThinPool), arg1, arg2, arg3)
+}
+
+// CreateThinLV ...
+func (m *MockLVM) CreateThinLV(vg, pool, name string, virtualSize uint64, tags []string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateThinLV", vg, pool, name, virtualSize, tags)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateThinLV ...
+func (mr *MockLVMMockRecorder) CreateThinLV(arg1, arg2, arg3, arg4, arg5 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateThinLV", reflect.TypeOf((*MockLVM)(nil).CreateThinLV), arg1, arg2, arg3, arg4, arg5)
+}
+
+// ExtendThinLV ...
+func (m *MockLVM) ExtendThinLV(vg, name string, virtualSize uint64) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExtendThinLV", vg, name, virtualSize)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExtendThinLV ...
+func (mr *MockLVMMockRecorder) ExtendThinLV(arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExtendThinLV", reflect.TypeOf((*MockLVM)(nil).ExtendThinLV), arg1, arg2, arg3)
+}
+
+// ExtendLV ...
+func (m *MockLVM) ExtendLV(vg, name string, size uint64) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExtendLV", vg, name, size)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExtendLV ...
+func (mr *MockLVMMockRecorder) ExtendLV(arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExtendLV", reflect.TypeOf((*MockLVM)(nil).ExtendLV), arg1, arg2, arg3)
+}
+
+// MovePV ...
+func (m *MockLVM) MovePV(source string, destinations []string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MovePV", source, destinations)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MovePV ...
+func (mr *MockLVMMockRecorder) MovePV(arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MovePV", reflect.TypeOf((*MockLVM)(nil).MovePV), arg1, arg2)
+}
+
+// ReduceVG ...
+func (m *MockLVM) ReduceVG(name string, physicalVolumes []string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReduceVG", name, physicalVolumes)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReduceVG ...
+func (mr *MockLVMMockRecorder) ReduceVG(arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReduceVG", reflect.TypeOf((*MockLVM)(nil).ReduceVG), arg1, arg2)
+}
+
+// RemovePV ...
+func (m *MockLVM) RemovePV(physicalVolumes []string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemovePV", physicalVolumes)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RemovePV ...
+func (mr *MockLVMMockRecorder) RemovePV(arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemovePV", reflect.TypeOf((*MockLVM)(nil).RemovePV), arg1)
+}
+
+// AddTagVG ...
+func (m *MockLVM) AddTagVG(name string, tags []string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddTagVG", name, tags)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddTagVG ...
+func (mr *MockLVMMockRecorder) AddTagVG(arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddTagVG", reflect.TypeOf((*MockLVM)(nil).AddTagVG), arg1, arg2)
+}
+
+// RemoveTagVG ...
+func (m *MockLVM) RemoveTagVG(name string, tags []string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveTagVG", name, tags)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RemoveTagVG ...
+func (mr *MockLVMMockRecorder) RemoveTagVG(arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveTagVG", reflect.TypeOf((*MockLVM)(nil).RemoveTagVG), arg1, arg2)
+}
+
+// AddTagPV ...
+func (m *MockLVM) AddTagPV(physicalVolumes []string, tags []string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddTagPV", physicalVolumes, tags)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddTagPV ...
+func (mr *MockLVMMockRecorder) AddTagPV(arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddTagPV", reflect.TypeOf((*MockLVM)(nil).AddTagPV), arg1, arg2)
+}
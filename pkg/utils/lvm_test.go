@@ -0,0 +1,210 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/openyurtio/node-resource-manager/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// readTestData returns the content of file in testdata
+func readTestData(t *testing.T, name string) string {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestListLV(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("lvs", "...").Return(readTestData(t, "lvs.txt"))
+	lvs, err := NewNodeLVM(executor).ListLV("volumegroup1")
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"lvs", "--units=b", "--separator=<:SEP:>", "--nosuffix", "--noheadings",
+		"-o", "lv_name,lv_size,lv_uuid,lv_attr,copy_percent,lv_kernel_major,lv_kernel_minor,lv_tags", "--nameprefixes", "-a", "volumegroup1"}}, executor.Calls())
+	assert.Equal(t, 2, len(lvs))
+	assert.Equal(t, "lv1", lvs[0].Name)
+	assert.Equal(t, uint64(10737418240), lvs[0].Size)
+	assert.Equal(t, model.VolumeOpen('o'), lvs[0].Attributes.Open)
+	assert.Equal(t, uint32(253), lvs[0].ActualDevMajNumber)
+	assert.Equal(t, []string{"protected"}, lvs[0].Tags)
+	assert.Equal(t, "lv2", lvs[1].Name)
+	assert.Equal(t, uint32(1), lvs[1].ActualDevMinNumber)
+}
+
+func TestListVG(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("vgs", "...").Return(readTestData(t, "vgs.txt"))
+	vgs, err := NewNodeLVM(executor).ListVG()
+	assert.Nil(t, err)
+	assert.Equal(t, []*model.VG{
+		{Name: "volumegroup1", Size: 42945478656, FreeSize: 10733223936, ExtentSize: 4194304, UUID: "Zl3i7J-e5Cq-3E5d-Xv2x-Yh5C-0Ir7-8bXEhO", Tags: []string{""}},
+		{Name: "volumegroup2", Size: 21441282048, FreeSize: 21441282048, ExtentSize: 33554432, UUID: "pQ2sF1-Gh6y-Lk2j-Mn3b-Vc4x-Za5s-Qw6eRt", Tags: []string{"protected"}},
+	}, vgs)
+}
+
+func TestListPhysicalVolume(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("pvs", "...").Return(readTestData(t, "pvs.txt"))
+	pvs, err := NewNodeLVM(executor).ListPhysicalVolume()
+	assert.Nil(t, err)
+	// physical volume not in any volume group is skipped
+	assert.Equal(t, []*model.PV{
+		{Name: "/dev/vdb", VgName: "volumegroup1", Size: 21472739328, FreeSize: 10735321088, UUID: "3aX1bC-2dE3-fG4h-5iJ6-kL7m-8nO9-pQ0rSt"},
+		{Name: "/dev/vdc", VgName: "volumegroup1", Size: 21472739328, FreeSize: 21472739328, UUID: "4bY2cD-3eF4-gH5i-6jK7-lM8n-9oP0-qR1sTu"},
+	}, pvs)
+}
+
+func TestListOrphanPhysicalVolume(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("pvs", "...").Return(readTestData(t, "pvs.txt"))
+	pvs, err := NewNodeLVM(executor).ListOrphanPhysicalVolume()
+	assert.Nil(t, err)
+	assert.Equal(t, []*model.PV{
+		{Name: "/dev/vdd", Size: 21474836480, FreeSize: 21474836480, UUID: "5cZ3dE-4fG5-hI6j-7kL8-mN9o-0pQ1-rS2tUv"},
+	}, pvs)
+}
+
+func TestCreateVG(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("vgcreate", "...")
+	_, err := NewNodeLVM(executor).CreateVG("volumegroup1", []string{"/dev/vdb", "/dev/vdc"}, []string{"foo"})
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"vgcreate", "volumegroup1", "/dev/vdb", "/dev/vdc", "-v", "--add-tag", "foo"}}, executor.Calls())
+
+	// invalid inputs never reach the host
+	_, err = NewNodeLVM(executor).CreateVG("-volumegroup1", []string{"/dev/vdb"}, nil)
+	assert.NotNil(t, err)
+	_, err = NewNodeLVM(executor).CreateVG("volumegroup1", []string{"/dev/vdb;reboot"}, nil)
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, len(executor.Calls()))
+}
+
+func TestCreateThinPool(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("lvcreate", "...")
+	lvm := NewNodeLVM(executor)
+	_, err := lvm.CreateThinPool("volumegroup1", "thinpool", 0, 80, 256*1024, 0, nil)
+	assert.Nil(t, err)
+	_, err = lvm.CreateThinPool("volumegroup1", "thinpool", 10*1024*1024*1024, 0, 0, 1024*1024*1024, []string{"foo"})
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{
+		{"lvcreate", "-v", "--type", "thin-pool", "-n", "thinpool", "-l", "80%VG", "--chunksize", "256k", "volumegroup1"},
+		{"lvcreate", "-v", "--type", "thin-pool", "-n", "thinpool", "-L", "10737418240b", "--poolmetadatasize", "1073741824b", "--add-tag", "foo", "volumegroup1"},
+	}, executor.Calls())
+
+	// invalid inputs never reach the host
+	_, err = lvm.CreateThinPool("volumegroup1", "thinpool", 0, 0, 0, 0, nil)
+	assert.NotNil(t, err)
+	_, err = lvm.CreateThinPool("volumegroup1", "thinpool", 0, 101, 0, 0, nil)
+	assert.NotNil(t, err)
+	_, err = lvm.CreateThinPool("volumegroup1", "thinpool", 0, 80, 100*1024, 0, nil)
+	assert.NotNil(t, err)
+	assert.Equal(t, 2, len(executor.Calls()))
+}
+
+func TestCreateThinLV(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("lvcreate", "...")
+	executor.On("lvextend", "...")
+	lvm := NewNodeLVM(executor)
+	_, err := lvm.CreateThinLV("volumegroup1", "thinpool", "lv1", 20*1024*1024*1024, []string{"foo"})
+	assert.Nil(t, err)
+	_, err = lvm.ExtendThinLV("volumegroup1", "lv1", 30*1024*1024*1024)
+	assert.Nil(t, err)
+	_, err = lvm.CreateThinLV("volumegroup1", "-thinpool", "lv1", 1024, nil)
+	assert.NotNil(t, err)
+	assert.Equal(t, [][]string{
+		{"lvcreate", "-v", "--type", "thin", "-n", "lv1", "-V", "21474836480b", "--thinpool", "thinpool", "--add-tag", "foo", "volumegroup1"},
+		{"lvextend", "-v", "-L", "32212254720b", "volumegroup1/lv1"},
+	}, executor.Calls())
+}
+
+func TestChangeTag(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("vgchange", "...")
+	executor.On("pvchange", "...")
+	lvm := NewNodeLVM(executor)
+	_, err := lvm.AddTagVG("volumegroup1", []string{"nrm.openyurt.io/managed", "nrm.openyurt.io/name=volumegroup1"})
+	assert.Nil(t, err)
+	_, err = lvm.RemoveTagVG("volumegroup1", []string{"nrm.openyurt.io/spec-hash=0123456789abcdef"})
+	assert.Nil(t, err)
+	_, err = lvm.AddTagPV([]string{"/dev/vdb", "/dev/vdc"}, []string{"nrm.openyurt.io/managed"})
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{
+		{"vgchange", "--addtag", "nrm.openyurt.io/managed", "--addtag", "nrm.openyurt.io/name=volumegroup1", "volumegroup1"},
+		{"vgchange", "--deltag", "nrm.openyurt.io/spec-hash=0123456789abcdef", "volumegroup1"},
+		{"pvchange", "--addtag", "nrm.openyurt.io/managed", "/dev/vdb", "/dev/vdc"},
+	}, executor.Calls())
+
+	// invalid inputs never reach the host
+	_, err = lvm.AddTagVG("volumegroup1", []string{"a b"})
+	assert.NotNil(t, err)
+	_, err = lvm.AddTagVG("volumegroup1", nil)
+	assert.NotNil(t, err)
+	_, err = lvm.AddTagPV([]string{"-a"}, []string{"foo"})
+	assert.NotNil(t, err)
+	assert.Equal(t, 3, len(executor.Calls()))
+}
+
+func TestMovePV(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("pvmove", "...")
+	executor.On("vgreduce", "...")
+	executor.On("pvremove", "...")
+	lvm := NewNodeLVM(executor)
+	_, err := lvm.MovePV("/dev/vdb", []string{"/dev/vdc", "/dev/vdd"})
+	assert.Nil(t, err)
+	_, err = lvm.ReduceVG("volumegroup1", []string{"/dev/vdb"})
+	assert.Nil(t, err)
+	_, err = lvm.RemovePV([]string{"/dev/vdb"})
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{
+		{"pvmove", "-b", "-v", "/dev/vdb", "/dev/vdc", "/dev/vdd"},
+		{"vgreduce", "volumegroup1", "/dev/vdb", "-v"},
+		{"pvremove", "/dev/vdb", "-v"},
+	}, executor.Calls())
+
+	// invalid inputs never reach the host
+	_, err = lvm.MovePV("/dev/vdb", []string{"/dev/vdb"})
+	assert.NotNil(t, err)
+	_, err = lvm.MovePV("/dev/vdb", nil)
+	assert.NotNil(t, err)
+	_, err = lvm.RemovePV([]string{"/dev/vdb", "-ff"})
+	assert.NotNil(t, err)
+	assert.Equal(t, 3, len(executor.Calls()))
+}
+
+func TestRemoveVG(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("vgs", "...").Return(readTestData(t, "vgs.txt"))
+	executor.On("vgremove", "-v", "volumegroup1")
+	lvm := NewNodeLVM(executor)
+	_, err := lvm.RemoveVG("volumegroup1")
+	assert.Nil(t, err)
+	_, err = lvm.RemoveVG("volumegroup2")
+	assert.Equal(t, errors.New("volume is protected"), err)
+	_, err = lvm.RemoveVG("volumegroup3")
+	assert.NotNil(t, err)
+	assert.Equal(t, 4, len(executor.Calls()))
+}
@@ -22,13 +22,16 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"syscall"
 
 	klog "k8s.io/klog/v2"
 	utilexec "k8s.io/utils/exec"
 	k8smount "k8s.io/utils/mount"
 
 	CusErr "github.com/openyurtio/node-resource-manager/pkg/err"
+	"github.com/openyurtio/node-resource-manager/pkg/model"
 )
 
 const (
@@ -55,6 +58,16 @@ type Mounter interface {
 	SafePathRemove(target string) error
 
 	FileExists(file string) bool
+
+	// ListHostMounts list the mount points in host mount namespace
+	ListHostMounts() ([]k8smount.MountPoint, error)
+
+	// GetFsStats returns the capacity of filesystem mounted at target in host
+	GetFsStats(target string) (*model.FsStats, error)
+
+	// GrowFS grows the ext4 or xfs filesystem on device to the device size if it is mounted in host,
+	// returns the mount point, or empty if device is not mounted
+	GrowFS(devicePath string) (string, error)
 }
 
 // TODO(arslan): this is Linux only for now. Refactor this into a package with
@@ -63,16 +76,18 @@ type Mounter interface {
 type NodeMounter struct {
 	k8smount.SafeFormatAndMount
 	utilexec.Interface
+	executor CommandExecutor
 }
 
 // NewMounter returns a new mounter instance
-func NewMounter() Mounter {
+func NewMounter(executor CommandExecutor) Mounter {
 	return &NodeMounter{
 		k8smount.SafeFormatAndMount{
 			Interface: k8smount.New(""),
 			Exec:      utilexec.New(),
 		},
 		utilexec.New(),
+		executor,
 	}
 }
 
@@ -99,10 +114,12 @@ func (m *NodeMounter) EnsureFolder(target string) error {
 		return err
 	}
 
-	mkdirCmd = NsenterCmd + mkdirCmd
-	mkdirCmd += fmt.Sprintf(" -p %s", target)
-	klog.Infof("mkdir for folder, the command is %s", mkdirCmd)
-	output, err := Run(mkdirCmd)
+	if err := ValidateMountPath(target); err != nil {
+		return err
+	}
+	args := HostCommand(mkdirCmd, "-p", target)
+	klog.Infof("mkdir for folder, the command is %v", args)
+	output, err := m.executor.Run(args...)
 	if err != nil {
 		return fmt.Errorf("EnsureFolder:: mkdir for folder output: %s error: %v", output, err)
 	}
@@ -113,34 +130,44 @@ func (m *NodeMounter) EnsureFolder(target string) error {
 func (m *NodeMounter) FormatAndMount(source, target, fstype string, mkfsOptions []string, mountOptions string) error {
 	// diskMounter.Interface = m.K8smounter
 	readOnly := false
+	mountCmd, err := mountArgs(source, target, mountOptions)
+	if err != nil {
+		return err
+	}
+	mkfsCmd, err := mkfsArgs(source, fstype, mkfsOptions)
+	if err != nil {
+		return err
+	}
 
 	if !readOnly {
 		// Run fsck on the disk to fix repairable issues, only do this for volumes requested as rw.
 		args := []string{"-a", source}
 
-		out, err := m.Exec.Command("fsck", args...).CombinedOutput()
+		out, errOut, err := m.executor.CommandWithTimeout(FormatCommandTimeout, "fsck", args...)
 		if err != nil {
-			ee, isExitError := err.(utilexec.ExitError)
+			var ee *exec.ExitError
+			isExitError := errors.As(err, &ee)
 			switch {
-			case err == utilexec.ErrExecutableNotFound:
+			case errors.Is(err, exec.ErrNotFound):
 				klog.Warningf("'fsck' not found on system; continuing mount without running 'fsck'.")
-			case isExitError && ee.ExitStatus() == fsckErrorsCorrected:
+			case isExitError && ee.ExitCode() == fsckErrorsCorrected:
 				klog.Infof("Device %s has errors which were corrected by fsck.", source)
-			case isExitError && ee.ExitStatus() == fsckErrorsUncorrected:
-				return fmt.Errorf("'fsck' found errors on device %s but could not correct them: %s", source, string(out))
-			case isExitError && ee.ExitStatus() > fsckErrorsUncorrected:
+			case isExitError && ee.ExitCode() == fsckErrorsUncorrected:
+				return fmt.Errorf("'fsck' found errors on device %s but could not correct them: %s%s", source, out, errOut)
+			case isExitError && ee.ExitCode() > fsckErrorsUncorrected:
+			case !isExitError:
+				return fmt.Errorf("'fsck' on device %s failed: %v", source, err)
 			}
 		}
 	}
 
 	// Try to mount the disk
-	cmd := fmt.Sprintf("%smount -o %s %s %s", NsenterCmd, mountOptions, source, target)
-	klog.Infof("FormatAndMount:: cmd: %s", cmd)
-	output, mountErr := Run(cmd)
+	klog.Infof("FormatAndMount:: cmd: %v", mountCmd)
+	output, mountErr := m.executor.Run(HostCommand(mountCmd...)...)
 	if mountErr != nil {
 		// Mount failed. This indicates either that the disk is unformatted or
 		// it contains an unexpected filesystem.
-		existingFormat, err := m.GetDiskFormat(source)
+		existingFormat, err := checkFSType(m.executor, source)
 
 		if err != nil {
 			return err
@@ -152,35 +179,11 @@ func (m *NodeMounter) FormatAndMount(source, target, fstype string, mkfsOptions
 			}
 
 			// Disk is unformatted so format it.
-			args := []string{source}
-			// Use 'ext4' as the default
-			if len(fstype) == 0 {
-				fstype = "ext4"
-			}
-
-			if fstype == "ext4" || fstype == "ext3" {
-				args = []string{
-					"-F",  // Force flag
-					"-m0", // Zero blocks reserved for super-user
-					source,
-				}
-				// add mkfs options
-				if len(mkfsOptions) != 0 {
-					args = []string{}
-					for _, opts := range mkfsOptions {
-						args = append(args, opts)
-					}
-					args = append(args, source)
-				}
-			}
-			klog.Infof("Disk %q appears to be unformatted, attempting to format as type: %q with options: %v", source, fstype, args)
-
-			mkfsCmd := fmt.Sprintf("%s mkfs.%s %s", NsenterCmd, fstype, strings.Join(args, " "))
-			klog.Infof("FormatAndMount:: mkfscmd: %s", mkfsCmd)
-			_, err = Run(mkfsCmd)
+			klog.Infof("Disk %q appears to be unformatted, attempting to format: %v", source, mkfsCmd)
+			_, err = m.executor.RunWithTimeout(FormatCommandTimeout, HostCommand(mkfsCmd...)...)
 			if err == nil {
 				// the disk has been formatted successfully try to mount it again.
-				output, mountErr := Run(cmd)
+				output, mountErr := m.executor.Run(HostCommand(mountCmd...)...)
 				klog.Infof("FormatAndMount:: cmd output %s", output)
 				return mountErr
 			}
@@ -203,6 +206,60 @@ func (m *NodeMounter) FormatAndMount(source, target, fstype string, mkfsOptions
 	return mountErr
 }
 
+// mountArgs validates inputs and returns the mount args
+func mountArgs(source, target, mountOptions string) ([]string, error) {
+	if err := ValidateDevicePath(source); err != nil {
+		return nil, err
+	}
+	if err := ValidateMountPath(target); err != nil {
+		return nil, err
+	}
+	if err := ValidateMountOptions(mountOptions); err != nil {
+		return nil, err
+	}
+	if mountOptions == "" {
+		return []string{"mount", source, target}, nil
+	}
+	return []string{"mount", "-o", mountOptions, source, target}, nil
+}
+
+// mkfsArgs validates inputs and returns the mkfs args, ext4 is the default fstype
+func mkfsArgs(source, fstype string, mkfsOptions []string) ([]string, error) {
+	if err := ValidateDevicePath(source); err != nil {
+		return nil, err
+	}
+	if err := ValidateFsType(fstype); err != nil {
+		return nil, err
+	}
+	if err := ValidateMkfsOptions(mkfsOptions); err != nil {
+		return nil, err
+	}
+	// Use 'ext4' as the default
+	if len(fstype) == 0 {
+		fstype = "ext4"
+	}
+	args := []string{"mkfs." + fstype}
+	switch fstype {
+	case "ext4":
+		if len(mkfsOptions) != 0 {
+			// add mkfs options
+			args = append(args, mkfsOptions...)
+		} else {
+			args = append(args,
+				"-F",  // Force flag
+				"-m0", // Zero blocks reserved for super-user
+			)
+		}
+	case "xfs":
+		// mkfs.xfs refuses to overwrite an existing filesystem without -f, the defaults suit project quota
+		args = append(args, mkfsOptions...)
+	default:
+		// same as the fstype enum of quota path, other filesystems lack project quota
+		return nil, fmt.Errorf("unsupported filesystem type %q, only ext4 and xfs can be formatted", fstype)
+	}
+	return append(args, source), nil
+}
+
 // IsMounted ...
 func (m *NodeMounter) IsMounted(target string) (bool, error) {
 	if target == "" {
@@ -210,8 +267,8 @@ func (m *NodeMounter) IsMounted(target string) (bool, error) {
 	}
 	findmntCmd := "grep"
 	findmntArgs := []string{target, "/proc/mounts"}
-	out, err := exec.Command(findmntCmd, findmntArgs...).CombinedOutput()
-	outStr := strings.TrimSpace(string(out))
+	out, _, err := m.executor.Command(findmntCmd, findmntArgs...)
+	outStr := strings.TrimSpace(out)
 	if err != nil {
 		if outStr == "" {
 			return false, nil
@@ -254,6 +311,70 @@ func (m *NodeMounter) SafePathRemove(targetPath string) error {
 	return nil
 }
 
+// ListHostMounts ...
+func (m *NodeMounter) ListHostMounts() ([]k8smount.MountPoint, error) {
+	return k8smount.ListProcMounts(HostMountsFile)
+}
+
+// GetFsStats ...
+func (m *NodeMounter) GetFsStats(target string) (*model.FsStats, error) {
+	statfs := &syscall.Statfs_t{}
+	err := syscall.Statfs(filepath.Join(HostRootPath, target), statfs)
+	if err != nil {
+		return nil, err
+	}
+	blockSize := uint64(statfs.Bsize)
+	return &model.FsStats{
+		Capacity:  statfs.Blocks * blockSize,
+		Used:      (statfs.Blocks - statfs.Bfree) * blockSize,
+		Available: statfs.Bavail * blockSize,
+	}, nil
+}
+
+// GrowFS ...
+func (m *NodeMounter) GrowFS(devicePath string) (string, error) {
+	target, growCmd, err := growFSArgs(m.executor, devicePath)
+	if err != nil || target == "" {
+		return "", err
+	}
+	klog.Infof("GrowFS:: cmd: %v", growCmd)
+	if _, err := m.executor.RunWithTimeout(FormatCommandTimeout, HostCommand(growCmd...)...); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// growFSArgs finds the mount point of device in host, and returns the args to grow its filesystem online.
+// Returns empty target if device is not mounted.
+func growFSArgs(executor CommandExecutor, devicePath string) (string, []string, error) {
+	if err := ValidateDevicePath(devicePath); err != nil {
+		return "", nil, err
+	}
+	args := HostCommand("findmnt", "-n", "-o", "TARGET,FSTYPE", "-S", devicePath)
+	out, errOut, err := executor.Command(args[0], args[1:]...)
+	outStr := strings.TrimSpace(out)
+	if err != nil {
+		if outStr == "" && strings.TrimSpace(errOut) == "" {
+			// findmnt finds nothing
+			return "", nil, nil
+		}
+		return "", nil, fmt.Errorf("GrowFS:: find mount point of %s error: %v, output: %s%s", devicePath, err, outStr, errOut)
+	}
+	// device may be mounted more than once, e.g. bind mounts, the first one is grown
+	fields := strings.Fields(strings.Split(outStr, "\n")[0])
+	if len(fields) != 2 {
+		return "", nil, fmt.Errorf("GrowFS:: unexpected findmnt output of %s: %s", devicePath, outStr)
+	}
+	target, fstype := fields[0], fields[1]
+	switch fstype {
+	case "ext2", "ext3", "ext4":
+		return target, []string{"resize2fs", devicePath}, nil
+	case "xfs":
+		return target, []string{"xfs_growfs", target}, nil
+	}
+	return "", nil, fmt.Errorf("GrowFS:: %s mounted at %s is %s, only ext4 and xfs can be grown online", devicePath, target, fstype)
+}
+
 // IsDirEmpty return status of dir empty or not
 func IsDirEmpty(name string) (bool, error) {
 	f, err := os.Open(name)
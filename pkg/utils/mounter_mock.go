@@ -20,6 +20,7 @@ import (
 	"reflect"
 
 	"github.com/golang/mock/gomock"
+	"github.com/openyurtio/node-resource-manager/pkg/model"
 	utilexec "k8s.io/utils/exec"
 	k8smount "k8s.io/utils/mount"
 )
@@ -119,3 +120,48 @@ func (mr MockMounterMockRecorder) SafePathRemove(target interface{}) *gomock.Cal
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SafePathRemove", reflect.TypeOf((*MockMounter)(nil).SafePathRemove), target)
 }
+
+// ListHostMounts ...
+func (m *MockMounter) ListHostMounts() ([]k8smount.MountPoint, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListHostMounts")
+	ret0, _ := ret[0].([]k8smount.MountPoint)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListHostMounts ...
+func (mr MockMounterMockRecorder) ListHostMounts() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListHostMounts", reflect.TypeOf((*MockMounter)(nil).ListHostMounts))
+}
+
+// GetFsStats ...
+func (m *MockMounter) GetFsStats(target string) (*model.FsStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFsStats", target)
+	ret0, _ := ret[0].(*model.FsStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFsStats ...
+func (mr MockMounterMockRecorder) GetFsStats(target interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFsStats", reflect.TypeOf((*MockMounter)(nil).GetFsStats), target)
+}
+
+// GrowFS ...
+func (m *MockMounter) GrowFS(devicePath string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GrowFS", devicePath)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GrowFS ...
+func (mr MockMounterMockRecorder) GrowFS(devicePath interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GrowFS", reflect.TypeOf((*MockMounter)(nil).GrowFS), devicePath)
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatAndMount(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("fsck", "-a", "/dev/vdb")
+	executor.On("mount", "...").Once().Fail("mount: wrong fs type", errors.New("exit status 32"))
+	executor.On("file", "-bsL", "/dev/vdb").Return("data\n")
+	executor.On("mkfs.ext4", "...")
+	executor.On("mount", "...")
+	mounter := NewMounter(executor)
+	assert.Nil(t, mounter.FormatAndMount("/dev/vdb", "/mnt/path1", "ext4", []string{"-O", "project,quota"}, "prjquota"))
+	assert.Equal(t, [][]string{
+		{"fsck", "-a", "/dev/vdb"},
+		{"mount", "-o", "prjquota", "/dev/vdb", "/mnt/path1"},
+		{"file", "-bsL", "/dev/vdb"},
+		{"mkfs.ext4", "-O", "project,quota", "/dev/vdb"},
+		{"mount", "-o", "prjquota", "/dev/vdb", "/mnt/path1"},
+	}, executor.Calls())
+}
+
+func TestMkfsArgs(t *testing.T) {
+	for _, c := range []struct {
+		fstype      string
+		mkfsOptions []string
+		expect      []string
+	}{
+		{"", nil, []string{"mkfs.ext4", "-F", "-m0", "/dev/vdb"}},
+		{"ext4", []string{"-O", "project,quota"}, []string{"mkfs.ext4", "-O", "project,quota", "/dev/vdb"}},
+		{"xfs", nil, []string{"mkfs.xfs", "/dev/vdb"}},
+		{"xfs", []string{"-m", "reflink=1"}, []string{"mkfs.xfs", "-m", "reflink=1", "/dev/vdb"}},
+	} {
+		args, err := mkfsArgs("/dev/vdb", c.fstype, c.mkfsOptions)
+		assert.Nil(t, err)
+		assert.Equal(t, c.expect, args)
+	}
+	for _, fstype := range []string{"ext3", "btrfs"} {
+		_, err := mkfsArgs("/dev/vdb", fstype, nil)
+		assert.NotNil(t, err, fstype)
+	}
+}
+
+func TestFormatAndMountInvalidInput(t *testing.T) {
+	executor := NewFakeExecutor()
+	mounter := NewMounter(executor)
+	assert.NotNil(t, mounter.FormatAndMount("/dev/vdb", "/mnt/x; rm -rf /", "ext4", nil, ""))
+	assert.NotNil(t, mounter.FormatAndMount("/dev/vdb", "/mnt/path1", "ext4", nil, "rw,$(reboot)"))
+	assert.NotNil(t, mounter.FormatAndMount("/dev/../etc/passwd", "/mnt/path1", "ext4", nil, ""))
+	assert.NotNil(t, mounter.EnsureFolder("../mnt"))
+	assert.Equal(t, 0, len(executor.Calls()))
+}
+
+func TestGrowFS(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("findmnt", "-n", "-o", "TARGET,FSTYPE", "-S", "/dev/volumegroup1/lv1").Return("/mnt/lv1 ext4\n")
+	executor.On("findmnt", "-n", "-o", "TARGET,FSTYPE", "-S", "/dev/volumegroup1/lv2").Return("/mnt/lv2 xfs\n/mnt/bind xfs\n")
+	executor.On("findmnt", "-n", "-o", "TARGET,FSTYPE", "-S", "/dev/volumegroup1/lv3").Fail("", errors.New("exit status 1"))
+	executor.On("findmnt", "-n", "-o", "TARGET,FSTYPE", "-S", "/dev/volumegroup1/lv4").Return("/mnt/lv4 btrfs\n")
+	executor.On("resize2fs", "...")
+	executor.On("xfs_growfs", "...")
+	mounter := NewMounter(executor)
+	target, err := mounter.GrowFS("/dev/volumegroup1/lv1")
+	assert.Nil(t, err)
+	assert.Equal(t, "/mnt/lv1", target)
+	target, err = mounter.GrowFS("/dev/volumegroup1/lv2")
+	assert.Nil(t, err)
+	assert.Equal(t, "/mnt/lv2", target)
+	// not mounted
+	target, err = mounter.GrowFS("/dev/volumegroup1/lv3")
+	assert.Nil(t, err)
+	assert.Equal(t, "", target)
+	_, err = mounter.GrowFS("/dev/volumegroup1/lv4")
+	assert.NotNil(t, err)
+	assert.Equal(t, [][]string{
+		{"findmnt", "-n", "-o", "TARGET,FSTYPE", "-S", "/dev/volumegroup1/lv1"},
+		{"resize2fs", "/dev/volumegroup1/lv1"},
+		{"findmnt", "-n", "-o", "TARGET,FSTYPE", "-S", "/dev/volumegroup1/lv2"},
+		{"xfs_growfs", "/mnt/lv2"},
+		{"findmnt", "-n", "-o", "TARGET,FSTYPE", "-S", "/dev/volumegroup1/lv3"},
+		{"findmnt", "-n", "-o", "TARGET,FSTYPE", "-S", "/dev/volumegroup1/lv4"},
+	}, executor.Calls())
+}
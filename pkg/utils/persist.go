@@ -0,0 +1,430 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package utils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/openyurtio/node-resource-manager/pkg/model"
+	klog "k8s.io/klog/v2"
+)
+
+const (
+	// PersistenceFstab persists mount as fstab entry
+	PersistenceFstab = "fstab"
+	// PersistenceSystemd persists mount as systemd mount unit
+	PersistenceSystemd = "systemd"
+	// persistMarker marks the fstab entry on next line and the mount unit owned by node-resource-manager
+	persistMarker = "# managed by node-resource-manager, do not edit"
+	// systemdUnitDir is the host directory of systemd mount units, relative to /etc
+	systemdUnitDir = "systemd/system"
+	// fsUUIDPrefix is the link of filesystem by uuid
+	fsUUIDPrefix = "/dev/disk/by-uuid/"
+)
+
+var (
+	// fsUUIDRegexp matches the filesystem uuid reported by blkid
+	fsUUIDRegexp = regexp.MustCompile(`^[a-zA-Z0-9-]+$`)
+	// fstabOptions are added to the options of fstab entry, so a missing device does not fail boot
+	// and kubelet is started after the mount
+	fstabOptions = []string{"nofail", "x-systemd.before=kubelet.service"}
+)
+
+// MountPersister keeps quota path mounts in host fstab or systemd mount units, so they are mounted on boot
+type MountPersister interface {
+	// ListPersistedMounts returns the mounts persisted by node-resource-manager
+	ListPersistedMounts() ([]model.PersistedMount, error)
+	// PersistMount writes the fstab entry or mount unit of mount, replacing the one of same path, nothing is changed if persisted already
+	PersistMount(mount model.PersistedMount) error
+	// RemovePersistedMount removes the fstab entry or mount unit of mount path
+	RemovePersistedMount(mount model.PersistedMount) error
+	// GetFsUUID returns the filesystem uuid of device
+	GetFsUUID(device string) (string, error)
+}
+
+// NodeMountPersister writes fstab and mount units in etcRoot, and runs systemctl on host
+type NodeMountPersister struct {
+	executor CommandExecutor
+	etcRoot  string
+}
+
+// NewMountPersister create MountPersister writing host /etc
+func NewMountPersister(executor CommandExecutor) *NodeMountPersister {
+	return NewNodeMountPersister(executor, DefaultEtcRoot)
+}
+
+// NewNodeMountPersister ...
+func NewNodeMountPersister(executor CommandExecutor, etcRoot string) *NodeMountPersister {
+	return &NodeMountPersister{executor: executor, etcRoot: etcRoot}
+}
+
+// ListPersistedMounts ...
+func (np *NodeMountPersister) ListPersistedMounts() ([]model.PersistedMount, error) {
+	mounts, _, err := readFstab(filepath.Join(np.etcRoot, "fstab"))
+	if err != nil {
+		return nil, err
+	}
+	units, err := filepath.Glob(filepath.Join(np.etcRoot, systemdUnitDir, "*.mount"))
+	if err != nil {
+		return nil, err
+	}
+	for _, unit := range units {
+		content, err := ioutil.ReadFile(unit)
+		if err != nil {
+			return nil, err
+		}
+		if mount, ok := parseMountUnit(string(content)); ok {
+			mounts = append(mounts, mount)
+		}
+	}
+	return mounts, nil
+}
+
+// readFstab returns the entries owned by node-resource-manager and the mount paths of others, a missing file has no entries
+func readFstab(path string) ([]model.PersistedMount, map[string]bool, error) {
+	mounts := []model.PersistedMount{}
+	others := map[string]bool{}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return mounts, others, nil
+		}
+		return nil, nil, err
+	}
+	owned := false
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == persistMarker {
+			owned = true
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 4 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if owned && strings.HasPrefix(fields[0], "UUID=") {
+			mounts = append(mounts, model.PersistedMount{Persistence: PersistenceFstab, UUID: strings.TrimPrefix(fields[0], "UUID="),
+				Path: fields[1], Fstype: fields[2], Options: fields[3]})
+		} else {
+			others[fields[1]] = true
+		}
+		owned = false
+	}
+	return mounts, others, nil
+}
+
+// parseMountUnit parses the mount unit written by node-resource-manager, returns false if not owned
+func parseMountUnit(content string) (model.PersistedMount, bool) {
+	mount := model.PersistedMount{Persistence: PersistenceSystemd}
+	lines := strings.Split(content, "\n")
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != persistMarker {
+		return mount, false
+	}
+	for _, line := range lines[1:] {
+		fields := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		switch fields[0] {
+		case "What":
+			mount.UUID = strings.TrimPrefix(fields[1], fsUUIDPrefix)
+		case "Where":
+			mount.Path = fields[1]
+		case "Type":
+			mount.Fstype = fields[1]
+		case "Options":
+			mount.Options = fields[1]
+		}
+	}
+	return mount, true
+}
+
+// PersistMount ...
+func (np *NodeMountPersister) PersistMount(mount model.PersistedMount) error {
+	switch mount.Persistence {
+	case PersistenceFstab:
+		return np.persistFstab(mount)
+	case PersistenceSystemd:
+		return np.persistMountUnit(mount)
+	default:
+		return fmt.Errorf("invalid persistence %q of %s, must be %s or %s", mount.Persistence, mount.Path, PersistenceFstab, PersistenceSystemd)
+	}
+}
+
+func (np *NodeMountPersister) persistFstab(mount model.PersistedMount) error {
+	entry, err := fstabEntry(mount)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(np.etcRoot, "fstab")
+	mounts, others, err := readFstab(path)
+	if err != nil {
+		return err
+	}
+	if others[mount.Path] {
+		return fmt.Errorf("fstab has an entry of %s not managed by node-resource-manager", mount.Path)
+	}
+	for _, existing := range mounts {
+		if samePersistedMount(existing, mount) {
+			return nil
+		}
+	}
+	if err := rewriteFstab(path, mount.Path, entry); err != nil {
+		return err
+	}
+	klog.Infof("PersistMount:: persist %s in fstab: %s", mount.Path, entry)
+	np.reloadSystemd()
+	return nil
+}
+
+// fstabEntry returns the validated fstab line of mount
+func fstabEntry(mount model.PersistedMount) (string, error) {
+	if err := validatePersistedMount(mount); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("UUID=%s %s %s %s 0 0", mount.UUID, mount.Path, mount.Fstype, persistedOptions(mount)), nil
+}
+
+// validatePersistedMount checks the fields written into host files
+func validatePersistedMount(mount model.PersistedMount) error {
+	if !fsUUIDRegexp.MatchString(mount.UUID) {
+		return fmt.Errorf("invalid filesystem uuid %q of %s", mount.UUID, mount.Path)
+	}
+	if err := ValidateMountPath(mount.Path); err != nil {
+		return err
+	}
+	if mount.Fstype == "" {
+		return fmt.Errorf("no filesystem type of %s", mount.Path)
+	}
+	if err := ValidateFsType(mount.Fstype); err != nil {
+		return err
+	}
+	return ValidateMountOptions(mount.Options)
+}
+
+// persistedOptions returns the options written for mount, fstab entry has fstabOptions added
+func persistedOptions(mount model.PersistedMount) string {
+	if mount.Persistence != PersistenceFstab {
+		return mount.Options
+	}
+	options := []string{}
+	if mount.Options != "" {
+		options = strings.Split(mount.Options, ",")
+	}
+	for _, option := range fstabOptions {
+		found := false
+		for _, existing := range options {
+			found = found || existing == option
+		}
+		if !found {
+			options = append(options, option)
+		}
+	}
+	return strings.Join(options, ",")
+}
+
+// samePersistedMount checks whether existing is persisted from mount
+func samePersistedMount(existing, mount model.PersistedMount) bool {
+	return existing.Persistence == mount.Persistence && existing.UUID == mount.UUID && existing.Path == mount.Path &&
+		existing.Fstype == mount.Fstype && existing.Options == persistedOptions(mount)
+}
+
+// rewriteFstab removes the owned entry of mount path from fstab, and appends entry if not empty.
+// The file is replaced by rename, so a crash never leaves a partial fstab.
+func rewriteFstab(path, mountPath, entry string) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	lines := []string{}
+	if len(content) > 0 {
+		lines = strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	}
+	kept := make([]string, 0, len(lines)+2)
+	for i := 0; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == persistMarker && i+1 < len(lines) {
+			if fields := strings.Fields(lines[i+1]); len(fields) >= 2 && fields[1] == mountPath {
+				i++
+				continue
+			}
+		}
+		kept = append(kept, lines[i])
+	}
+	if entry != "" {
+		kept = append(kept, persistMarker, entry)
+	}
+	newContent := strings.Join(kept, "\n")
+	if len(kept) > 0 {
+		newContent += "\n"
+	}
+	if newContent == string(content) {
+		return nil
+	}
+	tmp := path + ".nrm.tmp"
+	if err := ioutil.WriteFile(tmp, []byte(newContent), mode); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// reloadSystemd makes systemd generate mount units from the changed fstab, the error is ignored on host without systemd
+func (np *NodeMountPersister) reloadSystemd() {
+	if _, err := np.executor.Run(HostCommand("systemctl", "daemon-reload")...); err != nil {
+		klog.Warningf("reloadSystemd:: systemctl daemon-reload error: %v", err)
+	}
+}
+
+func (np *NodeMountPersister) persistMountUnit(mount model.PersistedMount) error {
+	content, err := mountUnitContent(mount)
+	if err != nil {
+		return err
+	}
+	unit := MountUnitName(mount.Path)
+	path := filepath.Join(np.etcRoot, systemdUnitDir, unit)
+	existing, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		if _, owned := parseMountUnit(string(existing)); !owned {
+			return fmt.Errorf("mount unit %s of %s is not managed by node-resource-manager", unit, mount.Path)
+		}
+	}
+	if string(existing) != content {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			return err
+		}
+		klog.Infof("PersistMount:: persist %s in mount unit %s", mount.Path, unit)
+	}
+	// enable is idempotent and reloads systemd
+	if _, err := np.executor.Run(HostCommand("systemctl", "enable", unit)...); err != nil {
+		klog.Errorf("PersistMount:: enable mount unit %s error: %v", unit, err)
+		return err
+	}
+	return nil
+}
+
+// mountUnitContent returns the validated mount unit of mount, which is wanted by local-fs.target so a missing device does not fail boot
+func mountUnitContent(mount model.PersistedMount) (string, error) {
+	if err := validatePersistedMount(mount); err != nil {
+		return "", err
+	}
+	lines := []string{
+		persistMarker,
+		"[Unit]",
+		"Description=node-resource-manager quota path " + mount.Path,
+		"Before=kubelet.service",
+		"",
+		"[Mount]",
+		"What=" + fsUUIDPrefix + mount.UUID,
+		"Where=" + mount.Path,
+		"Type=" + mount.Fstype,
+	}
+	if mount.Options != "" {
+		lines = append(lines, "Options="+mount.Options)
+	}
+	lines = append(lines, "", "[Install]", "WantedBy=local-fs.target", "")
+	return strings.Join(lines, "\n"), nil
+}
+
+// MountUnitName returns the systemd mount unit name of mount path like systemd-escape --path, e.g. /mnt/path-1 -> mnt-path\x2d1.mount
+func MountUnitName(mountPath string) string {
+	var name strings.Builder
+	for i, c := range []byte(strings.Trim(mountPath, "/")) {
+		switch {
+		case c == '/':
+			name.WriteByte('-')
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == ':', c == '_', c == '.' && i > 0:
+			name.WriteByte(c)
+		default:
+			fmt.Fprintf(&name, `\x%02x`, c)
+		}
+	}
+	return name.String() + ".mount"
+}
+
+// RemovePersistedMount ...
+func (np *NodeMountPersister) RemovePersistedMount(mount model.PersistedMount) error {
+	if err := ValidateMountPath(mount.Path); err != nil {
+		return err
+	}
+	switch mount.Persistence {
+	case PersistenceFstab:
+		if err := rewriteFstab(filepath.Join(np.etcRoot, "fstab"), mount.Path, ""); err != nil {
+			return err
+		}
+		np.reloadSystemd()
+	case PersistenceSystemd:
+		unit := MountUnitName(mount.Path)
+		path := filepath.Join(np.etcRoot, systemdUnitDir, unit)
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if _, owned := parseMountUnit(string(content)); !owned {
+			return fmt.Errorf("mount unit %s of %s is not managed by node-resource-manager", unit, mount.Path)
+		}
+		// disabled before removed, as disable reads the install section of unit
+		if _, err := np.executor.Run(HostCommand("systemctl", "disable", unit)...); err != nil {
+			klog.Errorf("RemovePersistedMount:: disable mount unit %s error: %v", unit, err)
+			return err
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		np.reloadSystemd()
+	default:
+		return fmt.Errorf("invalid persistence %q of %s", mount.Persistence, mount.Path)
+	}
+	klog.Infof("RemovePersistedMount:: remove %s of %s", mount.Persistence, mount.Path)
+	return nil
+}
+
+// GetFsUUID ...
+func (np *NodeMountPersister) GetFsUUID(device string) (string, error) {
+	if err := ValidateDevicePath(device); err != nil {
+		return "", err
+	}
+	out, err := np.executor.Run(HostCommand("blkid", "-s", "UUID", "-o", "value", device)...)
+	if err != nil {
+		klog.Errorf("GetFsUUID:: blkid %s error: %v", device, err)
+		return "", err
+	}
+	uuid := strings.TrimSpace(out)
+	if !fsUUIDRegexp.MatchString(uuid) {
+		return "", fmt.Errorf("device %s has no filesystem uuid", device)
+	}
+	return uuid, nil
+}
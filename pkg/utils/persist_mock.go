@@ -0,0 +1,104 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package utils
+
+import (
+	"reflect"
+
+	"github.com/golang/mock/gomock"
+	"github.com/openyurtio/node-resource-manager/pkg/model"
+)
+
+// MockMountPersister ...
+type MockMountPersister struct {
+	ctrl     *gomock.Controller
+	recorder *MockMountPersisterMockRecorder
+}
+
+// MockMountPersisterMockRecorder ...
+type MockMountPersisterMockRecorder struct {
+	mock *MockMountPersister
+}
+
+// NewMockMountPersister ...
+func NewMockMountPersister(ctrl *gomock.Controller) *MockMountPersister {
+	mock := &MockMountPersister{ctrl: ctrl}
+	mock.recorder = &MockMountPersisterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT ...
+func (m *MockMountPersister) EXPECT() *MockMountPersisterMockRecorder {
+	return m.recorder
+}
+
+// ListPersistedMounts ...
+func (m *MockMountPersister) ListPersistedMounts() ([]model.PersistedMount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPersistedMounts")
+	ret0, _ := ret[0].([]model.PersistedMount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPersistedMounts ...
+func (mr *MockMountPersisterMockRecorder) ListPersistedMounts() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPersistedMounts", reflect.TypeOf((*MockMountPersister)(nil).ListPersistedMounts))
+}
+
+// PersistMount ...
+func (m *MockMountPersister) PersistMount(mount model.PersistedMount) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PersistMount", mount)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PersistMount ...
+func (mr *MockMountPersisterMockRecorder) PersistMount(mount interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PersistMount", reflect.TypeOf((*MockMountPersister)(nil).PersistMount), mount)
+}
+
+// RemovePersistedMount ...
+func (m *MockMountPersister) RemovePersistedMount(mount model.PersistedMount) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemovePersistedMount", mount)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemovePersistedMount ...
+func (mr *MockMountPersisterMockRecorder) RemovePersistedMount(mount interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemovePersistedMount", reflect.TypeOf((*MockMountPersister)(nil).RemovePersistedMount), mount)
+}
+
+// GetFsUUID ...
+func (m *MockMountPersister) GetFsUUID(device string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFsUUID", device)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFsUUID ...
+func (mr *MockMountPersisterMockRecorder) GetFsUUID(device interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFsUUID", reflect.TypeOf((*MockMountPersister)(nil).GetFsUUID), device)
+}
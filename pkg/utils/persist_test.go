@@ -0,0 +1,118 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openyurtio/node-resource-manager/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPersistFstab(t *testing.T) {
+	etcRoot := t.TempDir()
+	fstab := filepath.Join(etcRoot, "fstab")
+	assert.Nil(t, ioutil.WriteFile(fstab, []byte("# comment\nUUID=1111 / ext4 defaults 0 1\n/dev/vdf /data xfs defaults 0 0"), 0644))
+	executor := NewFakeExecutor()
+	executor.On("systemctl", "daemon-reload")
+	persister := NewNodeMountPersister(executor, etcRoot)
+	mount := model.PersistedMount{Persistence: PersistenceFstab, UUID: "2222", Path: "/mnt/path1", Fstype: "ext4", Options: "prjquota"}
+
+	assert.Nil(t, persister.PersistMount(mount))
+	// persisting again changes nothing
+	assert.Nil(t, persister.PersistMount(mount))
+	content, err := ioutil.ReadFile(fstab)
+	assert.Nil(t, err)
+	assert.Equal(t, "# comment\nUUID=1111 / ext4 defaults 0 1\n/dev/vdf /data xfs defaults 0 0\n"+
+		persistMarker+"\nUUID=2222 /mnt/path1 ext4 prjquota,nofail,x-systemd.before=kubelet.service 0 0\n", string(content))
+	mounts, err := persister.ListPersistedMounts()
+	assert.Nil(t, err)
+	assert.Equal(t, []model.PersistedMount{{Persistence: PersistenceFstab, UUID: "2222", Path: "/mnt/path1", Fstype: "ext4",
+		Options: "prjquota,nofail,x-systemd.before=kubelet.service"}}, mounts)
+
+	// a new filesystem replaces the entry
+	mount.UUID = "3333"
+	assert.Nil(t, persister.PersistMount(mount))
+	mounts, err = persister.ListPersistedMounts()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(mounts))
+	assert.Equal(t, "3333", mounts[0].UUID)
+
+	// entries not managed are never changed
+	assert.NotNil(t, persister.PersistMount(model.PersistedMount{Persistence: PersistenceFstab, UUID: "4444", Path: "/data", Fstype: "xfs"}))
+	assert.NotNil(t, persister.PersistMount(model.PersistedMount{Persistence: PersistenceFstab, UUID: "4444", Path: "/mnt/path 2", Fstype: "xfs"}))
+
+	assert.Nil(t, persister.RemovePersistedMount(mount))
+	content, err = ioutil.ReadFile(fstab)
+	assert.Nil(t, err)
+	assert.Equal(t, "# comment\nUUID=1111 / ext4 defaults 0 1\n/dev/vdf /data xfs defaults 0 0\n", string(content))
+	assert.Equal(t, 3, len(executor.Calls()))
+}
+
+func TestPersistMountUnit(t *testing.T) {
+	etcRoot := t.TempDir()
+	executor := NewFakeExecutor()
+	executor.On("systemctl", "...")
+	persister := NewNodeMountPersister(executor, etcRoot)
+	mount := model.PersistedMount{Persistence: PersistenceSystemd, UUID: "2222", Path: "/mnt/path-1", Fstype: "xfs", Options: "prjquota"}
+	unit := filepath.Join(etcRoot, "systemd/system", `mnt-path\x2d1.mount`)
+
+	assert.Nil(t, persister.PersistMount(mount))
+	content, err := ioutil.ReadFile(unit)
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), "What=/dev/disk/by-uuid/2222\nWhere=/mnt/path-1\nType=xfs\nOptions=prjquota\n")
+	mounts, err := persister.ListPersistedMounts()
+	assert.Nil(t, err)
+	assert.Equal(t, []model.PersistedMount{mount}, mounts)
+
+	assert.Nil(t, persister.RemovePersistedMount(mount))
+	_, err = os.Stat(unit)
+	assert.True(t, os.IsNotExist(err))
+	assert.Equal(t, [][]string{
+		{"systemctl", "enable", `mnt-path\x2d1.mount`},
+		{"systemctl", "disable", `mnt-path\x2d1.mount`},
+		{"systemctl", "daemon-reload"},
+	}, executor.Calls())
+
+	// unit not managed is never changed
+	assert.Nil(t, ioutil.WriteFile(unit, []byte("[Mount]\nWhere=/mnt/path-1\n"), 0644))
+	assert.NotNil(t, persister.PersistMount(mount))
+	assert.NotNil(t, persister.RemovePersistedMount(mount))
+	mounts, err = persister.ListPersistedMounts()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(mounts))
+}
+
+func TestMountUnitName(t *testing.T) {
+	assert.Equal(t, "mnt-path1.mount", MountUnitName("/mnt/path1"))
+	assert.Equal(t, `mnt-a\x2bb-.c\x40d.mount`, MountUnitName("/mnt/a+b/.c@d"))
+	assert.Equal(t, `\x2emnt.mount`, MountUnitName("/.mnt"))
+}
+
+func TestGetFsUUID(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("blkid", "-s", "UUID", "-o", "value", "/dev/vdb").Return("6f0e6f7c-3ab2-4c8e-9d3a-0f1b2c3d4e5f\n")
+	executor.On("blkid", "...")
+	persister := NewNodeMountPersister(executor, t.TempDir())
+	uuid, err := persister.GetFsUUID("/dev/vdb")
+	assert.Nil(t, err)
+	assert.Equal(t, "6f0e6f7c-3ab2-4c8e-9d3a-0f1b2c3d4e5f", uuid)
+	_, err = persister.GetFsUUID("/dev/vdc")
+	assert.NotNil(t, err)
+}
@@ -19,7 +19,6 @@ package utils
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
 	"strings"
 
 	"github.com/openyurtio/node-resource-manager/pkg/model"
@@ -34,22 +33,23 @@ type Pmemer interface {
 	GetPmemNamespaceDeivcePath(string, string) (string, string, error)
 	MakeNamespaceMemory(chardev string) error
 	CheckKMEMCreated(chardev string) (bool, error)
+	ListDaxMemory() ([]*model.DaxctrlMem, error)
 }
 
 // NodePmemer ...
 type NodePmemer struct {
+	executor CommandExecutor
 }
 
 // NewNodePmemer create new NodePmemer struct
-func NewNodePmemer() *NodePmemer {
-	return &NodePmemer{}
+func NewNodePmemer(executor CommandExecutor) *NodePmemer {
+	return &NodePmemer{executor: executor}
 }
 
 // GetRegions ...
 func (np *NodePmemer) GetRegions() (*model.PmemRegions, error) {
 	regions := &model.PmemRegions{}
-	getRegionCmd := fmt.Sprintf("%s ndctl list -RN", NsenterCmd)
-	regionOut, err := Run(getRegionCmd)
+	regionOut, err := np.executor.Run(HostCommand("ndctl", "list", "-RN")...)
 	if err != nil {
 		return regions, err
 	}
@@ -71,13 +71,11 @@ func (np *NodePmemer) GetRegions() (*model.PmemRegions, error) {
 
 // CreateNamespace ...
 func (np *NodePmemer) CreateNamespace(region, pmemType string) error {
-	var createCmd string
-	if pmemType == "lvm" {
-		createCmd = fmt.Sprintf("%s ndctl create-namespace -r %s", NsenterCmd, region)
-	} else {
-		createCmd = fmt.Sprintf("%s ndctl create-namespace -r %s --mode=devdax", NsenterCmd, region)
+	args, err := createNamespaceArgs(region, pmemType)
+	if err != nil {
+		return err
 	}
-	_, err := Run(createCmd)
+	_, err = np.executor.Run(HostCommand(args...)...)
 	if err != nil {
 		klog.Errorf("Create NameSpace for region %s error: %v", region, err)
 		return err
@@ -88,14 +86,18 @@ func (np *NodePmemer) CreateNamespace(region, pmemType string) error {
 
 // CheckNamespaceUsed device used in block
 func (np *NodePmemer) CheckNamespaceUsed(devicePath string) bool {
-	pvCheckCmd := fmt.Sprintf("%s pvs %s 2>&1 | grep -v \"Failed to \" | grep /dev | awk '{print $2}' | wc -l", NsenterCmd, devicePath)
-	out, err := Run(pvCheckCmd)
-	if err == nil && strings.TrimSpace(out) != "0" {
+	if err := ValidateDevicePath(devicePath); err != nil {
+		klog.Errorf("CheckNamespaceUsed: %v", err)
+		return true
+	}
+	// pvs fails if device is not a physical volume
+	out, err := np.executor.Run(HostCommand("pvs", "--noheadings", "-o", "pv_name", devicePath)...)
+	if err == nil && strings.TrimSpace(out) != "" {
 		klog.Infof("CheckNamespaceUsed: NameSpace %s used for pv", devicePath)
 		return true
 	}
 
-	out, err = checkFSType(devicePath)
+	out, err = checkFSType(np.executor, devicePath)
 	if err == nil && strings.TrimSpace(out) != "" {
 		klog.Infof("CheckNamespaceUsed: NameSpace %s format as %s", devicePath, out)
 		return true
@@ -123,9 +125,10 @@ func (np *NodePmemer) GetPmemNamespaceDeivcePath(region, mode string) (devicePat
 }
 
 func (np *NodePmemer) getRegionNamespaceInfo(region string) (*model.PmemRegions, error) {
-	listCmd := fmt.Sprintf("%s ndctl list -RN -r %s", NsenterCmd, region)
-
-	out, err := Run(listCmd)
+	if err := ValidateRegion(region); err != nil {
+		return nil, err
+	}
+	out, err := np.executor.Run(HostCommand("ndctl", "list", "-RN", "-r", region)...)
 	if err != nil {
 		klog.Errorf("List NameSpace for region %s error: %v", region, err)
 		return nil, err
@@ -150,24 +153,21 @@ func (np *NodePmemer) getRegionNamespaceInfo(region string) (*model.PmemRegions,
 
 // MakeNamespaceMemory ...
 func (np *NodePmemer) MakeNamespaceMemory(chardev string) error {
-	makeCmd := fmt.Sprintf("%s daxctl reconfigure-device -m system-ram %s", NsenterCmd, chardev)
-	_, err := Run(makeCmd)
+	args, err := makeNamespaceMemoryArgs(chardev)
+	if err != nil {
+		return err
+	}
+	_, err = np.executor.Run(HostCommand(args...)...)
 	return err
 }
 
 // CheckKMEMCreated ...
 func (np *NodePmemer) CheckKMEMCreated(chardev string) (bool, error) {
-	listCmd := fmt.Sprintf("%s daxctl list", NsenterCmd)
-	out, err := Run(listCmd)
+	memList, err := np.ListDaxMemory()
 	if err != nil {
 		klog.Errorf("CheckKMEMCreated:: List daxctl error: %v", err)
 		return false, err
 	}
-	memList := []*model.DaxctrlMem{}
-	err = json.Unmarshal(([]byte)(out), &memList)
-	if err != nil {
-		return false, err
-	}
 	for _, mem := range memList {
 		if mem.Chardev == chardev && mem.Mode == "system-ram" {
 			return true, nil
@@ -175,3 +175,39 @@ func (np *NodePmemer) CheckKMEMCreated(chardev string) (bool, error) {
 	}
 	return false, nil
 }
+
+// ListDaxMemory list all dax devices on node
+func (np *NodePmemer) ListDaxMemory() ([]*model.DaxctrlMem, error) {
+	out, err := np.executor.Run(HostCommand("daxctl", "list")...)
+	if err != nil {
+		return nil, err
+	}
+	memList := []*model.DaxctrlMem{}
+	if strings.TrimSpace(out) == "" {
+		return memList, nil
+	}
+	err = json.Unmarshal(([]byte)(out), &memList)
+	if err != nil {
+		return nil, err
+	}
+	return memList, nil
+}
+
+// createNamespaceArgs validates inputs and returns the ndctl args
+func createNamespaceArgs(region, pmemType string) ([]string, error) {
+	if err := ValidateRegion(region); err != nil {
+		return nil, err
+	}
+	if pmemType == "lvm" {
+		return []string{"ndctl", "create-namespace", "-r", region}, nil
+	}
+	return []string{"ndctl", "create-namespace", "-r", region, "--mode=devdax"}, nil
+}
+
+// makeNamespaceMemoryArgs validates inputs and returns the daxctl args
+func makeNamespaceMemoryArgs(chardev string) ([]string, error) {
+	if err := ValidateDaxChardev(chardev); err != nil {
+		return nil, err
+	}
+	return []string{"daxctl", "reconfigure-device", "-m", "system-ram", chardev}, nil
+}
@@ -133,3 +133,18 @@ func (mr *MockPmemerMockRecorder) MakeNamespaceMemory(arg1 interface{}) *gomock.
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MakeNamespaceMemory", reflect.TypeOf((*MockPmemer)(nil).MakeNamespaceMemory), arg1)
 }
+
+// ListDaxMemory ...
+func (m *MockPmemer) ListDaxMemory() ([]*model.DaxctrlMem, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListDaxMemory")
+	ret0, _ := ret[0].([]*model.DaxctrlMem)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListDaxMemory ...
+func (mr *MockPmemerMockRecorder) ListDaxMemory() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListDaxMemory", reflect.TypeOf((*MockPmemer)(nil).ListDaxMemory))
+}
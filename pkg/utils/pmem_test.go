@@ -0,0 +1,89 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/openyurtio/node-resource-manager/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRegions(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("ndctl", "list", "-RN").Return(readTestData(t, "ndctl-list.json"))
+	regions, err := NewNodePmemer(executor).GetRegions()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(regions.Regions))
+	assert.Equal(t, "region0", regions.Regions[0].Dev)
+	assert.Equal(t, int64(133175443456), regions.Regions[0].Size)
+	assert.Equal(t, []model.PmemNameSpace{{
+		Dev:      "namespace0.0",
+		Mode:     "fsdax",
+		MapType:  "dev",
+		Size:     131128721408,
+		UUID:     "b0ddd1de-4f0a-4b27-9b19-54ddac9d4a9f",
+		Align:    2097152,
+		BlockDev: "pmem0",
+	}}, regions.Regions[0].Namespaces)
+	assert.Equal(t, "region1", regions.Regions[1].Dev)
+	assert.Equal(t, int64(133175443456), regions.Regions[1].AvailableSize)
+	assert.Equal(t, 0, len(regions.Regions[1].Namespaces))
+}
+
+func TestListDaxMemory(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("daxctl", "list").Return(readTestData(t, "daxctl-list.json"))
+	pmemer := NewNodePmemer(executor)
+	mems, err := pmemer.ListDaxMemory()
+	assert.Nil(t, err)
+	assert.Equal(t, []*model.DaxctrlMem{{Chardev: "dax1.0", Size: 132118478848, TargetNode: 3, Mode: "system-ram"}}, mems)
+
+	created, err := pmemer.CheckKMEMCreated("dax1.0")
+	assert.Nil(t, err)
+	assert.True(t, created)
+	created, err = pmemer.CheckKMEMCreated("dax0.0")
+	assert.Nil(t, err)
+	assert.False(t, created)
+}
+
+func TestCreateNamespace(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("ndctl", "create-namespace", "...")
+	pmemer := NewNodePmemer(executor)
+	assert.Nil(t, pmemer.CreateNamespace("region0", "lvm"))
+	assert.Nil(t, pmemer.CreateNamespace("region1", "kmem"))
+	assert.NotNil(t, pmemer.CreateNamespace("region1 --force", "kmem"))
+	assert.Equal(t, [][]string{
+		{"ndctl", "create-namespace", "-r", "region0"},
+		{"ndctl", "create-namespace", "-r", "region1", "--mode=devdax"},
+	}, executor.Calls())
+}
+
+func TestCheckNamespaceUsed(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("pvs", "--noheadings", "-o", "pv_name", "/dev/pmem0").Return("  /dev/pmem0\n")
+	executor.On("pvs", "...").Fail("  Failed to find physical volume", errors.New("exit status 5"))
+	executor.On("file", "-bsL", "/dev/pmem1").Return("Linux rev 1.0 ext4 filesystem data\n")
+	executor.On("blkid", "-c", "/dev/null", "-o", "export", "/dev/pmem1").Return("DEVNAME=/dev/pmem1\nTYPE=ext4\n")
+	executor.On("file", "-bsL", "/dev/pmem2").Return("data\n")
+	pmemer := NewNodePmemer(executor)
+	assert.True(t, pmemer.CheckNamespaceUsed("/dev/pmem0"))
+	assert.True(t, pmemer.CheckNamespaceUsed("/dev/pmem1"))
+	assert.False(t, pmemer.CheckNamespaceUsed("/dev/pmem2"))
+}
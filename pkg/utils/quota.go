@@ -0,0 +1,369 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/openyurtio/node-resource-manager/pkg/model"
+	klog "k8s.io/klog/v2"
+)
+
+const (
+	// DefaultEtcRoot is the host /etc mounted in container
+	DefaultEtcRoot = "/host/etc"
+	// fstypeXfs is the only filesystem managing project quota by xfs_quota, others are ext4 like
+	fstypeXfs = "xfs"
+)
+
+var (
+	// projectNameRegexp matches the project name in projid file
+	projectNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+	// graceRegexp matches the warning and grace column of xfs_quota report, e.g. [--------], [6 days]
+	graceRegexp = regexp.MustCompile(`\[[^\]]*\]`)
+)
+
+// Quotaer manages the project quota of directories in quota path
+type Quotaer interface {
+	// ListProjects returns the projects in projects and projid files, sorted by id
+	ListProjects() ([]model.Project, error)
+	// AddProject appends project to projects and projid files if missing
+	AddProject(project model.Project) error
+	// SetProject sets the project id of directory of project and all files in it
+	SetProject(fstype, mountPath string, project model.Project) error
+	// SetProjectLimits sets the block and inode limits of project id in quota
+	SetProjectLimits(fstype, mountPath string, quota model.ProjectQuota) error
+	// ListProjectQuotas returns the usage and limits of every project on filesystem mounted at mountPath
+	ListProjectQuotas(fstype, mountPath string) ([]model.ProjectQuota, error)
+}
+
+// NodeQuotaer runs quota commands on host, and keeps projects in etcRoot
+type NodeQuotaer struct {
+	executor CommandExecutor
+	etcRoot  string
+}
+
+// NewQuotaer create Quotaer keeping projects in host /etc
+func NewQuotaer(executor CommandExecutor) *NodeQuotaer {
+	return NewNodeQuotaer(executor, DefaultEtcRoot)
+}
+
+// NewNodeQuotaer ...
+func NewNodeQuotaer(executor CommandExecutor, etcRoot string) *NodeQuotaer {
+	return &NodeQuotaer{executor: executor, etcRoot: etcRoot}
+}
+
+// ListProjects a project only in one of the files is listed with empty name or path
+func (nq *NodeQuotaer) ListProjects() ([]model.Project, error) {
+	projects := map[uint32]*model.Project{}
+	get := func(id uint32) *model.Project {
+		if _, ok := projects[id]; !ok {
+			projects[id] = &model.Project{ID: id}
+		}
+		return projects[id]
+	}
+	// projects lines are id:path
+	err := readProjectFile(filepath.Join(nq.etcRoot, "projects"), func(id, path string) {
+		if projectID, err := strconv.ParseUint(id, 10, 32); err == nil {
+			get(uint32(projectID)).Path = path
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	// projid lines are name:id
+	err = readProjectFile(filepath.Join(nq.etcRoot, "projid"), func(name, id string) {
+		if projectID, err := strconv.ParseUint(id, 10, 32); err == nil {
+			get(uint32(projectID)).Name = name
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]model.Project, 0, len(projects))
+	for _, project := range projects {
+		result = append(result, *project)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result, nil
+}
+
+// readProjectFile calls parse with the two fields of every line, a missing file has no lines
+func readProjectFile(path string, parse func(string, string)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, ":", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		parse(fields[0], fields[1])
+	}
+	return scanner.Err()
+}
+
+// AddProject ...
+func (nq *NodeQuotaer) AddProject(project model.Project) error {
+	projectsLine, projidLine, err := projectLines(project)
+	if err != nil {
+		return err
+	}
+	if err := appendLine(filepath.Join(nq.etcRoot, "projects"), projectsLine); err != nil {
+		return err
+	}
+	if err := appendLine(filepath.Join(nq.etcRoot, "projid"), projidLine); err != nil {
+		return err
+	}
+	klog.Infof("AddProject:: add project %s with id %d for %s", project.Name, project.ID, project.Path)
+	return nil
+}
+
+// projectLines returns the validated lines of project in projects and projid files
+func projectLines(project model.Project) (string, string, error) {
+	if project.ID == 0 {
+		return "", "", fmt.Errorf("invalid project id 0 of %s", project.Path)
+	}
+	if err := ValidateMountPath(project.Path); err != nil {
+		return "", "", err
+	}
+	if !projectNameRegexp.MatchString(project.Name) {
+		return "", "", fmt.Errorf("invalid project name %q", project.Name)
+	}
+	return fmt.Sprintf("%d:%s", project.ID, project.Path), fmt.Sprintf("%s:%d", project.Name, project.ID), nil
+}
+
+// appendLine appends line to file if it is not in file, the file is created if missing
+func appendLine(path, line string) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, existing := range strings.Split(string(content), "\n") {
+		if strings.TrimSpace(existing) == line {
+			return nil
+		}
+	}
+	if len(content) > 0 && !strings.HasSuffix(string(content), "\n") {
+		line = "\n" + line
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = file.WriteString(line + "\n"); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// SetProject ...
+func (nq *NodeQuotaer) SetProject(fstype, mountPath string, project model.Project) error {
+	args, err := setProjectArgs(fstype, mountPath, project)
+	if err != nil {
+		return err
+	}
+	if _, err = nq.executor.Run(HostCommand(args...)...); err != nil {
+		klog.Errorf("SetProject:: set project %d of %s error: %v", project.ID, project.Path, err)
+		return err
+	}
+	return nil
+}
+
+// setProjectArgs returns the validated command to set project id of directory recursively
+func setProjectArgs(fstype, mountPath string, project model.Project) ([]string, error) {
+	if project.ID == 0 {
+		return nil, fmt.Errorf("invalid project id 0 of %s", project.Path)
+	}
+	if err := ValidateMountPath(mountPath); err != nil {
+		return nil, err
+	}
+	if err := ValidateMountPath(project.Path); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(project.Path, mountPath+"/") {
+		return nil, fmt.Errorf("project path %s is not in %s", project.Path, mountPath)
+	}
+	id := strconv.FormatUint(uint64(project.ID), 10)
+	switch fstype {
+	case "", "ext4":
+		return []string{"chattr", "-R", "+P", "-p", id, project.Path}, nil
+	case fstypeXfs:
+		return []string{"xfs_quota", "-x", "-c", fmt.Sprintf("project -s -p %s %s", project.Path, id), mountPath}, nil
+	default:
+		return nil, fmt.Errorf("project quota is not supported on %s", fstype)
+	}
+}
+
+// SetProjectLimits ...
+func (nq *NodeQuotaer) SetProjectLimits(fstype, mountPath string, quota model.ProjectQuota) error {
+	args, err := setProjectLimitsArgs(fstype, mountPath, quota)
+	if err != nil {
+		return err
+	}
+	if _, err = nq.executor.Run(HostCommand(args...)...); err != nil {
+		klog.Errorf("SetProjectLimits:: set limits of project %d on %s error: %v", quota.ID, mountPath, err)
+		return err
+	}
+	return nil
+}
+
+// setProjectLimitsArgs returns the validated command to set limits, block limits are rounded up to KiB
+func setProjectLimitsArgs(fstype, mountPath string, quota model.ProjectQuota) ([]string, error) {
+	if quota.ID == 0 {
+		return nil, fmt.Errorf("invalid project id 0 on %s", mountPath)
+	}
+	if err := ValidateMountPath(mountPath); err != nil {
+		return nil, err
+	}
+	id := strconv.FormatUint(uint64(quota.ID), 10)
+	blockSoft, blockHard := kibibytes(quota.BlockSoftLimit), kibibytes(quota.BlockHardLimit)
+	switch fstype {
+	case "", "ext4":
+		return []string{"setquota", "-P", id, strconv.FormatUint(blockSoft, 10), strconv.FormatUint(blockHard, 10),
+			strconv.FormatUint(quota.InodeSoftLimit, 10), strconv.FormatUint(quota.InodeHardLimit, 10), mountPath}, nil
+	case fstypeXfs:
+		limit := fmt.Sprintf("limit -p bsoft=%dk bhard=%dk isoft=%d ihard=%d %s",
+			blockSoft, blockHard, quota.InodeSoftLimit, quota.InodeHardLimit, id)
+		return []string{"xfs_quota", "-x", "-c", limit, mountPath}, nil
+	default:
+		return nil, fmt.Errorf("project quota is not supported on %s", fstype)
+	}
+}
+
+// kibibytes rounds bytes up to KiB
+func kibibytes(size uint64) uint64 {
+	return (size + 1023) / 1024
+}
+
+// ListProjectQuotas ...
+func (nq *NodeQuotaer) ListProjectQuotas(fstype, mountPath string) ([]model.ProjectQuota, error) {
+	args, err := listProjectQuotasArgs(fstype, mountPath)
+	if err != nil {
+		return nil, err
+	}
+	out, err := nq.executor.Run(HostCommand(args...)...)
+	if err != nil {
+		klog.Errorf("ListProjectQuotas:: report project quota of %s error: %v", mountPath, err)
+		return nil, err
+	}
+	if fstype == fstypeXfs {
+		return parseXfsQuotaReport(out)
+	}
+	return parseRepquota(out)
+}
+
+// listProjectQuotasArgs returns the validated command to report project quota with numeric ids
+func listProjectQuotasArgs(fstype, mountPath string) ([]string, error) {
+	if err := ValidateMountPath(mountPath); err != nil {
+		return nil, err
+	}
+	switch fstype {
+	case "", "ext4":
+		return []string{"repquota", "-P", "-n", mountPath}, nil
+	case fstypeXfs:
+		return []string{"xfs_quota", "-x", "-c", "report -p -n -b -i -N", mountPath}, nil
+	default:
+		return nil, fmt.Errorf("project quota is not supported on %s", fstype)
+	}
+}
+
+// parseRepquota parses the rows of repquota, e.g.
+// #10000    +-    2048    1024    4096  6days       3       0       0
+// the grace column is only shown if the soft limit is exceeded, marked by + in flags
+func parseRepquota(out string) ([]model.ProjectQuota, error) {
+	quotas := []model.ProjectQuota{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 8 || !strings.HasPrefix(fields[0], "#") || len(fields[1]) != 2 {
+			continue
+		}
+		values := []string{fields[0], fields[2], fields[3], fields[4]}
+		rest := fields[5:]
+		if fields[1][0] == '+' {
+			rest = rest[1:]
+		}
+		if len(rest) < 3 {
+			return nil, fmt.Errorf("parseRepquota:: invalid line %q", line)
+		}
+		quota, err := parseQuotaValues(append(values, rest[:3]...))
+		if err != nil {
+			return nil, fmt.Errorf("parseRepquota:: invalid line %q: %v", line, err)
+		}
+		quotas = append(quotas, quota)
+	}
+	return quotas, nil
+}
+
+// parseXfsQuotaReport parses the rows of xfs_quota report, e.g.
+// #10000   2048   1024   4096  00 [--------]   3   0   0  00 [--------]
+func parseXfsQuotaReport(out string) ([]model.ProjectQuota, error) {
+	quotas := []model.ProjectQuota{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(graceRegexp.ReplaceAllString(line, ""))
+		if len(fields) == 0 || !strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) != 9 {
+			return nil, fmt.Errorf("parseXfsQuotaReport:: invalid line %q", line)
+		}
+		quota, err := parseQuotaValues([]string{fields[0], fields[1], fields[2], fields[3], fields[5], fields[6], fields[7]})
+		if err != nil {
+			return nil, fmt.Errorf("parseXfsQuotaReport:: invalid line %q: %v", line, err)
+		}
+		quotas = append(quotas, quota)
+	}
+	return quotas, nil
+}
+
+// parseQuotaValues parses #id, block used, soft, hard in KiB and inode used, soft, hard
+func parseQuotaValues(values []string) (model.ProjectQuota, error) {
+	quota := model.ProjectQuota{}
+	id, err := strconv.ParseUint(strings.TrimPrefix(values[0], "#"), 10, 32)
+	if err != nil {
+		return quota, err
+	}
+	quota.ID = uint32(id)
+	numbers := make([]uint64, 6)
+	for i, value := range values[1:] {
+		if numbers[i], err = strconv.ParseUint(value, 10, 64); err != nil {
+			return quota, err
+		}
+	}
+	quota.BlockUsed, quota.BlockSoftLimit, quota.BlockHardLimit = numbers[0]*1024, numbers[1]*1024, numbers[2]*1024
+	quota.InodeUsed, quota.InodeSoftLimit, quota.InodeHardLimit = numbers[3], numbers[4], numbers[5]
+	return quota, nil
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"reflect"
+
+	"github.com/golang/mock/gomock"
+	"github.com/openyurtio/node-resource-manager/pkg/model"
+)
+
+// MockQuotaer ...
+type MockQuotaer struct {
+	ctrl     *gomock.Controller
+	recorder *MockQuotaerMockRecorder
+}
+
+// MockQuotaerMockRecorder ...
+type MockQuotaerMockRecorder struct {
+	mock *MockQuotaer
+}
+
+// NewMockQuotaer ...
+func NewMockQuotaer(ctrl *gomock.Controller) *MockQuotaer {
+	mock := &MockQuotaer{ctrl: ctrl}
+	mock.recorder = &MockQuotaerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT ...
+func (m *MockQuotaer) EXPECT() *MockQuotaerMockRecorder {
+	return m.recorder
+}
+
+// ListProjects ...
+func (m *MockQuotaer) ListProjects() ([]model.Project, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListProjects")
+	ret0, _ := ret[0].([]model.Project)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListProjects ...
+func (mr *MockQuotaerMockRecorder) ListProjects() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListProjects", reflect.TypeOf((*MockQuotaer)(nil).ListProjects))
+}
+
+// AddProject ...
+func (m *MockQuotaer) AddProject(project model.Project) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddProject", project)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddProject ...
+func (mr *MockQuotaerMockRecorder) AddProject(project interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddProject", reflect.TypeOf((*MockQuotaer)(nil).AddProject), project)
+}
+
+// SetProject ...
+func (m *MockQuotaer) SetProject(fstype, mountPath string, project model.Project) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetProject", fstype, mountPath, project)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetProject ...
+func (mr *MockQuotaerMockRecorder) SetProject(fstype, mountPath, project interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetProject", reflect.TypeOf((*MockQuotaer)(nil).SetProject), fstype, mountPath, project)
+}
+
+// SetProjectLimits ...
+func (m *MockQuotaer) SetProjectLimits(fstype, mountPath string, quota model.ProjectQuota) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetProjectLimits", fstype, mountPath, quota)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetProjectLimits ...
+func (mr *MockQuotaerMockRecorder) SetProjectLimits(fstype, mountPath, quota interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetProjectLimits", reflect.TypeOf((*MockQuotaer)(nil).SetProjectLimits), fstype, mountPath, quota)
+}
+
+// ListProjectQuotas ...
+func (m *MockQuotaer) ListProjectQuotas(fstype, mountPath string) ([]model.ProjectQuota, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListProjectQuotas", fstype, mountPath)
+	ret0, _ := ret[0].([]model.ProjectQuota)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListProjectQuotas ...
+func (mr *MockQuotaerMockRecorder) ListProjectQuotas(fstype, mountPath interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListProjectQuotas", reflect.TypeOf((*MockQuotaer)(nil).ListProjectQuotas), fstype, mountPath)
+}
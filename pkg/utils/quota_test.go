@@ -0,0 +1,103 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/openyurtio/node-resource-manager/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListProjectQuotas(t *testing.T) {
+	expect := []model.ProjectQuota{
+		{ID: 0, BlockUsed: 20 * 1024, InodeUsed: 2},
+		{ID: 10000, BlockUsed: 4096, BlockHardLimit: 1 << 30, InodeUsed: 1, InodeHardLimit: 1000},
+		{ID: 10001, BlockUsed: 2 << 20, BlockSoftLimit: 1 << 20, BlockHardLimit: 4 << 20, InodeUsed: 3},
+	}
+	executor := NewFakeExecutor()
+	executor.On("repquota", "-P", "-n", "/mnt/path1").Return(readTestData(t, "repquota.txt"))
+	executor.On("xfs_quota", "-x", "-c", "report -p -n -b -i -N", "/mnt/path2").Return(readTestData(t, "xfs-quota-report.txt"))
+	quotaer := NewNodeQuotaer(executor, t.TempDir())
+
+	quotas, err := quotaer.ListProjectQuotas("ext4", "/mnt/path1")
+	assert.Nil(t, err)
+	assert.Equal(t, expect, quotas)
+	quotas, err = quotaer.ListProjectQuotas("xfs", "/mnt/path2")
+	assert.Nil(t, err)
+	expect[0].BlockUsed = 0
+	expect[0].InodeUsed = 3
+	assert.Equal(t, expect, quotas)
+
+	_, err = quotaer.ListProjectQuotas("btrfs", "/mnt/path1")
+	assert.NotNil(t, err)
+}
+
+func TestSetProject(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("chattr", "...")
+	executor.On("setquota", "...")
+	executor.On("xfs_quota", "...")
+	quotaer := NewNodeQuotaer(executor, t.TempDir())
+	project := model.Project{ID: 10000, Name: "nrm_mnt_path1_app1", Path: "/mnt/path1/app1"}
+	quota := model.ProjectQuota{ID: 10000, BlockSoftLimit: 1000, BlockHardLimit: 1 << 30, InodeHardLimit: 1000}
+
+	assert.Nil(t, quotaer.SetProject("ext4", "/mnt/path1", project))
+	assert.Nil(t, quotaer.SetProjectLimits("ext4", "/mnt/path1", quota))
+	assert.Nil(t, quotaer.SetProject("xfs", "/mnt/path1", project))
+	assert.Nil(t, quotaer.SetProjectLimits("xfs", "/mnt/path1", quota))
+	assert.Equal(t, [][]string{
+		{"chattr", "-R", "+P", "-p", "10000", "/mnt/path1/app1"},
+		{"setquota", "-P", "10000", "1", "1048576", "0", "1000", "/mnt/path1"},
+		{"xfs_quota", "-x", "-c", "project -s -p /mnt/path1/app1 10000", "/mnt/path1"},
+		{"xfs_quota", "-x", "-c", "limit -p bsoft=1k bhard=1048576k isoft=0 ihard=1000 10000", "/mnt/path1"},
+	}, executor.Calls())
+
+	// directory must be in mount path
+	assert.NotNil(t, quotaer.SetProject("ext4", "/mnt/path2", project))
+	assert.NotNil(t, quotaer.SetProject("ext4", "/mnt/path1", model.Project{ID: 10000, Path: "/mnt/path1/app 1"}))
+	assert.NotNil(t, quotaer.SetProjectLimits("ext4", "/mnt/path1", model.ProjectQuota{}))
+	assert.Equal(t, 4, len(executor.Calls()))
+}
+
+func TestProjects(t *testing.T) {
+	etcRoot := t.TempDir()
+	// existing file without trailing newline is kept
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(etcRoot, "projects"), []byte("# comment\n42:/data/logs"), 0644))
+	quotaer := NewNodeQuotaer(NewFakeExecutor(), etcRoot)
+	projects, err := quotaer.ListProjects()
+	assert.Nil(t, err)
+	assert.Equal(t, []model.Project{{ID: 42, Path: "/data/logs"}}, projects)
+
+	project := model.Project{ID: 10000, Name: "nrm_mnt_path1_app1", Path: "/mnt/path1/app1"}
+	assert.Nil(t, quotaer.AddProject(project))
+	// adding again changes nothing
+	assert.Nil(t, quotaer.AddProject(project))
+	projects, err = quotaer.ListProjects()
+	assert.Nil(t, err)
+	assert.Equal(t, []model.Project{{ID: 42, Path: "/data/logs"}, project}, projects)
+	content, err := ioutil.ReadFile(filepath.Join(etcRoot, "projects"))
+	assert.Nil(t, err)
+	assert.Equal(t, "# comment\n42:/data/logs\n10000:/mnt/path1/app1\n", string(content))
+	content, err = ioutil.ReadFile(filepath.Join(etcRoot, "projid"))
+	assert.Nil(t, err)
+	assert.Equal(t, "nrm_mnt_path1_app1:10000\n", string(content))
+
+	assert.NotNil(t, quotaer.AddProject(model.Project{ID: 10001, Name: "bad:name", Path: "/mnt/path1/app2"}))
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/openyurtio/node-resource-manager/pkg/model"
+	klog "k8s.io/klog/v2"
+)
+
+var (
+	// raidLevelRegexp matches the md raid level supported, e.g. raid0
+	raidLevelRegexp = regexp.MustCompile(`^raid[01]$`)
+)
+
+// Raider manages md software raid arrays
+type Raider interface {
+	// ListArrays returns the md arrays on node
+	ListArrays() ([]*model.RaidArray, error)
+	// CreateArray creates md array /dev/md/name of level, e.g. raid0, on devices
+	CreateArray(name, level string, devices []string) (string, error)
+}
+
+// NodeRaider runs mdadm on host
+type NodeRaider struct {
+	executor CommandExecutor
+}
+
+// NewNodeRaider ...
+func NewNodeRaider(executor CommandExecutor) *NodeRaider {
+	return &NodeRaider{executor: executor}
+}
+
+// ListArrays ...
+func (nr *NodeRaider) ListArrays() ([]*model.RaidArray, error) {
+	out, err := nr.executor.Run(HostCommand("mdadm", "--detail", "--scan", "--verbose")...)
+	if err != nil {
+		klog.Errorf("ListArrays:: mdadm scan error: %v", err)
+		return nil, err
+	}
+	return parseMdadmScan(out), nil
+}
+
+// parseMdadmScan parses the arrays of mdadm --detail --scan --verbose, e.g.
+// ARRAY /dev/md/data level=raid1 num-devices=2 metadata=1.2 name=host1:data UUID=3aa5...
+//
+//	devices=/dev/vdb,/dev/vdc
+func parseMdadmScan(out string) []*model.RaidArray {
+	arrays := []*model.RaidArray{}
+	var array *model.RaidArray
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "ARRAY" && len(fields) > 1 {
+			array = &model.RaidArray{Path: fields[1], Name: filepath.Base(fields[1])}
+			arrays = append(arrays, array)
+			fields = fields[2:]
+		} else if array == nil {
+			continue
+		}
+		for _, field := range fields {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			switch kv[0] {
+			case "level":
+				array.Level = kv[1]
+			case "name":
+				// name is prefixed by the homehost
+				array.Name = kv[1][strings.LastIndex(kv[1], ":")+1:]
+			case "devices":
+				array.Devices = strings.Split(kv[1], ",")
+			}
+		}
+	}
+	return arrays
+}
+
+// CreateArray ...
+func (nr *NodeRaider) CreateArray(name, level string, devices []string) (string, error) {
+	args, err := createArrayArgs(name, level, devices)
+	if err != nil {
+		return "", err
+	}
+	out, err := nr.executor.Run(HostCommand(args...)...)
+	if err != nil {
+		klog.Errorf("CreateArray:: create %s array %s on %v error: %v", level, name, devices, err)
+		return "", err
+	}
+	return out, nil
+}
+
+// createArrayArgs returns the validated command to create md array, mdadm is run without prompt
+func createArrayArgs(name, level string, devices []string) ([]string, error) {
+	if err := ValidateRaidName(name); err != nil {
+		return nil, err
+	}
+	if !raidLevelRegexp.MatchString(level) {
+		return nil, fmt.Errorf("invalid raid level %q", level)
+	}
+	if len(devices) < 2 {
+		return nil, fmt.Errorf("%s needs at least 2 devices, got %v", level, devices)
+	}
+	if err := ValidateDevicePaths(devices); err != nil {
+		return nil, err
+	}
+	args := []string{"mdadm", "--create", "/dev/md/" + name, "--run", "--metadata=1.2", "--name=" + name,
+		"--level=" + level, "--raid-devices=" + strconv.Itoa(len(devices))}
+	return append(args, devices...), nil
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"reflect"
+
+	"github.com/golang/mock/gomock"
+	"github.com/openyurtio/node-resource-manager/pkg/model"
+)
+
+// MockRaider ...
+type MockRaider struct {
+	ctrl     *gomock.Controller
+	recorder *MockRaiderMockRecorder
+}
+
+// MockRaiderMockRecorder ...
+type MockRaiderMockRecorder struct {
+	mock *MockRaider
+}
+
+// NewMockRaider ...
+func NewMockRaider(ctrl *gomock.Controller) *MockRaider {
+	mock := &MockRaider{ctrl: ctrl}
+	mock.recorder = &MockRaiderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT ...
+func (m *MockRaider) EXPECT() *MockRaiderMockRecorder {
+	return m.recorder
+}
+
+// ListArrays ...
+func (m *MockRaider) ListArrays() ([]*model.RaidArray, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListArrays")
+	ret0, _ := ret[0].([]*model.RaidArray)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListArrays ...
+func (mr *MockRaiderMockRecorder) ListArrays() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListArrays", reflect.TypeOf((*MockRaider)(nil).ListArrays))
+}
+
+// CreateArray ...
+func (m *MockRaider) CreateArray(name, level string, devices []string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateArray", name, level, devices)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateArray ...
+func (mr *MockRaiderMockRecorder) CreateArray(name, level, devices interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateArray", reflect.TypeOf((*MockRaider)(nil).CreateArray), name, level, devices)
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/openyurtio/node-resource-manager/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListArrays(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("mdadm", "--detail", "--scan", "--verbose").Return(readTestData(t, "mdadm-scan.txt"))
+	arrays, err := NewNodeRaider(executor).ListArrays()
+	assert.Nil(t, err)
+	assert.Equal(t, []*model.RaidArray{
+		{Path: "/dev/md/qp_mnt_path1", Name: "qp_mnt_path1", Level: "raid0", Devices: []string{"/dev/vdb", "/dev/vdc"}},
+		{Path: "/dev/md127", Name: "data", Level: "raid1", Devices: []string{"/dev/vdd", "/dev/vde"}},
+	}, arrays)
+}
+
+func TestCreateArray(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("mdadm", "--create", "...")
+	raider := NewNodeRaider(executor)
+	_, err := raider.CreateArray("qp_mnt_path1", "raid1", []string{"/dev/vdb", "/dev/vdc"})
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"mdadm", "--create", "/dev/md/qp_mnt_path1", "--run", "--metadata=1.2", "--name=qp_mnt_path1",
+		"--level=raid1", "--raid-devices=2", "/dev/vdb", "/dev/vdc"}}, executor.Calls())
+
+	invalid := []struct {
+		name    string
+		level   string
+		devices []string
+	}{
+		{"qp_mnt_path1", "raid5", []string{"/dev/vdb", "/dev/vdc", "/dev/vdd"}},
+		{"qp_mnt_path1", "raid0", []string{"/dev/vdb"}},
+		{"qp_mnt_path1", "raid0", []string{"/dev/vdb", "/dev/../etc/passwd"}},
+		{"qp/mnt", "raid0", []string{"/dev/vdb", "/dev/vdc"}},
+		{"qp_mnt_path1_with_a_very_long_name", "raid0", []string{"/dev/vdb", "/dev/vdc"}},
+	}
+	for _, c := range invalid {
+		_, err := raider.CreateArray(c.name, c.level, c.devices)
+		assert.NotNil(t, err, "%v", c)
+	}
+	assert.Equal(t, 1, len(executor.Calls()))
+}
@@ -34,22 +34,28 @@ import (
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/record"
 	klog "k8s.io/klog/v2"
+	utilexec "k8s.io/utils/exec"
 )
 
 const (
 	// MetadataURL is metadata url
 	MetadataURL = "http://100.100.100.200/latest/meta-data/"
 
-	// NsenterCmd use to init resource
-	NsenterCmd = "/usr/bin/nsenter --mount=/proc/1/ns/mnt --ipc=/proc/1/ns/ipc --net=/proc/1/ns/net --uts=/proc/1/ns/uts "
-
 	// NodeResourceManager is resource manager
 	NodeResourceManager = "node-resource-manager"
+
+	// HostRootPath is the root filesystem of host, visible as nrm runs with hostPID
+	HostRootPath = "/proc/1/root"
+	// HostMountsFile is the mount table of host mount namespace
+	HostMountsFile = "/proc/1/mounts"
 )
 
 // ErrParse ...
 var ErrParse = errors.New("Cannot parse output of blkid")
 
+// NsenterArgs enters the namespaces of host to init resource
+var NsenterArgs = []string{"/usr/bin/nsenter", "--mount=/proc/1/ns/mnt", "--ipc=/proc/1/ns/ipc", "--net=/proc/1/ns/net", "--uts=/proc/1/ns/uts"}
+
 // GetMetaData get metadata from ecs meta-server
 func GetMetaData(resource string) (string, error) {
 	resp, err := http.Get(MetadataURL + resource)
@@ -64,13 +70,35 @@ func GetMetaData(resource string) (string, error) {
 	return string(body), nil
 }
 
-// Run run shell command
-func Run(cmd string) (string, error) {
-	out, err := exec.Command("sh", "-c", cmd).CombinedOutput()
-	if err != nil {
-		return "", fmt.Errorf("Failed to run cmd: " + cmd + ", with out: " + string(out) + ", with error: " + err.Error())
+// HostCommand returns the args run in host namespaces by nsenter
+func HostCommand(args ...string) []string {
+	return append(append([]string{}, NsenterArgs...), args...)
+}
+
+// commandBinary returns the binary name of args, nsenter prefix is skipped
+func commandBinary(args []string) string {
+	if len(args) > len(NsenterArgs) && args[0] == NsenterArgs[0] {
+		args = args[len(NsenterArgs):]
+	}
+	if len(args) == 0 {
+		return ""
 	}
-	return string(out), nil
+	return filepath.Base(args[0])
+}
+
+// exitCode returns exit code of command error, -1 if command is not started
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	if ee, ok := err.(utilexec.ExitError); ok {
+		return ee.ExitStatus()
+	}
+	return -1
 }
 
 // NodeFilter go through all configmap to find current node config
@@ -122,6 +150,12 @@ func ConvertRegion2Namespace(region string) string {
 	return fmt.Sprintf("namespace%s.0", regionIndex)
 }
 
+// ConvertDaxChardev2Region return the region of dax chardev, e.g. dax0.0 -> region0
+func ConvertDaxChardev2Region(chardev string) string {
+	regionIndex := strings.SplitN(strings.TrimPrefix(chardev, "dax"), ".", 2)[0]
+	return fmt.Sprintf("region%s", regionIndex)
+}
+
 // ConvertNamespace2LVMDevicePath ...
 func ConvertNamespace2LVMDevicePath(namespace string, regions *model.PmemRegions) string {
 	for _, region := range regions.Regions {
@@ -134,21 +168,21 @@ func ConvertNamespace2LVMDevicePath(namespace string, regions *model.PmemRegions
 	return ""
 }
 
-func checkFSType(devicePath string) (string, error) {
+func checkFSType(executor CommandExecutor, devicePath string) (string, error) {
 	// We use `file -bsL` to determine whether any filesystem type is detected.
 	// If a filesystem is detected (ie., the output is not "data", we use
 	// `blkid` to determine what the filesystem is. We use `blkid` as `file`
 	// has inconvenient output.
 	// We do *not* use `lsblk` as that requires udev to be up-to-date which
 	// is often not the case when a device is erased using `dd`.
-	output, err := exec.Command("file", "-bsL", devicePath).CombinedOutput()
+	output, _, err := executor.Command("file", "-bsL", devicePath)
 	if err != nil {
 		return "", err
 	}
-	if strings.TrimSpace(string(output)) == "data" {
+	if strings.TrimSpace(output) == "data" {
 		return "", nil
 	}
-	output, err = exec.Command("blkid", "-c", "/dev/null", "-o", "export", devicePath).CombinedOutput()
+	output, _, err = executor.Command("blkid", "-c", "/dev/null", "-o", "export", devicePath)
 	if err != nil {
 		return "", err
 	}
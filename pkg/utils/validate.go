@@ -0,0 +1,161 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	// maxLVMNameLength is the max length of volume group and logical volume name
+	maxLVMNameLength = 127
+	// maxLVMTagLength is the max length of lvm tag
+	maxLVMTagLength = 1024
+	// maxRaidNameLength is the max length of md array name kept in superblock
+	maxRaidNameLength = 32
+)
+
+var (
+	// lvmNameRegexp matches the characters lvm allows in volume group and logical volume name
+	lvmNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9+_.][a-zA-Z0-9+_.-]*$`)
+	// lvmTagRegexp matches the characters lvm allows in tag
+	lvmTagRegexp = regexp.MustCompile(`^[a-zA-Z0-9+_./=:][a-zA-Z0-9+_./=:-]*$`)
+	// devicePathRegexp matches device path under /dev
+	devicePathRegexp = regexp.MustCompile(`^/dev/[a-zA-Z0-9+_.:/-]+$`)
+	// mountPathRegexp matches absolute path without whitespace and shell characters
+	mountPathRegexp = regexp.MustCompile(`^/[a-zA-Z0-9+_.:@/-]+$`)
+	// fsTypeRegexp matches filesystem type, e.g. ext4, xfs
+	fsTypeRegexp = regexp.MustCompile(`^[a-z0-9]+$`)
+	// mountOptionRegexp matches one mount option, e.g. prjquota, size=1G
+	mountOptionRegexp = regexp.MustCompile(`^[a-zA-Z0-9+_.:=/][a-zA-Z0-9+_.:=/-]*$`)
+	// mkfsOptionRegexp matches one mkfs argument, e.g. -O, project,quota
+	mkfsOptionRegexp = regexp.MustCompile(`^[a-zA-Z0-9+_.:=,/-]+$`)
+	// regionRegexp matches pmem region name
+	regionRegexp = regexp.MustCompile(`^region[0-9]+$`)
+	// raidNameRegexp matches the characters allowed in md array name
+	raidNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_.][a-zA-Z0-9_.-]*$`)
+	// daxChardevRegexp matches pmem dax chardev name
+	daxChardevRegexp = regexp.MustCompile(`^dax[0-9]+\.[0-9]+$`)
+)
+
+// ValidateLVMName checks name of volume group or logical volume
+func ValidateLVMName(name string) error {
+	if len(name) > maxLVMNameLength {
+		return fmt.Errorf("lvm name %q is longer than %d", name, maxLVMNameLength)
+	}
+	if name == "." || name == ".." || !lvmNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid lvm name %q, only a-z A-Z 0-9 + _ . - are allowed and must not start with -", name)
+	}
+	return nil
+}
+
+// ValidateLVMTags checks tags of volume group or logical volume
+func ValidateLVMTags(tags []string) error {
+	for _, tag := range tags {
+		if len(tag) > maxLVMTagLength || !lvmTagRegexp.MatchString(tag) {
+			return fmt.Errorf("invalid lvm tag %q", tag)
+		}
+	}
+	return nil
+}
+
+// ValidateDevicePath checks device path is a clean path under /dev
+func ValidateDevicePath(devicePath string) error {
+	if !devicePathRegexp.MatchString(devicePath) || filepath.Clean(devicePath) != devicePath {
+		return fmt.Errorf("invalid device path %q, must be a clean path under /dev", devicePath)
+	}
+	return nil
+}
+
+// ValidateDevicePaths checks every device path
+func ValidateDevicePaths(devicePaths []string) error {
+	if len(devicePaths) == 0 {
+		return fmt.Errorf("no device specified")
+	}
+	for _, devicePath := range devicePaths {
+		if err := ValidateDevicePath(devicePath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateMountPath checks mount path is a clean absolute path other than /
+func ValidateMountPath(mountPath string) error {
+	if mountPath == "/" || !mountPathRegexp.MatchString(mountPath) || filepath.Clean(mountPath) != mountPath {
+		return fmt.Errorf("invalid mount path %q, must be a clean absolute path", mountPath)
+	}
+	return nil
+}
+
+// ValidateFsType checks filesystem type
+func ValidateFsType(fstype string) error {
+	if fstype != "" && !fsTypeRegexp.MatchString(fstype) {
+		return fmt.Errorf("invalid filesystem type %q", fstype)
+	}
+	return nil
+}
+
+// ValidateMountOptions checks comma separated mount options
+func ValidateMountOptions(options string) error {
+	if options == "" {
+		return nil
+	}
+	for _, option := range strings.Split(options, ",") {
+		if !mountOptionRegexp.MatchString(option) {
+			return fmt.Errorf("invalid mount option %q in %q", option, options)
+		}
+	}
+	return nil
+}
+
+// ValidateMkfsOptions checks arguments passed to mkfs
+func ValidateMkfsOptions(options []string) error {
+	for _, option := range options {
+		if !mkfsOptionRegexp.MatchString(option) {
+			return fmt.Errorf("invalid mkfs option %q", option)
+		}
+	}
+	return nil
+}
+
+// ValidateRegion checks pmem region name, e.g. region0
+func ValidateRegion(region string) error {
+	if !regionRegexp.MatchString(region) {
+		return fmt.Errorf("invalid pmem region %q", region)
+	}
+	return nil
+}
+
+// ValidateDaxChardev checks pmem dax chardev name, e.g. dax0.0
+func ValidateDaxChardev(chardev string) error {
+	if !daxChardevRegexp.MatchString(chardev) {
+		return fmt.Errorf("invalid dax chardev %q", chardev)
+	}
+	return nil
+}
+
+// ValidateRaidName checks md array name, which is kept in superblock with limited length
+func ValidateRaidName(name string) error {
+	if len(name) > maxRaidNameLength || !raidNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid md array name %q, only a-z A-Z 0-9 _ . - are allowed and at most %d characters", name, maxRaidNameLength)
+	}
+	return nil
+}